@@ -5,16 +5,46 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 	config "usergrowth/configs"
+	"usergrowth/internal/activity"
+	"usergrowth/internal/attribution"
+	"usergrowth/internal/badge"
+	"usergrowth/internal/bus"
+	"usergrowth/internal/campaign"
+	"usergrowth/internal/coupon"
+	"usergrowth/internal/event"
+	"usergrowth/internal/experiment"
+	"usergrowth/internal/export"
+	"usergrowth/internal/featureflag"
+	"usergrowth/internal/funnel"
+	"usergrowth/internal/leaderboard"
 	"usergrowth/internal/logs"
+	"usergrowth/internal/lottery"
+	"usergrowth/internal/notification"
 	"usergrowth/internal/observability"
+	"usergrowth/internal/outbox"
+	"usergrowth/internal/points"
+	"usergrowth/internal/property"
+	"usergrowth/internal/referral"
+	"usergrowth/internal/registration"
+	"usergrowth/internal/risk"
+	"usergrowth/internal/schema"
+	"usergrowth/internal/segment"
+	"usergrowth/internal/shortlink"
+	"usergrowth/internal/tier"
+	"usergrowth/internal/track"
 	"usergrowth/internal/user"
+	"usergrowth/internal/waitlist"
+	"usergrowth/internal/webhook"
+	"usergrowth/internal/winback"
 	"usergrowth/middleware"
 	"usergrowth/mysql"
 	"usergrowth/redis"
 
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gogf/gf/v2/os/gcron"
 )
 
 func main() {
@@ -34,6 +64,8 @@ func main() {
 			fmt.Println("not close:", err)
 		}
 	}(rdb)
+	// 集合、有序集合、Lua 等命令直接使用底层客户端
+	rawRedis := rdb.(*redis.MyRedis).Client
 
 	msq := mysql.NewDB(cfg.Config)
 
@@ -45,30 +77,315 @@ func main() {
 	defer shutdown()
 	s := g.Server()
 
+	// 用户仓库在同一事务中写入 outbox，需先建表
+	outboxRepo := outbox.NewOutboxRepository(msq.DB)
 	repo := user.NewUserRepository(msq.DB)
+	// 服务端事件写入后同步通知徽章等监听器
+	eventRepo := event.NewObserved(event.NewEventRepository(msq.DB))
 	s.SetServerRoot("./static")
 	s.SetErrorLogEnabled(false) // 关闭默认的错误日志记录
-	registerController := user.NewRegister(repo, userLogger)
-	loginController := user.NewLogin(rdb, repo, userLogger)
+	// 模块间的异步事件，订阅需在 Start 前完成
+	eventBus, err := bus.New(&cfg.Config.Bus, rawRedis, errorLogger)
+	if err != nil {
+		panic("event bus config error: " + err.Error())
+	}
 	errorManager := middleware.NewErrorManager(cfg.Config.App.LogPath, &cfg.Config.Middleware, errorLogger)
 	loggerManager := middleware.NewLoggerManager(cfg.Config.App.LogPath, &cfg.Config.Middleware)
-	jwtManager := middleware.NewJWTManager(rdb, userLogger, &cfg.Config.Middleware)
+	activityTracker := activity.NewTracker(rawRedis, cfg.Config.Activity.KeyTTL, errorLogger)
+	go activityTracker.SeedFirstSeen(redisCtx, repo)
+	jwtManager := middleware.NewJWTManager(rdb, userLogger, &cfg.Config.Middleware, activityTracker)
 	traceHandler := middleware.Trace
 	esController := logs.NewEsController(cfg.Config)
-	authController := user.NewAuthController()
 	panicController := user.NewPanicController()
+	adminManager := middleware.NewAdminManager(userLogger, &cfg.Config.Admin)
+	couponRepo := coupon.NewCouponRepository(msq.DB)
+	redeemController := coupon.NewRedeem(couponRepo, userLogger)
+	couponAdminController := coupon.NewAdmin(couponRepo, userLogger)
+	segmentRepo := segment.NewSegmentRepository(msq.DB)
+	// 自定义属性以 prop. 为前缀提供给分群与灰度规则
+	propertyService := property.NewService(property.NewPropertyRepository(msq.DB), &cfg.Config.Property, errorLogger)
+	if err := propertyService.WatchFile(cfg.Config.Property.File); err != nil {
+		fmt.Println("property definitions load error:", err)
+	}
+	propertyController := property.NewController(propertyService)
+	propertyAdminController := property.NewAdmin(propertyService, repo, userLogger)
+	attributionRepo := attribution.NewAttributionRepository(msq.DB)
+	attributionService := attribution.NewService(attributionRepo, cfg.Config.Attribution.CookieTTL, errorLogger)
+	// 分群与灰度规则可用的属性：用户表字段、注册来源渠道与自定义属性
+	profiler := segment.NewProfiler(eventRepo, segment.NewUserAttributes(repo), attributionService, propertyService)
+	materializer := segment.NewMaterializer(rawRedis, segmentRepo, repo, profiler, cfg.Config.Segment.BatchSize, errorLogger)
+	segmentAdminController := segment.NewAdmin(segmentRepo, profiler, materializer, userLogger)
+	pointsRepo := points.NewPointsRepository(msq.DB)
+	pointsService := points.NewService(pointsRepo, eventRepo, errorLogger)
+	tierService := tier.NewService(tier.NewTierRepository(msq.DB), pointsService, eventRepo, repo, cfg.Config.Tier.BatchSize, errorLogger)
+	if err := tierService.WatchFile(cfg.Config.Tier.File); err != nil {
+		fmt.Println("tier definitions load error:", err)
+	}
+	pointsService.OnChange(tierService.OnPointsChanged)
+	badgeService := badge.NewService(badge.NewBadgeRepository(msq.DB), pointsService, eventRepo, errorLogger)
+	if err := badgeService.WatchFile(cfg.Config.Badge.File); err != nil {
+		fmt.Println("badge definitions load error:", err)
+	}
+	eventRepo.OnCreated(badgeService.OnEvent)
+	pointsService.OnChange(badgeService.OnPointsChanged)
+	badgeController := badge.NewController(badgeService)
+	notificationService := notification.NewService(notification.NewNotificationRepository(msq.DB), rawRedis, materializer,
+		cfg.Config.Notification.DefaultTTL, cfg.Config.Notification.UnreadTTL, errorLogger)
+	notificationService.Start(redisCtx)
+	pointsService.OnChange(notificationService.OnPointsChanged)
+	notificationController := notification.NewController(notificationService, cfg.Config.Notification.Heartbeat)
+	notificationAdminController := notification.NewAdmin(notificationService, userLogger)
+	campaignLocation, err := time.LoadLocation(cfg.Config.Campaign.Timezone)
+	if err != nil {
+		fmt.Println("campaign timezone error:", err)
+		campaignLocation = time.Local
+	}
+	campaignRepo := campaign.NewCampaignRepository(msq.DB)
+	campaignService := campaign.NewService(campaignRepo, campaign.NewBudget(rawRedis), pointsService, materializer, campaignLocation, errorLogger)
+	pointsService.OnChange(campaignService.OnPointsChanged)
+	campaignController := campaign.NewController(campaignRepo, campaignService, userLogger)
+	campaignAdminController := campaign.NewAdmin(campaignRepo, campaignService, userLogger)
+	attributionAdminController := attribution.NewAdmin(attributionRepo, cfg.Config.Attribution.ActivationEvent, cfg.Config.Attribution.ActivationDays)
+	referralRepo := referral.NewReferralRepository(msq.DB)
+	riskRepo := risk.NewRiskRepository(msq.DB)
+	riskService := risk.NewService(riskRepo, rawRedis, referral.NewGraph(referralRepo), repo, pointsService, risk.Thresholds{
+		IPHourly:       cfg.Config.Risk.IPHourly,
+		SubnetDaily:    cfg.Config.Risk.SubnetDaily,
+		DeviceAccounts: cfg.Config.Risk.DeviceAccounts,
+		InviterDaily:   cfg.Config.Risk.InviterDaily,
+		FreshInviter:   cfg.Config.Risk.FreshInviter,
+	}, cfg.Config.Risk.ReviewScore, cfg.Config.Risk.DisposableDomains, errorLogger)
+	referralService := referral.NewService(referralRepo, riskService, eventRepo, cfg.Config.Referral.InviterPoints, cfg.Config.Referral.InviteePoints, errorLogger)
+	// 风控评估完成后按结果发放邀请奖励
+	riskService.OnAssessed(referralService.OnAssessed)
+	referralService.RetryRewards(eventBus)
+	referralController := referral.NewController(referralService, referralRepo)
+	shortlinkRepo := shortlink.NewShortlinkRepository(msq.DB)
+	shortlinkService := shortlink.NewService(shortlinkRepo, shortlink.NewRedisCounter(rawRedis, cfg.Config.ShortLink.QueueMax), referralService, shortlink.Options{
+		InviteURL:         cfg.Config.ShortLink.InviteURL,
+		AllowedHosts:      cfg.Config.ShortLink.AllowedHosts,
+		VisitorTTL:        cfg.Config.ShortLink.VisitorTTL,
+		AttributionWindow: cfg.Config.ShortLink.AttributionWindow,
+		FlushBatch:        cfg.Config.ShortLink.FlushBatch,
+	}, errorLogger)
+	waitlistRepo := waitlist.NewWaitlistRepository(msq.DB)
+	waitlistService := waitlist.NewService(waitlistRepo, &cfg.Config.Waitlist, errorLogger)
+	invitationRepo := registration.NewInvitationRepository(msq.DB)
+	invitations := registration.NewInvitations(invitationRepo, errorLogger)
+	// 注册模式随配置热更新；邀请凭证依次在管理员邀请与候补放行中查找，注册事件投递后标记已使用
+	registrationGate := registration.NewGate(cfg.Config, errorLogger)
+	cfg.OnReload(registrationGate.Reload)
+	registrationGate.AddRedeemer(invitations)
+	registrationGate.AddRedeemer(waitlistService)
+	// 注册成功后依次调用，邀请关系需先于风控评估建立
+	registerController := user.NewRegister(repo, eventRepo, registrationGate, []user.RegisterHook{
+		user.PublishRegistered(eventBus, errorLogger),
+		notificationService,
+		attributionService,
+		referralService,
+		riskService,
+		shortlinkService,
+	}, userLogger)
+	user.TopicRegistered.Subscribe(eventBus, "registration-invitation", invitations.OnRegister)
+	user.TopicRegistered.Subscribe(eventBus, "waitlist", waitlistService.OnRegister)
+	registrationController := registration.NewController(registrationGate)
+	invitationAdminController := registration.NewAdmin(invitationRepo, invitations, userLogger)
+	waitlistController := waitlist.NewController(waitlistService, userLogger)
+	waitlistAdminController := waitlist.NewAdmin(waitlistRepo, waitlistService, userLogger)
+	shortlinkController := shortlink.NewController(shortlinkRepo, shortlinkService, userLogger)
+	shortlinkRedirector := shortlink.NewRedirector(shortlinkService)
+	riskAdminController := risk.NewAdmin(riskRepo, riskService, userLogger)
+	lotteryRepo := lottery.NewLotteryRepository(msq.DB)
+	lotteryService := lottery.NewService(lotteryRepo, lottery.NewRedisInventory(rawRedis), pointsService, errorLogger)
+	lotteryController := lottery.NewController(lotteryRepo, lotteryService, userLogger)
+	lotteryAdminController := lottery.NewAdmin(lotteryRepo, lotteryService, userLogger)
+	webhookRepo := webhook.NewWebhookRepository(msq.DB)
+	webhookDispatcher := webhook.NewDispatcher(webhookRepo, outboxRepo, webhook.Options{
+		PollInterval: cfg.Config.Webhook.PollInterval,
+		Timeout:      cfg.Config.Webhook.Timeout,
+		BaseBackoff:  cfg.Config.Webhook.BaseBackoff,
+		MaxBackoff:   cfg.Config.Webhook.MaxBackoff,
+		MaxAttempts:  cfg.Config.Webhook.MaxAttempts,
+		BatchSize:    cfg.Config.Webhook.BatchSize,
+	}, errorLogger)
+	webhookDispatcher.Start(redisCtx)
+	webhookAdminController := webhook.NewAdmin(webhookRepo, userLogger)
+	userAdminController := user.NewAdmin(repo, rdb, userLogger)
+	loginController := user.NewLogin(rdb, repo, eventRepo, notificationService, userLogger)
+	pointsController := points.NewController(pointsService, pointsRepo)
+	pointsAdminController := points.NewAdmin(pointsService, userLogger)
+	tierController := tier.NewController(tierService)
+	authController := user.NewAuthController(tierService)
+	experimentRepo := experiment.NewExperimentRepository(msq.DB)
+	assignController := experiment.NewAssigner(experimentRepo, materializer, userLogger)
+	experimentAdminController := experiment.NewAdmin(experimentRepo, userLogger)
+	flagClient := featureflag.NewClient(profiler, errorLogger)
+	if cfg.Config.FeatureFlag.Source == "redis" {
+		flagClient.WatchRedis(redisCtx, rawRedis, cfg.Config.FeatureFlag.RedisKey, cfg.Config.FeatureFlag.PollInterval)
+	} else if err := flagClient.WatchFile(cfg.Config.FeatureFlag.File); err != nil {
+		fmt.Println("feature flag load error:", err)
+	}
+	flagController := featureflag.NewController(flagClient)
+	leaderboardRepo := leaderboard.NewLeaderboardRepository(msq.DB)
+	leaderboardService := leaderboard.NewService(leaderboardRepo, rawRedis, cfg.Config.Leaderboard.ArchiveTop, cfg.Config.Leaderboard.ArchiveTTL, errorLogger)
+	if err := leaderboardService.Reload(); err != nil {
+		fmt.Println("leaderboard load error:", err)
+	}
+	// 排行榜默认只按服务端事件计分，客户端上报的分值与时间不可信
+	eventRepo.OnCreated(leaderboardService.OnEvent)
+	leaderboardController := leaderboard.NewController(leaderboardService)
+	leaderboardAdminController := leaderboard.NewAdmin(leaderboardRepo, leaderboardService, userLogger)
+	trackSink, err := track.NewSink(cfg.Config, eventRepo)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	// 埋点事件同时驱动排行榜计分与徽章进度
+	trackPipeline := track.NewPipeline(track.NewMultiSink(trackSink, leaderboardService, badgeService), track.PipelineOptions{
+		QueueSize:     cfg.Config.Track.QueueSize,
+		Workers:       cfg.Config.Track.Workers,
+		BatchSize:     cfg.Config.Track.BatchSize,
+		FlushInterval: cfg.Config.Track.FlushInterval,
+		Overflow:      cfg.Config.Track.Overflow,
+		BlockTimeout:  cfg.Config.Track.BlockTimeout,
+	}, errorLogger)
+	defer func() {
+		if err := trackPipeline.Close(); err != nil {
+			fmt.Println("track pipeline close:", err)
+		}
+	}()
+	schemaRepo := schema.NewSchemaRepository(msq.DB)
+	schemaRegistry := schema.NewRegistry(schemaRepo, &cfg.Config.Schema, errorLogger)
+	if err := schemaRegistry.Reload(); err != nil {
+		fmt.Println("event schema load error:", err)
+	}
+	defer schemaRegistry.Flush(context.Background())
+	trackController := track.NewTrack(trackPipeline, &cfg.Config.Track, schemaRegistry, userLogger)
+	schemaAdminController := schema.NewAdmin(schemaRepo, schemaRegistry, userLogger)
+	funnelRepo := funnel.NewFunnelRepository(msq.DB)
+	funnelAdminController := funnel.NewAdmin(funnelRepo, funnel.NewService(funnelRepo, eventRepo, attributionService, rawRedis), userLogger)
+	retentionDays := activity.ParseRetentionDays(cfg.Config.Activity.RetentionDays)
+	snapshotRepo := activity.NewSnapshotRepository(msq.DB)
+	snapshotter := activity.NewSnapshotter(activityTracker, snapshotRepo, retentionDays, errorLogger)
+	winbackChannels := map[string]winback.Channel{"inapp": winback.NewInApp(notificationService)}
+	if cfg.Config.Winback.EmailGateway != "" {
+		winbackChannels["email"] = winback.NewEmailGateway(cfg.Config.Winback.EmailGateway, cfg.Config.Winback.GatewayTimeout)
+	}
+	winbackCohorts := make([]winback.Cohort, 0, len(cfg.Config.Winback.Cohorts))
+	for _, c := range cfg.Config.Winback.Cohorts {
+		winbackCohorts = append(winbackCohorts, winback.Cohort(c))
+	}
+	winbackRepo := winback.NewWinbackRepository(msq.DB)
+	winbackOptions := winback.Options{
+		ReturnWindow: cfg.Config.Winback.ReturnWindow,
+		CapCount:     cfg.Config.Winback.CapCount,
+		CapWindow:    cfg.Config.Winback.CapWindow,
+		BatchSize:    cfg.Config.Winback.BatchSize,
+	}
+	winbackJob, err := winback.NewJob(winbackRepo, repo, activityTracker, winbackChannels, winbackCohorts, winbackOptions, errorLogger)
+	if err != nil {
+		fmt.Println("winback cohorts error:", err)
+		winbackJob, _ = winback.NewJob(winbackRepo, repo, activityTracker, winbackChannels, nil, winbackOptions, errorLogger)
+	}
+	winbackAdminController := winback.NewAdmin(winbackRepo, winbackJob, userLogger)
+	metricsAdminController := activity.NewAdmin(activityTracker, snapshotRepo, retentionDays)
+	if _, err := gcron.AddSingleton(redisCtx, cfg.Config.Segment.Cron, materializer.RunAll, "segment-materialize"); err != nil {
+		fmt.Println("segment cron error:", err)
+	}
+	if _, err := gcron.AddSingleton(redisCtx, cfg.Config.Leaderboard.SeasonCron, leaderboardService.RunSeasons, "leaderboard-season"); err != nil {
+		fmt.Println("leaderboard cron error:", err)
+	}
+	if _, err := gcron.AddSingleton(redisCtx, cfg.Config.Tier.Cron, tierService.RunAll, "tier-evaluate"); err != nil {
+		fmt.Println("tier cron error:", err)
+	}
+	if _, err := gcron.AddSingleton(redisCtx, cfg.Config.Notification.CleanupCron, notificationService.Cleanup, "notification-cleanup"); err != nil {
+		fmt.Println("notification cron error:", err)
+	}
+	if _, err := gcron.AddSingleton(redisCtx, cfg.Config.Lottery.ReconcileCron, lotteryService.RunReconcile, "lottery-reconcile"); err != nil {
+		fmt.Println("lottery cron error:", err)
+	}
+	if _, err := gcron.AddSingleton(redisCtx, cfg.Config.ShortLink.FlushCron, shortlinkService.Flush, "shortlink-flush"); err != nil {
+		fmt.Println("short link cron error:", err)
+	}
+	if _, err := gcron.AddSingleton(redisCtx, cfg.Config.Winback.Cron, winbackJob.Run, "winback"); err != nil {
+		fmt.Println("winback cron error:", err)
+	}
+	if _, err := gcron.AddSingleton(redisCtx, cfg.Config.Activity.SnapshotCron, snapshotter.Run, "activity-snapshot"); err != nil {
+		fmt.Println("activity cron error:", err)
+	}
+	if _, err := gcron.AddSingleton(redisCtx, cfg.Config.Schema.Cron, schemaRegistry.Run, "event-schema"); err != nil {
+		fmt.Println("event schema cron error:", err)
+	}
+	if exporter, err := export.NewFromConfig(msq.DB, &cfg.Config.Export, errorLogger); err != nil {
+		fmt.Println("export config error:", err)
+	} else if _, err := gcron.AddSingleton(redisCtx, cfg.Config.Export.Cron, exporter.Run, "export"); err != nil {
+		fmt.Println("export cron error:", err)
+	}
+
+	if err := eventBus.Start(redisCtx); err != nil {
+		fmt.Println("event bus start error:", err)
+	}
+	defer func() {
+		if err := eventBus.Close(); err != nil {
+			fmt.Println("event bus close:", err)
+		}
+	}()
 
 	s.Use(traceHandler, errorManager.ErrorHandler, loggerManager.AccessHandler)
+	// 静态页面加载时记录来源触点
+	s.BindHookHandler("/*", ghttp.HookBeforeServe, attributionService.Capture)
 
 	s.Group("/", func(group *ghttp.RouterGroup) {
 		group.Bind(registerController)
 		group.Bind(loginController)
 		group.Bind(panicController)
+		group.Bind(shortlinkRedirector)
+		group.Bind(waitlistController)
+		group.Bind(registrationController)
 	})
 	s.Group("/", func(group *ghttp.RouterGroup) {
 		group.Middleware(jwtManager.JWTHandler)
 		group.Bind(esController)
 		group.Bind(authController)
+		group.Bind(redeemController)
+		group.Bind(assignController)
+		group.Bind(flagController)
+		group.Bind(leaderboardController)
+		group.Bind(pointsController)
+		group.Bind(tierController)
+		group.Bind(notificationController)
+		group.Bind(campaignController)
+		group.Bind(referralController)
+		group.Bind(lotteryController)
+		group.Bind(shortlinkController)
+		group.Bind(badgeController)
+		group.Bind(propertyController)
+	})
+	s.Group("/", func(group *ghttp.RouterGroup) {
+		group.Middleware(jwtManager.OptionalJWTHandler)
+		group.Bind(trackController)
+	})
+	s.Group("/", func(group *ghttp.RouterGroup) {
+		group.Middleware(jwtManager.JWTHandler, adminManager.AdminHandler)
+		group.Bind(couponAdminController)
+		group.Bind(segmentAdminController)
+		group.Bind(experimentAdminController)
+		group.Bind(funnelAdminController)
+		group.Bind(metricsAdminController)
+		group.Bind(leaderboardAdminController)
+		group.Bind(pointsAdminController)
+		group.Bind(notificationAdminController)
+		group.Bind(campaignAdminController)
+		group.Bind(riskAdminController)
+		group.Bind(attributionAdminController)
+		group.Bind(lotteryAdminController)
+		group.Bind(webhookAdminController)
+		group.Bind(userAdminController)
+		group.Bind(winbackAdminController)
+		group.Bind(waitlistAdminController)
+		group.Bind(invitationAdminController)
+		group.Bind(schemaAdminController)
+		group.Bind(propertyAdminController)
 	})
 	port, err := strconv.Atoi(cfg.Config.App.Port)
 	if err != nil {
@@ -6,6 +6,7 @@ import (
 	"os"
 	"reflect"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gogf/gf/v2/frame/g"
@@ -14,8 +15,10 @@ import (
 )
 
 type ConfigManager struct {
-	Config *Config
-	cfg    *gcfg.Config
+	Config  *Config
+	cfg     *gcfg.Config
+	mu      sync.Mutex
+	reloads []func(*Config)
 }
 
 type Config struct {
@@ -26,6 +29,29 @@ type Config struct {
 	JWT           JWTConfig           `yaml:"jwt"`
 	Middleware    MiddlewareConfig    `yaml:"middleware"`
 	Tracing       TracingConfig       `yaml:"tracing"`
+	Admin         AdminConfig         `yaml:"admin"`
+	Segment       SegmentConfig       `yaml:"segment"`
+	FeatureFlag   FeatureFlagConfig   `yaml:"featureFlag"`
+	Track         TrackConfig         `yaml:"track"`
+	Activity      ActivityConfig      `yaml:"activity"`
+	Leaderboard   LeaderboardConfig   `yaml:"leaderboard"`
+	Tier          TierConfig          `yaml:"tier"`
+	Notification  NotificationConfig  `yaml:"notification"`
+	Campaign      CampaignConfig      `yaml:"campaign"`
+	Referral      ReferralConfig      `yaml:"referral"`
+	Risk          RiskConfig          `yaml:"risk"`
+	Attribution   AttributionConfig   `yaml:"attribution"`
+	Lottery       LotteryConfig       `yaml:"lottery"`
+	Webhook       WebhookConfig       `yaml:"webhook"`
+	Winback       WinbackConfig       `yaml:"winback"`
+	ShortLink     ShortLinkConfig     `yaml:"shortLink"`
+	Badge         BadgeConfig         `yaml:"badge"`
+	Waitlist      WaitlistConfig      `yaml:"waitlist"`
+	Registration  RegistrationConfig  `yaml:"registration"`
+	Schema        SchemaConfig        `yaml:"schema"`
+	Export        ExportConfig        `yaml:"export"`
+	Bus           BusConfig           `yaml:"bus"`
+	Property      PropertyConfig      `yaml:"property"`
 }
 
 type MiddlewareConfig struct {
@@ -74,6 +100,145 @@ type TracingConfig struct {
 	ServiceName string `yaml:"serviceName" default:"gf-growth"`
 }
 
+type AdminConfig struct {
+	UserIDs []string `yaml:"userIds"`
+}
+
+type SegmentConfig struct {
+	Cron      string `yaml:"cron" default:"0 */10 * * * *"`
+	BatchSize int    `yaml:"batchSize" default:"500"`
+}
+
+type FeatureFlagConfig struct {
+	Source       string        `yaml:"source" default:"file"` // file 或 redis
+	File         string        `yaml:"file" default:"configs/flags.yaml"`
+	RedisKey     string        `yaml:"redisKey" default:"featureflags"`
+	PollInterval time.Duration `yaml:"pollInterval" default:"10s"`
+}
+
+type TrackConfig struct {
+	Sink             string        `yaml:"sink" default:"mysql"` // mysql、file 或 elasticsearch
+	FilePath         string        `yaml:"filePath" default:"./logs/events.ndjson"`
+	EsIndex          string        `yaml:"esIndex" default:"usergrowth-events"`
+	QueueSize        int           `yaml:"queueSize" default:"10000"`
+	Workers          int           `yaml:"workers" default:"2"`
+	BatchSize        int           `yaml:"batchSize" default:"200"`
+	FlushInterval    time.Duration `yaml:"flushInterval" default:"1s"`
+	Overflow         string        `yaml:"overflow" default:"reject"` // block、drop 或 reject
+	BlockTimeout     time.Duration `yaml:"blockTimeout" default:"100ms"`
+	MaxBatchEvents   int           `yaml:"maxBatchEvents" default:"100"`
+	MaxPropertyBytes int           `yaml:"maxPropertyBytes" default:"8192"`
+	MaxEventAge      time.Duration `yaml:"maxEventAge" default:"168h"`
+}
+
+type ActivityConfig struct {
+	SnapshotCron  string        `yaml:"snapshotCron" default:"0 10 0 * * *"`
+	RetentionDays string        `yaml:"retentionDays" default:"1,3,7,14,30"` // 逗号分隔的留存天数
+	KeyTTL        time.Duration `yaml:"keyTTL" default:"9600h"`
+}
+
+type LeaderboardConfig struct {
+	SeasonCron string        `yaml:"seasonCron" default:"0 * * * * *"` // 同时负责刷新排行榜定义
+	ArchiveTop int           `yaml:"archiveTop" default:"1000"`
+	ArchiveTTL time.Duration `yaml:"archiveTTL" default:"168h"` // 赛季结束后旧有序集合的保留时间
+}
+
+type TierConfig struct {
+	File      string `yaml:"file" default:"configs/tiers.yaml"`
+	Cron      string `yaml:"cron" default:"0 0 4 * * *"` // 全量重新评估，活跃度下降导致的降级在此生效
+	BatchSize int    `yaml:"batchSize" default:"500"`
+}
+
+type NotificationConfig struct {
+	DefaultTTL  time.Duration `yaml:"defaultTTL" default:"720h"`
+	UnreadTTL   time.Duration `yaml:"unreadTTL" default:"10m"` // 未读数缓存时间，兼顾消息过期后的修正
+	Heartbeat   time.Duration `yaml:"heartbeat" default:"25s"` // SSE 心跳间隔
+	CleanupCron string        `yaml:"cleanupCron" default:"0 30 2 * * *"`
+}
+
+type CampaignConfig struct {
+	Timezone string `yaml:"timezone" default:"Asia/Shanghai"` // 活动起止时间的解析与展示时区
+}
+
+type ReferralConfig struct {
+	InviterPoints int64 `yaml:"inviterPoints" default:"100"`
+	InviteePoints int64 `yaml:"inviteePoints" default:"50"`
+}
+
+type RiskConfig struct {
+	ReviewScore       int           `yaml:"reviewScore" default:"60"` // 风险分达到该值的用户相关奖励冻结待审核
+	IPHourly          int64         `yaml:"ipHourly" default:"3"`
+	SubnetDaily       int64         `yaml:"subnetDaily" default:"20"`
+	DeviceAccounts    int64         `yaml:"deviceAccounts" default:"2"`
+	InviterDaily      int64         `yaml:"inviterDaily" default:"10"`
+	FreshInviter      time.Duration `yaml:"freshInviter" default:"24h"`
+	DisposableDomains []string      `yaml:"disposableDomains"` // 追加到内置的临时邮箱域名列表
+}
+
+type AttributionConfig struct {
+	CookieTTL       time.Duration `yaml:"cookieTTL" default:"2160h"`
+	ActivationEvent string        `yaml:"activationEvent" default:"login"` // 注册后在窗口期内发生该事件视为激活
+	ActivationDays  int           `yaml:"activationDays" default:"7"`
+}
+
+type LotteryConfig struct {
+	ReconcileCron string `yaml:"reconcileCron" default:"0 */5 * * * *"` // Redis 库存与 MySQL 抽奖记录对账
+}
+
+type WebhookConfig struct {
+	PollInterval time.Duration `yaml:"pollInterval" default:"1s"`
+	Timeout      time.Duration `yaml:"timeout" default:"5s"`
+	BaseBackoff  time.Duration `yaml:"baseBackoff" default:"10s"` // 第 n 次失败后等待 baseBackoff * 2^(n-1)
+	MaxBackoff   time.Duration `yaml:"maxBackoff" default:"1h"`
+	MaxAttempts  int           `yaml:"maxAttempts" default:"8"` // 超过后进入死信表
+	BatchSize    int           `yaml:"batchSize" default:"100"`
+}
+
+type WinbackConfig struct {
+	Cron           string          `yaml:"cron" default:"0 0 10 * * *"`
+	ReturnWindow   time.Duration   `yaml:"returnWindow" default:"168h"` // 发送后该时长内回访计为召回成功
+	CapCount       int             `yaml:"capCount" default:"1"`        // capWindow 内每个用户最多收到的召回消息数
+	CapWindow      time.Duration   `yaml:"capWindow" default:"168h"`
+	BatchSize      int             `yaml:"batchSize" default:"500"`
+	EmailGateway   string          `yaml:"emailGateway"` // 邮件发送网关地址，为空时不启用 email 渠道
+	GatewayTimeout time.Duration   `yaml:"gatewayTimeout" default:"5s"`
+	Cohorts        []WinbackCohort `yaml:"cohorts"`
+}
+
+// WinbackCohort 的模板可使用 {{.Username}}、{{.Days}}、{{.Cohort}}
+type WinbackCohort struct {
+	Name         string `yaml:"name"`
+	InactiveDays int    `yaml:"inactiveDays"`
+	Channel      string `yaml:"channel"` // inapp，或配置了 emailGateway 时的 email
+	Title        string `yaml:"title"`
+	Body         string `yaml:"body"`
+}
+
+type ShortLinkConfig struct {
+	InviteURL         string        `yaml:"inviteURL" default:"/register.html?invite={code}"` // 未指定目标地址时的默认落地页
+	AllowedHosts      []string      `yaml:"allowedHosts"`                                     // 允许跳转的外部域名，为空时只允许站内路径
+	VisitorTTL        time.Duration `yaml:"visitorTTL" default:"720h"`                        // 独立访客去重窗口
+	AttributionWindow time.Duration `yaml:"attributionWindow" default:"720h"`                 // 点击后在该时长内注册计为转化
+	FlushCron         string        `yaml:"flushCron" default:"*/30 * * * * *"`
+	FlushBatch        int           `yaml:"flushBatch" default:"1000"`
+	QueueMax          int           `yaml:"queueMax" default:"100000"` // Redis 中待写点击明细的上限
+}
+
+type BadgeConfig struct {
+	File string `yaml:"file" default:"configs/badges.yaml"` // 徽章规则定义，修改后热更新
+}
+
+type WaitlistConfig struct {
+	Enabled       bool          `yaml:"enabled"`                    // 已废弃，registration.mode 为 open 时等同于 invite
+	ReferralBoost int64         `yaml:"referralBoost" default:"10"` // 每成功推荐一人前进的名次
+	InviteTTL     time.Duration `yaml:"inviteTTL" default:"168h"`   // 放行凭证有效期，凭证在 invite 模式下可用于注册
+}
+
+type RegistrationConfig struct {
+	Mode           string   `yaml:"mode" default:"open"` // open、invite、domain 或 closed，修改后即时生效
+	AllowedDomains []string `yaml:"allowedDomains"`      // domain 模式下允许的邮箱域名，含其子域名
+}
+
 func NewConfigManager() *ConfigManager {
 	return &ConfigManager{
 		Config: &Config{},
@@ -173,9 +338,23 @@ func (c *ConfigManager) StartWatcher(path string) {
 	if adapter, ok := c.cfg.GetAdapter().(gcfg.WatcherAdapter); ok {
 		adapter.AddWatcher(path, func(ctx context.Context) {
 			c.LoadConfigWithReflex(path)
+			c.mu.Lock()
+			reloads := c.reloads
+			c.mu.Unlock()
+			for _, fn := range reloads {
+				fn(c.Config)
+			}
 		})
 	}
 }
+
+// OnReload 注册配置热更新后的回调，回调与重新加载在同一 goroutine 中执行，
+// 需要在请求中读取热更新配置的模块应在回调中复制一份快照，而不是直接读取 Config
+func (c *ConfigManager) OnReload(fn func(*Config)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reloads = append(c.reloads, fn)
+}
 func (c *ConfigManager) PrintConfig() {
 	c.ConfigReflexIterator(reflect.ValueOf(c.Config).Elem())
 	fmt.Println("App Name:", c.Config.App.Name)
@@ -196,3 +375,48 @@ func (c *ConfigManager) PrintConfig() {
 	fmt.Println("Tracing Path:", c.Config.Tracing.Path)
 	fmt.Println("Tracing ServiceName:", c.Config.Tracing.ServiceName)
 }
+
+type SchemaConfig struct {
+	OnUnknown string `yaml:"onUnknown" default:"tag"`       // 未注册 schema 的事件：tag 或 reject，修改后即时生效
+	OnInvalid string `yaml:"onInvalid" default:"tag"`       // 属性不符合 schema 的事件：tag 或 reject
+	Cron      string `yaml:"cron" default:"*/30 * * * * *"` // 刷新 schema 并写入统计
+}
+
+type ExportConfig struct {
+	Cron        string         `yaml:"cron" default:"0 30 1 * * *"`
+	Target      string         `yaml:"target" default:"local"` // local 或 s3
+	Dir         string         `yaml:"dir" default:"./exports"`
+	Formats     string         `yaml:"formats" default:"csv,parquet"`   // 逗号分隔，csv、parquet
+	Datasets    string         `yaml:"datasets" default:"events,users"` // 逗号分隔，events、users
+	PartRows    int            `yaml:"partRows" default:"50000"`
+	CatchUpDays int            `yaml:"catchUpDays" default:"3"` // 定时任务回看的天数
+	S3          ExportS3Config `yaml:"s3"`
+}
+
+type ExportS3Config struct {
+	Endpoint  string `yaml:"endpoint"` // host:port，不含协议
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	Prefix    string `yaml:"prefix"`
+	AccessKey string `yaml:"accessKey"`
+	SecretKey string `yaml:"secretKey"`
+	UseSSL    bool   `yaml:"useSSL"`
+}
+
+type BusConfig struct {
+	Backend     string        `yaml:"backend" default:"redis"` // redis 或 memory，memory 仅在单个进程内投递
+	MaxAttempts int           `yaml:"maxAttempts" default:"5"` // 超过后转入死信
+	RetryDelay  time.Duration `yaml:"retryDelay" default:"30s"`
+	Batch       int           `yaml:"batch" default:"16"`
+	Block       time.Duration `yaml:"block" default:"2s"`
+	MaxLen      int64         `yaml:"maxLen" default:"100000"` // stream 保留的近似长度
+	Consumer    string        `yaml:"consumer"`                // 缺省为 主机名-进程号，多实例部署时需各不相同
+	QueueSize   int           `yaml:"queueSize" default:"1024"`
+}
+
+type PropertyConfig struct {
+	File            string `yaml:"file" default:"configs/properties.yaml"` // 属性定义，修改后热更新
+	MaxStringLength int    `yaml:"maxStringLength" default:"255"`          // 字符串与列表元素的最大字符数，不超过 255
+	MaxListItems    int    `yaml:"maxListItems" default:"100"`
+	MaxOps          int    `yaml:"maxOps" default:"20"` // 单次请求最多的修改数
+}
@@ -0,0 +1,154 @@
+package activity
+
+import (
+	"context"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// 单次查询允许的最大日期跨度
+const maxQueryDays = 93
+
+type ActiveReq struct {
+	g.Meta `path:"/api/admin/metrics/active" method:"get"`
+	Date   string `p:"date" v:"date#日期格式应为YYYY-MM-DD"` // 为空时取当天
+}
+
+type ActiveRes struct {
+}
+
+type RetentionReq struct {
+	g.Meta `path:"/api/admin/metrics/retention" method:"get"`
+	Start  string `p:"start" v:"required|date#开始日期不能为空|开始日期格式应为YYYY-MM-DD"`
+	End    string `p:"end" v:"required|date#结束日期不能为空|结束日期格式应为YYYY-MM-DD"`
+	Days   string `p:"days"` // 逗号分隔，为空时使用配置
+}
+
+type RetentionRes struct {
+}
+
+type HistoryReq struct {
+	g.Meta `path:"/api/admin/metrics/history" method:"get"`
+	Start  string `p:"start" v:"required|date#开始日期不能为空|开始日期格式应为YYYY-MM-DD"`
+	End    string `p:"end" v:"required|date#结束日期不能为空|结束日期格式应为YYYY-MM-DD"`
+}
+
+type HistoryRes struct {
+}
+
+type Admin struct {
+	tracker       *Tracker
+	repo          SnapshotRepository
+	retentionDays []int
+}
+
+func NewAdmin(tracker *Tracker, repo SnapshotRepository, retentionDays []int) *Admin {
+	return &Admin{
+		tracker:       tracker,
+		repo:          repo,
+		retentionDays: retentionDays,
+	}
+}
+
+// parseRange 解析包含两端的日期区间
+func parseRange(startRaw, endRaw string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation(time.DateOnly, startRaw, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, gerror.NewCode(gcode.CodeValidationFailed, "开始日期格式应为YYYY-MM-DD")
+	}
+	end, err := time.ParseInLocation(time.DateOnly, endRaw, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, gerror.NewCode(gcode.CodeValidationFailed, "结束日期格式应为YYYY-MM-DD")
+	}
+	if end.Before(start) || end.Sub(start) >= maxQueryDays*24*time.Hour {
+		return time.Time{}, time.Time{}, gerror.NewCode(gcode.CodeValidationFailed, "日期区间不合法")
+	}
+	return start, end, nil
+}
+
+func (params *Admin) Active(ctx context.Context, req *ActiveReq) (res *ActiveRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	day := startOfDay(time.Now())
+	if req.Date != "" {
+		if day, err = time.ParseInLocation(time.DateOnly, req.Date, time.Local); err != nil {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "日期格式应为YYYY-MM-DD")
+		}
+	}
+	stats, err := params.tracker.Active(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    stats,
+	})
+	return nil, nil
+}
+
+// Retention 从 Redis 实时计算区间内每天新增用户的留存表
+func (params *Admin) Retention(ctx context.Context, req *RetentionReq) (res *RetentionRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	start, end, err := parseRange(req.Start, req.End)
+	if err != nil {
+		return nil, err
+	}
+	days := params.retentionDays
+	if req.Days != "" {
+		days = ParseRetentionDays(req.Days)
+	}
+	if len(days) == 0 {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "留存天数不合法")
+	}
+
+	now := startOfDay(time.Now())
+	cohorts := make([]*Cohort, 0)
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		cohort, err := params.tracker.Retention(ctx, day, days, now)
+		if err != nil {
+			return nil, err
+		}
+		cohorts = append(cohorts, cohort)
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data": g.Map{
+			"days":    days,
+			"cohorts": cohorts,
+		},
+	})
+	return nil, nil
+}
+
+// History 返回定时任务写入 MySQL 的快照
+func (params *Admin) History(ctx context.Context, req *HistoryReq) (res *HistoryRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	start, end, err := parseRange(req.Start, req.End)
+	if err != nil {
+		return nil, err
+	}
+	daily, err := params.repo.ListDaily(start.Format(time.DateOnly), end.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	retention, err := params.repo.ListRetention(start.Format(time.DateOnly), end.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data": g.Map{
+			"daily":     daily,
+			"retention": retention,
+		},
+	})
+	return nil, nil
+}
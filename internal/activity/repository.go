@@ -0,0 +1,68 @@
+package activity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DailySnapshot struct {
+	Date      string    `gorm:"type:char(10);primaryKey" json:"date"`
+	DAU       int64     `gorm:"not null" json:"dau"`
+	WAU       int64     `gorm:"not null" json:"wau"`
+	MAU       int64     `gorm:"not null" json:"mau"`
+	NewUsers  int64     `gorm:"not null" json:"new_users"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RetentionSnapshot struct {
+	CohortDate string    `gorm:"type:char(10);primaryKey" json:"cohort_date"`
+	Day        int       `gorm:"primaryKey" json:"day"`
+	CohortSize int64     `gorm:"not null" json:"cohort_size"`
+	Retained   int64     `gorm:"not null" json:"retained"`
+	Rate       float64   `gorm:"not null" json:"rate"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type snapshotRepository struct {
+	db *gorm.DB
+}
+
+type SnapshotRepository interface {
+	SaveDaily(snapshot *DailySnapshot) error
+	SaveRetention(snapshots []RetentionSnapshot) error
+	ListDaily(start, end string) ([]DailySnapshot, error)
+	ListRetention(start, end string) ([]RetentionSnapshot, error)
+}
+
+func NewSnapshotRepository(db *gorm.DB) SnapshotRepository {
+	if err := db.AutoMigrate(&DailySnapshot{}, &RetentionSnapshot{}); err != nil {
+		panic("failed to migrate activity snapshot tables")
+	}
+	return &snapshotRepository{db: db}
+}
+
+// SaveDaily 重跑同一天时覆盖旧快照
+func (repo *snapshotRepository) SaveDaily(snapshot *DailySnapshot) error {
+	return repo.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(snapshot).Error
+}
+
+func (repo *snapshotRepository) SaveRetention(snapshots []RetentionSnapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	return repo.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&snapshots).Error
+}
+
+func (repo *snapshotRepository) ListDaily(start, end string) ([]DailySnapshot, error) {
+	var snapshots []DailySnapshot
+	err := repo.db.Where("date BETWEEN ? AND ?", start, end).Order("date").Find(&snapshots).Error
+	return snapshots, err
+}
+
+func (repo *snapshotRepository) ListRetention(start, end string) ([]RetentionSnapshot, error) {
+	var snapshots []RetentionSnapshot
+	err := repo.db.Where("cohort_date BETWEEN ? AND ?", start, end).Order("cohort_date, day").Find(&snapshots).Error
+	return snapshots, err
+}
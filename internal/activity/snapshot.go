@@ -0,0 +1,96 @@
+package activity
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+// ParseRetentionDays 解析 "1,7,30" 形式的配置，忽略非法值并去重排序
+func ParseRetentionDays(raw string) []int {
+	seen := make(map[int]bool)
+	days := make([]int, 0)
+	for _, part := range strings.Split(raw, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n <= 0 || seen[n] {
+			continue
+		}
+		seen[n] = true
+		days = append(days, n)
+	}
+	sort.Ints(days)
+	return days
+}
+
+// Snapshotter 每天把前一天的活跃指标以及刚满 N 天的留存写入 MySQL，Redis key 过期后仍可查询历史
+type Snapshotter struct {
+	tracker       *Tracker
+	repo          SnapshotRepository
+	retentionDays []int
+	logger        logs.Logger
+}
+
+func NewSnapshotter(tracker *Tracker, repo SnapshotRepository, retentionDays []int, logger logs.Logger) *Snapshotter {
+	return &Snapshotter{
+		tracker:       tracker,
+		repo:          repo,
+		retentionDays: retentionDays,
+		logger:        logger,
+	}
+}
+
+// Run 作为定时任务执行
+func (s *Snapshotter) Run(ctx context.Context) {
+	yesterday := startOfDay(time.Now()).AddDate(0, 0, -1)
+	if err := s.Snapshot(ctx, yesterday); err != nil {
+		s.logger.Error(ctx, "activity snapshot failed:", yesterday.Format(time.DateOnly), err.Error())
+	}
+}
+
+// Snapshot 记录 day 的活跃指标，并补齐第 N 天恰好是 day 的各个 cohort 的留存
+func (s *Snapshotter) Snapshot(ctx context.Context, day time.Time) error {
+	ctx, span := gtrace.NewSpan(ctx, "Activity.Snapshot")
+	defer span.End()
+
+	stats, err := s.tracker.Active(ctx, day)
+	if err != nil {
+		return err
+	}
+	if err = s.repo.SaveDaily(&DailySnapshot{
+		Date:     stats.Date,
+		DAU:      stats.DAU,
+		WAU:      stats.WAU,
+		MAU:      stats.MAU,
+		NewUsers: stats.NewUsers,
+	}); err != nil {
+		return err
+	}
+
+	snapshots := make([]RetentionSnapshot, 0, len(s.retentionDays))
+	for _, n := range s.retentionDays {
+		cohort, err := s.tracker.Retention(ctx, day.AddDate(0, 0, -n), []int{n}, day)
+		if err != nil {
+			return err
+		}
+		for _, cell := range cohort.Cells {
+			snapshots = append(snapshots, RetentionSnapshot{
+				CohortDate: cohort.Date,
+				Day:        cell.Day,
+				CohortSize: cohort.Size,
+				Retained:   cell.Retained,
+				Rate:       cell.Rate,
+			})
+		}
+	}
+	return s.repo.SaveRetention(snapshots)
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
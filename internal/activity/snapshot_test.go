@@ -0,0 +1,79 @@
+package activity
+
+import (
+	"context"
+	"testing"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/user"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetentionDays(t *testing.T) {
+	assert.Equal(t, []int{1, 7, 30}, ParseRetentionDays("30, 7,1"))
+	assert.Equal(t, []int{1, 3}, ParseRetentionDays("1,x,3,3,-2,0"))
+	assert.Empty(t, ParseRetentionDays(""))
+}
+
+func TestKeysUseLocalDay(t *testing.T) {
+	day := time.Date(2026, 5, 1, 23, 59, 0, 0, time.Local)
+	assert.Equal(t, "activity:dau:20260501", activeHLLKey(day))
+	assert.Equal(t, "activity:active:20260501", activeBitmapKey(day))
+	assert.Equal(t, "activity:new:20260501", newBitmapKey(day))
+	assert.Equal(t, time.Date(2026, 5, 1, 0, 0, 0, 0, time.Local), startOfDay(day))
+}
+
+type fakeUsers struct {
+	user.UserRepository
+	users []user.Users
+}
+
+func (f *fakeUsers) ListUsers(afterID uint, limit int) ([]user.Users, error) {
+	var out []user.Users
+	for _, u := range f.users {
+		if u.UserID > afterID && len(out) < limit {
+			out = append(out, u)
+		}
+	}
+	return out, nil
+}
+
+func TestMarkActive(t *testing.T) {
+	mr := miniredis.RunT(t)
+	tracker := NewTracker(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}), 48*time.Hour, logs.Nop())
+	ctx := context.Background()
+	day := time.Date(2026, 5, 2, 10, 0, 0, 0, time.Local)
+
+	// 老用户按注册日期回填，上线当天活跃不计为新增
+	tracker.SeedFirstSeen(ctx, &fakeUsers{users: []user.Users{{UserID: 1, CreatedAt: day.AddDate(0, -1, 0)}}})
+	assert.NoError(t, tracker.markActive(ctx, 1, day))
+	// 新用户首次活跃计为新增，重复标记不重复计数
+	assert.NoError(t, tracker.markActive(ctx, 2, day))
+	assert.NoError(t, tracker.markActive(ctx, 2, day.Add(time.Hour)))
+
+	stats, err := tracker.Active(ctx, day)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(2), stats.DAU)
+	assert.Equal(t, int64(1), stats.NewUsers)
+	assert.Equal(t, 48*time.Hour, mr.TTL(activeBitmapKey(day)))
+	assert.Equal(t, 48*time.Hour, mr.TTL(newBitmapKey(day)))
+
+	// 第二天活跃不再计为新增
+	next := day.AddDate(0, 0, 1)
+	assert.NoError(t, tracker.markActive(ctx, 2, next))
+	stats, _ = tracker.Active(ctx, next)
+	assert.Equal(t, int64(0), stats.NewUsers)
+	cohort, err := tracker.Retention(ctx, day, []int{1}, next)
+	if assert.NoError(t, err) && assert.Len(t, cohort.Cells, 1) {
+		assert.Equal(t, int64(1), cohort.Cells[0].Retained)
+	}
+
+	// 回填只执行一次
+	tracker.SeedFirstSeen(ctx, &fakeUsers{users: []user.Users{{UserID: 3, CreatedAt: day}}})
+	assert.Empty(t, mr.HGet(firstSeenKey, "3"))
+}
@@ -0,0 +1,240 @@
+package activity
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/user"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const dayLayout = "20060102"
+
+const (
+	firstSeenKey = "activity:first_seen"
+	// seededKey 标记 first_seen 已按注册时间回填
+	seededKey = "activity:first_seen:seeded"
+	// 回填 first_seen 时每批读取的用户数
+	seedBatch = 1000
+)
+
+// markScript 原子地写入活跃 bitmap、HLL、首次活跃日与新增 bitmap；当天已标记过时直接返回 0。
+// 首次活跃日等于当天即计入新增，重复执行结果不变
+var markScript = goredis.NewScript(`
+if redis.call('SETBIT', KEYS[1], ARGV[1], 1) == 1 then
+	return 0
+end
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+redis.call('PFADD', KEYS[2], ARGV[1])
+redis.call('EXPIRE', KEYS[2], ARGV[2])
+local first = redis.call('HGET', KEYS[3], ARGV[1])
+if not first then
+	redis.call('HSET', KEYS[3], ARGV[1], ARGV[3])
+	first = ARGV[3]
+end
+if first == ARGV[3] then
+	redis.call('SETBIT', KEYS[4], ARGV[1], 1)
+	redis.call('EXPIRE', KEYS[4], ARGV[2])
+end
+return 1
+`)
+
+func activeHLLKey(day time.Time) string {
+	return "activity:dau:" + day.Format(dayLayout)
+}
+
+func activeBitmapKey(day time.Time) string {
+	return "activity:active:" + day.Format(dayLayout)
+}
+
+func newBitmapKey(day time.Time) string {
+	return "activity:new:" + day.Format(dayLayout)
+}
+
+// Tracker 用 HyperLogLog 统计去重活跃数，用 bitmap（偏移量为用户 ID）计算留存
+type Tracker struct {
+	rdb    goredis.Cmdable
+	keyTTL time.Duration
+	logger logs.Logger
+}
+
+func NewTracker(rdb goredis.Cmdable, keyTTL time.Duration, logger logs.Logger) *Tracker {
+	return &Tracker{
+		rdb:    rdb,
+		keyTTL: keyTTL,
+		logger: logger,
+	}
+}
+
+// MarkActive 在每次鉴权通过后调用；当天已标记过的用户在脚本中只执行一次 SETBIT 即返回
+func (t *Tracker) MarkActive(ctx context.Context, userID string) {
+	uid, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil || uid <= 0 {
+		return
+	}
+	if err = t.markActive(ctx, uid, time.Now()); err != nil {
+		t.logger.Error(ctx, "activity mark failed:", userID, err.Error())
+	}
+}
+
+func (t *Tracker) markActive(ctx context.Context, uid int64, now time.Time) error {
+	return markScript.Run(ctx, t.rdb,
+		[]string{activeBitmapKey(now), activeHLLKey(now), firstSeenKey, newBitmapKey(now)},
+		uid, int64(t.keyTTL/time.Second), now.Format(dayLayout)).Err()
+}
+
+// SeedFirstSeen 在首次部署时按注册日期回填已有用户的首次活跃日，避免老用户在上线当天被计为新增；
+// 只执行一次，失败时清除标记以便下次启动重试
+func (t *Tracker) SeedFirstSeen(ctx context.Context, users user.UserRepository) {
+	ok, err := t.rdb.SetNX(ctx, seededKey, time.Now().Unix(), 0).Result()
+	if err != nil || !ok {
+		if err != nil {
+			t.logger.Error(ctx, "activity first seen seed failed:", err.Error())
+		}
+		return
+	}
+	if err = t.seedFirstSeen(ctx, users); err != nil {
+		t.rdb.Del(ctx, seededKey)
+		t.logger.Error(ctx, "activity first seen seed failed:", err.Error())
+	}
+}
+
+func (t *Tracker) seedFirstSeen(ctx context.Context, users user.UserRepository) error {
+	var afterID uint
+	for {
+		batch, err := users.ListUsers(afterID, seedBatch)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		pipe := t.rdb.Pipeline()
+		for _, u := range batch {
+			pipe.HSetNX(ctx, firstSeenKey, strconv.FormatUint(uint64(u.UserID), 10), u.CreatedAt.Local().Format(dayLayout))
+		}
+		if _, err = pipe.Exec(ctx); err != nil {
+			return err
+		}
+		afterID = batch[len(batch)-1].UserID
+	}
+}
+
+type ActiveStats struct {
+	Date     string `json:"date"`
+	DAU      int64  `json:"dau"`
+	WAU      int64  `json:"wau"`
+	MAU      int64  `json:"mau"`
+	NewUsers int64  `json:"new_users"`
+}
+
+func (t *Tracker) countWindow(ctx context.Context, day time.Time, days int) (int64, error) {
+	keys := make([]string, 0, days)
+	for i := 0; i < days; i++ {
+		keys = append(keys, activeHLLKey(day.AddDate(0, 0, -i)))
+	}
+	return t.rdb.PFCount(ctx, keys...).Result()
+}
+
+// Active 返回截至 day 当天的日活、近 7 日与近 30 日去重活跃及当日新增
+func (t *Tracker) Active(ctx context.Context, day time.Time) (*ActiveStats, error) {
+	dau, err := t.rdb.PFCount(ctx, activeHLLKey(day)).Result()
+	if err != nil {
+		return nil, err
+	}
+	wau, err := t.countWindow(ctx, day, 7)
+	if err != nil {
+		return nil, err
+	}
+	mau, err := t.countWindow(ctx, day, 30)
+	if err != nil {
+		return nil, err
+	}
+	newUsers, err := t.rdb.BitCount(ctx, newBitmapKey(day), nil).Result()
+	if err != nil {
+		return nil, err
+	}
+	return &ActiveStats{
+		Date:     day.Format(time.DateOnly),
+		DAU:      dau,
+		WAU:      wau,
+		MAU:      mau,
+		NewUsers: newUsers,
+	}, nil
+}
+
+type RetentionCell struct {
+	Day      int     `json:"day"`
+	Retained int64   `json:"retained"`
+	Rate     float64 `json:"rate"`
+}
+
+type Cohort struct {
+	Date  string          `json:"date"`
+	Size  int64           `json:"size"`
+	Cells []RetentionCell `json:"cells"`
+}
+
+// Retention 计算 cohort 当天首次出现的用户在第 N 天仍活跃的比例，尚未到达的天数不返回
+func (t *Tracker) Retention(ctx context.Context, cohortDay time.Time, days []int, now time.Time) (*Cohort, error) {
+	size, err := t.rdb.BitCount(ctx, newBitmapKey(cohortDay), nil).Result()
+	if err != nil {
+		return nil, err
+	}
+	cohort := &Cohort{Date: cohortDay.Format(time.DateOnly), Size: size, Cells: make([]RetentionCell, 0, len(days))}
+	for _, n := range days {
+		target := cohortDay.AddDate(0, 0, n)
+		if target.After(now) {
+			break
+		}
+		retained := int64(0)
+		if size > 0 {
+			if retained, err = t.retained(ctx, cohortDay, target); err != nil {
+				return nil, err
+			}
+		}
+		cell := RetentionCell{Day: n, Retained: retained}
+		if size > 0 {
+			cell.Rate = float64(retained) / float64(size)
+		}
+		cohort.Cells = append(cohort.Cells, cell)
+	}
+	return cohort, nil
+}
+
+func (t *Tracker) retained(ctx context.Context, cohortDay, target time.Time) (int64, error) {
+	dest := fmt.Sprintf("activity:tmp:retention:%s:%s:%d", cohortDay.Format(dayLayout), target.Format(dayLayout), time.Now().UnixNano())
+	defer t.rdb.Del(ctx, dest)
+	if err := t.rdb.BitOpAnd(ctx, dest, newBitmapKey(cohortDay), activeBitmapKey(target)).Err(); err != nil {
+		return 0, err
+	}
+	return t.rdb.BitCount(ctx, dest, nil).Result()
+}
+
+// FirstActiveSince 按天查找用户在 since 当天及之后最早的活跃日，超出 keyTTL 的 bitmap 已过期不再查询
+func (t *Tracker) FirstActiveSince(ctx context.Context, userID uint, since, now time.Time) (time.Time, bool, error) {
+	if limit := now.Add(-t.keyTTL); t.keyTTL > 0 && since.Before(limit) {
+		since = limit
+	}
+	var days []time.Time
+	for day := startOfDay(since); !day.After(now); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	pipe := t.rdb.Pipeline()
+	bits := make([]*goredis.IntCmd, len(days))
+	for i, day := range days {
+		bits[i] = pipe.GetBit(ctx, activeBitmapKey(day), int64(userID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != goredis.Nil {
+		return time.Time{}, false, err
+	}
+	for i, cmd := range bits {
+		if cmd.Val() == 1 {
+			return days[i], true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
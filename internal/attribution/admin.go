@@ -0,0 +1,140 @@
+package attribution
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+// 单次查询允许的最大日期跨度
+const maxQueryDays = 93
+
+type ReportReq struct {
+	g.Meta `path:"/api/admin/attribution/report" method:"get"`
+	Start  string `p:"start" v:"required|date#开始日期不能为空|开始日期格式应为YYYY-MM-DD"`
+	End    string `p:"end" v:"required|date#结束日期不能为空|结束日期格式应为YYYY-MM-DD"`
+	Model  string `p:"model" d:"first" v:"in:first,last#归因模型应为first或last"`
+}
+
+type ReportRes struct {
+}
+
+type UserAttributionReq struct {
+	g.Meta `path:"/api/admin/attribution/users/{id}" method:"get"`
+	UserID uint `p:"id" v:"required"`
+}
+
+type UserAttributionRes struct {
+}
+
+// ChannelTotal 是区间内某渠道的汇总
+type ChannelTotal struct {
+	Channel        string  `json:"channel"`
+	Registrations  int64   `json:"registrations"`
+	Activations    int64   `json:"activations"`
+	ActivationRate float64 `json:"activation_rate"`
+}
+
+type Admin struct {
+	repo            AttributionRepository
+	activationEvent string
+	activationDays  int
+}
+
+func NewAdmin(repo AttributionRepository, activationEvent string, activationDays int) *Admin {
+	return &Admin{
+		repo:            repo,
+		activationEvent: activationEvent,
+		activationDays:  activationDays,
+	}
+}
+
+// totals 按渠道汇总，注册数多的渠道排在前面
+func totals(rows []Row) []ChannelTotal {
+	index := make(map[string]int)
+	out := make([]ChannelTotal, 0)
+	for _, row := range rows {
+		i, ok := index[row.Channel]
+		if !ok {
+			i = len(out)
+			index[row.Channel] = i
+			out = append(out, ChannelTotal{Channel: row.Channel})
+		}
+		out[i].Registrations += row.Registrations
+		out[i].Activations += row.Activations
+	}
+	for i := range out {
+		if out[i].Registrations > 0 {
+			out[i].ActivationRate = float64(out[i].Activations) / float64(out[i].Registrations)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Registrations != out[j].Registrations {
+			return out[i].Registrations > out[j].Registrations
+		}
+		return out[i].Channel < out[j].Channel
+	})
+	return out
+}
+
+// Report 按日期与渠道统计注册数与激活数，日期区间包含两端
+func (params *Admin) Report(ctx context.Context, req *ReportReq) (res *ReportRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Attribution.Report")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	start, err := time.ParseInLocation(time.DateOnly, req.Start, time.Local)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "开始日期格式应为YYYY-MM-DD")
+	}
+	end, err := time.ParseInLocation(time.DateOnly, req.End, time.Local)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "结束日期格式应为YYYY-MM-DD")
+	}
+	if end.Before(start) || end.Sub(start) >= maxQueryDays*24*time.Hour {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "日期区间不合法")
+	}
+
+	window := time.Duration(params.activationDays) * 24 * time.Hour
+	rows, err := params.repo.Report(req.Model, start, end.AddDate(0, 0, 1), params.activationEvent, window)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data": g.Map{
+			"model":            req.Model,
+			"activation_event": params.activationEvent,
+			"activation_days":  params.activationDays,
+			"rows":             rows,
+			"channels":         totals(rows),
+		},
+	})
+	return nil, nil
+}
+
+func (params *Admin) User(ctx context.Context, req *UserAttributionReq) (res *UserAttributionRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	attribution, err := params.repo.Find(req.UserID)
+	if err != nil {
+		if errors.Is(err, ErrAttributionNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "该用户没有归因记录")
+		}
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    attribution,
+	})
+	return nil, nil
+}
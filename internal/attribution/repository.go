@@ -0,0 +1,102 @@
+package attribution
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrAttributionNotFound = errors.New("attribution not found")
+
+// 归因模型
+const (
+	ModelFirstTouch = "first"
+	ModelLastTouch  = "last"
+)
+
+// Attribution 是用户注册时的来源，First 为首次触点，Last 为注册前最近一次带来源的触点
+type Attribution struct {
+	UserID       uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	First        Touch     `gorm:"embedded;embeddedPrefix:first_" json:"first_touch"`
+	Last         Touch     `gorm:"embedded;embeddedPrefix:last_" json:"last_touch"`
+	RegisteredAt time.Time `gorm:"not null;index" json:"registered_at"`
+}
+
+// Row 是按日期与渠道汇总的注册与激活数
+type Row struct {
+	Date          string `json:"date"`
+	Channel       string `json:"channel"`
+	Registrations int64  `json:"registrations"`
+	Activations   int64  `json:"activations"`
+}
+
+type attributionRepository struct {
+	db *gorm.DB
+}
+
+type AttributionRepository interface {
+	Save(attribution *Attribution) error
+	Find(userID uint) (*Attribution, error)
+	// FirstChannels 返回用户的首次触点渠道，没有归因记录的用户不在结果中
+	FirstChannels(userIDs []uint) (map[uint]string, error)
+	// Report 统计 [start, end) 内注册的用户，注册后 window 内发生过 activationEvent 的算作激活
+	Report(model string, start, end time.Time, activationEvent string, window time.Duration) ([]Row, error)
+}
+
+func NewAttributionRepository(db *gorm.DB) AttributionRepository {
+	if err := db.AutoMigrate(&Attribution{}); err != nil {
+		panic("failed to migrate attribution table")
+	}
+	return &attributionRepository{db: db}
+}
+
+func (repo *attributionRepository) Save(attribution *Attribution) error {
+	return repo.db.Clauses(clause.OnConflict{DoNothing: true}).Create(attribution).Error
+}
+
+func (repo *attributionRepository) Find(userID uint) (*Attribution, error) {
+	var attribution Attribution
+	if err := repo.db.Where("user_id = ?", userID).First(&attribution).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAttributionNotFound
+		}
+		return nil, err
+	}
+	return &attribution, nil
+}
+
+func (repo *attributionRepository) FirstChannels(userIDs []uint) (map[uint]string, error) {
+	var rows []struct {
+		UserID       uint
+		FirstChannel string
+	}
+	if err := repo.db.Model(&Attribution{}).Select("user_id, first_channel").
+		Where("user_id IN ?", userIDs).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	channels := make(map[uint]string, len(rows))
+	for _, row := range rows {
+		channels[row.UserID] = row.FirstChannel
+	}
+	return channels, nil
+}
+
+func (repo *attributionRepository) Report(model string, start, end time.Time, activationEvent string, window time.Duration) ([]Row, error) {
+	channelColumn := "first_channel"
+	if model == ModelLastTouch {
+		channelColumn = "last_channel"
+	}
+	var rows []Row
+	err := repo.db.Table("attributions AS a").
+		Select("DATE_FORMAT(a.registered_at, '%Y-%m-%d') AS date, a."+channelColumn+" AS channel, COUNT(*) AS registrations, "+
+			"SUM(EXISTS (SELECT 1 FROM user_events e WHERE e.user_id = a.user_id AND e.name = ? "+
+			"AND e.created_at >= a.registered_at AND e.created_at < DATE_ADD(a.registered_at, INTERVAL ? SECOND))) AS activations",
+			activationEvent, int64(window.Seconds())).
+		Where("a.registered_at >= ? AND a.registered_at < ?", start, end).
+		Group("date, channel").
+		Order("date, channel").
+		Scan(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,127 @@
+package attribution
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/user"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+type Service struct {
+	repo      AttributionRepository
+	cookieTTL time.Duration
+	logger    logs.Logger
+}
+
+func NewService(repo AttributionRepository, cookieTTL time.Duration, logger logs.Logger) *Service {
+	return &Service{
+		repo:      repo,
+		cookieTTL: cookieTTL,
+		logger:    logger,
+	}
+}
+
+// isPageLoad 只在加载静态页面时记录触点，接口请求与静态资源不处理
+func isPageLoad(r *ghttp.Request) bool {
+	if r.Method != "GET" || r.StaticFile == nil {
+		return false
+	}
+	path := r.URL.Path
+	return strings.HasSuffix(path, "/") || strings.HasSuffix(path, ".html")
+}
+
+// Capture 作为 HookBeforeServe 钩子，首次访问写入首次触点 cookie，带来源的访问刷新最近触点 cookie
+func (s *Service) Capture(r *ghttp.Request) {
+	if !isPageLoad(r) {
+		return
+	}
+	touch := NewTouch(r.URL, r.Referer(), r.Host, time.Now())
+	if !r.Cookie.Contains(FirstTouchCookie) {
+		r.Cookie.SetCookie(FirstTouchCookie, touch.Encode(), "", "/", s.cookieTTL)
+	}
+	if touch.Attributed() || !r.Cookie.Contains(LastTouchCookie) {
+		r.Cookie.SetCookie(LastTouchCookie, touch.Encode(), "", "/", s.cookieTTL)
+	}
+}
+
+// OnRegister 注册为用户注册钩子，从 cookie 中读取触点保存归因记录。
+// 没有 cookie 时以注册请求的 Referer（即注册页地址）作为落地页
+func (s *Service) OnRegister(ctx context.Context, reg *user.Registration) {
+	r := g.RequestFromCtx(ctx)
+	if r == nil {
+		return
+	}
+	now := time.Now()
+
+	first := DecodeTouch(r.Cookie.Get(FirstTouchCookie).String())
+	last := DecodeTouch(r.Cookie.Get(LastTouchCookie).String())
+	if first == nil || last == nil {
+		fallback := &Touch{Channel: ChannelDirect, At: now}
+		if page, err := url.Parse(r.Referer()); err == nil && strings.EqualFold(page.Hostname(), hostname(r.Host)) {
+			fallback = NewTouch(page, "", r.Host, now)
+		}
+		if first == nil {
+			first = fallback
+		}
+		if last == nil {
+			last = first
+		}
+	}
+
+	attribution := &Attribution{
+		UserID:       reg.UserID,
+		First:        *first,
+		Last:         *last,
+		RegisteredAt: reg.CreatedAt,
+	}
+	if attribution.RegisteredAt.IsZero() {
+		attribution.RegisteredAt = now
+	}
+	if err := s.repo.Save(attribution); err != nil {
+		s.logger.Error(ctx, "attribution save failed:", reg.UserID, err.Error())
+		return
+	}
+	s.logger.Info(ctx, "Attribution recorded:", reg.UserID, "first:", first.Channel, "last:", last.Channel)
+}
+
+// Attributes 实现 segment.AttributeSource，提供注册来源：channel 为首次触点渠道，
+// last_channel 为最近触点渠道；没有归因记录的用户不提供这些属性
+func (s *Service) Attributes(ctx context.Context, userID uint) (map[string]any, error) {
+	attribution, err := s.repo.Find(userID)
+	if err != nil {
+		if errors.Is(err, ErrAttributionNotFound) {
+			return map[string]any{}, nil
+		}
+		return nil, err
+	}
+	return map[string]any{
+		"channel":      attribution.First.Channel,
+		"last_channel": attribution.Last.Channel,
+		"utm_source":   attribution.First.Source,
+		"utm_campaign": attribution.First.Campaign,
+	}, nil
+}
+
+// 批量查询首次触点渠道时每次的用户数
+const channelBatch = 1000
+
+// FirstChannels 实现 funnel.ChannelSource，按注册归因返回用户的首次触点渠道
+func (s *Service) FirstChannels(ctx context.Context, userIDs []uint) (map[uint]string, error) {
+	channels := make(map[uint]string, len(userIDs))
+	for start := 0; start < len(userIDs); start += channelBatch {
+		batch, err := s.repo.FirstChannels(userIDs[start:min(start+channelBatch, len(userIDs))])
+		if err != nil {
+			return nil, err
+		}
+		for id, c := range batch {
+			channels[id] = c
+		}
+	}
+	return channels, nil
+}
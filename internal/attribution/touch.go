@@ -0,0 +1,110 @@
+package attribution
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+	"usergrowth/internal/text"
+)
+
+// 首次触点与最近触点的 cookie 名
+const (
+	FirstTouchCookie = "ug_ft"
+	LastTouchCookie  = "ug_lt"
+)
+
+const (
+	ChannelDirect = "direct"
+	// 字段截断长度，与表结构一致
+	maxFieldLength = 255
+)
+
+// Touch 是一次带来源信息的访问
+type Touch struct {
+	Source   string    `gorm:"type:varchar(255)" json:"source"`
+	Medium   string    `gorm:"type:varchar(255)" json:"medium"`
+	Campaign string    `gorm:"type:varchar(255)" json:"campaign"`
+	Term     string    `gorm:"type:varchar(255)" json:"term"`
+	Content  string    `gorm:"type:varchar(255)" json:"content"`
+	Referrer string    `gorm:"type:varchar(255)" json:"referrer"`
+	Landing  string    `gorm:"type:varchar(255)" json:"landing"`
+	Channel  string    `gorm:"type:varchar(255);index" json:"channel"`
+	At       time.Time `json:"at"`
+}
+
+func truncate(s string) string {
+	return text.Truncate(strings.TrimSpace(s), maxFieldLength)
+}
+
+// NewTouch 从落地页地址与 Referer 构造触点，host 是本站域名，站内跳转不算作来源
+func NewTouch(landing *url.URL, referrer, host string, at time.Time) *Touch {
+	q := landing.Query()
+	t := &Touch{
+		Source:   truncate(strings.ToLower(q.Get("utm_source"))),
+		Medium:   truncate(strings.ToLower(q.Get("utm_medium"))),
+		Campaign: truncate(q.Get("utm_campaign")),
+		Term:     truncate(q.Get("utm_term")),
+		Content:  truncate(q.Get("utm_content")),
+		Landing:  truncate(landing.Path),
+		At:       at,
+	}
+	if ref, err := url.Parse(referrer); err == nil && ref.Host != "" && !strings.EqualFold(ref.Hostname(), hostname(host)) {
+		t.Referrer = truncate(ref.Scheme + "://" + ref.Host + ref.Path)
+	}
+	t.Channel = channel(t)
+	return t
+}
+
+func hostname(host string) string {
+	if u, err := url.Parse("//" + host); err == nil {
+		return u.Hostname()
+	}
+	return host
+}
+
+// channel 优先使用 utm_source，其次是外部来源站点的域名，都没有时为 direct
+func channel(t *Touch) string {
+	if t.Source != "" {
+		return t.Source
+	}
+	if t.Referrer != "" {
+		if ref, err := url.Parse(t.Referrer); err == nil && ref.Hostname() != "" {
+			return strings.TrimPrefix(strings.ToLower(ref.Hostname()), "www.")
+		}
+	}
+	return ChannelDirect
+}
+
+// Attributed 表示这次访问带有来源信息，直接访问不覆盖最近触点
+func (t *Touch) Attributed() bool {
+	return t.Channel != ChannelDirect
+}
+
+func (t *Touch) Encode() string {
+	b, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeTouch 解析 cookie 中的触点，格式不对时返回 nil
+func DecodeTouch(raw string) *Touch {
+	if raw == "" {
+		return nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil
+	}
+	var t Touch
+	if err = json.Unmarshal(b, &t); err != nil || t.At.IsZero() {
+		return nil
+	}
+	// cookie 可被客户端篡改，重新截断并计算渠道
+	for _, f := range []*string{&t.Source, &t.Medium, &t.Campaign, &t.Term, &t.Content, &t.Referrer, &t.Landing} {
+		*f = truncate(*f)
+	}
+	t.Source, t.Medium = strings.ToLower(t.Source), strings.ToLower(t.Medium)
+	t.Channel = channel(&t)
+	return &t
+}
@@ -0,0 +1,99 @@
+package attribution
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustURL(t *testing.T, raw string) *url.URL {
+	u, err := url.Parse(raw)
+	assert.NoError(t, err)
+	return u
+}
+
+func TestNewTouchUTM(t *testing.T) {
+	at := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	touch := NewTouch(mustURL(t, "/register.html?utm_source=WeChat&utm_medium=cpc&utm_campaign=Spring"), "https://mp.weixin.qq.com/s/abc?x=1", "example.com", at)
+	assert.Equal(t, "wechat", touch.Source)
+	assert.Equal(t, "cpc", touch.Medium)
+	assert.Equal(t, "Spring", touch.Campaign)
+	assert.Equal(t, "https://mp.weixin.qq.com/s/abc", touch.Referrer)
+	assert.Equal(t, "/register.html", touch.Landing)
+	assert.Equal(t, "wechat", touch.Channel)
+	assert.True(t, touch.Attributed())
+}
+
+func TestNewTouchReferrerAndDirect(t *testing.T) {
+	now := time.Now()
+	touch := NewTouch(mustURL(t, "/"), "https://www.Google.com/search?q=x", "example.com:8080", now)
+	assert.Equal(t, "google.com", touch.Channel)
+
+	// 站内跳转不算来源
+	touch = NewTouch(mustURL(t, "/login.html"), "http://example.com:8080/register.html", "example.com:8080", now)
+	assert.Equal(t, "", touch.Referrer)
+	assert.Equal(t, ChannelDirect, touch.Channel)
+	assert.False(t, touch.Attributed())
+}
+
+func TestTouchCookieRoundTrip(t *testing.T) {
+	touch := NewTouch(mustURL(t, "/?utm_source=newsletter"), "", "example.com", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	decoded := DecodeTouch(touch.Encode())
+	if !assert.NotNil(t, decoded) {
+		return
+	}
+	assert.Equal(t, touch.Source, decoded.Source)
+	assert.Equal(t, touch.Channel, decoded.Channel)
+	assert.True(t, touch.At.Equal(decoded.At))
+
+	assert.Nil(t, DecodeTouch(""))
+	assert.Nil(t, DecodeTouch("not-base64!"))
+}
+
+func TestTotals(t *testing.T) {
+	rows := []Row{
+		{Date: "2026-03-01", Channel: "direct", Registrations: 2, Activations: 1},
+		{Date: "2026-03-01", Channel: "wechat", Registrations: 5, Activations: 2},
+		{Date: "2026-03-02", Channel: "wechat", Registrations: 3, Activations: 3},
+	}
+	got := totals(rows)
+	if !assert.Len(t, got, 2) {
+		return
+	}
+	assert.Equal(t, ChannelTotal{Channel: "wechat", Registrations: 8, Activations: 5, ActivationRate: 0.625}, got[0])
+	assert.Equal(t, "direct", got[1].Channel)
+	assert.Equal(t, 0.5, got[1].ActivationRate)
+}
+
+type fakeRepo struct {
+	AttributionRepository
+	rows map[uint]*Attribution
+}
+
+func (f *fakeRepo) Find(userID uint) (*Attribution, error) {
+	if a, ok := f.rows[userID]; ok {
+		return a, nil
+	}
+	return nil, ErrAttributionNotFound
+}
+
+func TestAttributes(t *testing.T) {
+	repo := &fakeRepo{rows: map[uint]*Attribution{
+		1: {UserID: 1, First: Touch{Channel: "douyin", Source: "douyin", Campaign: "spring"}, Last: Touch{Channel: ChannelDirect}},
+	}}
+	s := NewService(repo, time.Hour, nil)
+
+	attrs, err := s.Attributes(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "douyin", attrs["channel"])
+	assert.Equal(t, ChannelDirect, attrs["last_channel"])
+	assert.Equal(t, "spring", attrs["utm_campaign"])
+
+	// 没有归因记录时不提供渠道属性，exists 规则可以区分
+	attrs, err = s.Attributes(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.NotContains(t, attrs, "channel")
+}
@@ -0,0 +1,122 @@
+package badge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"usergrowth/internal/event"
+
+	"github.com/gogf/gf/v2/util/gconv"
+)
+
+var ErrInvalidDefinitions = errors.New("invalid badge definitions")
+
+// 规则类型
+const (
+	RuleCount  = "count"  // 事件累计发生 Threshold 次
+	RuleStreak = "streak" // 连续 Threshold 天发生事件
+	RulePoints = "points" // 累计获得积分达到 Threshold
+)
+
+// 事件来源，客户端埋点可被伪造，默认只统计服务端事件
+const (
+	SourceServer = "server"
+	SourceAny    = "any"
+)
+
+// Rule 描述获得徽章的条件，Where 中的属性需全部相等，按字符串比较
+type Rule struct {
+	Type      string            `json:"type"`
+	Event     string            `json:"event"`
+	Where     map[string]string `json:"where"`
+	Threshold int64             `json:"threshold"`
+	Source    string            `json:"source"`
+}
+
+// Badge 的 Key 一经发放不可修改，Points 大于 0 时获得徽章同时发放积分
+type Badge struct {
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+	Points      int64  `json:"points"`
+	Rule        Rule   `json:"rule"`
+}
+
+type Definitions struct {
+	Badges  []Badge `json:"badges"`
+	byEvent map[string][]*Badge
+	byKey   map[string]*Badge
+}
+
+func (d *Definitions) validate() error {
+	d.byEvent = make(map[string][]*Badge)
+	d.byKey = make(map[string]*Badge, len(d.Badges))
+	for i := range d.Badges {
+		b := &d.Badges[i]
+		if b.Key == "" {
+			return fmt.Errorf("%w: badge %d has empty key", ErrInvalidDefinitions, i)
+		}
+		if _, ok := d.byKey[b.Key]; ok {
+			return fmt.Errorf("%w: duplicate key %s", ErrInvalidDefinitions, b.Key)
+		}
+		d.byKey[b.Key] = b
+		if b.Points < 0 || b.Rule.Threshold <= 0 {
+			return fmt.Errorf("%w: invalid points or threshold in %s", ErrInvalidDefinitions, b.Key)
+		}
+		switch b.Rule.Source {
+		case "":
+			b.Rule.Source = SourceServer
+		case SourceServer, SourceAny:
+		default:
+			return fmt.Errorf("%w: unknown source %s in %s", ErrInvalidDefinitions, b.Rule.Source, b.Key)
+		}
+		switch b.Rule.Type {
+		case RuleCount, RuleStreak:
+			if b.Rule.Event == "" {
+				return fmt.Errorf("%w: event required in %s", ErrInvalidDefinitions, b.Key)
+			}
+			d.byEvent[b.Rule.Event] = append(d.byEvent[b.Rule.Event], b)
+		case RulePoints:
+		default:
+			return fmt.Errorf("%w: unknown rule type %s in %s", ErrInvalidDefinitions, b.Rule.Type, b.Key)
+		}
+	}
+	return nil
+}
+
+func (d *Definitions) find(key string) *Badge {
+	return d.byKey[key]
+}
+
+// matches 判断事件是否计入该徽章的进度，client 表示事件来自埋点上报
+func (b *Badge) matches(e *event.UserEvent, client bool) bool {
+	if e.Name != b.Rule.Event || (client && b.Rule.Source != SourceAny) {
+		return false
+	}
+	if len(b.Rule.Where) == 0 {
+		return true
+	}
+	var props map[string]any
+	if err := json.Unmarshal([]byte(e.Properties), &props); err != nil {
+		return false
+	}
+	for k, want := range b.Rule.Where {
+		v, ok := props[k]
+		if !ok || gconv.String(v) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// satisfied 判断累加后的进度是否达到门槛
+func (b *Badge) satisfied(p *Progress) bool {
+	switch b.Rule.Type {
+	case RuleCount:
+		return p.Count >= b.Rule.Threshold
+	case RuleStreak:
+		return p.Streak >= b.Rule.Threshold
+	}
+	return false
+}
@@ -0,0 +1,283 @@
+package badge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"usergrowth/internal/event"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/points"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type progressKey struct {
+	userID uint
+	key    string
+}
+
+// fakeRepo 在内存中实现与 advanceSQL 相同的语义
+type fakeRepo struct {
+	BadgeRepository
+	progress map[progressKey]*Progress
+	earned   []UserBadge
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{progress: make(map[progressKey]*Progress)}
+}
+
+func (f *fakeRepo) Advance(userID uint, badgeKey string, day time.Time) (*Progress, error) {
+	d := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	p, ok := f.progress[progressKey{userID, badgeKey}]
+	if !ok {
+		p = &Progress{UserID: userID, BadgeKey: badgeKey, Count: 1, Streak: 1, LastDay: &d}
+		f.progress[progressKey{userID, badgeKey}] = p
+		out := *p
+		return &out, nil
+	}
+	switch {
+	case !d.After(*p.LastDay):
+	case d.Equal(p.LastDay.AddDate(0, 0, 1)):
+		p.Streak++
+	default:
+		p.Streak = 1
+	}
+	p.Count++
+	if d.After(*p.LastDay) {
+		p.LastDay = &d
+	}
+	out := *p
+	return &out, nil
+}
+
+func (f *fakeRepo) ListProgress(userID uint) ([]Progress, error) {
+	var out []Progress
+	for k, p := range f.progress {
+		if k.userID == userID {
+			out = append(out, *p)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeRepo) Award(badge *UserBadge) (bool, error) {
+	for _, b := range f.earned {
+		if b.UserID == badge.UserID && b.BadgeKey == badge.BadgeKey {
+			return false, nil
+		}
+	}
+	f.earned = append(f.earned, *badge)
+	return true, nil
+}
+
+func (f *fakeRepo) Earned(userID uint) ([]UserBadge, error) {
+	var out []UserBadge
+	for _, b := range f.earned {
+		if b.UserID == userID {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+type fakePoints struct {
+	entries  map[string]int64
+	lifetime int64
+	err      error
+}
+
+func (f *fakePoints) Award(ctx context.Context, userID uint, delta int64, reason, refID string) (*points.Account, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.entries == nil {
+		f.entries = make(map[string]int64)
+	}
+	if _, ok := f.entries[reason+"/"+refID]; ok {
+		return nil, points.ErrDuplicateEntry
+	}
+	f.entries[reason+"/"+refID] = delta
+	f.lifetime += delta
+	return &points.Account{UserID: userID, Lifetime: f.lifetime}, nil
+}
+
+func (f *fakePoints) Account(userID uint) (*points.Account, error) {
+	return &points.Account{UserID: userID, Lifetime: f.lifetime}, nil
+}
+
+type fakeEvents struct {
+	event.EventRepository
+	names []string
+}
+
+func (f *fakeEvents) Record(ctx context.Context, userID uint, name string, properties map[string]any) error {
+	f.names = append(f.names, name)
+	return nil
+}
+
+const testDefinitions = `
+badges:
+  - key: first_login
+    name: 初次登录
+    points: 10
+    rule: {type: count, event: login, threshold: 1}
+  - key: streak_3
+    rule: {type: streak, event: login, threshold: 3}
+  - key: inviter_2
+    points: 50
+    rule: {type: count, event: points_changed, where: {reason: referral_inviter}, threshold: 2}
+  - key: sharer
+    rule: {type: count, event: share, threshold: 2, source: any}
+  - key: points_100
+    rule: {type: points, threshold: 100}
+`
+
+var testNow = time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+
+func newTestService(t *testing.T) (*Service, *fakeRepo, *fakePoints, *fakeEvents) {
+	repo, pts, events := newFakeRepo(), &fakePoints{}, &fakeEvents{}
+	s := NewService(repo, pts, events, logs.Nop())
+	s.now = func() time.Time { return testNow }
+	_, err := s.Load([]byte(testDefinitions))
+	assert.NoError(t, err)
+	return s, repo, pts, events
+}
+
+func login(userID uint, at time.Time) *event.UserEvent {
+	return &event.UserEvent{UserID: userID, Name: event.NameLogin, Properties: "{}", CreatedAt: at}
+}
+
+func TestLoadValidation(t *testing.T) {
+	s, _, _, _ := newTestService(t)
+	assert.Len(t, s.Definitions().byEvent[event.NameLogin], 2)
+	assert.Equal(t, SourceServer, s.Definitions().find("first_login").Rule.Source)
+
+	for _, raw := range []string{
+		`{"badges":[{"key":"a","rule":{"type":"count","event":"x","threshold":1}},{"key":"a","rule":{"type":"count","event":"y","threshold":1}}]}`,
+		`{"badges":[{"key":"a","rule":{"type":"unknown","threshold":1}}]}`,
+		`{"badges":[{"key":"a","rule":{"type":"count","threshold":1}}]}`,
+		`{"badges":[{"key":"a","rule":{"type":"count","event":"x","threshold":0}}]}`,
+		`{"badges":[{"key":"a","rule":{"type":"count","event":"x","threshold":1,"source":"client"}}]}`,
+	} {
+		_, err := s.Load([]byte(raw))
+		assert.ErrorIs(t, err, ErrInvalidDefinitions, raw)
+	}
+	// 非法定义不替换当前定义
+	assert.NotNil(t, s.Definitions().find("first_login"))
+}
+
+func TestCountAwardedOnce(t *testing.T) {
+	s, repo, pts, events := newTestService(t)
+	ctx := context.Background()
+
+	s.OnEvent(ctx, login(1, testNow))
+	s.OnEvent(ctx, login(1, testNow))
+	assert.Len(t, repo.earned, 1)
+	assert.Equal(t, map[string]int64{"badge/first_login:1": 10}, pts.entries)
+	assert.Equal(t, []string{NameEarned}, events.names)
+	// 已获得的徽章不再累加进度
+	assert.Equal(t, int64(1), repo.progress[progressKey{1, "first_login"}].Count)
+
+	// 匿名事件不计入
+	s.OnEvent(ctx, login(0, testNow))
+	assert.Len(t, repo.earned, 1)
+}
+
+func TestWhereAndSource(t *testing.T) {
+	s, repo, _, _ := newTestService(t)
+	ctx := context.Background()
+	inviter := &event.UserEvent{UserID: 2, Name: points.NameChanged, Properties: `{"reason":"referral_inviter","delta":100}`}
+	other := &event.UserEvent{UserID: 2, Name: points.NameChanged, Properties: `{"reason":"admin_adjust"}`}
+
+	s.OnEvent(ctx, inviter)
+	s.OnEvent(ctx, other)
+	assert.Empty(t, repo.earned)
+	// 客户端伪造的服务端事件名不计入默认规则
+	assert.NoError(t, s.Write(ctx, []event.UserEvent{*inviter}))
+	assert.Empty(t, repo.earned)
+	s.OnEvent(ctx, inviter)
+	if !assert.Len(t, repo.earned, 1) {
+		return
+	}
+	assert.Equal(t, "inviter_2", repo.earned[0].BadgeKey)
+
+	share := event.UserEvent{UserID: 2, Name: "share", Properties: "{}"}
+	assert.NoError(t, s.Write(ctx, []event.UserEvent{share, share}))
+	assert.Len(t, repo.earned, 2)
+}
+
+func TestStreak(t *testing.T) {
+	s, repo, _, _ := newTestService(t)
+	ctx := context.Background()
+	day := func(n int) time.Time { return testNow.AddDate(0, 0, n) }
+
+	s.OnEvent(ctx, login(3, day(0)))
+	s.OnEvent(ctx, login(3, day(1)))
+	s.OnEvent(ctx, login(3, day(1))) // 同一天不重复计
+	s.OnEvent(ctx, login(3, day(0))) // 迟到的事件不影响连续天数
+	s.OnEvent(ctx, login(3, day(3))) // 中断后重新计数
+	s.OnEvent(ctx, login(3, day(4)))
+	assert.Equal(t, int64(2), repo.progress[progressKey{3, "streak_3"}].Streak)
+	assert.Len(t, repo.earned, 1)
+
+	s.OnEvent(ctx, login(3, day(5)))
+	assert.Len(t, repo.earned, 2)
+}
+
+func TestPointsRule(t *testing.T) {
+	s, repo, pts, _ := newTestService(t)
+	ctx := context.Background()
+
+	s.OnPointsChanged(ctx, &points.Account{UserID: 4, Lifetime: 99}, &points.Entry{})
+	assert.Empty(t, repo.earned)
+	s.OnPointsChanged(ctx, &points.Account{UserID: 4, Lifetime: 100}, &points.Entry{})
+	s.OnPointsChanged(ctx, &points.Account{UserID: 4, Lifetime: 150}, &points.Entry{})
+	assert.Len(t, repo.earned, 1)
+	assert.Empty(t, pts.entries)
+}
+
+func TestPointsFailureRetried(t *testing.T) {
+	s, repo, pts, _ := newTestService(t)
+	ctx := context.Background()
+
+	pts.err = errors.New("db down")
+	s.OnEvent(ctx, login(5, testNow))
+	assert.Empty(t, repo.earned)
+
+	// 下一次满足条件的事件补发，积分只发一次
+	pts.err = nil
+	s.OnEvent(ctx, login(5, testNow))
+	assert.Len(t, repo.earned, 1)
+	assert.Len(t, pts.entries, 1)
+}
+
+func TestList(t *testing.T) {
+	s, _, pts, _ := newTestService(t)
+	ctx := context.Background()
+
+	s.OnEvent(ctx, login(6, testNow.AddDate(0, 0, -1)))
+	s.OnEvent(ctx, login(6, testNow))
+	pts.lifetime = 40
+
+	views, err := s.List(ctx, 6)
+	if !assert.NoError(t, err) || !assert.Len(t, views, 5) {
+		return
+	}
+	byKey := make(map[string]View)
+	for _, v := range views {
+		byKey[v.Key] = v
+	}
+	assert.True(t, byKey["first_login"].Earned)
+	assert.Equal(t, testNow, *byKey["first_login"].EarnedAt)
+	assert.False(t, byKey["streak_3"].Earned)
+	assert.Equal(t, int64(2), byKey["streak_3"].Progress)
+	assert.Equal(t, int64(3), byKey["streak_3"].Target)
+	assert.Equal(t, int64(40), byKey["points_100"].Progress)
+
+	// 连续登录中断后进度显示为 0
+	s.now = func() time.Time { return testNow.AddDate(0, 0, 3) }
+	views, _ = s.List(ctx, 6)
+	assert.Equal(t, int64(0), views[1].Progress)
+}
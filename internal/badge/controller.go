@@ -0,0 +1,53 @@
+package badge
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type ListReq struct {
+	g.Meta `path:"/api/badges" method:"get"`
+}
+
+type ListRes struct {
+}
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// List 返回全部徽章，已获得的附带获得时间，未获得的附带当前进度
+func (c *Controller) List(ctx context.Context, req *ListReq) (res *ListRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Badge.List")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	userid := r.GetCtxVar("userid").String()
+	uid, err := strconv.ParseUint(userid, 10, 64)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+	span.SetAttributes(attribute.String("user.id", userid))
+
+	views, err := c.service.List(ctx, uint(uid))
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    views,
+	})
+	return nil, nil
+}
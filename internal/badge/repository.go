@@ -0,0 +1,98 @@
+package badge
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Progress 是用户在单个徽章上的累计进度，LastDay 为最近一次事件所在的日期
+type Progress struct {
+	UserID    uint       `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	BadgeKey  string     `gorm:"primaryKey;type:varchar(64)" json:"badge_key"`
+	Count     int64      `gorm:"not null;default:0" json:"count"`
+	Streak    int64      `gorm:"not null;default:0" json:"streak"`
+	LastDay   *time.Time `gorm:"type:date" json:"last_day"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func (Progress) TableName() string {
+	return "badge_progress"
+}
+
+// UserBadge 以 (user_id, badge_key) 为主键，保证每个徽章只发放一次
+type UserBadge struct {
+	UserID   uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	BadgeKey string    `gorm:"primaryKey;type:varchar(64)" json:"badge_key"`
+	Points   int64     `gorm:"not null;default:0" json:"points"`
+	EarnedAt time.Time `gorm:"not null;index" json:"earned_at"`
+}
+
+func (UserBadge) TableName() string {
+	return "user_badges"
+}
+
+type badgeRepository struct {
+	db *gorm.DB
+}
+
+type BadgeRepository interface {
+	// Advance 原子累加一次进度并返回累加后的结果；day 早于已记录的最后一天时连续天数不变
+	Advance(userID uint, badgeKey string, day time.Time) (*Progress, error)
+	ListProgress(userID uint) ([]Progress, error)
+	// Award 记录获得的徽章，已获得时返回 false
+	Award(badge *UserBadge) (bool, error)
+	Earned(userID uint) ([]UserBadge, error)
+}
+
+func NewBadgeRepository(db *gorm.DB) BadgeRepository {
+	if err := db.AutoMigrate(&Progress{}, &UserBadge{}); err != nil {
+		panic("failed to migrate badge tables")
+	}
+	return &badgeRepository{db: db}
+}
+
+// advanceSQL 中 streak 的赋值需在 last_day 之前，MySQL 按顺序使用已更新的列值
+const advanceSQL = `INSERT INTO badge_progress (user_id, badge_key, count, streak, last_day, updated_at)
+VALUES (?, ?, 1, 1, ?, ?)
+ON DUPLICATE KEY UPDATE
+	streak = CASE
+		WHEN last_day IS NULL THEN 1
+		WHEN VALUES(last_day) <= last_day THEN streak
+		WHEN VALUES(last_day) = DATE_ADD(last_day, INTERVAL 1 DAY) THEN streak + 1
+		ELSE 1 END,
+	count = count + 1,
+	last_day = GREATEST(COALESCE(last_day, VALUES(last_day)), VALUES(last_day)),
+	updated_at = VALUES(updated_at)`
+
+func (repo *badgeRepository) Advance(userID uint, badgeKey string, day time.Time) (*Progress, error) {
+	var progress Progress
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(advanceSQL, userID, badgeKey, day.Format(time.DateOnly), time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND badge_key = ?", userID, badgeKey).First(&progress).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+func (repo *badgeRepository) ListProgress(userID uint) ([]Progress, error) {
+	var progress []Progress
+	err := repo.db.Where("user_id = ?", userID).Find(&progress).Error
+	return progress, err
+}
+
+func (repo *badgeRepository) Award(badge *UserBadge) (bool, error) {
+	res := repo.db.Clauses(clause.OnConflict{DoNothing: true}).Create(badge)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (repo *badgeRepository) Earned(userID uint) ([]UserBadge, error) {
+	var badges []UserBadge
+	err := repo.db.Where("user_id = ?", userID).Order("earned_at").Find(&badges).Error
+	return badges, err
+}
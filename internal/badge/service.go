@@ -0,0 +1,311 @@
+package badge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"usergrowth/internal/event"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/points"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"github.com/gogf/gf/v2/os/gfsnotify"
+)
+
+// NameEarned 是获得徽章时写入的服务端事件
+const NameEarned = "badge_earned"
+
+// ReasonBadge 是徽章奖励积分的流水原因
+const ReasonBadge = "badge"
+
+// PointsService 由 points.Service 实现
+type PointsService interface {
+	Award(ctx context.Context, userID uint, delta int64, reason, refID string) (*points.Account, error)
+	Account(userID uint) (*points.Account, error)
+}
+
+// Service 持有当前生效的徽章定义，在事件写入与积分变动时增量累加进度
+type Service struct {
+	defs    atomic.Pointer[Definitions]
+	repo    BadgeRepository
+	points  PointsService
+	events  event.EventRepository
+	now     func() time.Time
+	logger  logs.Logger
+	mu      sync.Mutex
+	lastRaw string
+}
+
+func NewService(repo BadgeRepository, pointsService PointsService, events event.EventRepository, logger logs.Logger) *Service {
+	s := &Service{
+		repo:   repo,
+		points: pointsService,
+		events: events,
+		now:    time.Now,
+		logger: logger,
+	}
+	s.defs.Store(&Definitions{})
+	return s
+}
+
+// Load 解析 JSON 或 YAML 格式的定义，非法时保留旧定义；返回定义是否发生变化
+func (s *Service) Load(raw []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if string(raw) == s.lastRaw {
+		return false, nil
+	}
+
+	j, err := gjson.LoadContent(raw)
+	if err != nil {
+		return false, err
+	}
+	var defs Definitions
+	if err = json.Unmarshal(j.MustToJson(), &defs); err != nil {
+		return false, err
+	}
+	if err = defs.validate(); err != nil {
+		return false, err
+	}
+	s.defs.Store(&defs)
+	s.lastRaw = string(raw)
+	return true, nil
+}
+
+// WatchFile 加载文件并在文件变更时热更新；新增的徽章只统计更新之后发生的事件
+func (s *Service) WatchFile(path string) error {
+	load := func() (bool, error) {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		return s.Load(raw)
+	}
+	if _, err := load(); err != nil {
+		return err
+	}
+	_, err := gfsnotify.Add(path, func(e *gfsnotify.Event) {
+		if e.IsWrite() || e.IsCreate() || e.IsRename() {
+			ctx := context.Background()
+			changed, err := load()
+			if err != nil {
+				s.logger.Error(ctx, "badge definitions reload failed:", path, err.Error())
+				return
+			}
+			if changed {
+				s.logger.Info(ctx, "badge definitions reloaded from file:", path)
+			}
+		}
+	})
+	return err
+}
+
+func (s *Service) Definitions() *Definitions {
+	return s.defs.Load()
+}
+
+// OnEvent 注册为服务端事件监听器
+func (s *Service) OnEvent(ctx context.Context, e *event.UserEvent) {
+	s.observe(ctx, e, false)
+}
+
+// Write 让 Service 可以作为埋点管道的 Sink，只统计 source 为 any 的规则；
+// 进度已部分累加时重试会重复计数，因此失败只记录日志不返回错误
+func (s *Service) Write(ctx context.Context, events []event.UserEvent) error {
+	for i := range events {
+		s.observe(ctx, &events[i], true)
+	}
+	return nil
+}
+
+func (s *Service) Close() error {
+	return nil
+}
+
+// OnPointsChanged 注册为积分变动监听器，累计积分达到门槛时发放 points 类徽章
+func (s *Service) OnPointsChanged(ctx context.Context, account *points.Account, entry *points.Entry) {
+	defs := s.defs.Load()
+	var reached []*Badge
+	for i := range defs.Badges {
+		b := &defs.Badges[i]
+		if b.Rule.Type == RulePoints && account.Lifetime >= b.Rule.Threshold {
+			reached = append(reached, b)
+		}
+	}
+	if len(reached) == 0 {
+		return
+	}
+	earned, err := s.earnedKeys(account.UserID)
+	if err != nil {
+		s.logger.Error(ctx, "badge earned lookup failed:", account.UserID, err.Error())
+		return
+	}
+	for _, b := range reached {
+		if !earned[b.Key] {
+			s.award(ctx, b, account.UserID)
+		}
+	}
+}
+
+func (s *Service) observe(ctx context.Context, e *event.UserEvent, client bool) {
+	if e.UserID == 0 {
+		return
+	}
+	var candidates []*Badge
+	for _, b := range s.defs.Load().byEvent[e.Name] {
+		if b.matches(e, client) {
+			candidates = append(candidates, b)
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	ctx, span := gtrace.NewSpan(ctx, "Badge.Observe")
+	defer span.End()
+
+	earned, err := s.earnedKeys(e.UserID)
+	if err != nil {
+		s.logger.Error(ctx, "badge earned lookup failed:", e.UserID, err.Error())
+		return
+	}
+	at := e.CreatedAt
+	if at.IsZero() {
+		at = s.now()
+	}
+	for _, b := range candidates {
+		if earned[b.Key] {
+			continue
+		}
+		progress, err := s.repo.Advance(e.UserID, b.Key, at)
+		if err != nil {
+			s.logger.Error(ctx, "badge progress update failed:", e.UserID, b.Key, err.Error())
+			continue
+		}
+		if b.satisfied(progress) {
+			s.award(ctx, b, e.UserID)
+		}
+	}
+}
+
+func (s *Service) earnedKeys(userID uint) (map[string]bool, error) {
+	badges, err := s.repo.Earned(userID)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool, len(badges))
+	for _, b := range badges {
+		keys[b.BadgeKey] = true
+	}
+	return keys, nil
+}
+
+// award 先按幂等键发放积分再记录徽章，中途失败时下一次满足条件的事件会补发，积分不会重复
+func (s *Service) award(ctx context.Context, b *Badge, userID uint) {
+	if b.Points > 0 {
+		_, err := s.points.Award(ctx, userID, b.Points, ReasonBadge, fmt.Sprintf("%s:%d", b.Key, userID))
+		if err != nil && !errors.Is(err, points.ErrDuplicateEntry) {
+			s.logger.Error(ctx, "badge points award failed:", userID, b.Key, err.Error())
+			return
+		}
+	}
+	created, err := s.repo.Award(&UserBadge{UserID: userID, BadgeKey: b.Key, Points: b.Points, EarnedAt: s.now()})
+	if err != nil {
+		s.logger.Error(ctx, "badge award failed:", userID, b.Key, err.Error())
+		return
+	}
+	if !created {
+		return
+	}
+	s.logger.Info(ctx, "Badge earned:", b.Key, "userid:", userID)
+	if err = s.events.Record(ctx, userID, NameEarned, g.Map{"badge": b.Key, "points": b.Points}); err != nil {
+		s.logger.Error(ctx, "badge event record failed:", userID, err.Error())
+	}
+}
+
+// View 是徽章列表中的一项，未获得的徽章附带当前进度
+type View struct {
+	Key         string     `json:"key"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Icon        string     `json:"icon"`
+	Points      int64      `json:"points"`
+	Earned      bool       `json:"earned"`
+	EarnedAt    *time.Time `json:"earned_at"`
+	Progress    int64      `json:"progress"`
+	Target      int64      `json:"target"`
+}
+
+// List 按定义顺序返回全部徽章；已获得但定义已删除的徽章排在最后
+func (s *Service) List(ctx context.Context, userID uint) ([]View, error) {
+	defs := s.defs.Load()
+	earned, err := s.repo.Earned(userID)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := s.repo.ListProgress(userID)
+	if err != nil {
+		return nil, err
+	}
+	earnedAt := make(map[string]time.Time, len(earned))
+	for _, e := range earned {
+		earnedAt[e.BadgeKey] = e.EarnedAt
+	}
+	progressOf := make(map[string]Progress, len(progress))
+	for _, p := range progress {
+		progressOf[p.BadgeKey] = p
+	}
+	var account *points.Account
+
+	views := make([]View, 0, len(defs.Badges))
+	for i := range defs.Badges {
+		b := &defs.Badges[i]
+		v := View{Key: b.Key, Name: b.Name, Description: b.Description, Icon: b.Icon, Points: b.Points, Target: b.Rule.Threshold}
+		if at, ok := earnedAt[b.Key]; ok {
+			v.Earned, v.EarnedAt, v.Progress = true, &at, b.Rule.Threshold
+			delete(earnedAt, b.Key)
+			views = append(views, v)
+			continue
+		}
+		switch b.Rule.Type {
+		case RuleCount:
+			v.Progress = progressOf[b.Key].Count
+		case RuleStreak:
+			v.Progress = s.currentStreak(progressOf[b.Key])
+		case RulePoints:
+			if account == nil {
+				if account, err = s.points.Account(userID); err != nil {
+					return nil, err
+				}
+			}
+			v.Progress = min(account.Lifetime, b.Rule.Threshold)
+		}
+		views = append(views, v)
+	}
+	for _, e := range earned {
+		if at, ok := earnedAt[e.BadgeKey]; ok {
+			views = append(views, View{Key: e.BadgeKey, Points: e.Points, Earned: true, EarnedAt: &at})
+		}
+	}
+	return views, nil
+}
+
+// currentStreak 最后一天早于昨天时连续已中断，展示为 0
+func (s *Service) currentStreak(p Progress) int64 {
+	if p.LastDay == nil {
+		return 0
+	}
+	y, m, d := s.now().Date()
+	yesterday := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1).Format(time.DateOnly)
+	if p.LastDay.Format(time.DateOnly) < yesterday {
+		return 0
+	}
+	return p.Streak
+}
@@ -0,0 +1,167 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+	config "usergrowth/configs"
+	"usergrowth/internal/logs"
+
+	goredis "github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrQueueFull = errors.New("bus queue full")
+	ErrClosed    = errors.New("bus closed")
+	// ErrPermanent 包装的错误不再重试，直接转入死信
+	ErrPermanent = errors.New("permanent failure")
+)
+
+// Permanent 标记无法通过重试恢复的错误，如消息格式错误
+func Permanent(err error) error {
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+// Envelope 是总线上传递的消息，Trace 携带发布方的 trace 上下文
+type Envelope struct {
+	ID          string            `json:"id"` // 由后端分配
+	Topic       string            `json:"topic"`
+	Payload     json.RawMessage   `json:"payload"`
+	Trace       map[string]string `json:"trace,omitempty"`
+	PublishedAt time.Time         `json:"published_at"`
+	Attempt     int               `json:"-"` // 本次为第几次投递，从 1 开始
+}
+
+// Handler 返回 nil 时确认消息，返回错误时在 RetryDelay 后重新投递，超过 MaxAttempts 转入死信
+type Handler func(ctx context.Context, env *Envelope) error
+
+// EventBus 是模块间的异步消息通道，投递语义为至少一次，处理方需要幂等
+type EventBus interface {
+	Publish(ctx context.Context, topic string, payload any) error
+	// Subscribe 需在 Start 前调用；同一 group 内的订阅竞争消费，不同 group 各自收到全部消息
+	Subscribe(topic, group string, handler Handler)
+	Start(ctx context.Context) error
+	// Close 停止消费并等待处理中的消息结束
+	Close() error
+}
+
+type Options struct {
+	MaxAttempts int
+	RetryDelay  time.Duration
+	Batch       int           // redis 单次读取条数
+	Block       time.Duration // redis 阻塞读取的超时
+	MaxLen      int64         // redis stream 保留的近似长度
+	Consumer    string        // redis 消费者名，缺省为 主机名-进程号
+	QueueSize   int           // memory 每个 group 的队列容量
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = 30 * time.Second
+	}
+	if o.Batch <= 0 {
+		o.Batch = 16
+	}
+	if o.Block <= 0 {
+		o.Block = 2 * time.Second
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1024
+	}
+	if o.Consumer == "" {
+		host, _ := os.Hostname()
+		o.Consumer = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return o
+}
+
+// New 按配置创建事件总线，memory 只在单个进程内投递，适用于测试与单实例部署
+func New(cfg *config.BusConfig, rdb goredis.Cmdable, logger logs.Logger) (EventBus, error) {
+	opts := Options{
+		MaxAttempts: cfg.MaxAttempts,
+		RetryDelay:  cfg.RetryDelay,
+		Batch:       cfg.Batch,
+		Block:       cfg.Block,
+		MaxLen:      cfg.MaxLen,
+		Consumer:    cfg.Consumer,
+		QueueSize:   cfg.QueueSize,
+	}
+	switch cfg.Backend {
+	case "redis":
+		return NewRedis(rdb, opts, logger), nil
+	case "memory":
+		return NewMemory(opts, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown bus backend: %s", cfg.Backend)
+	}
+}
+
+// newEnvelope 序列化负载并注入当前 trace 上下文
+func newEnvelope(ctx context.Context, topic string, payload any) (*Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	env := &Envelope{Topic: topic, Payload: data, Trace: map[string]string{}, PublishedAt: time.Now()}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(env.Trace))
+	return env, nil
+}
+
+// handle 在发布方 trace 下开启消费 span 并调用 handler，handler panic 视为处理失败
+func handle(ctx context.Context, group string, env *Envelope, handler Handler) (err error) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(env.Trace))
+	ctx, span := otel.Tracer("usergrowth-bus").Start(ctx, "bus.consume "+env.Topic, trace.WithSpanKind(trace.SpanKindConsumer))
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("messaging.destination", env.Topic),
+		attribute.String("messaging.consumer_group", group),
+		attribute.String("messaging.message_id", env.ID),
+		attribute.Int("messaging.attempt", env.Attempt),
+	)
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("handler panic: %v", p)
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}()
+	return handler(ctx, env)
+}
+
+// Topic 把主题名与负载类型绑定，发布与订阅两端共用同一个声明
+type Topic[T any] struct {
+	Name string
+}
+
+func NewTopic[T any](name string) Topic[T] {
+	return Topic[T]{Name: name}
+}
+
+func (t Topic[T]) Publish(ctx context.Context, b EventBus, payload *T) error {
+	return b.Publish(ctx, t.Name, payload)
+}
+
+// Subscribe 负载无法解析时不重试
+func (t Topic[T]) Subscribe(b EventBus, group string, handler func(ctx context.Context, payload *T) error) {
+	b.Subscribe(t.Name, group, func(ctx context.Context, env *Envelope) error {
+		var payload T
+		if err := json.Unmarshal(env.Payload, &payload); err != nil {
+			return Permanent(err)
+		}
+		return handler(ctx, &payload)
+	})
+}
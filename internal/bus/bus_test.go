@@ -0,0 +1,162 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type signup struct {
+	UserID uint   `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+var topicSignup = NewTopic[signup]("test.signup")
+
+func newTestBus(t *testing.T) *Memory {
+	b := NewMemory(Options{MaxAttempts: 3, RetryDelay: 10 * time.Millisecond, QueueSize: 8}, logs.Nop())
+	t.Cleanup(func() { _ = b.Close() })
+	return b
+}
+
+func TestMemoryFanOut(t *testing.T) {
+	b := newTestBus(t)
+	var mu sync.Mutex
+	got := map[string][]uint{}
+	record := func(group string) func(ctx context.Context, s *signup) error {
+		return func(ctx context.Context, s *signup) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got[group] = append(got[group], s.UserID)
+			return nil
+		}
+	}
+	topicSignup.Subscribe(b, "a", record("a"))
+	topicSignup.Subscribe(b, "b", record("b"))
+	// 同一 group 的两个订阅竞争消费，每条消息只处理一次
+	topicSignup.Subscribe(b, "b", record("b"))
+	assert.NoError(t, b.Start(context.Background()))
+
+	for i := uint(1); i <= 4; i++ {
+		assert.NoError(t, topicSignup.Publish(context.Background(), b, &signup{UserID: i}))
+	}
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got["a"]) == 4 && len(got["b"]) == 4
+	}, time.Second, 5*time.Millisecond)
+	mu.Lock()
+	assert.ElementsMatch(t, []uint{1, 2, 3, 4}, got["b"])
+	mu.Unlock()
+
+	// 没有订阅的主题直接丢弃
+	assert.NoError(t, b.Publish(context.Background(), "test.none", 1))
+}
+
+func TestMemoryRetry(t *testing.T) {
+	b := newTestBus(t)
+	var attempts []int
+	var calls atomic.Int32
+	b.Subscribe("test.retry", "g", func(ctx context.Context, env *Envelope) error {
+		attempts = append(attempts, env.Attempt)
+		if calls.Add(1) < 3 {
+			return errors.New("db down")
+		}
+		return nil
+	})
+	assert.NoError(t, b.Start(context.Background()))
+	assert.NoError(t, b.Publish(context.Background(), "test.retry", "x"))
+
+	assert.Eventually(t, func() bool { return calls.Load() == 3 }, time.Second, 5*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, []int{1, 2, 3}, attempts)
+	assert.Empty(t, b.DeadLetters())
+}
+
+func TestMemoryDeadLetter(t *testing.T) {
+	b := newTestBus(t)
+	var failing, panicking, bad atomic.Int32
+	b.Subscribe("test.fail", "g", func(ctx context.Context, env *Envelope) error {
+		failing.Add(1)
+		return errors.New("always")
+	})
+	b.Subscribe("test.panic", "g", func(ctx context.Context, env *Envelope) error {
+		panicking.Add(1)
+		panic("boom")
+	})
+	// 负载无法解析为 signup，不重试
+	topicSignup.Subscribe(b, "g", func(ctx context.Context, s *signup) error {
+		bad.Add(1)
+		return nil
+	})
+	assert.NoError(t, b.Start(context.Background()))
+	assert.NoError(t, b.Publish(context.Background(), "test.fail", 1))
+	assert.NoError(t, b.Publish(context.Background(), "test.panic", 2))
+	assert.NoError(t, b.Publish(context.Background(), topicSignup.Name, "not an object"))
+
+	assert.Eventually(t, func() bool { return len(b.DeadLetters()) == 3 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(3), failing.Load())
+	assert.Equal(t, int32(3), panicking.Load())
+	assert.Equal(t, int32(0), bad.Load())
+
+	topics := map[string]int{}
+	for _, env := range b.DeadLetters() {
+		topics[env.Topic] = env.Attempt
+	}
+	assert.Equal(t, map[string]int{"test.fail": 3, "test.panic": 3, topicSignup.Name: 1}, topics)
+}
+
+func TestMemoryTracePropagation(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(prev)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	b := newTestBus(t)
+	got := make(chan trace.TraceID, 1)
+	var envTrace map[string]string
+	b.Subscribe("test.trace", "g", func(ctx context.Context, env *Envelope) error {
+		envTrace = env.Trace
+		got <- trace.SpanContextFromContext(ctx).TraceID()
+		return nil
+	})
+	assert.NoError(t, b.Start(context.Background()))
+	assert.NoError(t, b.Publish(ctx, "test.trace", 1))
+
+	select {
+	case id := <-got:
+		assert.Equal(t, traceID, id)
+		assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", envTrace["traceparent"])
+	case <-time.After(time.Second):
+		t.Fatal("message not delivered")
+	}
+}
+
+func TestMemoryQueueFullAndClose(t *testing.T) {
+	b := NewMemory(Options{QueueSize: 1}, logs.Nop())
+	b.Subscribe("test.full", "g", func(ctx context.Context, env *Envelope) error { return nil })
+	// 未启动时消息堆积在队列中
+	assert.NoError(t, b.Publish(context.Background(), "test.full", 1))
+	assert.ErrorIs(t, b.Publish(context.Background(), "test.full", 2), ErrQueueFull)
+
+	assert.NoError(t, b.Close())
+	assert.ErrorIs(t, b.Publish(context.Background(), "test.full", 3), ErrClosed)
+	assert.NoError(t, b.Close())
+}
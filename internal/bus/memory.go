@@ -0,0 +1,160 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"usergrowth/internal/logs"
+)
+
+type memorySub struct {
+	topic   string
+	group   string
+	handler Handler
+}
+
+// Memory 在进程内投递消息，每个 group 一个有界队列，进程退出时未处理的消息丢失
+type Memory struct {
+	opts    Options
+	mu      sync.Mutex
+	subs    []memorySub
+	queues  map[string]map[string]chan *Envelope // topic -> group -> 队列
+	dead    []*Envelope
+	seq     atomic.Uint64
+	started bool
+	closed  bool
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	logger  logs.Logger
+}
+
+func NewMemory(opts Options, logger logs.Logger) *Memory {
+	return &Memory{
+		opts:   opts.withDefaults(),
+		queues: make(map[string]map[string]chan *Envelope),
+		logger: logger,
+	}
+}
+
+func (m *Memory) Subscribe(topic, group string, handler Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subs = append(m.subs, memorySub{topic: topic, group: group, handler: handler})
+	if m.queues[topic] == nil {
+		m.queues[topic] = make(map[string]chan *Envelope)
+	}
+	if m.queues[topic][group] == nil {
+		m.queues[topic][group] = make(chan *Envelope, m.opts.QueueSize)
+	}
+}
+
+// Publish 向主题的每个 group 各投递一份，任一队列已满时返回 ErrQueueFull，已投递的不会撤回
+func (m *Memory) Publish(ctx context.Context, topic string, payload any) error {
+	env, err := newEnvelope(ctx, topic, payload)
+	if err != nil {
+		return err
+	}
+	env.ID = strconv.FormatUint(m.seq.Add(1), 10)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	for _, queue := range m.queues[topic] {
+		copied := *env
+		copied.Attempt = 1
+		select {
+		case queue <- &copied:
+		default:
+			return ErrQueueFull
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.started {
+		return nil
+	}
+	m.started = true
+	ctx, m.cancel = context.WithCancel(ctx)
+	for _, sub := range m.subs {
+		m.wg.Add(1)
+		go m.consume(ctx, sub, m.queues[sub.topic][sub.group])
+	}
+	return nil
+}
+
+func (m *Memory) consume(ctx context.Context, sub memorySub, queue chan *Envelope) {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case env := <-queue:
+			err := handle(ctx, sub.group, env, sub.handler)
+			if err == nil {
+				continue
+			}
+			if errors.Is(err, ErrPermanent) || env.Attempt >= m.opts.MaxAttempts {
+				m.deadLetter(ctx, sub.group, env, err)
+				continue
+			}
+			m.logger.Error(ctx, "bus handler failed, will retry:", sub.topic, sub.group, env.ID, "attempt:", env.Attempt, err.Error())
+			next := *env
+			next.Attempt++
+			time.AfterFunc(m.opts.RetryDelay, func() {
+				m.requeue(ctx, sub.group, queue, &next)
+			})
+		}
+	}
+}
+
+func (m *Memory) requeue(ctx context.Context, group string, queue chan *Envelope, env *Envelope) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return
+	}
+	select {
+	case queue <- env:
+	default:
+		m.dead = append(m.dead, env)
+		m.logger.Error(ctx, "bus retry dropped, queue full:", env.Topic, group, env.ID)
+	}
+}
+
+func (m *Memory) deadLetter(ctx context.Context, group string, env *Envelope, err error) {
+	m.mu.Lock()
+	m.dead = append(m.dead, env)
+	m.mu.Unlock()
+	m.logger.Error(ctx, "bus message dead-lettered:", env.Topic, group, env.ID, "attempt:", env.Attempt, err.Error())
+}
+
+// DeadLetters 返回重试耗尽或无法处理的消息
+func (m *Memory) DeadLetters() []*Envelope {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Envelope(nil), m.dead...)
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+	return nil
+}
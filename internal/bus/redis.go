@@ -0,0 +1,223 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"usergrowth/internal/logs"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const envelopeField = "envelope"
+
+func streamKey(topic string) string {
+	return "bus:" + topic
+}
+
+func deadKey(topic string) string {
+	return "bus:" + topic + ":dead"
+}
+
+type redisSub struct {
+	topic   string
+	group   string
+	handler Handler
+}
+
+// Redis 基于 Redis Streams 与消费组实现，未确认的消息留在 PEL 中，
+// 空闲超过 RetryDelay 后由任一实例认领重试，进程崩溃不会丢消息
+type Redis struct {
+	rdb     goredis.Cmdable
+	opts    Options
+	subs    []redisSub
+	started bool
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	logger  logs.Logger
+}
+
+func NewRedis(rdb goredis.Cmdable, opts Options, logger logs.Logger) *Redis {
+	return &Redis{rdb: rdb, opts: opts.withDefaults(), logger: logger}
+}
+
+func (b *Redis) Subscribe(topic, group string, handler Handler) {
+	b.subs = append(b.subs, redisSub{topic: topic, group: group, handler: handler})
+}
+
+func (b *Redis) Publish(ctx context.Context, topic string, payload any) error {
+	env, err := newEnvelope(ctx, topic, payload)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	args := &goredis.XAddArgs{Stream: streamKey(topic), Values: map[string]any{envelopeField: data}}
+	if b.opts.MaxLen > 0 {
+		args.MaxLen = b.opts.MaxLen
+		args.Approx = true
+	}
+	return b.rdb.XAdd(ctx, args).Err()
+}
+
+// Start 为每个订阅创建消费组（已存在时沿用），新建的组只消费此后发布的消息
+func (b *Redis) Start(ctx context.Context) error {
+	if b.started {
+		return nil
+	}
+	for _, sub := range b.subs {
+		err := b.rdb.XGroupCreateMkStream(ctx, streamKey(sub.topic), sub.group, "$").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return err
+		}
+	}
+	b.started = true
+	ctx, b.cancel = context.WithCancel(ctx)
+	for _, sub := range b.subs {
+		b.wg.Add(2)
+		go b.consume(ctx, sub)
+		go b.reclaim(ctx, sub)
+	}
+	return nil
+}
+
+func (b *Redis) consume(ctx context.Context, sub redisSub) {
+	defer b.wg.Done()
+	for ctx.Err() == nil {
+		streams, err := b.rdb.XReadGroup(ctx, &goredis.XReadGroupArgs{
+			Group:    sub.group,
+			Consumer: b.opts.Consumer,
+			Streams:  []string{streamKey(sub.topic), ">"},
+			Count:    int64(b.opts.Batch),
+			Block:    b.opts.Block,
+		}).Result()
+		if err != nil {
+			if !errors.Is(err, goredis.Nil) && ctx.Err() == nil {
+				b.logger.Error(ctx, "bus read failed:", sub.topic, sub.group, err.Error())
+				sleep(ctx, time.Second)
+			}
+			continue
+		}
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				b.process(ctx, sub, msg, 1)
+			}
+		}
+	}
+}
+
+// reclaim 认领空闲超过 RetryDelay 的未确认消息，包括本实例处理失败的与其他实例崩溃遗留的
+func (b *Redis) reclaim(ctx context.Context, sub redisSub) {
+	defer b.wg.Done()
+	interval := max(b.opts.RetryDelay/2, time.Second)
+	for sleep(ctx, interval) {
+		pending, err := b.rdb.XPendingExt(ctx, &goredis.XPendingExtArgs{
+			Stream: streamKey(sub.topic),
+			Group:  sub.group,
+			Idle:   b.opts.RetryDelay,
+			Start:  "-",
+			End:    "+",
+			Count:  int64(b.opts.Batch),
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				b.logger.Error(ctx, "bus pending scan failed:", sub.topic, sub.group, err.Error())
+			}
+			continue
+		}
+		if len(pending) == 0 {
+			continue
+		}
+		deliveries := make(map[string]int64, len(pending))
+		ids := make([]string, 0, len(pending))
+		for _, p := range pending {
+			deliveries[p.ID] = p.RetryCount
+			ids = append(ids, p.ID)
+		}
+		// 多个实例同时认领时只有一个成功，MinIdle 保证不会抢走刚被认领的消息
+		msgs, err := b.rdb.XClaim(ctx, &goredis.XClaimArgs{
+			Stream:   streamKey(sub.topic),
+			Group:    sub.group,
+			Consumer: b.opts.Consumer,
+			MinIdle:  b.opts.RetryDelay,
+			Messages: ids,
+		}).Result()
+		if err != nil {
+			b.logger.Error(ctx, "bus claim failed:", sub.topic, sub.group, err.Error())
+			continue
+		}
+		for _, msg := range msgs {
+			b.process(ctx, sub, msg, int(deliveries[msg.ID])+1)
+		}
+	}
+}
+
+func (b *Redis) process(ctx context.Context, sub redisSub, msg goredis.XMessage, attempt int) {
+	env := &Envelope{}
+	raw, _ := msg.Values[envelopeField].(string)
+	if err := json.Unmarshal([]byte(raw), env); err != nil {
+		b.deadLetter(ctx, sub, msg.ID, raw, Permanent(err))
+		return
+	}
+	env.ID = msg.ID
+	env.Attempt = attempt
+	if attempt > b.opts.MaxAttempts {
+		b.deadLetter(ctx, sub, msg.ID, raw, errors.New("max attempts exceeded"))
+		return
+	}
+
+	err := handle(ctx, sub.group, env, sub.handler)
+	switch {
+	case err == nil:
+		if err = b.rdb.XAck(ctx, streamKey(sub.topic), sub.group, msg.ID).Err(); err != nil {
+			b.logger.Error(ctx, "bus ack failed:", sub.topic, sub.group, msg.ID, err.Error())
+		}
+	case errors.Is(err, ErrPermanent) || attempt >= b.opts.MaxAttempts:
+		b.deadLetter(ctx, sub, msg.ID, raw, err)
+	default:
+		// 不确认，留在 PEL 中等待 reclaim
+		b.logger.Error(ctx, "bus handler failed, will retry:", sub.topic, sub.group, msg.ID, "attempt:", attempt, err.Error())
+	}
+}
+
+// deadLetter 把消息连同失败原因写入死信 stream 后确认原消息
+func (b *Redis) deadLetter(ctx context.Context, sub redisSub, id, raw string, cause error) {
+	b.logger.Error(ctx, "bus message dead-lettered:", sub.topic, sub.group, id, cause.Error())
+	err := b.rdb.XAdd(ctx, &goredis.XAddArgs{
+		Stream: deadKey(sub.topic),
+		Values: map[string]any{envelopeField: raw, "group": sub.group, "id": id, "error": cause.Error()},
+	}).Err()
+	if err != nil {
+		// 死信写入失败时不确认，下次认领再试
+		b.logger.Error(ctx, "bus dead letter write failed:", sub.topic, sub.group, id, err.Error())
+		return
+	}
+	if err = b.rdb.XAck(ctx, streamKey(sub.topic), sub.group, id).Err(); err != nil {
+		b.logger.Error(ctx, "bus ack failed:", sub.topic, sub.group, id, err.Error())
+	}
+}
+
+func (b *Redis) Close() error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.wg.Wait()
+	return nil
+}
+
+// sleep 等待 d 或 ctx 结束，返回 false 表示 ctx 已结束
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
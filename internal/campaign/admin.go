@@ -0,0 +1,233 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+type CreateCampaignReq struct {
+	g.Meta        `path:"/api/admin/campaigns" method:"post"`
+	Key           string  `json:"key" v:"required|regex:^[a-z0-9_]{1,64}$#活动标识不能为空|活动标识只能包含小写字母、数字和下划线"`
+	Name          string  `json:"name" v:"required|max-length:255#活动名称不能为空|活动名称过长"`
+	StartAt       string  `json:"start_at" v:"required|datetime#开始时间不能为空|开始时间格式应为YYYY-MM-DD HH:mm:ss"` // 按配置的时区解析
+	EndAt         string  `json:"end_at" v:"required|datetime#结束时间不能为空|结束时间格式应为YYYY-MM-DD HH:mm:ss"`
+	SegmentID     uint    `json:"segment_id"`
+	RewardType    string  `json:"reward_type" v:"required|in:points,points_multiplier#奖励类型不能为空|奖励类型应为points或points_multiplier"`
+	RewardPoints  int64   `json:"reward_points" v:"min:0"`
+	Multiplier    float64 `json:"multiplier" v:"min:0"`
+	GlobalBudget  int64   `json:"global_budget" v:"min:0"`
+	PerUserBudget int64   `json:"per_user_budget" v:"min:0"`
+}
+
+type CreateCampaignRes struct {
+}
+
+type ListCampaignReq struct {
+	g.Meta `path:"/api/admin/campaigns" method:"get"`
+}
+
+type ListCampaignRes struct {
+}
+
+type PauseCampaignReq struct {
+	g.Meta     `path:"/api/admin/campaigns/{id}/pause" method:"post"`
+	CampaignID uint `p:"id" v:"required"`
+}
+
+type PauseCampaignRes struct {
+}
+
+type ResumeCampaignReq struct {
+	g.Meta     `path:"/api/admin/campaigns/{id}/resume" method:"post"`
+	CampaignID uint `p:"id" v:"required"`
+}
+
+type ResumeCampaignRes struct {
+}
+
+type CampaignStatusReq struct {
+	g.Meta     `path:"/api/admin/campaigns/{id}/status" method:"get"`
+	CampaignID uint `p:"id" v:"required"`
+}
+
+type CampaignStatusRes struct {
+}
+
+type Admin struct {
+	repo       CampaignRepository
+	service    *Service
+	userLogger logs.Logger
+}
+
+func NewAdmin(repo CampaignRepository, service *Service, logger logs.Logger) *Admin {
+	return &Admin{
+		repo:       repo,
+		service:    service,
+		userLogger: logger,
+	}
+}
+
+// localize 把时间转换到活动时区用于展示
+func localize(c *Campaign, loc *time.Location) *Campaign {
+	c.StartAt = c.StartAt.In(loc)
+	c.EndAt = c.EndAt.In(loc)
+	return c
+}
+
+func (params *Admin) findCampaign(campaignID uint) (*Campaign, error) {
+	c, err := params.repo.Find(campaignID)
+	if err != nil {
+		if errors.Is(err, ErrCampaignNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "活动不存在")
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+func (params *Admin) Create(ctx context.Context, req *CreateCampaignReq) (res *CreateCampaignRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Campaign.Create")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	loc := params.service.Location()
+	startAt, err := time.ParseInLocation(time.DateTime, req.StartAt, loc)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "开始时间格式应为YYYY-MM-DD HH:mm:ss")
+	}
+	endAt, err := time.ParseInLocation(time.DateTime, req.EndAt, loc)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "结束时间格式应为YYYY-MM-DD HH:mm:ss")
+	}
+	if !endAt.After(startAt) {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "结束时间必须晚于开始时间")
+	}
+	switch req.RewardType {
+	case RewardPoints:
+		if req.RewardPoints <= 0 {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "奖励积分必须大于0")
+		}
+		req.Multiplier = 0
+	case RewardMultiplier:
+		if req.Multiplier <= 1 {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "奖励倍数必须大于1")
+		}
+		req.RewardPoints = 0
+	}
+
+	c := &Campaign{
+		Key:           req.Key,
+		Name:          req.Name,
+		Status:        StatusRunning,
+		StartAt:       startAt,
+		EndAt:         endAt,
+		SegmentID:     req.SegmentID,
+		RewardType:    req.RewardType,
+		RewardPoints:  req.RewardPoints,
+		Multiplier:    req.Multiplier,
+		GlobalBudget:  req.GlobalBudget,
+		PerUserBudget: req.PerUserBudget,
+	}
+	if err = params.repo.Create(c); err != nil {
+		return nil, err
+	}
+	if err = params.service.SetStatus(ctx, c, StatusRunning); err != nil {
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Campaign created:", c.CampaignID, c.Key, c.StartAt, "-", c.EndAt)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "campaign created",
+		"data":    localize(c, loc),
+	})
+	return nil, nil
+}
+
+func (params *Admin) List(ctx context.Context, req *ListCampaignReq) (res *ListCampaignRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	campaigns, err := params.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	loc := params.service.Location()
+	for i := range campaigns {
+		localize(&campaigns[i], loc)
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    campaigns,
+	})
+	return nil, nil
+}
+
+func (params *Admin) setStatus(ctx context.Context, campaignID uint, status string) error {
+	r := g.RequestFromCtx(ctx)
+
+	c, err := params.findCampaign(campaignID)
+	if err != nil {
+		return err
+	}
+	if err = params.service.SetStatus(ctx, c, status); err != nil {
+		return err
+	}
+
+	params.userLogger.Info(ctx, "Campaign status changed:", c.CampaignID, c.Key, status)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "campaign " + status,
+		"data":    localize(c, params.service.Location()),
+	})
+	return nil
+}
+
+func (params *Admin) Pause(ctx context.Context, req *PauseCampaignReq) (res *PauseCampaignRes, err error) {
+	return nil, params.setStatus(ctx, req.CampaignID, StatusPaused)
+}
+
+func (params *Admin) Resume(ctx context.Context, req *ResumeCampaignReq) (res *ResumeCampaignRes, err error) {
+	return nil, params.setStatus(ctx, req.CampaignID, StatusRunning)
+}
+
+// Status 展示活动状态以及预算消耗
+func (params *Admin) Status(ctx context.Context, req *CampaignStatusReq) (res *CampaignStatusRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	c, err := params.findCampaign(req.CampaignID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := params.service.budget.Usage(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	data := g.Map{
+		"campaign":        localize(c, params.service.Location()),
+		"state":           State(c, usage, time.Now()),
+		"spent":           usage.Spent,
+		"participants":    usage.Participants,
+		"global_budget":   c.GlobalBudget,
+		"per_user_budget": c.PerUserBudget,
+	}
+	if c.GlobalBudget > 0 {
+		data["remaining"] = max(c.GlobalBudget-usage.Spent, 0)
+		data["spent_ratio"] = float64(usage.Spent) / float64(c.GlobalBudget)
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    data,
+	})
+	return nil, nil
+}
@@ -0,0 +1,128 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrNotInWindow          = errors.New("campaign not in time window")
+	ErrCampaignPaused       = errors.New("campaign paused")
+	ErrGlobalBudgetExceeded = errors.New("campaign budget exhausted")
+	ErrUserBudgetExceeded   = errors.New("campaign user budget exhausted")
+)
+
+// key 使用 {id} 作为 hash tag，集群模式下同一活动的 key 落在同一 slot，Lua 脚本可原子操作
+func stateKey(campaignID uint) string {
+	return fmt.Sprintf("campaign:{%d}:state", campaignID)
+}
+
+func spentKey(campaignID uint) string {
+	return fmt.Sprintf("campaign:{%d}:spent", campaignID)
+}
+
+func usersKey(campaignID uint) string {
+	return fmt.Sprintf("campaign:{%d}:users", campaignID)
+}
+
+// reserveScript 在一个脚本内检查时间窗口、暂停状态与两级预算并扣减，所有实例共享同一份状态。
+// partial 为 1 时允许按剩余预算部分发放（倍数奖励），为 0 时不足额直接拒绝（固定奖励）
+var reserveScript = goredis.NewScript(`
+local now = tonumber(ARGV[6])
+if now < tonumber(ARGV[7]) or now >= tonumber(ARGV[8]) then
+	return -1
+end
+if redis.call('HGET', KEYS[1], 'status') == 'paused' then
+	return -2
+end
+local amount = tonumber(ARGV[2])
+local globalBudget = tonumber(ARGV[3])
+local userBudget = tonumber(ARGV[4])
+local spent = tonumber(redis.call('GET', KEYS[2]) or '0')
+local used = tonumber(redis.call('HGET', KEYS[3], ARGV[1]) or '0')
+local allowed = amount
+local limitedBy = 0
+if globalBudget > 0 and globalBudget - spent < allowed then
+	allowed = globalBudget - spent
+	limitedBy = -3
+end
+if userBudget > 0 and userBudget - used < allowed then
+	allowed = userBudget - used
+	limitedBy = -4
+end
+if allowed <= 0 or (allowed < amount and ARGV[5] == '0') then
+	return limitedBy
+end
+redis.call('INCRBY', KEYS[2], allowed)
+redis.call('HINCRBY', KEYS[3], ARGV[1], allowed)
+return allowed
+`)
+
+// Budget 管理活动在 Redis 中的预算与暂停状态
+type Budget struct {
+	rdb goredis.Cmdable
+}
+
+func NewBudget(rdb goredis.Cmdable) *Budget {
+	return &Budget{rdb: rdb}
+}
+
+// Reserve 预留 amount 积分，返回实际预留的数量
+func (b *Budget) Reserve(ctx context.Context, c *Campaign, userID uint, amount int64, partial bool, now time.Time) (int64, error) {
+	partialArg := "0"
+	if partial {
+		partialArg = "1"
+	}
+	res, err := reserveScript.Run(ctx, b.rdb,
+		[]string{stateKey(c.CampaignID), spentKey(c.CampaignID), usersKey(c.CampaignID)},
+		strconv.FormatUint(uint64(userID), 10), amount, c.GlobalBudget, c.PerUserBudget, partialArg,
+		now.Unix(), c.StartAt.Unix(), c.EndAt.Unix()).Int64()
+	if err != nil {
+		return 0, err
+	}
+	switch res {
+	case -1:
+		return 0, ErrNotInWindow
+	case -2:
+		return 0, ErrCampaignPaused
+	case -3:
+		return 0, ErrGlobalBudgetExceeded
+	case -4:
+		return 0, ErrUserBudgetExceeded
+	}
+	return res, nil
+}
+
+// Release 在发放失败时归还预留的预算
+func (b *Budget) Release(ctx context.Context, c *Campaign, userID uint, amount int64) error {
+	pipe := b.rdb.TxPipeline()
+	pipe.DecrBy(ctx, spentKey(c.CampaignID), amount)
+	pipe.HIncrBy(ctx, usersKey(c.CampaignID), strconv.FormatUint(uint64(userID), 10), -amount)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (b *Budget) SetStatus(ctx context.Context, c *Campaign, status string) error {
+	return b.rdb.HSet(ctx, stateKey(c.CampaignID), "status", status).Err()
+}
+
+type Usage struct {
+	Spent        int64 `json:"spent"`
+	Participants int64 `json:"participants"`
+}
+
+func (b *Budget) Usage(ctx context.Context, c *Campaign) (*Usage, error) {
+	pipe := b.rdb.Pipeline()
+	spent := pipe.Get(ctx, spentKey(c.CampaignID))
+	participants := pipe.HLen(ctx, usersKey(c.CampaignID))
+	if _, err := pipe.Exec(ctx); err != nil && err != goredis.Nil {
+		return nil, err
+	}
+	n, _ := spent.Int64()
+	return &Usage{Spent: n, Participants: participants.Val()}, nil
+}
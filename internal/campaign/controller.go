@@ -0,0 +1,136 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type ActiveCampaignsReq struct {
+	g.Meta `path:"/api/campaigns" method:"get"`
+}
+
+type ActiveCampaignsRes struct {
+}
+
+type ClaimReq struct {
+	g.Meta `path:"/api/campaigns/{key}/claim" method:"post"`
+	Key    string `p:"key" v:"required"`
+}
+
+type ClaimRes struct {
+	Campaign string `json:"campaign"`
+	Points   int64  `json:"points"`
+}
+
+type Controller struct {
+	repo       CampaignRepository
+	service    *Service
+	userLogger logs.Logger
+}
+
+func NewController(repo CampaignRepository, service *Service, logger logs.Logger) *Controller {
+	return &Controller{
+		repo:       repo,
+		service:    service,
+		userLogger: logger,
+	}
+}
+
+// Active 返回当前用户可参与的进行中活动
+func (c *Controller) Active(ctx context.Context, req *ActiveCampaignsReq) (res *ActiveCampaignsRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	userid := r.GetCtxVar("userid").String()
+	uid, err := strconv.ParseUint(userid, 10, 64)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+
+	campaigns, err := c.repo.ListActive(time.Now(), "")
+	if err != nil {
+		return nil, err
+	}
+	visible := make([]g.Map, 0, len(campaigns))
+	for i := range campaigns {
+		campaign := &campaigns[i]
+		ok, err := c.service.eligible(ctx, campaign, uint(uid))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		localize(campaign, c.service.Location())
+		visible = append(visible, g.Map{
+			"key":           campaign.Key,
+			"name":          campaign.Name,
+			"start_at":      campaign.StartAt,
+			"end_at":        campaign.EndAt,
+			"reward_type":   campaign.RewardType,
+			"reward_points": campaign.RewardPoints,
+			"multiplier":    campaign.Multiplier,
+		})
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    visible,
+	})
+	return nil, nil
+}
+
+func (c *Controller) Claim(ctx context.Context, req *ClaimReq) (res *ClaimRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Campaign.ClaimHandler")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	userid := r.GetCtxVar("userid").String()
+	uid, err := strconv.ParseUint(userid, 10, 64)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+	span.SetAttributes(attribute.String("user.id", userid), attribute.String("campaign", req.Key))
+
+	campaign, granted, err := c.service.Claim(ctx, req.Key, uint(uid))
+	if err != nil {
+		c.userLogger.Info(ctx, "Campaign claim rejected:", req.Key, "userid:", userid, "reason:", err.Error())
+		switch {
+		case errors.Is(err, ErrCampaignNotFound):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "活动不存在")
+		case errors.Is(err, ErrNotClaimable):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "该活动无需领取")
+		case errors.Is(err, ErrNotEligible):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "不符合活动参与条件")
+		case errors.Is(err, ErrNotInWindow):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "不在活动时间内")
+		case errors.Is(err, ErrCampaignPaused):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "活动已暂停")
+		case errors.Is(err, ErrGlobalBudgetExceeded):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "活动奖励已发完")
+		case errors.Is(err, ErrUserBudgetExceeded), errors.Is(err, ErrAlreadyClaimed):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "已领取过该活动奖励")
+		}
+		return nil, err
+	}
+
+	c.userLogger.Info(ctx, "Campaign claim success:", campaign.Key, "userid:", userid, "points:", granted)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "claim success",
+		"data": &ClaimRes{
+			Campaign: campaign.Key,
+			Points:   granted,
+		},
+	})
+	return nil, nil
+}
@@ -0,0 +1,106 @@
+package campaign
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrCampaignNotFound = errors.New("campaign not found")
+
+// 奖励类型
+const (
+	RewardPoints     = "points"            // 用户领取固定积分，如新人礼包
+	RewardMultiplier = "points_multiplier" // 活动期间获得积分时按倍数额外奖励，如周末双倍积分
+)
+
+// 运营设置的状态，实际是否生效还取决于时间窗口与预算
+const (
+	StatusRunning = "running"
+	StatusPaused  = "paused"
+)
+
+type Campaign struct {
+	CampaignID    uint      `gorm:"primaryKey;autoIncrement" json:"campaign_id"`
+	Key           string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"key"`
+	Name          string    `gorm:"type:varchar(255);not null" json:"name"`
+	Status        string    `gorm:"type:varchar(16);not null;default:running" json:"status"`
+	StartAt       time.Time `gorm:"not null;index:idx_window" json:"start_at"`
+	EndAt         time.Time `gorm:"not null;index:idx_window" json:"end_at"`
+	SegmentID     uint      `gorm:"not null;default:0" json:"segment_id"` // 0 表示全体用户
+	RewardType    string    `gorm:"type:varchar(32);not null" json:"reward_type"`
+	RewardPoints  int64     `gorm:"not null;default:0" json:"reward_points"`   // points 类型每次领取的积分
+	Multiplier    float64   `gorm:"not null;default:0" json:"multiplier"`      // points_multiplier 类型的倍数，2 表示双倍
+	GlobalBudget  int64     `gorm:"not null;default:0" json:"global_budget"`   // 总积分预算，0 表示不限
+	PerUserBudget int64     `gorm:"not null;default:0" json:"per_user_budget"` // 单用户积分上限，0 表示不限
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type campaignRepository struct {
+	db *gorm.DB
+}
+
+type CampaignRepository interface {
+	Create(campaign *Campaign) error
+	Find(campaignID uint) (*Campaign, error)
+	FindByKey(key string) (*Campaign, error)
+	List() ([]Campaign, error)
+	// ListActive 返回时间窗口覆盖 now 且未暂停的活动，rewardType 为空时不过滤
+	ListActive(now time.Time, rewardType string) ([]Campaign, error)
+	UpdateStatus(campaignID uint, status string) error
+}
+
+func NewCampaignRepository(db *gorm.DB) CampaignRepository {
+	if err := db.AutoMigrate(&Campaign{}); err != nil {
+		panic("failed to migrate campaign table")
+	}
+	return &campaignRepository{db: db}
+}
+
+func (repo *campaignRepository) Create(campaign *Campaign) error {
+	return repo.db.Create(campaign).Error
+}
+
+func (repo *campaignRepository) Find(campaignID uint) (*Campaign, error) {
+	var campaign Campaign
+	if err := repo.db.First(&campaign, campaignID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+func (repo *campaignRepository) FindByKey(key string) (*Campaign, error) {
+	var campaign Campaign
+	if err := repo.db.Where("`key` = ?", key).First(&campaign).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+func (repo *campaignRepository) List() ([]Campaign, error) {
+	var campaigns []Campaign
+	err := repo.db.Order("campaign_id DESC").Find(&campaigns).Error
+	return campaigns, err
+}
+
+func (repo *campaignRepository) ListActive(now time.Time, rewardType string) ([]Campaign, error) {
+	var campaigns []Campaign
+	query := repo.db.Where("start_at <= ? AND end_at > ? AND status = ?", now, now, StatusRunning)
+	if rewardType != "" {
+		query = query.Where("reward_type = ?", rewardType)
+	}
+	err := query.Order("campaign_id").Find(&campaigns).Error
+	return campaigns, err
+}
+
+func (repo *campaignRepository) UpdateStatus(campaignID uint, status string) error {
+	return repo.db.Model(&Campaign{}).Where("campaign_id = ?", campaignID).Update("status", status).Error
+}
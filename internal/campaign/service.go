@@ -0,0 +1,167 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/points"
+
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+// 积分流水原因，带 campaign_ 前缀的积分变动不会再次触发倍数奖励
+const (
+	ReasonClaim = "campaign_claim"
+	ReasonBonus = "campaign_bonus"
+)
+
+var (
+	ErrAlreadyClaimed = errors.New("campaign already claimed")
+	ErrNotEligible    = errors.New("user not in campaign segment")
+	ErrNotClaimable   = errors.New("campaign reward is not claimable")
+)
+
+// 展示用的活动状态
+const (
+	StateScheduled = "scheduled"
+	StateRunning   = "running"
+	StatePaused    = "paused"
+	StateEnded     = "ended"
+	StateExhausted = "exhausted"
+)
+
+// SegmentChecker 判断用户是否属于活动的目标分群，由 segment 模块提供
+type SegmentChecker interface {
+	IsMember(ctx context.Context, segmentID, userID uint) (bool, error)
+}
+
+type Service struct {
+	repo     CampaignRepository
+	budget   *Budget
+	points   *points.Service
+	segments SegmentChecker
+	loc      *time.Location
+	logger   logs.Logger
+}
+
+func NewService(repo CampaignRepository, budget *Budget, pointsService *points.Service, segments SegmentChecker, loc *time.Location, logger logs.Logger) *Service {
+	return &Service{
+		repo:     repo,
+		budget:   budget,
+		points:   pointsService,
+		segments: segments,
+		loc:      loc,
+		logger:   logger,
+	}
+}
+
+// Location 返回活动时间使用的时区
+func (s *Service) Location() *time.Location {
+	return s.loc
+}
+
+// State 根据运营状态、时间窗口与预算计算活动当前状态
+func State(c *Campaign, usage *Usage, now time.Time) string {
+	switch {
+	case c.Status == StatusPaused:
+		return StatePaused
+	case now.Before(c.StartAt):
+		return StateScheduled
+	case !now.Before(c.EndAt):
+		return StateEnded
+	case usage != nil && c.GlobalBudget > 0 && usage.Spent >= c.GlobalBudget:
+		return StateExhausted
+	}
+	return StateRunning
+}
+
+func (s *Service) eligible(ctx context.Context, c *Campaign, userID uint) (bool, error) {
+	if c.SegmentID == 0 {
+		return true, nil
+	}
+	return s.segments.IsMember(ctx, c.SegmentID, userID)
+}
+
+// Claim 领取固定积分奖励，每个用户每个活动只能领取一次
+func (s *Service) Claim(ctx context.Context, key string, userID uint) (*Campaign, int64, error) {
+	ctx, span := gtrace.NewSpan(ctx, "Campaign.Claim")
+	defer span.End()
+
+	c, err := s.repo.FindByKey(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.RewardType != RewardPoints {
+		return nil, 0, ErrNotClaimable
+	}
+	ok, err := s.eligible(ctx, c, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok {
+		return nil, 0, ErrNotEligible
+	}
+
+	reserved, err := s.budget.Reserve(ctx, c, userID, c.RewardPoints, false, time.Now())
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err = s.points.Award(ctx, userID, reserved, ReasonClaim, fmt.Sprint(c.CampaignID)); err != nil {
+		if releaseErr := s.budget.Release(ctx, c, userID, reserved); releaseErr != nil {
+			s.logger.Error(ctx, "campaign budget release failed:", c.CampaignID, userID, releaseErr.Error())
+		}
+		if errors.Is(err, points.ErrDuplicateEntry) {
+			return nil, 0, ErrAlreadyClaimed
+		}
+		return nil, 0, err
+	}
+	return c, reserved, nil
+}
+
+// OnPointsChanged 注册为积分变动监听器，用户获得积分时按进行中的倍数活动发放额外奖励
+func (s *Service) OnPointsChanged(ctx context.Context, account *points.Account, entry *points.Entry) {
+	if entry.Delta <= 0 || strings.HasPrefix(entry.Reason, "campaign_") {
+		return
+	}
+	now := time.Now()
+	campaigns, err := s.repo.ListActive(now, RewardMultiplier)
+	if err != nil {
+		s.logger.Error(ctx, "campaign list failed:", err.Error())
+		return
+	}
+	for i := range campaigns {
+		c := &campaigns[i]
+		bonus := int64(math.Floor(float64(entry.Delta) * (c.Multiplier - 1)))
+		if bonus <= 0 {
+			continue
+		}
+		if ok, err := s.eligible(ctx, c, entry.UserID); err != nil || !ok {
+			continue
+		}
+		reserved, err := s.budget.Reserve(ctx, c, entry.UserID, bonus, true, now)
+		if err != nil {
+			// 预算耗尽或已暂停属于正常情况
+			continue
+		}
+		refID := fmt.Sprintf("%d:%d", c.CampaignID, entry.EntryID)
+		if _, err = s.points.Award(ctx, entry.UserID, reserved, ReasonBonus, refID); err != nil {
+			s.logger.Error(ctx, "campaign bonus award failed:", c.CampaignID, entry.UserID, err.Error())
+			if releaseErr := s.budget.Release(ctx, c, entry.UserID, reserved); releaseErr != nil {
+				s.logger.Error(ctx, "campaign budget release failed:", c.CampaignID, entry.UserID, releaseErr.Error())
+			}
+		}
+	}
+}
+
+// SetStatus 同时更新 MySQL 与 Redis，发放时以 Redis 中的状态为准，各实例立即一致
+func (s *Service) SetStatus(ctx context.Context, c *Campaign, status string) error {
+	if err := s.repo.UpdateStatus(c.CampaignID, status); err != nil {
+		return err
+	}
+	c.Status = status
+	return s.budget.SetStatus(ctx, c, status)
+}
@@ -0,0 +1,99 @@
+package campaign
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestState(t *testing.T) {
+	start := time.Date(2026, 10, 24, 0, 0, 0, 0, time.UTC)
+	c := &Campaign{Status: StatusRunning, StartAt: start, EndAt: start.Add(48 * time.Hour), GlobalBudget: 1000}
+
+	assert.Equal(t, StateScheduled, State(c, nil, start.Add(-time.Second)))
+	assert.Equal(t, StateRunning, State(c, &Usage{Spent: 999}, start))
+	assert.Equal(t, StateExhausted, State(c, &Usage{Spent: 1000}, start.Add(time.Hour)))
+	assert.Equal(t, StateEnded, State(c, nil, start.Add(48*time.Hour)))
+
+	c.Status = StatusPaused
+	assert.Equal(t, StatePaused, State(c, nil, start.Add(time.Hour)))
+}
+
+func TestKeysShareHashTag(t *testing.T) {
+	assert.Equal(t, "campaign:{7}:state", stateKey(7))
+	assert.Equal(t, "campaign:{7}:spent", spentKey(7))
+	assert.Equal(t, "campaign:{7}:users", usersKey(7))
+}
+
+func TestBudgetReserve(t *testing.T) {
+	mr := miniredis.RunT(t)
+	b := NewBudget(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	start := time.Date(2026, 10, 24, 0, 0, 0, 0, time.UTC)
+	now := start.Add(time.Hour)
+	c := &Campaign{CampaignID: 7, StartAt: start, EndAt: start.Add(48 * time.Hour), GlobalBudget: 250, PerUserBudget: 150}
+
+	// 时间窗口外拒绝
+	_, err := b.Reserve(ctx, c, 1, 100, false, start.Add(-time.Second))
+	assert.ErrorIs(t, err, ErrNotInWindow)
+	_, err = b.Reserve(ctx, c, 1, 100, false, c.EndAt)
+	assert.ErrorIs(t, err, ErrNotInWindow)
+
+	n, err := b.Reserve(ctx, c, 1, 100, false, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), n)
+
+	// 固定奖励不足额直接拒绝，倍数奖励按单用户剩余额度部分发放
+	_, err = b.Reserve(ctx, c, 1, 100, false, now)
+	assert.ErrorIs(t, err, ErrUserBudgetExceeded)
+	n, err = b.Reserve(ctx, c, 1, 100, true, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50), n)
+	_, err = b.Reserve(ctx, c, 1, 1, true, now)
+	assert.ErrorIs(t, err, ErrUserBudgetExceeded)
+
+	// 总预算剩余 100，同时受两级预算限制
+	n, err = b.Reserve(ctx, c, 2, 120, true, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), n)
+	_, err = b.Reserve(ctx, c, 3, 10, true, now)
+	assert.ErrorIs(t, err, ErrGlobalBudgetExceeded)
+
+	// 归还后可以再次预留
+	assert.NoError(t, b.Release(ctx, c, 2, 30))
+	n, err = b.Reserve(ctx, c, 3, 10, false, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), n)
+
+	usage, err := b.Usage(ctx, c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(230), usage.Spent)
+		assert.Equal(t, int64(3), usage.Participants)
+	}
+}
+
+func TestBudgetPause(t *testing.T) {
+	mr := miniredis.RunT(t)
+	b := NewBudget(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	start := time.Date(2026, 10, 24, 0, 0, 0, 0, time.UTC)
+	c := &Campaign{CampaignID: 8, StartAt: start, EndAt: start.Add(48 * time.Hour)}
+
+	assert.NoError(t, b.SetStatus(ctx, c, StatusPaused))
+	_, err := b.Reserve(ctx, c, 1, 100, false, start)
+	assert.ErrorIs(t, err, ErrCampaignPaused)
+	usage, err := b.Usage(ctx, c)
+	if assert.NoError(t, err) {
+		assert.Zero(t, usage.Spent)
+	}
+
+	// 恢复后不限预算的活动照常发放
+	assert.NoError(t, b.SetStatus(ctx, c, StatusRunning))
+	n, err := b.Reserve(ctx, c, 1, 100, false, start)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), n)
+}
@@ -0,0 +1,158 @@
+package coupon
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+type CreateBatchReq struct {
+	g.Meta       `path:"/api/admin/coupons/batches" method:"post"`
+	Name         string      `json:"name" v:"required#批次名称不能为空"`
+	Channel      string      `json:"channel" v:"max-length:64"`
+	Reward       string      `json:"reward" v:"max-length:255"`
+	Count        int         `json:"count" v:"required|between:1,100000#生成数量不能为空|生成数量需在1到100000之间"`
+	MaxUses      int         `json:"max_uses" d:"1" v:"min:1"`
+	PerUserLimit int         `json:"per_user_limit" d:"1" v:"min:1"`
+	Prefix       string      `json:"prefix" v:"max-length:8"`
+	CodeLength   int         `json:"code_length" d:"10" v:"between:6,24"`
+	StartAt      *gtime.Time `json:"start_at" v:"required#开始时间不能为空"`
+	EndAt        *gtime.Time `json:"end_at" v:"required|after:StartAt#结束时间不能为空|结束时间需晚于开始时间"`
+}
+
+type CreateBatchRes struct {
+	BatchID uint `json:"batch_id"`
+	Count   int  `json:"count"`
+}
+
+type ExportBatchReq struct {
+	g.Meta  `path:"/api/admin/coupons/batches/{id}/export" method:"get"`
+	BatchID uint `p:"id" v:"required"`
+}
+
+type ExportBatchRes struct {
+}
+
+type Admin struct {
+	repo       CouponRepository
+	userLogger logs.Logger
+}
+
+func NewAdmin(repo CouponRepository, logger logs.Logger) *Admin {
+	return &Admin{
+		repo:       repo,
+		userLogger: logger,
+	}
+}
+
+// 单批次生成时与历史码冲突的重试上限
+const maxGenerateRounds = 5
+
+func (params *Admin) CreateBatch(ctx context.Context, req *CreateBatchReq) (res *CreateBatchRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Coupon.CreateBatch")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	batch := &CouponBatch{
+		Name:         req.Name,
+		Channel:      req.Channel,
+		Reward:       req.Reward,
+		MaxUses:      req.MaxUses,
+		PerUserLimit: req.PerUserLimit,
+		StartAt:      req.StartAt.Time,
+		EndAt:        req.EndAt.Time,
+	}
+	if err = params.repo.CreateBatch(batch); err != nil {
+		return nil, err
+	}
+
+	inserted, err := fillCodes(params.repo, batch.BatchID, strings.ToUpper(req.Prefix), req.CodeLength, req.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Coupon batch created:", batch.BatchID, "count:", inserted, "channel:", batch.Channel)
+
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "batch created",
+		"data": &CreateBatchRes{
+			BatchID: batch.BatchID,
+			Count:   inserted,
+		},
+	})
+	return nil, nil
+}
+
+// fillCodes 生成并写入 count 个码，与历史码冲突被跳过的部分重新生成
+func fillCodes(repo CouponRepository, batchID uint, prefix string, length, count int) (int, error) {
+	inserted := 0
+	for round := 0; inserted < count; round++ {
+		if round >= maxGenerateRounds {
+			return inserted, fmt.Errorf("coupon batch %d: only %d of %d codes generated", batchID, inserted, count)
+		}
+		codes, err := generateCodes(prefix, length, count-inserted)
+		if err != nil {
+			return inserted, err
+		}
+		n, err := repo.InsertCodes(batchID, codes)
+		if err != nil {
+			return inserted, err
+		}
+		inserted += int(n)
+	}
+	return inserted, nil
+}
+
+func (params *Admin) ExportBatch(ctx context.Context, req *ExportBatchReq) (res *ExportBatchRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Coupon.ExportBatch")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	batch, err := params.repo.FindBatch(req.BatchID)
+	if err != nil {
+		if errors.Is(err, ErrBatchNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "批次不存在")
+		}
+		return nil, err
+	}
+	coupons, err := params.repo.ListCodes(batch.BatchID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Response.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	r.Response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="coupons-%d.csv"`, batch.BatchID))
+
+	w := csv.NewWriter(r.Response.BufferWriter)
+	_ = w.Write([]string{"code", "channel", "used_count", "max_uses", "start_at", "end_at"})
+	for _, c := range coupons {
+		_ = w.Write([]string{
+			c.Code,
+			batch.Channel,
+			strconv.Itoa(c.UsedCount),
+			strconv.Itoa(batch.MaxUses),
+			batch.StartAt.Format("2006-01-02 15:04:05"),
+			batch.EndAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Coupon batch exported:", batch.BatchID, "count:", len(coupons))
+	return nil, nil
+}
@@ -0,0 +1,40 @@
+package coupon
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// 去掉 0/O/1/I 等易混淆字符，方便用户手动输入
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func generateCode(prefix string, length int) (string, error) {
+	buf := make([]byte, length)
+	max := big.NewInt(int64(len(codeAlphabet)))
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = codeAlphabet[n.Int64()]
+	}
+	return prefix + string(buf), nil
+}
+
+// generateCodes 生成 n 个互不相同的码，与库中已有码的冲突由写入时处理
+func generateCodes(prefix string, length, n int) ([]string, error) {
+	seen := make(map[string]struct{}, n)
+	codes := make([]string, 0, n)
+	for len(codes) < n {
+		code, err := generateCode(prefix, length)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[code]; ok {
+			continue
+		}
+		seen[code] = struct{}{}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
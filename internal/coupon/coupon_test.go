@@ -0,0 +1,114 @@
+package coupon
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRepo 在内存中模拟兑换码的写入
+type fakeRepo struct {
+	CouponRepository
+	coupons map[string]*Coupon
+	// conflicts 大于 0 时，接下来的若干个码视为与库中已有码冲突
+	conflicts int
+	inserts   int
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{coupons: map[string]*Coupon{}}
+}
+
+func (f *fakeRepo) InsertCodes(batchID uint, codes []string) (int64, error) {
+	f.inserts++
+	var n int64
+	for _, code := range codes {
+		if f.conflicts > 0 {
+			f.conflicts--
+			continue
+		}
+		if f.coupons[code] != nil {
+			continue
+		}
+		f.coupons[code] = &Coupon{CouponID: uint(len(f.coupons) + 1), BatchID: batchID, Code: code}
+		n++
+	}
+	return n, nil
+}
+
+func TestGenerateCodes(t *testing.T) {
+	cases := []struct {
+		prefix string
+		length int
+		n      int
+	}{
+		{"", 6, 1},
+		{"VIP", 10, 500},
+		{"SUMMER", 24, 50},
+	}
+	for _, c := range cases {
+		codes, err := generateCodes(c.prefix, c.length, c.n)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, codes, c.n)
+		seen := map[string]bool{}
+		for _, code := range codes {
+			assert.False(t, seen[code], "duplicate code %s", code)
+			seen[code] = true
+			assert.True(t, strings.HasPrefix(code, c.prefix), code)
+			assert.Len(t, code, len(c.prefix)+c.length)
+			// 不含易混淆字符
+			assert.Empty(t, strings.Trim(strings.TrimPrefix(code, c.prefix), codeAlphabet), code)
+		}
+	}
+}
+
+func TestFillCodesRetriesConflicts(t *testing.T) {
+	repo := newFakeRepo()
+	repo.conflicts = 3
+	n, err := fillCodes(repo, 1, "X", 10, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+	assert.Len(t, repo.coupons, 10)
+	assert.Equal(t, 2, repo.inserts)
+
+	// 一直冲突时在重试上限后报错
+	repo.conflicts = 1000
+	n, err = fillCodes(repo, 1, "X", 10, 10)
+	assert.ErrorContains(t, err, "only 0 of 10 codes generated")
+	assert.Equal(t, 0, n)
+}
+
+func TestRedeemChecks(t *testing.T) {
+	start := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+	batch := &CouponBatch{MaxUses: 3, PerUserLimit: 2, StartAt: start, EndAt: end}
+
+	cases := []struct {
+		name      string
+		usedCount int
+		userUsed  int
+		now       time.Time
+		err       error
+	}{
+		{"ok", 0, 0, start, nil},
+		{"not started", 0, 0, start.Add(-time.Second), ErrCouponNotStarted},
+		{"last moment", 2, 1, end.Add(-time.Second), nil},
+		{"expired", 0, 0, end, ErrCouponExpired},
+		{"exhausted", 3, 0, start, ErrCouponExhausted},
+		{"user limit", 1, 2, start, ErrUserLimitReached},
+		// 有效期先于次数校验
+		{"expired and exhausted", 3, 2, end, ErrCouponExpired},
+	}
+	for _, c := range cases {
+		err := batch.checkRedeem(c.usedCount, c.userUsed, c.now)
+		if c.err == nil {
+			assert.NoError(t, err, c.name)
+		} else {
+			assert.ErrorIs(t, err, c.err, c.name)
+		}
+	}
+}
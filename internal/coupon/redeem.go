@@ -0,0 +1,85 @@
+package coupon
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type RedeemReq struct {
+	g.Meta `path:"/api/coupons/redeem" method:"post"`
+	Code   string `json:"code" v:"required#兑换码不能为空"`
+}
+
+type RedeemRes struct {
+	Code    string `json:"code"`
+	Reward  string `json:"reward"`
+	Channel string `json:"channel"`
+}
+
+type Redeem struct {
+	repo       CouponRepository
+	userLogger logs.Logger
+}
+
+func NewRedeem(repo CouponRepository, logger logs.Logger) *Redeem {
+	return &Redeem{
+		repo:       repo,
+		userLogger: logger,
+	}
+}
+
+func (params *Redeem) Redeem(ctx context.Context, req *RedeemReq) (res *RedeemRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Coupon.Redeem")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	userid := r.GetCtxVar("userid").String()
+	uid, err := strconv.ParseUint(userid, 10, 64)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+	span.SetAttributes(attribute.String("user.id", userid))
+
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	batch, err := params.repo.Redeem(code, uint(uid), time.Now())
+	if err != nil {
+		params.userLogger.Info(ctx, "Coupon redeem rejected:", code, "userid:", userid, "reason:", err.Error())
+		switch {
+		case errors.Is(err, ErrCouponNotFound):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "兑换码不存在")
+		case errors.Is(err, ErrCouponNotStarted):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "兑换码尚未生效")
+		case errors.Is(err, ErrCouponExpired):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "兑换码已过期")
+		case errors.Is(err, ErrCouponExhausted):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "兑换码已被使用")
+		case errors.Is(err, ErrUserLimitReached):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "已达到兑换次数上限")
+		}
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Coupon redeem success:", code, "userid:", userid, "batch:", batch.BatchID, "channel:", batch.Channel)
+
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "redeem success",
+		"data": &RedeemRes{
+			Code:    code,
+			Reward:  batch.Reward,
+			Channel: batch.Channel,
+		},
+	})
+	return nil, nil
+}
@@ -0,0 +1,174 @@
+package coupon
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrCouponNotFound = errors.New("coupon not found")
+
+var ErrCouponNotStarted = errors.New("coupon not started")
+
+var ErrCouponExpired = errors.New("coupon expired")
+
+var ErrCouponExhausted = errors.New("coupon exhausted")
+
+var ErrUserLimitReached = errors.New("user redemption limit reached")
+
+var ErrBatchNotFound = errors.New("coupon batch not found")
+
+type CouponBatch struct {
+	BatchID      uint      `gorm:"primaryKey;autoIncrement"`
+	Name         string    `gorm:"type:varchar(255);not null"`
+	Channel      string    `gorm:"type:varchar(64);index"` // 投放渠道
+	Reward       string    `gorm:"type:varchar(255)"`      // 奖励描述，由发放方解释
+	MaxUses      int       `gorm:"not null;default:1"`     // 单个码的全局可用次数，1 即一次性码
+	PerUserLimit int       `gorm:"not null;default:1"`     // 同一用户在本批次内最多兑换次数
+	StartAt      time.Time `gorm:"not null"`
+	EndAt        time.Time `gorm:"not null"`
+	CreatedAt    time.Time
+}
+
+// checkRedeem 依次校验有效期、码的全局次数与用户在批次内的次数
+func (batch *CouponBatch) checkRedeem(usedCount, userUsed int, now time.Time) error {
+	if now.Before(batch.StartAt) {
+		return ErrCouponNotStarted
+	}
+	if !now.Before(batch.EndAt) {
+		return ErrCouponExpired
+	}
+	if usedCount >= batch.MaxUses {
+		return ErrCouponExhausted
+	}
+	if userUsed >= batch.PerUserLimit {
+		return ErrUserLimitReached
+	}
+	return nil
+}
+
+type Coupon struct {
+	CouponID  uint   `gorm:"primaryKey;autoIncrement"`
+	BatchID   uint   `gorm:"not null;index"`
+	Code      string `gorm:"type:varchar(32);not null;uniqueIndex"`
+	UsedCount int    `gorm:"not null;default:0"`
+}
+
+type CouponRedemption struct {
+	RedemptionID uint   `gorm:"primaryKey;autoIncrement"`
+	CouponID     uint   `gorm:"not null;index"`
+	BatchID      uint   `gorm:"not null;index"`
+	UserID       uint   `gorm:"not null;index"`
+	Channel      string `gorm:"type:varchar(64)"`
+	CreatedAt    time.Time
+}
+
+// CouponUserQuota 记录用户在批次内的已兑换次数，行锁保证并发下的用户限额
+type CouponUserQuota struct {
+	BatchID uint `gorm:"primaryKey"`
+	UserID  uint `gorm:"primaryKey"`
+	Used    int  `gorm:"not null;default:0"`
+}
+
+type couponRepository struct {
+	db *gorm.DB
+}
+
+type CouponRepository interface {
+	CreateBatch(batch *CouponBatch) error
+	FindBatch(batchID uint) (*CouponBatch, error)
+	InsertCodes(batchID uint, codes []string) (int64, error)
+	ListCodes(batchID uint) ([]Coupon, error)
+	Redeem(code string, userID uint, now time.Time) (*CouponBatch, error)
+}
+
+func NewCouponRepository(db *gorm.DB) CouponRepository {
+	if err := db.AutoMigrate(&CouponBatch{}, &Coupon{}, &CouponRedemption{}, &CouponUserQuota{}); err != nil {
+		panic("failed to migrate coupon tables")
+	}
+	return &couponRepository{db: db}
+}
+
+func (repo *couponRepository) CreateBatch(batch *CouponBatch) error {
+	return repo.db.Create(batch).Error
+}
+
+func (repo *couponRepository) FindBatch(batchID uint) (*CouponBatch, error) {
+	var batch CouponBatch
+	if err := repo.db.First(&batch, batchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBatchNotFound
+		}
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// InsertCodes 跳过与已有码冲突的记录，返回实际写入条数，调用方据此补发
+func (repo *couponRepository) InsertCodes(batchID uint, codes []string) (int64, error) {
+	rows := make([]Coupon, 0, len(codes))
+	for _, code := range codes {
+		rows = append(rows, Coupon{BatchID: batchID, Code: code})
+	}
+	result := repo.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(rows, 500)
+	return result.RowsAffected, result.Error
+}
+
+func (repo *couponRepository) ListCodes(batchID uint) ([]Coupon, error) {
+	var coupons []Coupon
+	err := repo.db.Where("batch_id = ?", batchID).Order("coupon_id").Find(&coupons).Error
+	return coupons, err
+}
+
+// Redeem 在一个事务内完成校验与记录：
+// 码行加锁保证全局次数不超发，用户配额行加锁保证同一用户并发兑换不超限
+func (repo *couponRepository) Redeem(code string, userID uint, now time.Time) (*CouponBatch, error) {
+	var batch CouponBatch
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		var coupon Coupon
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code = ?", code).First(&coupon).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCouponNotFound
+			}
+			return err
+		}
+
+		if err := tx.First(&batch, coupon.BatchID).Error; err != nil {
+			return err
+		}
+		// 失败时事务回滚，不会留下配额行
+		quota := CouponUserQuota{BatchID: batch.BatchID, UserID: userID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&quota).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("batch_id = ? AND user_id = ?", batch.BatchID, userID).First(&quota).Error; err != nil {
+			return err
+		}
+		if err := batch.checkRedeem(coupon.UsedCount, quota.Used, now); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&Coupon{}).Where("coupon_id = ?", coupon.CouponID).
+			Update("used_count", gorm.Expr("used_count + 1")).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&CouponUserQuota{}).Where("batch_id = ? AND user_id = ?", batch.BatchID, userID).
+			Update("used", gorm.Expr("used + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Create(&CouponRedemption{
+			CouponID: coupon.CouponID,
+			BatchID:  batch.BatchID,
+			UserID:   userID,
+			Channel:  batch.Channel,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
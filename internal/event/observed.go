@@ -0,0 +1,44 @@
+package event
+
+import "context"
+
+// Listener 在服务端事件写入成功后被同步调用，如徽章进度累加
+type Listener func(ctx context.Context, event *UserEvent)
+
+// Observed 包装 EventRepository，单条写入成功后通知监听器；
+// InsertBatch 来自埋点管道，由管道自己的 Sink 处理，不在此通知
+type Observed struct {
+	EventRepository
+	listeners []Listener
+}
+
+func NewObserved(repo EventRepository) *Observed {
+	return &Observed{EventRepository: repo}
+}
+
+// OnCreated 需在启动阶段注册，运行期间不可再调用
+func (o *Observed) OnCreated(l Listener) {
+	o.listeners = append(o.listeners, l)
+}
+
+func (o *Observed) Record(ctx context.Context, userID uint, name string, properties map[string]any) error {
+	props, err := encodeProperties(properties)
+	if err != nil {
+		return err
+	}
+	return o.Create(ctx, &UserEvent{
+		UserID:     userID,
+		Name:       name,
+		Properties: props,
+	})
+}
+
+func (o *Observed) Create(ctx context.Context, event *UserEvent) error {
+	if err := o.EventRepository.Create(ctx, event); err != nil {
+		return err
+	}
+	for _, l := range o.listeners {
+		l(ctx, event)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gogf/gf/v2/net/gtrace"
+	"gorm.io/gorm"
+)
+
+// 服务端产生的用户行为事件名
+const (
+	NameRegister = "register"
+	NameLogin    = "login"
+)
+
+// UserEvent 同时承载服务端事件与客户端上报事件，匿名事件的 UserID 为 0
+type UserEvent struct {
+	EventID       uint64    `gorm:"primaryKey;autoIncrement" json:"event_id"`
+	UserID        uint      `gorm:"not null;index:idx_user_name_time,priority:1" json:"user_id"`
+	Name          string    `gorm:"type:varchar(64);not null;index:idx_user_name_time,priority:2;index:idx_name_time,priority:1" json:"name"`
+	Properties    string    `gorm:"type:text" json:"properties"` // JSON 对象
+	AnonymousID   string    `gorm:"type:varchar(64);index" json:"anonymous_id"`
+	IP            string    `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent     string    `gorm:"type:varchar(512)" json:"user_agent"`
+	TraceID       string    `gorm:"type:varchar(64)" json:"trace_id"`
+	SchemaVersion int       `gorm:"not null;default:0" json:"schema_version,omitempty"`
+	SchemaStatus  string    `gorm:"type:varchar(16)" json:"schema_status,omitempty"`                                               // 客户端事件的 schema 校验结果，服务端事件为空
+	CreatedAt     time.Time `gorm:"not null;index:idx_user_name_time,priority:3;index:idx_name_time,priority:2" json:"created_at"` // 事件发生时间
+	ReceivedAt    time.Time `gorm:"not null;index:idx_received,priority:1" json:"received_at"`                                     // 服务端接收时间，导出按它分区
+}
+
+type eventRepository struct {
+	db *gorm.DB
+}
+
+type EventRepository interface {
+	Record(ctx context.Context, userID uint, name string, properties map[string]any) error
+	Create(ctx context.Context, event *UserEvent) error
+	InsertBatch(ctx context.Context, events []UserEvent) error
+	CountByUser(ctx context.Context, userID uint, name string, since time.Time) (int64, error)
+	// ScanByNames 按主键分批读取时间范围内的指定事件，避免一次性加载到内存
+	ScanByNames(ctx context.Context, names []string, start, end time.Time, fn func(events []UserEvent) error) error
+}
+
+func NewEventRepository(db *gorm.DB) EventRepository {
+	if err := db.AutoMigrate(&UserEvent{}); err != nil {
+		panic("failed to migrate event table")
+	}
+	return &eventRepository{db: db}
+}
+
+func (repo *eventRepository) Record(ctx context.Context, userID uint, name string, properties map[string]any) error {
+	props, err := encodeProperties(properties)
+	if err != nil {
+		return err
+	}
+	return repo.Create(ctx, &UserEvent{
+		UserID:     userID,
+		Name:       name,
+		Properties: props,
+	})
+}
+
+func encodeProperties(properties map[string]any) (string, error) {
+	if len(properties) == 0 {
+		return "{}", nil
+	}
+	b, err := json.Marshal(properties)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Create 写入单个服务端事件，未设置的时间与 trace 字段自动补全
+func (repo *eventRepository) Create(ctx context.Context, event *UserEvent) error {
+	now := time.Now()
+	if event.Properties == "" {
+		event.Properties = "{}"
+	}
+	if len(event.UserAgent) > 512 {
+		event.UserAgent = event.UserAgent[:512]
+	}
+	if event.TraceID == "" {
+		event.TraceID = gtrace.GetTraceID(ctx)
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = now
+	}
+	if event.ReceivedAt.IsZero() {
+		event.ReceivedAt = now
+	}
+	return repo.db.WithContext(ctx).Create(event).Error
+}
+
+func (repo *eventRepository) InsertBatch(ctx context.Context, events []UserEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return repo.db.WithContext(ctx).CreateInBatches(events, 500).Error
+}
+
+func (repo *eventRepository) CountByUser(ctx context.Context, userID uint, name string, since time.Time) (int64, error) {
+	var count int64
+	err := repo.db.WithContext(ctx).Model(&UserEvent{}).
+		Where("user_id = ? AND name = ? AND created_at >= ?", userID, name, since).
+		Count(&count).Error
+	return count, err
+}
+
+func (repo *eventRepository) ScanByNames(ctx context.Context, names []string, start, end time.Time, fn func(events []UserEvent) error) error {
+	var batch []UserEvent
+	return repo.db.WithContext(ctx).
+		Where("name IN ? AND created_at >= ? AND created_at < ?", names, start, end).
+		FindInBatches(&batch, 2000, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
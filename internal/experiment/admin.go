@@ -0,0 +1,218 @@
+package experiment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+type CreateExperimentReq struct {
+	g.Meta    `path:"/api/admin/experiments" method:"post"`
+	Key       string    `json:"key" v:"required|regex:^[a-z0-9_\\-]{1,64}$#实验标识不能为空|实验标识只能包含小写字母、数字、下划线和中划线"`
+	Salt      string    `json:"salt" v:"max-length:64"`
+	SegmentID uint      `json:"segment_id"`
+	Variants  []Variant `json:"variants" v:"required#实验分组不能为空"`
+}
+
+type CreateExperimentRes struct {
+}
+
+type ListExperimentReq struct {
+	g.Meta `path:"/api/admin/experiments" method:"get"`
+}
+
+type ListExperimentRes struct {
+}
+
+type UpdateExperimentReq struct {
+	g.Meta       `path:"/api/admin/experiments/{id}" method:"put"`
+	ExperimentID uint      `p:"id" v:"required"`
+	Status       string    `json:"status" v:"required|in:draft,running,stopped#实验状态不能为空|实验状态不合法"`
+	SegmentID    uint      `json:"segment_id"`
+	Variants     []Variant `json:"variants" v:"required#实验分组不能为空"`
+}
+
+type UpdateExperimentRes struct {
+}
+
+type ExperimentReportReq struct {
+	g.Meta       `path:"/api/admin/experiments/{id}/report" method:"get"`
+	ExperimentID uint    `p:"id" v:"required"`
+	Event        string  `p:"event" v:"required|max-length:64#转化事件不能为空|转化事件过长"`
+	WindowDays   int     `p:"window_days" v:"between:0,90#转化窗口应在0到90天之间"` // 0 表示不限
+	Control      string  `p:"control"`                                    // 为空时取第一个分组
+	Confidence   float64 `p:"confidence" d:"0.95" v:"between:0.8,0.999#置信水平应在0.8到0.999之间"`
+}
+
+type ExperimentReportRes struct {
+}
+
+type Admin struct {
+	repo       ExperimentRepository
+	userLogger logs.Logger
+}
+
+func NewAdmin(repo ExperimentRepository, logger logs.Logger) *Admin {
+	return &Admin{
+		repo:       repo,
+		userLogger: logger,
+	}
+}
+
+func encodeVariants(variants []Variant) (string, error) {
+	seen := make(map[string]struct{}, len(variants))
+	total := 0
+	for _, v := range variants {
+		if v.Key == "" || len(v.Key) > 64 {
+			return "", gerror.NewCode(gcode.CodeValidationFailed, "分组标识不合法")
+		}
+		if _, ok := seen[v.Key]; ok {
+			return "", gerror.NewCode(gcode.CodeValidationFailed, "分组标识重复: "+v.Key)
+		}
+		if v.Weight < 0 {
+			return "", gerror.NewCode(gcode.CodeValidationFailed, "分组权重不能为负数")
+		}
+		seen[v.Key] = struct{}{}
+		total += v.Weight
+	}
+	if total == 0 {
+		return "", gerror.NewCode(gcode.CodeValidationFailed, "分组权重之和必须大于0")
+	}
+	b, err := json.Marshal(variants)
+	return string(b), err
+}
+
+func (params *Admin) Create(ctx context.Context, req *CreateExperimentReq) (res *CreateExperimentRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Experiment.Create")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	variants, err := encodeVariants(req.Variants)
+	if err != nil {
+		return nil, err
+	}
+	salt := req.Salt
+	if salt == "" {
+		salt = req.Key
+	}
+	experiment := &Experiment{
+		Key:       req.Key,
+		Salt:      salt,
+		Status:    StatusDraft,
+		SegmentID: req.SegmentID,
+		Variants:  variants,
+	}
+	if err = params.repo.Create(experiment); err != nil {
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Experiment created:", experiment.ExperimentID, experiment.Key)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "experiment created",
+		"data":    experiment,
+	})
+	return nil, nil
+}
+
+func (params *Admin) List(ctx context.Context, req *ListExperimentReq) (res *ListExperimentRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	experiments, err := params.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    experiments,
+	})
+	return nil, nil
+}
+
+// Update 只影响尚未分组的用户，已有分组记录保持不变
+func (params *Admin) Update(ctx context.Context, req *UpdateExperimentReq) (res *UpdateExperimentRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Experiment.Update")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	experiment, err := params.repo.Find(req.ExperimentID)
+	if err != nil {
+		if errors.Is(err, ErrExperimentNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "实验不存在")
+		}
+		return nil, err
+	}
+	variants, err := encodeVariants(req.Variants)
+	if err != nil {
+		return nil, err
+	}
+	experiment.Status = req.Status
+	experiment.SegmentID = req.SegmentID
+	experiment.Variants = variants
+	if err = params.repo.Update(experiment); err != nil {
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Experiment updated:", experiment.ExperimentID, experiment.Key, "status:", experiment.Status)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "experiment updated",
+		"data":    experiment,
+	})
+	return nil, nil
+}
+
+// Report 统计各分组转化率并与对照组做显著性检验，同时检查样本比例是否失衡
+func (params *Admin) Report(ctx context.Context, req *ExperimentReportReq) (res *ExperimentReportRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Experiment.Report")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	experiment, err := params.repo.Find(req.ExperimentID)
+	if err != nil {
+		if errors.Is(err, ErrExperimentNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "实验不存在")
+		}
+		return nil, err
+	}
+	variants, err := experiment.ParseVariants()
+	if err != nil {
+		return nil, err
+	}
+	if req.Control != "" {
+		found := false
+		for _, v := range variants {
+			found = found || v.Key == req.Control
+		}
+		if !found {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "对照组不存在: "+req.Control)
+		}
+	}
+	window := time.Duration(req.WindowDays) * 24 * time.Hour
+	counts, err := params.repo.CountConversions(experiment.ExperimentID, req.Event, window)
+	if err != nil {
+		return nil, err
+	}
+
+	report := Analyze(variants, counts, req.Control, req.Confidence)
+	report.ExperimentID = experiment.ExperimentID
+	report.Key = experiment.Key
+	report.Event = req.Event
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    report,
+	})
+	return nil, nil
+}
@@ -0,0 +1,126 @@
+package experiment
+
+// SRM 检验的显著性阈值，分流本身出问题时偏差通常极其显著，取严格阈值避免误报
+const srmAlpha = 0.001
+
+// VariantResult 是单个分组的转化率及其相对对照组的检验结果
+type VariantResult struct {
+	Variant     string  `json:"variant"`
+	Weight      int     `json:"weight"`
+	Control     bool    `json:"control"`
+	Users       int64   `json:"users"`
+	Conversions int64   `json:"conversions"`
+	Rate        float64 `json:"rate"`
+	RateLow     float64 `json:"rate_low"`
+	RateHigh    float64 `json:"rate_high"`
+	// 以下字段相对对照组，对照组自身为 0
+	Diff        float64 `json:"diff"`
+	DiffLow     float64 `json:"diff_low"`
+	DiffHigh    float64 `json:"diff_high"`
+	Lift        float64 `json:"lift"` // 相对提升，对照组转化率为 0 时为 0
+	ZScore      float64 `json:"z_score"`
+	PValue      float64 `json:"p_value"`
+	Significant bool    `json:"significant"`
+}
+
+// SampleRatio 是样本比例失衡检验结果，Mismatch 为真时分流或埋点存在问题，结论不可信
+type SampleRatio struct {
+	ChiSquare float64 `json:"chi_square"`
+	PValue    float64 `json:"p_value"`
+	Mismatch  bool    `json:"mismatch"`
+}
+
+type Report struct {
+	ExperimentID uint            `json:"experiment_id"`
+	Key          string          `json:"key"`
+	Event        string          `json:"event"`
+	Confidence   float64         `json:"confidence"`
+	Alpha        float64         `json:"alpha"` // 多组比较按 Bonferroni 校正后的显著性水平
+	Control      string          `json:"control"`
+	Winner       string          `json:"winner"` // 显著优于对照组且转化率最高的分组，没有则为空
+	SampleRatio  SampleRatio     `json:"sample_ratio"`
+	Variants     []VariantResult `json:"variants"`
+}
+
+// Analyze 按实验定义的分组顺序汇总统计结果，control 为空时取第一个分组作为对照组；
+// 定义中已删除但仍有分组记录的分组排在最后，权重视为 0
+func Analyze(variants []Variant, counts []VariantCount, control string, confidence float64) *Report {
+	byVariant := make(map[string]VariantCount, len(counts))
+	for _, c := range counts {
+		byVariant[c.Variant] = c
+	}
+	results := make([]VariantResult, 0, len(variants))
+	seen := make(map[string]struct{}, len(variants))
+	for _, v := range variants {
+		c := byVariant[v.Key]
+		results = append(results, VariantResult{Variant: v.Key, Weight: v.Weight, Users: c.Users, Conversions: c.Conversions})
+		seen[v.Key] = struct{}{}
+	}
+	for _, c := range counts {
+		if _, ok := seen[c.Variant]; !ok {
+			results = append(results, VariantResult{Variant: c.Variant, Users: c.Users, Conversions: c.Conversions})
+		}
+	}
+
+	report := &Report{Confidence: confidence, Control: control, Variants: results}
+	if report.Control == "" && len(results) > 0 {
+		report.Control = results[0].Variant
+	}
+	ctrl := -1
+	for i := range results {
+		if results[i].Variant == report.Control {
+			ctrl = i
+		}
+	}
+
+	report.Alpha = 1 - confidence
+	if len(results) > 2 {
+		report.Alpha /= float64(len(results) - 1)
+	}
+	for i := range results {
+		r := &results[i]
+		if r.Users > 0 {
+			r.Rate = float64(r.Conversions) / float64(r.Users)
+		}
+		r.RateLow, r.RateHigh = WilsonInterval(r.Conversions, r.Users, confidence)
+		if ctrl < 0 {
+			continue
+		}
+		if i == ctrl {
+			r.Control = true
+			r.PValue = 1
+			continue
+		}
+		c := results[ctrl]
+		r.Diff = r.Rate - c.Rate
+		if c.Users > 0 && c.Conversions > 0 {
+			r.Lift = r.Diff / (float64(c.Conversions) / float64(c.Users))
+		}
+		r.DiffLow, r.DiffHigh = DiffInterval(c.Conversions, c.Users, r.Conversions, r.Users, 1-report.Alpha)
+		r.ZScore, r.PValue = TwoProportionZTest(c.Conversions, c.Users, r.Conversions, r.Users)
+		r.Significant = r.PValue < report.Alpha
+	}
+
+	best := -1
+	for i := range results {
+		if results[i].Significant && results[i].Diff > 0 && (best < 0 || results[i].Rate > results[best].Rate) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		report.Winner = results[best].Variant
+	}
+
+	// 只对当前权重大于 0 的分组做检验，实验期间调整过权重时结果仅供参考
+	var observed []int64
+	var expected []float64
+	for _, r := range results {
+		if r.Weight > 0 {
+			observed = append(observed, r.Users)
+			expected = append(expected, float64(r.Weight))
+		}
+	}
+	chi2, p := ChiSquareTest(observed, expected)
+	report.SampleRatio = SampleRatio{ChiSquare: chi2, PValue: p, Mismatch: p < srmAlpha}
+	return report
+}
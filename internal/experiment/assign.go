@@ -0,0 +1,136 @@
+package experiment
+
+import (
+	"context"
+	"strconv"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SegmentChecker 判断用户是否属于目标分群，由 segment 模块提供
+type SegmentChecker interface {
+	IsMember(ctx context.Context, segmentID, userID uint) (bool, error)
+}
+
+type AssignmentsReq struct {
+	g.Meta `path:"/api/experiments/assignments" method:"get"`
+}
+
+type AssignmentsRes struct {
+}
+
+type UserVariant struct {
+	Experiment string `json:"experiment"`
+	Variant    string `json:"variant"`
+}
+
+type Assigner struct {
+	repo       ExperimentRepository
+	segments   SegmentChecker
+	userLogger logs.Logger
+}
+
+func NewAssigner(repo ExperimentRepository, segments SegmentChecker, logger logs.Logger) *Assigner {
+	return &Assigner{
+		repo:       repo,
+		segments:   segments,
+		userLogger: logger,
+	}
+}
+
+// assignments 返回用户在所有运行中实验的分组，已分组的用户直接沿用历史结果
+func (a *Assigner) assignments(ctx context.Context, userID uint) ([]UserVariant, error) {
+	experiments, err := a.repo.ListRunning()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(experiments))
+	for _, e := range experiments {
+		ids = append(ids, e.ExperimentID)
+	}
+	existing, err := a.repo.FindAssignments(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	sticky := make(map[uint]string, len(existing))
+	for _, as := range existing {
+		sticky[as.ExperimentID] = as.Variant
+	}
+
+	result := make([]UserVariant, 0, len(experiments))
+	for i := range experiments {
+		variant, ok := sticky[experiments[i].ExperimentID]
+		if !ok {
+			variant, ok, err = a.assign(ctx, &experiments[i], userID)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		result = append(result, UserVariant{Experiment: experiments[i].Key, Variant: variant})
+	}
+	return result, nil
+}
+
+func (a *Assigner) assign(ctx context.Context, experiment *Experiment, userID uint) (string, bool, error) {
+	if experiment.SegmentID != 0 {
+		member, err := a.segments.IsMember(ctx, experiment.SegmentID, userID)
+		if err != nil || !member {
+			return "", false, err
+		}
+	}
+	variants, err := experiment.ParseVariants()
+	if err != nil {
+		return "", false, err
+	}
+	variant, ok := PickVariant(variants, Bucket(experiment.Salt, userID))
+	if !ok {
+		return "", false, nil
+	}
+	variant, err = a.repo.SaveAssignment(&Assignment{
+		ExperimentID: experiment.ExperimentID,
+		UserID:       userID,
+		Variant:      variant,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return variant, true, nil
+}
+
+func (a *Assigner) List(ctx context.Context, req *AssignmentsReq) (res *AssignmentsRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Experiment.Assignments")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	userid := r.GetCtxVar("userid").String()
+	uid, err := strconv.ParseUint(userid, 10, 64)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+	span.SetAttributes(attribute.String("user.id", userid))
+
+	assignments, err := a.assignments(ctx, uint(uid))
+	if err != nil {
+		return nil, err
+	}
+	// 曝光日志写入 user.log，trace_id 由日志 handler 从 ctx 中带出
+	for _, as := range assignments {
+		a.userLogger.Info(ctx, "Experiment exposure:", as.Experiment, "variant:", as.Variant, "userid:", userid)
+	}
+
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    assignments,
+	})
+	return nil, nil
+}
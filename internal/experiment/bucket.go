@@ -0,0 +1,41 @@
+package experiment
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+)
+
+// BucketCount 是分桶粒度，权重按万分比映射到桶区间
+const BucketCount = 10000
+
+// Bucket 对 salt 与用户 ID 做哈希，同一用户在同一实验下结果恒定
+func Bucket(salt string, userID uint) int {
+	sum := sha256.Sum256([]byte(salt + ":" + strconv.FormatUint(uint64(userID), 10)))
+	return int(binary.BigEndian.Uint64(sum[:8]) % BucketCount)
+}
+
+// PickVariant 按累计权重把桶映射到分组，权重为相对值，总和不要求为 100
+func PickVariant(variants []Variant, bucket int) (string, bool) {
+	total := 0
+	for _, v := range variants {
+		if v.Weight > 0 {
+			total += v.Weight
+		}
+	}
+	if total == 0 {
+		return "", false
+	}
+
+	acc := 0
+	for _, v := range variants {
+		if v.Weight <= 0 {
+			continue
+		}
+		acc += v.Weight
+		if bucket < acc*BucketCount/total {
+			return v.Key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,40 @@
+package experiment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketDeterministic(t *testing.T) {
+	for uid := uint(1); uid < 100; uid++ {
+		assert.Equal(t, Bucket("exp-a", uid), Bucket("exp-a", uid))
+	}
+	diff := 0
+	for uid := uint(1); uid < 100; uid++ {
+		if Bucket("exp-a", uid) != Bucket("exp-b", uid) {
+			diff++
+		}
+	}
+	assert.Greater(t, diff, 90)
+}
+
+func TestPickVariantWeights(t *testing.T) {
+	variants := []Variant{{Key: "control", Weight: 1}, {Key: "treatment", Weight: 3}}
+	counts := map[string]int{}
+	for uid := uint(1); uid <= 20000; uid++ {
+		v, ok := PickVariant(variants, Bucket("weights", uid))
+		assert.True(t, ok)
+		counts[v]++
+	}
+	assert.InDelta(t, 5000, counts["control"], 400)
+	assert.InDelta(t, 15000, counts["treatment"], 400)
+
+	v, _ := PickVariant(variants, 0)
+	assert.Equal(t, "control", v)
+	v, _ = PickVariant(variants, BucketCount-1)
+	assert.Equal(t, "treatment", v)
+
+	_, ok := PickVariant([]Variant{{Key: "off", Weight: 0}}, 10)
+	assert.False(t, ok)
+}
@@ -0,0 +1,156 @@
+package experiment
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrExperimentNotFound = errors.New("experiment not found")
+
+const (
+	StatusDraft   = "draft"
+	StatusRunning = "running"
+	StatusStopped = "stopped"
+)
+
+type Variant struct {
+	Key    string `json:"key"`
+	Weight int    `json:"weight"`
+}
+
+type Experiment struct {
+	ExperimentID uint      `gorm:"primaryKey;autoIncrement" json:"experiment_id"`
+	Key          string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"key"`
+	Salt         string    `gorm:"type:varchar(64);not null" json:"salt"`
+	Status       string    `gorm:"type:varchar(16);not null;index" json:"status"`
+	SegmentID    uint      `json:"segment_id"`                         // 0 表示不限定人群
+	Variants     string    `gorm:"type:text;not null" json:"variants"` // []Variant 的 JSON
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (e *Experiment) ParseVariants() ([]Variant, error) {
+	var variants []Variant
+	if err := json.Unmarshal([]byte(e.Variants), &variants); err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// Assignment 一经写入不再改变，保证流量调整不会重新分配老用户
+type Assignment struct {
+	ExperimentID uint      `gorm:"primaryKey" json:"experiment_id"`
+	UserID       uint      `gorm:"primaryKey;index" json:"user_id"`
+	Variant      string    `gorm:"type:varchar(64);not null" json:"variant"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// VariantCount 是分组的用户数与转化用户数
+type VariantCount struct {
+	Variant     string `json:"variant"`
+	Users       int64  `json:"users"`
+	Conversions int64  `json:"conversions"`
+}
+
+type experimentRepository struct {
+	db *gorm.DB
+}
+
+type ExperimentRepository interface {
+	Create(experiment *Experiment) error
+	Update(experiment *Experiment) error
+	Find(experimentID uint) (*Experiment, error)
+	List() ([]Experiment, error)
+	ListRunning() ([]Experiment, error)
+	FindAssignments(userID uint, experimentIDs []uint) ([]Assignment, error)
+	// SaveAssignment 并发写入同一用户时以先写入者为准，返回最终生效的分组
+	SaveAssignment(assignment *Assignment) (string, error)
+	// CountConversions 按分组统计用户数，分组后 window 内发生过 conversionEvent 的算作转化，window 为 0 表示不限
+	CountConversions(experimentID uint, conversionEvent string, window time.Duration) ([]VariantCount, error)
+}
+
+func NewExperimentRepository(db *gorm.DB) ExperimentRepository {
+	if err := db.AutoMigrate(&Experiment{}, &Assignment{}); err != nil {
+		panic("failed to migrate experiment tables")
+	}
+	return &experimentRepository{db: db}
+}
+
+func (repo *experimentRepository) Create(experiment *Experiment) error {
+	return repo.db.Create(experiment).Error
+}
+
+func (repo *experimentRepository) Update(experiment *Experiment) error {
+	return repo.db.Model(&Experiment{}).Where("experiment_id = ?", experiment.ExperimentID).Updates(map[string]any{
+		"status":     experiment.Status,
+		"segment_id": experiment.SegmentID,
+		"variants":   experiment.Variants,
+	}).Error
+}
+
+func (repo *experimentRepository) Find(experimentID uint) (*Experiment, error) {
+	var experiment Experiment
+	if err := repo.db.First(&experiment, experimentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExperimentNotFound
+		}
+		return nil, err
+	}
+	return &experiment, nil
+}
+
+func (repo *experimentRepository) List() ([]Experiment, error) {
+	var experiments []Experiment
+	err := repo.db.Order("experiment_id").Find(&experiments).Error
+	return experiments, err
+}
+
+func (repo *experimentRepository) ListRunning() ([]Experiment, error) {
+	var experiments []Experiment
+	err := repo.db.Where("status = ?", StatusRunning).Order("experiment_id").Find(&experiments).Error
+	return experiments, err
+}
+
+func (repo *experimentRepository) FindAssignments(userID uint, experimentIDs []uint) ([]Assignment, error) {
+	var assignments []Assignment
+	if len(experimentIDs) == 0 {
+		return assignments, nil
+	}
+	err := repo.db.Where("user_id = ? AND experiment_id IN ?", userID, experimentIDs).Find(&assignments).Error
+	return assignments, err
+}
+
+func (repo *experimentRepository) SaveAssignment(assignment *Assignment) (string, error) {
+	result := repo.db.Clauses(clause.OnConflict{DoNothing: true}).Create(assignment)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected > 0 {
+		return assignment.Variant, nil
+	}
+	var existing Assignment
+	err := repo.db.Where("experiment_id = ? AND user_id = ?", assignment.ExperimentID, assignment.UserID).First(&existing).Error
+	return existing.Variant, err
+}
+
+func (repo *experimentRepository) CountConversions(experimentID uint, conversionEvent string, window time.Duration) ([]VariantCount, error) {
+	cond := "e.user_id = a.user_id AND e.name = ? AND e.created_at >= a.created_at"
+	args := []any{conversionEvent}
+	if window > 0 {
+		cond += " AND e.created_at < DATE_ADD(a.created_at, INTERVAL ? SECOND)"
+		args = append(args, int64(window.Seconds()))
+	}
+	var counts []VariantCount
+	err := repo.db.Table("assignments AS a").
+		Select("a.variant AS variant, COUNT(*) AS users, "+
+			"SUM(EXISTS (SELECT 1 FROM user_events e WHERE "+cond+")) AS conversions", args...).
+		Where("a.experiment_id = ?", experimentID).
+		Group("a.variant").
+		Order("a.variant").
+		Scan(&counts).Error
+	return counts, err
+}
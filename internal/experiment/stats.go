@@ -0,0 +1,134 @@
+package experiment
+
+import "math"
+
+// NormalCDF 标准正态分布的累积分布函数
+func NormalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// NormalQuantile 标准正态分布的分位数，p 需在 (0, 1) 内
+func NormalQuantile(p float64) float64 {
+	return -math.Sqrt2 * math.Erfcinv(2*p)
+}
+
+// WilsonInterval 转化率的 Wilson 置信区间，样本小或转化率接近 0、1 时比正态近似可靠
+func WilsonInterval(successes, n int64, confidence float64) (float64, float64) {
+	if n <= 0 {
+		return 0, 0
+	}
+	z := NormalQuantile(1 - (1-confidence)/2)
+	nf := float64(n)
+	p := float64(successes) / nf
+	denom := 1 + z*z/nf
+	center := (p + z*z/(2*nf)) / denom
+	half := z * math.Sqrt(p*(1-p)/nf+z*z/(4*nf*nf)) / denom
+	return math.Max(0, center-half), math.Min(1, center+half)
+}
+
+// TwoProportionZTest 合并方差的双比例 z 检验，返回 z 值与双侧 p 值，z 为正表示 b 高于 a
+func TwoProportionZTest(successesA, nA, successesB, nB int64) (float64, float64) {
+	if nA <= 0 || nB <= 0 {
+		return 0, 1
+	}
+	pA := float64(successesA) / float64(nA)
+	pB := float64(successesB) / float64(nB)
+	pooled := float64(successesA+successesB) / float64(nA+nB)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(nA) + 1/float64(nB)))
+	if se == 0 {
+		return 0, 1
+	}
+	z := (pB - pA) / se
+	return z, math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// DiffInterval 转化率之差 pB-pA 的置信区间，使用非合并方差
+func DiffInterval(successesA, nA, successesB, nB int64, confidence float64) (float64, float64) {
+	if nA <= 0 || nB <= 0 {
+		return 0, 0
+	}
+	z := NormalQuantile(1 - (1-confidence)/2)
+	pA := float64(successesA) / float64(nA)
+	pB := float64(successesB) / float64(nB)
+	se := math.Sqrt(pA*(1-pA)/float64(nA) + pB*(1-pB)/float64(nB))
+	return pB - pA - z*se, pB - pA + z*se
+}
+
+// ChiSquareTest 拟合优度检验，expected 为各组期望比例（无需归一化），返回卡方值与 p 值
+func ChiSquareTest(observed []int64, expected []float64) (float64, float64) {
+	var total int64
+	var weight float64
+	for i := range observed {
+		total += observed[i]
+		weight += expected[i]
+	}
+	if len(observed) < 2 || total == 0 || weight <= 0 {
+		return 0, 1
+	}
+	chi2 := 0.0
+	for i := range observed {
+		e := float64(total) * expected[i] / weight
+		if e == 0 {
+			continue
+		}
+		d := float64(observed[i]) - e
+		chi2 += d * d / e
+	}
+	return chi2, ChiSquareSurvival(chi2, float64(len(observed)-1))
+}
+
+// ChiSquareSurvival 自由度为 df 的卡方分布上尾概率 P(X >= x)
+func ChiSquareSurvival(x, df float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+	return gammaQ(df/2, x/2)
+}
+
+const (
+	gammaEpsilon    = 1e-14
+	gammaIterations = 500
+)
+
+// gammaQ 正则化上不完全伽马函数，x < a+1 时用级数展开，否则用连分式
+func gammaQ(a, x float64) float64 {
+	lg, _ := math.Lgamma(a)
+	prefix := math.Exp(-x + a*math.Log(x) - lg)
+	if x < a+1 {
+		sum, term := 1/a, 1/a
+		for n := 1; n < gammaIterations; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*gammaEpsilon {
+				break
+			}
+		}
+		return math.Max(0, 1-sum*prefix)
+	}
+
+	// Lentz 算法
+	const tiny = 1e-300
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < gammaIterations; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < gammaEpsilon {
+			break
+		}
+	}
+	return prefix * h
+}
@@ -0,0 +1,104 @@
+package experiment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormal(t *testing.T) {
+	assert.InDelta(t, 0.5, NormalCDF(0), 1e-12)
+	assert.InDelta(t, 0.9750021048517795, NormalCDF(1.96), 1e-12)
+	assert.InDelta(t, 0.0227501319481792, NormalCDF(-2), 1e-12)
+	assert.InDelta(t, 1.959963984540054, NormalQuantile(0.975), 1e-9)
+	assert.InDelta(t, -1.6448536269514729, NormalQuantile(0.05), 1e-9)
+}
+
+func TestWilsonInterval(t *testing.T) {
+	lo, hi := WilsonInterval(20, 100, 0.95)
+	assert.InDelta(t, 0.1333669333310325, lo, 1e-9)
+	assert.InDelta(t, 0.2888291655931589, hi, 1e-9)
+
+	// 零转化时下限为 0，上限仍大于 0
+	lo, hi = WilsonInterval(0, 50, 0.95)
+	assert.Equal(t, 0.0, lo)
+	assert.InDelta(t, 0.07134759913, hi, 1e-9)
+
+	lo, hi = WilsonInterval(0, 0, 0.95)
+	assert.Equal(t, 0.0, lo)
+	assert.Equal(t, 0.0, hi)
+}
+
+func TestTwoProportionZTest(t *testing.T) {
+	z, p := TwoProportionZTest(200, 1000, 250, 1000)
+	assert.InDelta(t, 2.677397763008329, z, 1e-9)
+	assert.InDelta(t, 0.007419649261025674, p, 1e-9)
+
+	z, p = TwoProportionZTest(250, 1000, 200, 1000)
+	assert.InDelta(t, -2.677397763008329, z, 1e-9)
+	assert.InDelta(t, 0.007419649261025674, p, 1e-9)
+
+	lo, hi := DiffInterval(200, 1000, 250, 1000, 0.95)
+	assert.InDelta(t, 0.013463621687539853, lo, 1e-9)
+	assert.InDelta(t, 0.08653637831246015, hi, 1e-9)
+
+	// 两组都没有转化时无法检验
+	z, p = TwoProportionZTest(0, 100, 0, 100)
+	assert.Equal(t, 0.0, z)
+	assert.Equal(t, 1.0, p)
+}
+
+func TestChiSquare(t *testing.T) {
+	// 常用临界值：各自由度下 0.05 与 0.001 的上尾概率
+	assert.InDelta(t, 0.05, ChiSquareSurvival(3.841458820694124, 1), 1e-9)
+	assert.InDelta(t, 0.05, ChiSquareSurvival(5.991464547107979, 2), 1e-9)
+	assert.InDelta(t, 0.05, ChiSquareSurvival(7.814727903251178, 3), 1e-9)
+	assert.InDelta(t, 0.001, ChiSquareSurvival(10.827566170662733, 1), 1e-9)
+	assert.InDelta(t, 0.05, ChiSquareSurvival(18.307038053275146, 10), 1e-9)
+	assert.InDelta(t, 0.95, ChiSquareSurvival(3.940299136119145, 10), 1e-9)
+	assert.Equal(t, 1.0, ChiSquareSurvival(0, 3))
+
+	chi2, p := ChiSquareTest([]int64{5000, 5200}, []float64{1, 1})
+	assert.InDelta(t, 3.9215686274509802, chi2, 1e-9)
+	assert.InDelta(t, 0.04767038065616144, p, 1e-9)
+
+	// 期望比例 1:3，观测完全吻合
+	chi2, p = ChiSquareTest([]int64{250, 750}, []float64{1, 3})
+	assert.Equal(t, 0.0, chi2)
+	assert.Equal(t, 1.0, p)
+}
+
+func TestAnalyze(t *testing.T) {
+	variants := []Variant{{Key: "control", Weight: 1}, {Key: "a", Weight: 1}, {Key: "b", Weight: 1}}
+	counts := []VariantCount{
+		{Variant: "a", Users: 1000, Conversions: 250},
+		{Variant: "b", Users: 1000, Conversions: 215},
+		{Variant: "control", Users: 1000, Conversions: 200},
+		{Variant: "removed", Users: 10, Conversions: 1},
+	}
+	report := Analyze(variants, counts, "", 0.95)
+	assert.Equal(t, "control", report.Control)
+	// 三组与对照组比较，显著性水平按 Bonferroni 校正
+	assert.InDelta(t, 0.05/3, report.Alpha, 1e-12)
+	if !assert.Len(t, report.Variants, 4) {
+		return
+	}
+	ctrl, a, b := report.Variants[0], report.Variants[1], report.Variants[2]
+	assert.True(t, ctrl.Control)
+	assert.Equal(t, 0.2, ctrl.Rate)
+	assert.InDelta(t, 0.05, a.Diff, 1e-12)
+	assert.InDelta(t, 0.25, a.Lift, 1e-12)
+	assert.True(t, a.Significant)
+	assert.False(t, b.Significant)
+	assert.Equal(t, "removed", report.Variants[3].Variant)
+	assert.Equal(t, "a", report.Winner)
+	assert.False(t, report.SampleRatio.Mismatch)
+
+	// 分流比例严重偏离权重
+	counts = []VariantCount{{Variant: "control", Users: 1000}, {Variant: "a", Users: 1200}}
+	report = Analyze(variants[:2], counts, "a", 0.95)
+	assert.Equal(t, "a", report.Control)
+	assert.InDelta(t, 0.05, report.Alpha, 1e-12)
+	assert.True(t, report.SampleRatio.Mismatch)
+	assert.Equal(t, "", report.Winner)
+}
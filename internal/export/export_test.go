@@ -0,0 +1,163 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEvents 按主键分页返回内存中的事件，failAfter 次调用后返回错误以模拟中途崩溃
+type fakeEvents struct {
+	rows      []EventRow
+	calls     []uint64
+	failAfter int
+}
+
+func (f *fakeEvents) Name() string { return DatasetEvents }
+func (f *fakeEvents) Model() any   { return EventRow{} }
+
+func (f *fakeEvents) Fetch(ctx context.Context, start, end time.Time, afterID uint64, limit int) ([]any, uint64, error) {
+	f.calls = append(f.calls, afterID)
+	if f.failAfter > 0 && len(f.calls) > f.failAfter {
+		return nil, 0, errors.New("connection lost")
+	}
+	var rows []any
+	last := afterID
+	for _, r := range f.rows {
+		if r.EventID > afterID && !r.ReceivedAt.Before(start) && r.ReceivedAt.Before(end) && len(rows) < limit {
+			rows = append(rows, r)
+			last = r.EventID
+		}
+	}
+	return rows, last, nil
+}
+
+var testDay = time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func newFakeEvents() *fakeEvents {
+	f := &fakeEvents{}
+	for i := uint64(1); i <= 5; i++ {
+		f.rows = append(f.rows, EventRow{
+			EventID:    i * 10,
+			UserID:     i,
+			Name:       "login",
+			Properties: `{"a":"x,y"}`,
+			CreatedAt:  testDay.Add(time.Duration(i) * time.Hour),
+			ReceivedAt: testDay.Add(time.Duration(i) * time.Hour),
+		})
+	}
+	// 前一天接收的事件不属于该分区
+	f.rows = append(f.rows, EventRow{EventID: 60, Name: "login", CreatedAt: testDay.Add(-time.Minute), ReceivedAt: testDay.Add(-time.Minute)})
+	return f
+}
+
+func newTestExporter(dir string, ds Dataset) *Exporter {
+	formats, _ := ParseFormats("csv, parquet")
+	x := NewExporter(NewLocalTarget(dir), []Dataset{ds}, formats, Options{PartRows: 2}, logs.Nop())
+	x.now = func() time.Time { return testDay.AddDate(0, 0, 1) }
+	return x
+}
+
+func TestExportPartition(t *testing.T) {
+	dir := t.TempDir()
+	ds := newFakeEvents()
+	x := newTestExporter(dir, ds)
+
+	manifest, err := x.ExportPartition(context.Background(), ds, testDay, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(5), manifest.Rows)
+	assert.Len(t, manifest.Files, 6)
+	assert.Equal(t, "events/dt=2026-06-01/part-00002.parquet", manifest.Files[5].Path)
+	assert.Equal(t, Columns(EventRow{}), manifest.Columns)
+
+	raw, err := os.ReadFile(filepath.Join(dir, "events/dt=2026-06-01/_manifest.json"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	var onDisk Manifest
+	assert.NoError(t, json.Unmarshal(raw, &onDisk))
+	assert.Equal(t, manifest.Files, onDisk.Files)
+
+	data, _ := os.ReadFile(filepath.Join(dir, "events/dt=2026-06-01/part-00000.csv"))
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, "event_id", records[0][0])
+		assert.Equal(t, []string{"10", "1", "login", `{"a":"x,y"}`}, records[1][:4])
+		assert.Equal(t, "2026-06-01T01:00:00Z", records[1][10])
+	}
+
+	rows, err := parquet.ReadFile[EventRow](filepath.Join(dir, "events/dt=2026-06-01/part-00002.parquet"))
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, uint64(50), rows[0].EventID)
+		assert.True(t, testDay.Add(5*time.Hour).Equal(rows[0].CreatedAt))
+	}
+
+	// 已有清单的分区不再读取数据，force 时重新导出
+	ds.calls = nil
+	_, err = x.ExportPartition(context.Background(), ds, testDay, false)
+	assert.NoError(t, err)
+	assert.Empty(t, ds.calls)
+	_, err = x.ExportPartition(context.Background(), ds, testDay, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{0, 20, 40}, ds.calls)
+}
+
+func TestExportResume(t *testing.T) {
+	dir := t.TempDir()
+	ds := newFakeEvents()
+	ds.failAfter = 1
+	x := newTestExporter(dir, ds)
+
+	err := x.Backfill(context.Background(), testDay, testDay, false)
+	assert.ErrorContains(t, err, "events/dt=2026-06-01: connection lost")
+	_, err = os.Stat(filepath.Join(dir, "events/dt=2026-06-01/_manifest.json"))
+	assert.True(t, os.IsNotExist(err))
+
+	ds.failAfter = 0
+	ds.calls = nil
+	manifest, err := x.ExportPartition(context.Background(), ds, testDay, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// 从第一个分片之后继续
+	assert.Equal(t, []uint64{20, 40}, ds.calls)
+	assert.Equal(t, int64(5), manifest.Rows)
+	assert.Len(t, manifest.Files, 6)
+}
+
+func TestUserRowParquet(t *testing.T) {
+	login := testDay.Add(time.Hour)
+	rows := []any{UserRow{UserID: 1, Username: "a", CreatedAt: testDay, LastLoginAt: &login}, UserRow{UserID: 2, Username: "b", CreatedAt: testDay}}
+	data, err := Parquet{}.Encode(UserRow{}, rows)
+	if !assert.NoError(t, err) {
+		return
+	}
+	out, err := parquet.Read[UserRow](bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	if assert.Len(t, out, 2) {
+		assert.True(t, login.Equal(*out[0].LastLoginAt))
+		assert.Nil(t, out[1].LastLoginAt)
+	}
+
+	data, _ = CSV{}.Encode(UserRow{}, rows)
+	assert.Equal(t, "user_id,username,created_at,last_login_at,banned_at,ban_reason\n"+
+		"1,a,2026-06-01T00:00:00Z,2026-06-01T01:00:00Z,,\n"+
+		"2,b,2026-06-01T00:00:00Z,,,\n", string(data))
+
+	_, err = ParseFormats("csv,orc")
+	assert.Error(t, err)
+}
@@ -0,0 +1,228 @@
+package export
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	config "usergrowth/configs"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/net/gtrace"
+	"gorm.io/gorm"
+)
+
+const (
+	checkpointFile = "_checkpoint.json"
+	manifestFile   = "_manifest.json"
+)
+
+// File 是分区内的一个数据文件
+type File struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+	Part   int    `json:"part"`
+	Rows   int    `json:"rows"`
+	Bytes  int    `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// Checkpoint 记录分区已写完的分片，进程中途退出后从 LastID 之后继续
+type Checkpoint struct {
+	LastID uint64 `json:"last_id"`
+	Parts  int    `json:"parts"`
+	Rows   int64  `json:"rows"`
+	Files  []File `json:"files"`
+}
+
+// Manifest 在分区全部写完后最后写入，下游以它的存在判断分区完整，文件列表以它为准
+type Manifest struct {
+	Dataset    string    `json:"dataset"`
+	Date       string    `json:"date"`
+	Columns    []string  `json:"columns"`
+	Rows       int64     `json:"rows"`
+	Files      []File    `json:"files"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+type Options struct {
+	PartRows    int // 每个分片的最大行数
+	CatchUpDays int // 定时任务回看的天数，补上停机期间漏掉的分区
+}
+
+// Exporter 把每个数据集按天导出为 {dataset}/dt=YYYY-MM-DD/ 下的分片文件，每个分片按所有格式各写一份
+type Exporter struct {
+	target   Target
+	datasets []Dataset
+	formats  []Format
+	opts     Options
+	now      func() time.Time
+	logger   logs.Logger
+}
+
+func NewExporter(target Target, datasets []Dataset, formats []Format, opts Options, logger logs.Logger) *Exporter {
+	if opts.PartRows <= 0 {
+		opts.PartRows = 50000
+	}
+	if opts.CatchUpDays <= 0 {
+		opts.CatchUpDays = 1
+	}
+	return &Exporter{
+		target:   target,
+		datasets: datasets,
+		formats:  formats,
+		opts:     opts,
+		now:      time.Now,
+		logger:   logger,
+	}
+}
+
+func partitionDir(dataset string, day time.Time) string {
+	return dataset + "/dt=" + day.Format(time.DateOnly)
+}
+
+// Run 作为定时任务执行：导出最近 CatchUpDays 个已结束的自然日中尚未完成的分区
+func (x *Exporter) Run(ctx context.Context) {
+	now := x.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if err := x.Backfill(ctx, today.AddDate(0, 0, -x.opts.CatchUpDays), today.AddDate(0, 0, -1), false); err != nil {
+		x.logger.Error(ctx, "export failed:", err.Error())
+	}
+}
+
+// Backfill 依次导出 [from, to] 内的每一天，force 为真时忽略已有的清单与断点重新导出
+func (x *Exporter) Backfill(ctx context.Context, from, to time.Time, force bool) error {
+	var errs []error
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		for _, ds := range x.datasets {
+			if _, err := x.ExportPartition(ctx, ds, day, force); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", partitionDir(ds.Name(), day), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ExportPartition 导出单个数据集某一天的分区；分区已有清单时直接返回
+func (x *Exporter) ExportPartition(ctx context.Context, ds Dataset, day time.Time, force bool) (*Manifest, error) {
+	ctx, span := gtrace.NewSpan(ctx, "Export.Partition")
+	defer span.End()
+
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+	dir := partitionDir(ds.Name(), start)
+
+	cp := &Checkpoint{}
+	if !force {
+		var manifest Manifest
+		found, err := x.load(ctx, dir+"/"+manifestFile, &manifest)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return &manifest, nil
+		}
+		if _, err = x.load(ctx, dir+"/"+checkpointFile, cp); err != nil {
+			return nil, err
+		}
+		if cp.Parts > 0 {
+			x.logger.Info(ctx, "Export resumed:", dir, "parts:", cp.Parts)
+		}
+	}
+
+	model := ds.Model()
+	for {
+		rows, lastID, err := ds.Fetch(ctx, start, end, cp.LastID, x.opts.PartRows)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		// 分片在写入断点前中断时，重启后以同样的文件名与内容重写
+		for _, f := range x.formats {
+			data, err := f.Encode(model, rows)
+			if err != nil {
+				return nil, err
+			}
+			file := File{
+				Path:   fmt.Sprintf("%s/part-%05d.%s", dir, cp.Parts, f.Name()),
+				Format: f.Name(),
+				Part:   cp.Parts,
+				Rows:   len(rows),
+				Bytes:  len(data),
+			}
+			sum := sha256.Sum256(data)
+			file.SHA256 = hex.EncodeToString(sum[:])
+			if err = x.target.Put(ctx, file.Path, data); err != nil {
+				return nil, err
+			}
+			cp.Files = append(cp.Files, file)
+		}
+		cp.LastID = lastID
+		cp.Parts++
+		cp.Rows += int64(len(rows))
+		if err = x.save(ctx, dir+"/"+checkpointFile, cp); err != nil {
+			return nil, err
+		}
+		if len(rows) < x.opts.PartRows {
+			break
+		}
+	}
+
+	manifest := &Manifest{
+		Dataset:    ds.Name(),
+		Date:       start.Format(time.DateOnly),
+		Columns:    Columns(model),
+		Rows:       cp.Rows,
+		Files:      cp.Files,
+		ExportedAt: x.now(),
+	}
+	if manifest.Files == nil {
+		manifest.Files = []File{}
+	}
+	if err := x.save(ctx, dir+"/"+manifestFile, manifest); err != nil {
+		return nil, err
+	}
+	x.logger.Info(ctx, "Export finished:", dir, "rows:", cp.Rows, "parts:", cp.Parts)
+	return manifest, nil
+}
+
+func (x *Exporter) load(ctx context.Context, key string, v any) (bool, error) {
+	data, err := x.target.Get(ctx, key)
+	if errors.Is(err, ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func (x *Exporter) save(ctx context.Context, key string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return x.target.Put(ctx, key, data)
+}
+
+// NewFromConfig 按配置组装导出任务，服务内定时任务与命令行补导共用
+func NewFromConfig(db *gorm.DB, cfg *config.ExportConfig, logger logs.Logger) (*Exporter, error) {
+	target, err := NewTarget(cfg)
+	if err != nil {
+		return nil, err
+	}
+	formats, err := ParseFormats(cfg.Formats)
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := NewDatasets(db, cfg.Datasets)
+	if err != nil {
+		return nil, err
+	}
+	return NewExporter(target, datasets, formats, Options{PartRows: cfg.PartRows, CatchUpDays: cfg.CatchUpDays}, logger), nil
+}
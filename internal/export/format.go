@@ -0,0 +1,140 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// 导出文件格式
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// Format 把一批行编码为单个文件，行是 Dataset.Model 同类型的结构体
+type Format interface {
+	Name() string
+	Encode(model any, rows []any) ([]byte, error)
+}
+
+// ParseFormats 解析逗号分隔的格式列表
+func ParseFormats(s string) ([]Format, error) {
+	var formats []Format
+	seen := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		switch name {
+		case FormatCSV:
+			formats = append(formats, CSV{})
+		case FormatParquet:
+			formats = append(formats, Parquet{})
+		default:
+			return nil, fmt.Errorf("unknown export format: %s", name)
+		}
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no export format configured")
+	}
+	return formats, nil
+}
+
+// Columns 返回行结构体的列名，取自 parquet 标签，CSV 与 Parquet 列保持一致
+func Columns(model any) []string {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	columns := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("parquet"), ",")
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		columns = append(columns, name)
+	}
+	return columns
+}
+
+// CSV 带表头，时间使用 RFC 3339，空指针输出为空串
+type CSV struct{}
+
+func (CSV) Name() string {
+	return FormatCSV
+}
+
+func (CSV) Encode(model any, rows []any) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(Columns(model)); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		v := reflect.Indirect(reflect.ValueOf(row))
+		record := make([]string, v.NumField())
+		for i := range record {
+			record[i] = csvValue(v.Field(i))
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func csvValue(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// Parquet 使用 Snappy 压缩，schema 由行结构体的 parquet 标签推导
+type Parquet struct{}
+
+func (Parquet) Name() string {
+	return FormatParquet
+}
+
+func (Parquet) Encode(model any, rows []any) ([]byte, error) {
+	var buf bytes.Buffer
+	w := parquet.NewWriter(&buf, parquet.SchemaOf(model), parquet.Compression(&parquet.Snappy))
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
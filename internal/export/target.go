@@ -0,0 +1,121 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	config "usergrowth/configs"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+var ErrNotExist = errors.New("export object not exist")
+
+// Target 是导出文件的存放位置，key 使用 / 分隔
+type Target interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Get 在对象不存在时返回 ErrNotExist
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// NewTarget 按配置创建导出目标：local 写入本地目录，s3 写入 S3 兼容存储
+func NewTarget(cfg *config.ExportConfig) (Target, error) {
+	switch cfg.Target {
+	case "local":
+		return NewLocalTarget(cfg.Dir), nil
+	case "s3":
+		client, err := minio.New(cfg.S3.Endpoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(cfg.S3.AccessKey, cfg.S3.SecretKey, ""),
+			Secure: cfg.S3.UseSSL,
+			Region: cfg.S3.Region,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return NewS3Target(client, cfg.S3.Bucket, cfg.S3.Prefix), nil
+	default:
+		return nil, fmt.Errorf("unknown export target: %s", cfg.Target)
+	}
+}
+
+type LocalTarget struct {
+	dir string
+}
+
+func NewLocalTarget(dir string) *LocalTarget {
+	return &LocalTarget{dir: dir}
+}
+
+// Put 先写临时文件再改名，进程中途退出不会留下不完整的文件
+func (t *LocalTarget) Put(ctx context.Context, key string, data []byte) error {
+	name := filepath.Join(t.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (t *LocalTarget) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(t.dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotExist
+	}
+	return data, err
+}
+
+type S3Target struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func NewS3Target(client *minio.Client, bucket, prefix string) *S3Target {
+	return &S3Target{client: client, bucket: bucket, prefix: strings.Trim(prefix, "/")}
+}
+
+func (t *S3Target) key(key string) string {
+	if t.prefix == "" {
+		return key
+	}
+	return path.Join(t.prefix, key)
+}
+
+// Put 单次上传整个对象，S3 上对象要么完整可见要么不存在
+func (t *S3Target) Put(ctx context.Context, key string, data []byte) error {
+	_, err := t.client.PutObject(ctx, t.bucket, t.key(key), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{})
+	return err
+}
+
+func (t *S3Target) Get(ctx context.Context, key string) ([]byte, error) {
+	obj, err := t.client.GetObject(ctx, t.bucket, t.key(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	var buf bytes.Buffer
+	if _, err = buf.ReadFrom(obj); err != nil {
+		if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+			return nil, ErrNotExist
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,196 @@
+package featureflag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/segment"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/os/gfsnotify"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+type definitions struct {
+	Flags []Flag `json:"flags"`
+}
+
+// Client 持有当前生效的开关定义，定义更新时整体原子替换，评估过程无锁
+type Client struct {
+	flags    atomic.Pointer[map[string]*Flag]
+	profiler *segment.Profiler
+	logger   logs.Logger
+	mu       sync.Mutex
+	lastRaw  string
+}
+
+func NewClient(profiler *segment.Profiler, logger logs.Logger) *Client {
+	c := &Client{
+		profiler: profiler,
+		logger:   logger,
+	}
+	empty := make(map[string]*Flag)
+	c.flags.Store(&empty)
+	return c
+}
+
+// Load 解析 JSON 或 YAML 格式的定义，任一开关非法时保留旧定义
+func (c *Client) Load(raw []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if string(raw) == c.lastRaw {
+		return nil
+	}
+
+	j, err := gjson.LoadContent(raw)
+	if err != nil {
+		return err
+	}
+	var defs definitions
+	if err = json.Unmarshal(j.MustToJson(), &defs); err != nil {
+		return err
+	}
+	flags := make(map[string]*Flag, len(defs.Flags))
+	for i := range defs.Flags {
+		f := &defs.Flags[i]
+		if err = f.normalize(); err != nil {
+			return err
+		}
+		if _, ok := flags[f.Key]; ok {
+			return fmt.Errorf("%w: duplicate key %s", ErrInvalidFlag, f.Key)
+		}
+		flags[f.Key] = f
+	}
+	c.flags.Store(&flags)
+	c.lastRaw = string(raw)
+	return nil
+}
+
+// WatchFile 加载文件并在文件变更时热更新
+func (c *Client) WatchFile(path string) error {
+	load := func() error {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return c.Load(raw)
+	}
+	if err := load(); err != nil {
+		return err
+	}
+	_, err := gfsnotify.Add(path, func(event *gfsnotify.Event) {
+		if event.IsWrite() || event.IsCreate() || event.IsRename() {
+			if err := load(); err != nil {
+				c.logger.Error(context.Background(), "feature flag reload failed:", path, err.Error())
+				return
+			}
+			c.logger.Info(context.Background(), "feature flags reloaded from file:", path)
+		}
+	})
+	return err
+}
+
+// WatchRedis 定期拉取 Redis 中的定义，内容未变化时不会重新解析
+func (c *Client) WatchRedis(ctx context.Context, rdb goredis.Cmdable, key string, interval time.Duration) {
+	load := func() {
+		raw, err := rdb.Get(ctx, key).Bytes()
+		if err != nil {
+			if err != goredis.Nil {
+				c.logger.Error(ctx, "feature flag fetch failed:", key, err.Error())
+			}
+			return
+		}
+		if err = c.Load(raw); err != nil {
+			c.logger.Error(ctx, "feature flag reload failed:", key, err.Error())
+		}
+	}
+	load()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				load()
+			}
+		}
+	}()
+}
+
+func (c *Client) subject(userID uint) SubjectFunc {
+	var (
+		profile *segment.Profile
+		err     error
+	)
+	return func(ctx context.Context) (segment.Subject, error) {
+		if profile == nil && err == nil {
+			profile, err = c.profiler.Profile(ctx, userID)
+		}
+		return profile, err
+	}
+}
+
+// Evaluate 评估单个开关，开关不存在时返回 nil 值与 not_found
+func (c *Client) Evaluate(ctx context.Context, key string, userID uint) (*Evaluation, error) {
+	f, ok := (*c.flags.Load())[key]
+	if !ok {
+		return &Evaluation{Key: key, Reason: ReasonNotFound}, nil
+	}
+	return f.Evaluate(ctx, userID, c.subject(userID), time.Now())
+}
+
+// Bool 供业务代码使用，出错或值类型不符时返回 def
+func (c *Client) Bool(ctx context.Context, key string, userID uint, def bool) bool {
+	e, err := c.Evaluate(ctx, key, userID)
+	if err != nil {
+		c.logger.Error(ctx, "feature flag evaluate failed:", key, err.Error())
+		return def
+	}
+	if v, ok := e.Value.(bool); ok {
+		return v
+	}
+	return def
+}
+
+// Variant 供多分组开关使用，出错或开关不存在时返回 def
+func (c *Client) Variant(ctx context.Context, key string, userID uint, def string) string {
+	e, err := c.Evaluate(ctx, key, userID)
+	if err != nil {
+		c.logger.Error(ctx, "feature flag evaluate failed:", key, err.Error())
+		return def
+	}
+	if e.Reason == ReasonNotFound {
+		return def
+	}
+	return e.Variant
+}
+
+// All 评估全部开关，同一次调用内共享用户画像
+func (c *Client) All(ctx context.Context, userID uint) ([]*Evaluation, error) {
+	flags := *c.flags.Load()
+	keys := make([]string, 0, len(flags))
+	for k := range flags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	subject := c.subject(userID)
+	now := time.Now()
+	result := make([]*Evaluation, 0, len(keys))
+	for _, k := range keys {
+		e, err := flags[k].Evaluate(ctx, userID, subject, now)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
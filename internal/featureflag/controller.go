@@ -0,0 +1,57 @@
+package featureflag
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type FlagsReq struct {
+	g.Meta `path:"/api/flags" method:"get"`
+}
+
+type FlagsRes struct {
+}
+
+type Controller struct {
+	client *Client
+}
+
+func NewController(client *Client) *Controller {
+	return &Controller{client: client}
+}
+
+func (c *Controller) Flags(ctx context.Context, req *FlagsReq) (res *FlagsRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "FeatureFlag.Flags")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	userid := r.GetCtxVar("userid").String()
+	uid, err := strconv.ParseUint(userid, 10, 64)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+	span.SetAttributes(attribute.String("user.id", userid))
+
+	evaluations, err := c.client.All(ctx, uint(uid))
+	if err != nil {
+		return nil, err
+	}
+	flags := make(g.Map, len(evaluations))
+	for _, e := range evaluations {
+		flags[e.Key] = e.Value
+	}
+
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    flags,
+	})
+	return nil, nil
+}
@@ -0,0 +1,178 @@
+package featureflag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"usergrowth/internal/experiment"
+	"usergrowth/internal/segment"
+)
+
+var ErrInvalidFlag = errors.New("invalid feature flag")
+
+const (
+	VariantOn  = "on"
+	VariantOff = "off"
+)
+
+// 评估结果的命中原因，便于排查用户为什么拿到某个值
+const (
+	ReasonDisabled  = "disabled"
+	ReasonAllowlist = "allowlist"
+	ReasonRule      = "rule"
+	ReasonRollout   = "rollout"
+	ReasonDefault   = "default"
+	ReasonNotFound  = "not_found"
+)
+
+// Target 把名单内的用户固定到某个分组
+type Target struct {
+	Variant string   `json:"variant"`
+	UserIDs []string `json:"user_ids"`
+}
+
+// TargetRule 命中属性规则后返回固定分组，或在分组间按比例放量
+type TargetRule struct {
+	Rule    segment.Rule         `json:"rule"`
+	Variant string               `json:"variant,omitempty"`
+	Rollout []experiment.Variant `json:"rollout,omitempty"`
+}
+
+// Flag 不声明 variants 时视为布尔开关，取值 on=true / off=false
+type Flag struct {
+	Key      string               `json:"key"`
+	Enabled  bool                 `json:"enabled"`
+	Variants map[string]any       `json:"variants,omitempty"`
+	Default  string               `json:"default,omitempty"`
+	Off      string               `json:"off,omitempty"` // 关闭时返回的分组
+	Targets  []Target             `json:"targets,omitempty"`
+	Rules    []TargetRule         `json:"rules,omitempty"`
+	Rollout  []experiment.Variant `json:"rollout,omitempty"`
+	Salt     string               `json:"salt,omitempty"`
+}
+
+type Evaluation struct {
+	Key     string `json:"key"`
+	Variant string `json:"variant"`
+	Value   any    `json:"value"`
+	Reason  string `json:"reason"`
+}
+
+// SubjectFunc 延迟构造规则评估所需的用户画像，只有存在属性规则时才会调用
+type SubjectFunc func(ctx context.Context) (segment.Subject, error)
+
+func (f *Flag) normalize() error {
+	if f.Key == "" {
+		return fmt.Errorf("%w: key is required", ErrInvalidFlag)
+	}
+	if len(f.Variants) == 0 {
+		f.Variants = map[string]any{VariantOn: true, VariantOff: false}
+	}
+	if f.Default == "" {
+		f.Default = VariantOff
+	}
+	if f.Off == "" {
+		f.Off = f.Default
+	}
+	if f.Salt == "" {
+		f.Salt = f.Key
+	}
+
+	check := func(variant string) error {
+		if _, ok := f.Variants[variant]; !ok {
+			return fmt.Errorf("%w: %s references unknown variant %q", ErrInvalidFlag, f.Key, variant)
+		}
+		return nil
+	}
+	if err := check(f.Default); err != nil {
+		return err
+	}
+	if err := check(f.Off); err != nil {
+		return err
+	}
+	for _, t := range f.Targets {
+		if err := check(t.Variant); err != nil {
+			return err
+		}
+	}
+	for i := range f.Rules {
+		if err := f.Rules[i].Rule.Validate(); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidFlag, f.Key, err)
+		}
+		if f.Rules[i].Variant == "" && len(f.Rules[i].Rollout) == 0 {
+			return fmt.Errorf("%w: %s rule needs variant or rollout", ErrInvalidFlag, f.Key)
+		}
+		if f.Rules[i].Variant != "" {
+			if err := check(f.Rules[i].Variant); err != nil {
+				return err
+			}
+		}
+		for _, v := range f.Rules[i].Rollout {
+			if err := check(v.Key); err != nil {
+				return err
+			}
+		}
+	}
+	for _, v := range f.Rollout {
+		if err := check(v.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Evaluate 依次检查：总开关、白名单、属性规则（按顺序首个命中）、全量放量比例、默认分组
+func (f *Flag) Evaluate(ctx context.Context, userID uint, subject SubjectFunc, now time.Time) (*Evaluation, error) {
+	if !f.Enabled {
+		return f.result(f.Off, ReasonDisabled), nil
+	}
+
+	uid := strconv.FormatUint(uint64(userID), 10)
+	for _, t := range f.Targets {
+		for _, id := range t.UserIDs {
+			if id == uid {
+				return f.result(t.Variant, ReasonAllowlist), nil
+			}
+		}
+	}
+
+	if len(f.Rules) > 0 && userID != 0 {
+		s, err := subject(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for i := range f.Rules {
+			ok, err := f.Rules[i].Rule.Evaluate(ctx, s, now)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			if f.Rules[i].Variant != "" {
+				return f.result(f.Rules[i].Variant, ReasonRule), nil
+			}
+			if v, ok := experiment.PickVariant(f.Rules[i].Rollout, experiment.Bucket(f.Salt, userID)); ok {
+				return f.result(v, ReasonRule), nil
+			}
+		}
+	}
+
+	if len(f.Rollout) > 0 && userID != 0 {
+		if v, ok := experiment.PickVariant(f.Rollout, experiment.Bucket(f.Salt, userID)); ok {
+			return f.result(v, ReasonRollout), nil
+		}
+	}
+	return f.result(f.Default, ReasonDefault), nil
+}
+
+func (f *Flag) result(variant, reason string) *Evaluation {
+	return &Evaluation{
+		Key:     f.Key,
+		Variant: variant,
+		Value:   f.Variants[variant],
+		Reason:  reason,
+	}
+}
@@ -0,0 +1,73 @@
+package featureflag
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+	"usergrowth/internal/segment"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type attrSubject map[string]any
+
+func (s attrSubject) UserID() uint { return 0 }
+
+func (s attrSubject) Attr(name string) (any, bool) {
+	v, ok := s[name]
+	return v, ok
+}
+
+func (s attrSubject) EventCount(ctx context.Context, name string, since time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestLoadAndEvaluate(t *testing.T) {
+	raw, err := os.ReadFile("../../configs/flags.yaml")
+	assert.NoError(t, err)
+
+	c := NewClient(nil, nil)
+	assert.NoError(t, c.Load(raw))
+
+	banner := (*c.flags.Load())["home_banner"]
+	newcomer := func(ctx context.Context) (segment.Subject, error) {
+		return attrSubject{"registered_days": 2}, nil
+	}
+	veteran := func(ctx context.Context) (segment.Subject, error) {
+		return attrSubject{"registered_days": 300}, nil
+	}
+
+	e, err := banner.Evaluate(context.Background(), 1, veteran, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, ReasonAllowlist, e.Reason)
+	assert.Equal(t, "spring_festival", e.Value)
+
+	e, _ = banner.Evaluate(context.Background(), 2, newcomer, time.Now())
+	assert.Equal(t, ReasonRule, e.Reason)
+	assert.Equal(t, "spring", e.Variant)
+
+	e, _ = banner.Evaluate(context.Background(), 2, veteran, time.Now())
+	assert.Equal(t, ReasonDefault, e.Reason)
+	assert.Equal(t, "default", e.Value)
+
+	banner.Enabled = false
+	e, _ = banner.Evaluate(context.Background(), 1, veteran, time.Now())
+	assert.Equal(t, ReasonDisabled, e.Reason)
+
+	on := 0
+	rollout := (*c.flags.Load())["new_register_page"]
+	for uid := uint(1); uid <= 10000; uid++ {
+		e, _ = rollout.Evaluate(context.Background(), uid, nil, time.Now())
+		if e.Value == true {
+			on++
+		}
+	}
+	assert.InDelta(t, 2000, on, 300)
+}
+
+func TestLoadRejectsUnknownVariant(t *testing.T) {
+	c := NewClient(nil, nil)
+	err := c.Load([]byte(`{"flags": [{"key": "x", "enabled": true, "default": "missing"}]}`))
+	assert.ErrorIs(t, err, ErrInvalidFlag)
+}
@@ -0,0 +1,217 @@
+package funnel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+// 单次报表允许的最大日期跨度
+const maxReportDays = 93
+
+type CreateFunnelReq struct {
+	g.Meta     `path:"/api/admin/funnels" method:"post"`
+	Name       string   `json:"name" v:"required|max-length:255#漏斗名称不能为空|漏斗名称过长"`
+	Steps      []string `json:"steps" v:"required|min-length:2#漏斗步骤不能为空|漏斗至少需要两个步骤"`
+	WindowDays int      `json:"window_days" d:"7" v:"between:1,90"`
+}
+
+type CreateFunnelRes struct {
+}
+
+type ListFunnelReq struct {
+	g.Meta `path:"/api/admin/funnels" method:"get"`
+}
+
+type ListFunnelRes struct {
+}
+
+type UpdateFunnelReq struct {
+	g.Meta     `path:"/api/admin/funnels/{id}" method:"put"`
+	FunnelID   uint     `p:"id" v:"required"`
+	Name       string   `json:"name" v:"required|max-length:255#漏斗名称不能为空|漏斗名称过长"`
+	Steps      []string `json:"steps" v:"required|min-length:2#漏斗步骤不能为空|漏斗至少需要两个步骤"`
+	WindowDays int      `json:"window_days" d:"7" v:"between:1,90"`
+}
+
+type UpdateFunnelRes struct {
+}
+
+type DeleteFunnelReq struct {
+	g.Meta   `path:"/api/admin/funnels/{id}" method:"delete"`
+	FunnelID uint `p:"id" v:"required"`
+}
+
+type DeleteFunnelRes struct {
+}
+
+type FunnelReportReq struct {
+	g.Meta   `path:"/api/admin/funnels/{id}/report" method:"get"`
+	FunnelID uint   `p:"id" v:"required"`
+	Start    string `p:"start" v:"required|date#开始日期不能为空|开始日期格式应为YYYY-MM-DD"`
+	End      string `p:"end" v:"required|date#结束日期不能为空|结束日期格式应为YYYY-MM-DD"`
+}
+
+type FunnelReportRes struct {
+}
+
+type Admin struct {
+	repo       FunnelRepository
+	service    *Service
+	userLogger logs.Logger
+}
+
+func NewAdmin(repo FunnelRepository, service *Service, logger logs.Logger) *Admin {
+	return &Admin{
+		repo:       repo,
+		service:    service,
+		userLogger: logger,
+	}
+}
+
+func encodeSteps(steps []string) (string, error) {
+	for _, s := range steps {
+		if s == "" || len(s) > 64 {
+			return "", gerror.NewCode(gcode.CodeValidationFailed, "漏斗步骤事件名不合法")
+		}
+	}
+	b, err := json.Marshal(steps)
+	return string(b), err
+}
+
+func (params *Admin) findFunnel(funnelID uint) (*Funnel, error) {
+	f, err := params.repo.Find(funnelID)
+	if err != nil {
+		if errors.Is(err, ErrFunnelNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "漏斗不存在")
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func (params *Admin) Create(ctx context.Context, req *CreateFunnelReq) (res *CreateFunnelRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Funnel.Create")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	steps, err := encodeSteps(req.Steps)
+	if err != nil {
+		return nil, err
+	}
+	f := &Funnel{Name: req.Name, Steps: steps, WindowDays: req.WindowDays}
+	if err = params.repo.Create(f); err != nil {
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Funnel created:", f.FunnelID, f.Name)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "funnel created",
+		"data":    f,
+	})
+	return nil, nil
+}
+
+func (params *Admin) List(ctx context.Context, req *ListFunnelReq) (res *ListFunnelRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	funnels, err := params.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    funnels,
+	})
+	return nil, nil
+}
+
+func (params *Admin) Update(ctx context.Context, req *UpdateFunnelReq) (res *UpdateFunnelRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Funnel.Update")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	f, err := params.findFunnel(req.FunnelID)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := encodeSteps(req.Steps)
+	if err != nil {
+		return nil, err
+	}
+	f.Name = req.Name
+	f.Steps = steps
+	f.WindowDays = req.WindowDays
+	if err = params.repo.Update(f); err != nil {
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Funnel updated:", f.FunnelID, f.Name)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "funnel updated",
+		"data":    f,
+	})
+	return nil, nil
+}
+
+func (params *Admin) Delete(ctx context.Context, req *DeleteFunnelReq) (res *DeleteFunnelRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	if err = params.repo.Delete(req.FunnelID); err != nil {
+		if errors.Is(err, ErrFunnelNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "漏斗不存在")
+		}
+		return nil, err
+	}
+	params.userLogger.Info(ctx, "Funnel deleted:", req.FunnelID)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "funnel deleted",
+	})
+	return nil, nil
+}
+
+// Report 的 end 为包含当天的结束日期
+func (params *Admin) Report(ctx context.Context, req *FunnelReportReq) (res *FunnelReportRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	f, err := params.findFunnel(req.FunnelID)
+	if err != nil {
+		return nil, err
+	}
+	start, err := time.ParseInLocation(time.DateOnly, req.Start, time.Local)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "开始日期格式应为YYYY-MM-DD")
+	}
+	end, err := time.ParseInLocation(time.DateOnly, req.End, time.Local)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "结束日期格式应为YYYY-MM-DD")
+	}
+	end = end.AddDate(0, 0, 1)
+	if !end.After(start) || end.Sub(start) > maxReportDays*24*time.Hour {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "日期区间不合法")
+	}
+
+	report, err := params.service.Report(ctx, f, start, end)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    report,
+	})
+	return nil, nil
+}
@@ -0,0 +1,230 @@
+package funnel
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+	"usergrowth/internal/event"
+)
+
+const unknownChannel = "unknown"
+
+type StepStat struct {
+	Name                string  `json:"name"`
+	Users               int     `json:"users"`
+	ConversionFromPrev  float64 `json:"conversion_from_prev"`
+	ConversionFromStart float64 `json:"conversion_from_start"`
+	MedianSecondsToStep float64 `json:"median_seconds_to_step"` // 距上一步的中位耗时
+}
+
+type Report struct {
+	FunnelID    uint                  `json:"funnel_id"`
+	Start       string                `json:"start"`
+	End         string                `json:"end"`
+	Steps       []StepStat            `json:"steps"`
+	Channels    map[string][]StepStat `json:"channels"`
+	GeneratedAt time.Time             `json:"generated_at"`
+}
+
+type hit struct {
+	step    int
+	at      time.Time
+	channel string
+}
+
+// builder 按参与者收集事件，参与者优先使用用户 ID，未登录时用匿名 ID，
+// 同时出现用户 ID 与匿名 ID 的事件用于把匿名行为归并到用户上
+type builder struct {
+	steps   []string
+	window  time.Duration
+	start   time.Time
+	end     time.Time
+	hits    map[string][]hit
+	aliases map[string]string
+	users   map[string]uint
+}
+
+func newBuilder(steps []string, window time.Duration, start, end time.Time) *builder {
+	return &builder{
+		steps:   steps,
+		window:  window,
+		start:   start,
+		end:     end,
+		hits:    make(map[string][]hit),
+		aliases: make(map[string]string),
+		users:   make(map[string]uint),
+	}
+}
+
+func userActor(userID uint) string {
+	return "u:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+func (b *builder) add(e *event.UserEvent) {
+	if e.UserID != 0 && e.AnonymousID != "" {
+		b.aliases["a:"+e.AnonymousID] = userActor(e.UserID)
+	}
+	actor := userActor(e.UserID)
+	switch {
+	case e.UserID != 0:
+		b.users[actor] = e.UserID
+	case e.AnonymousID == "":
+		return
+	default:
+		actor = "a:" + e.AnonymousID
+	}
+	// 同名事件可能出现在多个步骤中，逐个登记
+	for i, name := range b.steps {
+		if name == e.Name {
+			b.hits[actor] = append(b.hits[actor], hit{step: i, at: e.CreatedAt, channel: eventChannel(e)})
+		}
+	}
+}
+
+func eventChannel(e *event.UserEvent) string {
+	if e.Properties == "" || e.Properties == "{}" {
+		return ""
+	}
+	var props struct {
+		Channel   string `json:"channel"`
+		UTMSource string `json:"utm_source"`
+	}
+	if err := json.Unmarshal([]byte(e.Properties), &props); err != nil {
+		return ""
+	}
+	if props.Channel != "" {
+		return props.Channel
+	}
+	return props.UTMSource
+}
+
+// userIDs 返回出现过的已登录参与者
+func (b *builder) userIDs() []uint {
+	ids := make([]uint, 0, len(b.users))
+	for _, id := range b.users {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (b *builder) merged() map[string][]hit {
+	merged := make(map[string][]hit, len(b.hits))
+	for actor, hits := range b.hits {
+		if to, ok := b.aliases[actor]; ok {
+			actor = to
+		}
+		merged[actor] = append(merged[actor], hits...)
+	}
+	return merged
+}
+
+// walk 返回参与者到达各步骤的时间，第一步必须落在统计区间内，后续步骤须在窗口期内按顺序发生
+func (b *builder) walk(hits []hit) ([]time.Time, string) {
+	sort.Slice(hits, func(i, j int) bool { return hits[i].at.Before(hits[j].at) })
+
+	reached := make([]time.Time, 0, len(b.steps))
+	channel := ""
+	for _, h := range hits {
+		next := len(reached)
+		if next == len(b.steps) {
+			break
+		}
+		if h.step != next {
+			continue
+		}
+		if next == 0 {
+			if h.at.Before(b.start) || !h.at.Before(b.end) {
+				continue
+			}
+			channel = h.channel
+		} else if h.at.Sub(reached[0]) > b.window {
+			break
+		}
+		if channel == "" {
+			channel = h.channel
+		}
+		reached = append(reached, h.at)
+	}
+	if channel == "" {
+		channel = unknownChannel
+	}
+	return reached, channel
+}
+
+// report 中已登录参与者按 userChannels 中的注册归因渠道分组，没有归因时使用第一步事件上的渠道
+func (b *builder) report(userChannels map[uint]string) ([]StepStat, map[string][]StepStat) {
+	total := newTally(len(b.steps))
+	channels := make(map[string]*tally)
+	for actor, hits := range b.merged() {
+		reached, channel := b.walk(hits)
+		if len(reached) == 0 {
+			continue
+		}
+		if c := userChannels[b.users[actor]]; c != "" {
+			channel = c
+		}
+		total.add(reached)
+		if channels[channel] == nil {
+			channels[channel] = newTally(len(b.steps))
+		}
+		channels[channel].add(reached)
+	}
+
+	byChannel := make(map[string][]StepStat, len(channels))
+	for name, t := range channels {
+		byChannel[name] = t.stats(b.steps)
+	}
+	return total.stats(b.steps), byChannel
+}
+
+type tally struct {
+	users     []int
+	durations [][]float64
+}
+
+func newTally(n int) *tally {
+	return &tally{
+		users:     make([]int, n),
+		durations: make([][]float64, n),
+	}
+}
+
+func (t *tally) add(reached []time.Time) {
+	for i, at := range reached {
+		t.users[i]++
+		if i > 0 {
+			t.durations[i] = append(t.durations[i], at.Sub(reached[i-1]).Seconds())
+		}
+	}
+}
+
+func (t *tally) stats(steps []string) []StepStat {
+	stats := make([]StepStat, len(steps))
+	for i, name := range steps {
+		stats[i] = StepStat{Name: name, Users: t.users[i]}
+		if i > 0 && t.users[i-1] > 0 {
+			stats[i].ConversionFromPrev = float64(t.users[i]) / float64(t.users[i-1])
+		}
+		if t.users[0] > 0 {
+			stats[i].ConversionFromStart = float64(t.users[i]) / float64(t.users[0])
+		}
+		stats[i].MedianSecondsToStep = median(t.durations[i])
+	}
+	if len(stats) > 0 && stats[0].Users > 0 {
+		stats[0].ConversionFromPrev = 1
+	}
+	return stats
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 1 {
+		return values[mid]
+	}
+	return (values[mid-1] + values[mid]) / 2
+}
@@ -0,0 +1,73 @@
+package funnel
+
+import (
+	"testing"
+	"time"
+	"usergrowth/internal/event"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilderReport(t *testing.T) {
+	day := time.Date(2026, 5, 1, 0, 0, 0, 0, time.Local)
+	at := func(minutes int) time.Time { return day.Add(time.Duration(minutes) * time.Minute) }
+	steps := []string{"register_page_view", "register", "login"}
+	b := newBuilder(steps, 24*time.Hour, day, day.AddDate(0, 0, 1))
+
+	events := []event.UserEvent{
+		// 匿名浏览后注册，注册事件带匿名 ID，完成全部步骤
+		{AnonymousID: "a1", Name: "register_page_view", CreatedAt: at(0), Properties: `{"utm_source":"wechat"}`},
+		{UserID: 1, AnonymousID: "a1", Name: "register", CreatedAt: at(10)},
+		{UserID: 1, Name: "login", CreatedAt: at(30)},
+		// 注册但未登录
+		{AnonymousID: "a2", Name: "register_page_view", CreatedAt: at(5), Properties: `{"utm_source":"wechat"}`},
+		{UserID: 2, AnonymousID: "a2", Name: "register", CreatedAt: at(25)},
+		// 只浏览
+		{AnonymousID: "a3", Name: "register_page_view", CreatedAt: at(8)},
+		// 顺序颠倒的登录不计入
+		{UserID: 4, Name: "login", CreatedAt: at(1)},
+		{UserID: 4, Name: "register_page_view", CreatedAt: at(2)},
+		// 超出转化窗口
+		{UserID: 5, Name: "register_page_view", CreatedAt: at(3)},
+		{UserID: 5, Name: "register", CreatedAt: at(3 + 25*60)},
+	}
+	for i := range events {
+		b.add(&events[i])
+	}
+
+	total, channels := b.report(nil)
+	assert.Equal(t, []int{5, 2, 1}, []int{total[0].Users, total[1].Users, total[2].Users})
+	assert.InDelta(t, 0.4, total[1].ConversionFromPrev, 1e-9)
+	assert.InDelta(t, 0.5, total[2].ConversionFromPrev, 1e-9)
+	assert.InDelta(t, 0.2, total[2].ConversionFromStart, 1e-9)
+	assert.InDelta(t, 15*60, total[1].MedianSecondsToStep, 1e-9)
+	assert.InDelta(t, 20*60, total[2].MedianSecondsToStep, 1e-9)
+
+	assert.Equal(t, 2, channels["wechat"][0].Users)
+	assert.Equal(t, 2, channels["wechat"][1].Users)
+	assert.Equal(t, 3, channels[unknownChannel][0].Users)
+	assert.Equal(t, 0, channels[unknownChannel][1].Users)
+}
+
+func TestReportUsesRegistrationChannel(t *testing.T) {
+	day := time.Date(2026, 5, 1, 0, 0, 0, 0, time.Local)
+	b := newBuilder([]string{"register", "login"}, 24*time.Hour, day, day.AddDate(0, 0, 1))
+	events := []event.UserEvent{
+		// 服务端 register 事件不带渠道属性
+		{UserID: 1, Name: "register", CreatedAt: day.Add(time.Minute)},
+		{UserID: 1, Name: "login", CreatedAt: day.Add(2 * time.Minute)},
+		{UserID: 2, Name: "register", CreatedAt: day.Add(3 * time.Minute)},
+		{UserID: 3, Name: "register", CreatedAt: day.Add(4 * time.Minute), Properties: `{"channel":"weibo"}`},
+	}
+	for i := range events {
+		b.add(&events[i])
+	}
+	assert.ElementsMatch(t, []uint{1, 2, 3}, b.userIDs())
+
+	_, channels := b.report(map[uint]string{1: "douyin", 2: "douyin"})
+	assert.Equal(t, 2, channels["douyin"][0].Users)
+	assert.Equal(t, 1, channels["douyin"][1].Users)
+	// 没有归因记录时退回事件上的渠道
+	assert.Equal(t, 1, channels["weibo"][0].Users)
+	assert.Nil(t, channels[unknownChannel])
+}
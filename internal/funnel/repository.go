@@ -0,0 +1,87 @@
+package funnel
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrFunnelNotFound = errors.New("funnel not found")
+
+type Funnel struct {
+	FunnelID   uint      `gorm:"primaryKey;autoIncrement" json:"funnel_id"`
+	Name       string    `gorm:"type:varchar(255);not null;uniqueIndex" json:"name"`
+	Steps      string    `gorm:"type:text;not null" json:"steps"`       // 事件名数组的 JSON
+	WindowDays int       `gorm:"not null;default:7" json:"window_days"` // 从第一步起算的转化窗口
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (f *Funnel) ParseSteps() ([]string, error) {
+	var steps []string
+	if err := json.Unmarshal([]byte(f.Steps), &steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+type funnelRepository struct {
+	db *gorm.DB
+}
+
+type FunnelRepository interface {
+	Create(funnel *Funnel) error
+	Update(funnel *Funnel) error
+	Delete(funnelID uint) error
+	Find(funnelID uint) (*Funnel, error)
+	List() ([]Funnel, error)
+}
+
+func NewFunnelRepository(db *gorm.DB) FunnelRepository {
+	if err := db.AutoMigrate(&Funnel{}); err != nil {
+		panic("failed to migrate funnel table")
+	}
+	return &funnelRepository{db: db}
+}
+
+func (repo *funnelRepository) Create(funnel *Funnel) error {
+	return repo.db.Create(funnel).Error
+}
+
+func (repo *funnelRepository) Update(funnel *Funnel) error {
+	return repo.db.Model(&Funnel{}).Where("funnel_id = ?", funnel.FunnelID).Updates(map[string]any{
+		"name":        funnel.Name,
+		"steps":       funnel.Steps,
+		"window_days": funnel.WindowDays,
+	}).Error
+}
+
+func (repo *funnelRepository) Delete(funnelID uint) error {
+	result := repo.db.Delete(&Funnel{}, funnelID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrFunnelNotFound
+	}
+	return nil
+}
+
+func (repo *funnelRepository) Find(funnelID uint) (*Funnel, error) {
+	var funnel Funnel
+	if err := repo.db.First(&funnel, funnelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFunnelNotFound
+		}
+		return nil, err
+	}
+	return &funnel, nil
+}
+
+func (repo *funnelRepository) List() ([]Funnel, error) {
+	var funnels []Funnel
+	err := repo.db.Order("funnel_id").Find(&funnels).Error
+	return funnels, err
+}
@@ -0,0 +1,90 @@
+package funnel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"usergrowth/internal/event"
+
+	"github.com/gogf/gf/v2/net/gtrace"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// 报表缓存按自然日失效，当天内同一区间只计算一次
+const reportCacheTTL = 24 * time.Hour
+
+// ChannelSource 提供已注册用户的来源渠道，由 attribution 模块提供
+type ChannelSource interface {
+	FirstChannels(ctx context.Context, userIDs []uint) (map[uint]string, error)
+}
+
+type Service struct {
+	repo     FunnelRepository
+	events   event.EventRepository
+	channels ChannelSource
+	rdb      goredis.Cmdable
+}
+
+func NewService(repo FunnelRepository, events event.EventRepository, channels ChannelSource, rdb goredis.Cmdable) *Service {
+	return &Service{
+		repo:     repo,
+		events:   events,
+		channels: channels,
+		rdb:      rdb,
+	}
+}
+
+// cacheKey 包含漏斗更新时间，修改步骤后旧缓存自然失效
+func cacheKey(f *Funnel, start, end time.Time, today string) string {
+	return fmt.Sprintf("funnel:report:%d:%d:%s:%s:%s", f.FunnelID, f.UpdatedAt.Unix(),
+		start.Format(time.DateOnly), end.Format(time.DateOnly), today)
+}
+
+// Report 统计 [start, end) 内进入第一步的参与者，后续步骤可延伸到 end + 窗口期
+func (s *Service) Report(ctx context.Context, f *Funnel, start, end time.Time) (*Report, error) {
+	ctx, span := gtrace.NewSpan(ctx, "Funnel.Report")
+	defer span.End()
+
+	key := cacheKey(f, start, end, time.Now().Format("20060102"))
+	if cached, err := s.rdb.Get(ctx, key).Bytes(); err == nil {
+		var report Report
+		if err = json.Unmarshal(cached, &report); err == nil {
+			return &report, nil
+		}
+	}
+
+	steps, err := f.ParseSteps()
+	if err != nil {
+		return nil, err
+	}
+	window := time.Duration(f.WindowDays) * 24 * time.Hour
+	b := newBuilder(steps, window, start, end)
+	err = s.events.ScanByNames(ctx, steps, start, end.Add(window), func(events []event.UserEvent) error {
+		for i := range events {
+			b.add(&events[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	userChannels, err := s.channels.FirstChannels(ctx, b.userIDs())
+	if err != nil {
+		return nil, err
+	}
+	total, channels := b.report(userChannels)
+	report := &Report{
+		FunnelID:    f.FunnelID,
+		Start:       start.Format(time.DateOnly),
+		End:         end.AddDate(0, 0, -1).Format(time.DateOnly),
+		Steps:       total,
+		Channels:    channels,
+		GeneratedAt: time.Now(),
+	}
+	if b, err := json.Marshal(report); err == nil {
+		_ = s.rdb.Set(ctx, key, b, reportCacheTTL).Err()
+	}
+	return report, nil
+}
@@ -0,0 +1,172 @@
+package leaderboard
+
+import (
+	"context"
+	"errors"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+type CreateBoardReq struct {
+	g.Meta     `path:"/api/admin/leaderboards" method:"post"`
+	Name       string `json:"name" v:"required|regex:^[a-z0-9_]{1,64}$#排行榜名称不能为空|排行榜名称只能包含小写字母、数字和下划线"`
+	EventName  string `json:"event_name" v:"required|max-length:64#事件名不能为空|事件名过长"`
+	Aggregate  string `json:"aggregate" v:"required|in:count,sum,max#计分方式不能为空|计分方式应为count、sum或max"`
+	Property   string `json:"property" v:"required-if:aggregate,sum,aggregate,max|max-length:64#计分属性不能为空|计分属性过长"`
+	SeasonDays int    `json:"season_days" v:"between:0,366"`
+	Source     string `json:"source" d:"server" v:"in:server,any#计分来源应为server或any"`
+}
+
+type CreateBoardRes struct {
+}
+
+type ListBoardReq struct {
+	g.Meta `path:"/api/admin/leaderboards" method:"get"`
+}
+
+type ListBoardRes struct {
+}
+
+type EndSeasonReq struct {
+	g.Meta `path:"/api/admin/leaderboards/{name}/season/end" method:"post"`
+	Name   string `p:"name" v:"required"`
+}
+
+type EndSeasonRes struct {
+}
+
+type ArchivesReq struct {
+	g.Meta `path:"/api/admin/leaderboards/{name}/archives" method:"get"`
+	Name   string `p:"name" v:"required"`
+	Season int    `p:"season" v:"required|min:1#赛季不能为空|赛季不合法"`
+	Limit  int    `p:"limit" d:"100" v:"between:1,1000"`
+}
+
+type ArchivesRes struct {
+}
+
+type Admin struct {
+	repo       LeaderboardRepository
+	service    *Service
+	userLogger logs.Logger
+}
+
+func NewAdmin(repo LeaderboardRepository, service *Service, logger logs.Logger) *Admin {
+	return &Admin{
+		repo:       repo,
+		service:    service,
+		userLogger: logger,
+	}
+}
+
+func (params *Admin) findBoard(name string) (*Leaderboard, error) {
+	board, err := params.repo.FindByName(name)
+	if err != nil {
+		if errors.Is(err, ErrBoardNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "排行榜不存在")
+		}
+		return nil, err
+	}
+	return board, nil
+}
+
+func (params *Admin) Create(ctx context.Context, req *CreateBoardReq) (res *CreateBoardRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Leaderboard.Create")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	board := &Leaderboard{
+		Name:          req.Name,
+		EventName:     req.EventName,
+		Aggregate:     req.Aggregate,
+		Property:      req.Property,
+		Source:        req.Source,
+		SeasonDays:    req.SeasonDays,
+		CurrentSeason: 1,
+		SeasonStart:   time.Now(),
+	}
+	if board.Aggregate == AggregateCount {
+		board.Property = ""
+	}
+	if err = params.repo.Create(board); err != nil {
+		return nil, err
+	}
+	if err = params.service.Reload(); err != nil {
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Leaderboard created:", board.BoardID, board.Name)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "leaderboard created",
+		"data":    board,
+	})
+	return nil, nil
+}
+
+func (params *Admin) List(ctx context.Context, req *ListBoardReq) (res *ListBoardRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	boards, err := params.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    boards,
+	})
+	return nil, nil
+}
+
+// EndSeason 立即结束当前赛季并从现在开始新赛季
+func (params *Admin) EndSeason(ctx context.Context, req *EndSeasonReq) (res *EndSeasonRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	board, err := params.findBoard(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := params.service.EndSeason(ctx, board, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "赛季已被结束，请刷新后重试")
+	}
+
+	params.userLogger.Info(ctx, "Leaderboard season ended manually:", board.Name, board.CurrentSeason)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "season ended",
+		"data": g.Map{
+			"ended_season": board.CurrentSeason,
+		},
+	})
+	return nil, nil
+}
+
+func (params *Admin) Archives(ctx context.Context, req *ArchivesReq) (res *ArchivesRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	board, err := params.findBoard(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	archives, err := params.repo.ListArchives(board.BoardID, req.Season, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    archives,
+	})
+	return nil, nil
+}
@@ -0,0 +1,73 @@
+package leaderboard
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type BoardReq struct {
+	g.Meta `path:"/api/leaderboards/{name}" method:"get"`
+	Name   string `p:"name" v:"required"`
+	Limit  int    `p:"limit" d:"10" v:"between:1,100#条数应在1到100之间"`
+}
+
+type BoardRes struct {
+}
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// Board 返回当前赛季前 N 名以及调用者自己的名次
+func (c *Controller) Board(ctx context.Context, req *BoardReq) (res *BoardRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Leaderboard.Board")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	userid := r.GetCtxVar("userid").String()
+	uid, err := strconv.ParseUint(userid, 10, 64)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+	span.SetAttributes(attribute.String("user.id", userid), attribute.String("leaderboard", req.Name))
+
+	board, ok := c.service.Board(req.Name)
+	if !ok {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "排行榜不存在")
+	}
+	top, err := c.service.Top(ctx, board, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	me, err := c.service.Rank(ctx, board, uint(uid))
+	if err != nil {
+		return nil, err
+	}
+
+	data := g.Map{
+		"name":   board.Name,
+		"season": board.CurrentSeason,
+		"top":    top,
+		"me":     me,
+	}
+	if end := board.SeasonEnd(); !end.IsZero() {
+		data["season_end"] = end
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    data,
+	})
+	return nil, nil
+}
@@ -0,0 +1,128 @@
+package leaderboard
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrBoardNotFound = errors.New("leaderboard not found")
+
+// 计分方式
+const (
+	AggregateCount = "count" // 每个事件 +1，如按 invite_accepted 统计邀请数
+	AggregateSum   = "sum"   // 累加事件属性值，如按 points_changed 的 delta 统计积分
+	AggregateMax   = "max"   // 取属性历史最大值，如 points_changed 的 balance 峰值
+)
+
+// 计分事件来源，客户端上报的属性值与时间不可信，默认只统计服务端事件
+const (
+	SourceServer = "server"
+	SourceAny    = "any"
+)
+
+type Leaderboard struct {
+	BoardID       uint      `gorm:"primaryKey;autoIncrement" json:"board_id"`
+	Name          string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"name"`
+	EventName     string    `gorm:"type:varchar(64);not null;index" json:"event_name"`
+	Aggregate     string    `gorm:"type:varchar(16);not null" json:"aggregate"`
+	Property      string    `gorm:"type:varchar(64)" json:"property"`                       // sum / max 读取的事件属性
+	Source        string    `gorm:"type:varchar(16);not null;default:server" json:"source"` // server 只统计服务端事件，any 同时统计埋点上报
+	SeasonDays    int       `gorm:"not null;default:0" json:"season_days"`                  // 0 表示不分赛季
+	CurrentSeason int       `gorm:"not null;default:1" json:"current_season"`
+	SeasonStart   time.Time `gorm:"not null" json:"season_start"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// SeasonEnd 返回当前赛季结束时间，不分赛季时返回零值
+func (b *Leaderboard) SeasonEnd() time.Time {
+	if b.SeasonDays <= 0 {
+		return time.Time{}
+	}
+	return b.SeasonStart.AddDate(0, 0, b.SeasonDays)
+}
+
+// accepts 判断排行榜是否统计该来源的事件，client 表示事件来自埋点上报
+func (b *Leaderboard) accepts(client bool) bool {
+	return !client || b.Source == SourceAny
+}
+
+// Archive 是赛季结束时归档的排名
+type Archive struct {
+	BoardID    uint      `gorm:"primaryKey;autoIncrement:false" json:"board_id"`
+	Season     int       `gorm:"primaryKey;autoIncrement:false" json:"season"`
+	Rank       int       `gorm:"primaryKey;autoIncrement:false" json:"rank"`
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	Score      int64     `gorm:"not null" json:"score"`
+	AchievedAt time.Time `gorm:"not null" json:"achieved_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type leaderboardRepository struct {
+	db *gorm.DB
+}
+
+type LeaderboardRepository interface {
+	Create(board *Leaderboard) error
+	List() ([]Leaderboard, error)
+	FindByName(name string) (*Leaderboard, error)
+	// AdvanceSeason 仅在赛季号仍为 season 时推进，返回是否由本次调用完成，避免多实例重复归档
+	AdvanceSeason(boardID uint, season int, start time.Time) (bool, error)
+	SaveArchives(archives []Archive) error
+	ListArchives(boardID uint, season int, limit int) ([]Archive, error)
+}
+
+func NewLeaderboardRepository(db *gorm.DB) LeaderboardRepository {
+	if err := db.AutoMigrate(&Leaderboard{}, &Archive{}); err != nil {
+		panic("failed to migrate leaderboard tables")
+	}
+	return &leaderboardRepository{db: db}
+}
+
+func (repo *leaderboardRepository) Create(board *Leaderboard) error {
+	return repo.db.Create(board).Error
+}
+
+func (repo *leaderboardRepository) List() ([]Leaderboard, error) {
+	var boards []Leaderboard
+	err := repo.db.Order("board_id").Find(&boards).Error
+	return boards, err
+}
+
+func (repo *leaderboardRepository) FindByName(name string) (*Leaderboard, error) {
+	var board Leaderboard
+	if err := repo.db.Where("name = ?", name).First(&board).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBoardNotFound
+		}
+		return nil, err
+	}
+	return &board, nil
+}
+
+func (repo *leaderboardRepository) AdvanceSeason(boardID uint, season int, start time.Time) (bool, error) {
+	result := repo.db.Model(&Leaderboard{}).
+		Where("board_id = ? AND current_season = ?", boardID, season).
+		Updates(map[string]any{
+			"current_season": season + 1,
+			"season_start":   start,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (repo *leaderboardRepository) SaveArchives(archives []Archive) error {
+	if len(archives) == 0 {
+		return nil
+	}
+	return repo.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&archives, 500).Error
+}
+
+func (repo *leaderboardRepository) ListArchives(boardID uint, season int, limit int) ([]Archive, error) {
+	var archives []Archive
+	err := repo.db.Where("board_id = ? AND season = ?", boardID, season).
+		Order("`rank`").Limit(limit).Find(&archives).Error
+	return archives, err
+}
@@ -0,0 +1,90 @@
+package leaderboard
+
+import (
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// 有序集合的 score 同时编码分数与达成时间：score = points*timeScale + (timeScale-1-elapsed)，
+// elapsed 为距赛季开始的秒数，分数相同时先达成者 score 更大、排名更靠前。
+// float64 可精确表示 2^53，因此 points 上限约为 9e7，elapsed 上限约 3 年
+const (
+	timeScale = 100000000
+	MaxPoints = (1<<53)/timeScale - 1
+)
+
+func encodeScore(points int64, elapsed int64) float64 {
+	return float64(points*timeScale + (timeScale - 1 - clampElapsed(elapsed)))
+}
+
+func decodeScore(score float64) (points int64, elapsed int64) {
+	s := int64(score)
+	points = s / timeScale
+	elapsed = timeScale - 1 - s%timeScale
+	return points, elapsed
+}
+
+func clampElapsed(elapsed int64) int64 {
+	if elapsed < 0 {
+		return 0
+	}
+	if elapsed > timeScale-1 {
+		return timeScale - 1
+	}
+	return elapsed
+}
+
+// applyScript 原子地读取旧分数、按计分方式计算新分数并带上本次达成时间写回；
+// max 模式下未超过历史最大值时保持原 score，不刷新达成时间。
+// KEYS[1] 是赛季元数据，其它实例已推进赛季时写入下一赛季（KEYS[3]）并按新赛季开始时间计算耗时，
+// 元数据落后两个赛季以上时返回 -1 由调用方刷新定义后重试
+var applyScript = goredis.NewScript(`
+local season = tonumber(ARGV[7])
+local start = tonumber(ARGV[8])
+local key = KEYS[2]
+local meta = redis.call('HMGET', KEYS[1], 'season', 'start')
+if meta[1] then
+	local current = tonumber(meta[1])
+	if current == season + 1 then
+		key = KEYS[3]
+		start = tonumber(meta[2])
+	elseif current > season + 1 then
+		return -1
+	end
+end
+local cur = redis.call('ZSCORE', key, ARGV[1])
+local scale = tonumber(ARGV[5])
+local limit = tonumber(ARGV[6])
+local elapsed = math.min(math.max(tonumber(ARGV[4]) - start, 0), scale - 1)
+local points = 0
+if cur then
+	points = math.floor(tonumber(cur) / scale)
+end
+local value = tonumber(ARGV[3])
+local nextPoints
+if ARGV[2] == 'max' then
+	if cur and value <= points then
+		return points
+	end
+	nextPoints = value
+else
+	nextPoints = points + value
+end
+if nextPoints < 0 then
+	nextPoints = 0
+end
+if nextPoints > limit then
+	nextPoints = limit
+end
+redis.call('ZADD', key, string.format('%.0f', nextPoints * scale + (scale - 1 - elapsed)), ARGV[1])
+return nextPoints
+`)
+
+// syncSeasonScript 只向前推进赛季元数据，避免落后的实例把赛季写回旧值
+var syncSeasonScript = goredis.NewScript(`
+local cur = tonumber(redis.call('HGET', KEYS[1], 'season') or '0')
+if cur < tonumber(ARGV[1]) then
+	redis.call('HSET', KEYS[1], 'season', ARGV[1], 'start', ARGV[2])
+	return 1
+end
+return 0
+`)
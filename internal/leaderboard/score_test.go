@@ -0,0 +1,131 @@
+package leaderboard
+
+import (
+	"context"
+	"testing"
+	"time"
+	"usergrowth/internal/event"
+	"usergrowth/internal/logs"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeScoreOrdering(t *testing.T) {
+	// 分数高者在前
+	assert.Greater(t, encodeScore(11, 5000), encodeScore(10, 0))
+	// 分数相同先达成者在前
+	assert.Greater(t, encodeScore(10, 100), encodeScore(10, 200))
+
+	points, elapsed := decodeScore(encodeScore(MaxPoints, 12345))
+	assert.Equal(t, int64(MaxPoints), points)
+	assert.Equal(t, int64(12345), elapsed)
+
+	points, elapsed = decodeScore(encodeScore(0, -10))
+	assert.Equal(t, int64(0), points)
+	assert.Equal(t, int64(0), elapsed)
+}
+
+func TestEventValue(t *testing.T) {
+	count := &Leaderboard{Aggregate: AggregateCount}
+	sum := &Leaderboard{Aggregate: AggregateSum, Property: "points"}
+
+	v, ok := eventValue(count, &event.UserEvent{})
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), v)
+
+	v, ok = eventValue(sum, &event.UserEvent{Properties: `{"points":30}`})
+	assert.True(t, ok)
+	assert.Equal(t, int64(30), v)
+
+	_, ok = eventValue(sum, &event.UserEvent{Properties: `{"other":1}`})
+	assert.False(t, ok)
+}
+
+func TestAccepts(t *testing.T) {
+	server := &Leaderboard{Source: SourceServer}
+	anySource := &Leaderboard{Source: SourceAny}
+
+	assert.True(t, server.accepts(false))
+	assert.False(t, server.accepts(true))
+	assert.True(t, anySource.accepts(false))
+	assert.True(t, anySource.accepts(true))
+}
+
+type fakeRepo struct {
+	LeaderboardRepository
+	boards   []Leaderboard
+	archives []Archive
+}
+
+func (f *fakeRepo) List() ([]Leaderboard, error) {
+	return append([]Leaderboard(nil), f.boards...), nil
+}
+
+func (f *fakeRepo) AdvanceSeason(boardID uint, season int, start time.Time) (bool, error) {
+	b := &f.boards[boardID-1]
+	if b.CurrentSeason != season {
+		return false, nil
+	}
+	b.CurrentSeason, b.SeasonStart = season+1, start
+	return true, nil
+}
+
+func (f *fakeRepo) SaveArchives(archives []Archive) error {
+	f.archives = append(f.archives, archives...)
+	return nil
+}
+
+func TestScoreFollowsSeasonAcrossInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+	start := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	repo := &fakeRepo{boards: []Leaderboard{{BoardID: 1, Name: "points", EventName: "points_changed",
+		Aggregate: AggregateSum, Property: "delta", Source: SourceServer, SeasonDays: 7, CurrentSeason: 1, SeasonStart: start}}}
+	a := NewService(repo, rdb, 10, time.Hour, logs.Nop())
+	b := NewService(repo, rdb, 10, time.Hour, logs.Nop())
+	assert.NoError(t, a.Reload())
+	assert.NoError(t, b.Reload())
+
+	score := func(s *Service, userID uint, delta string, at time.Time) {
+		s.OnEvent(ctx, &event.UserEvent{UserID: userID, Name: "points_changed", Properties: `{"delta":` + delta + `}`, CreatedAt: at})
+	}
+	score(b, 1, "30", start.Add(time.Hour))
+
+	// a 结束赛季后 b 的缓存仍是第 1 赛季，b 的计分应进入第 2 赛季而不是已归档的第 1 赛季
+	next := start.AddDate(0, 0, 7)
+	board, _ := a.Board("points")
+	ok, err := a.EndSeason(ctx, board, next)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	if assert.Len(t, repo.archives, 1) {
+		assert.Equal(t, int64(30), repo.archives[0].Score)
+	}
+	stale, _ := b.Board("points")
+	assert.Equal(t, 1, stale.CurrentSeason)
+	score(b, 2, "5", next.Add(10*time.Second))
+
+	season1, _ := rdb.ZCard(ctx, seasonKey(stale, 1)).Result()
+	assert.Equal(t, int64(1), season1)
+	current, _ := a.Board("points")
+	top, err := a.Top(ctx, current, 10)
+	if assert.NoError(t, err) && assert.Len(t, top, 1) {
+		assert.Equal(t, uint(2), top[0].UserID)
+		assert.Equal(t, int64(5), top[0].Score)
+		assert.Equal(t, next.Add(10*time.Second), top[0].AchievedAt.UTC())
+	}
+
+	// 落后两个赛季时刷新定义后重试
+	current, _ = a.Board("points")
+	_, err = a.EndSeason(ctx, current, next.AddDate(0, 0, 7))
+	assert.NoError(t, err)
+	score(b, 3, "7", next.AddDate(0, 0, 7).Add(time.Minute))
+	latest, _ := b.Board("points")
+	assert.Equal(t, 3, latest.CurrentSeason)
+	rank, err := b.Rank(ctx, latest, 3)
+	if assert.NoError(t, err) && assert.NotNil(t, rank) {
+		assert.Equal(t, int64(7), rank.Score)
+	}
+}
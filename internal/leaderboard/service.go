@@ -0,0 +1,306 @@
+package leaderboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"usergrowth/internal/event"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/net/gtrace"
+	"github.com/gogf/gf/v2/util/gconv"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// 归档时每次从有序集合读取的条数
+const archivePageSize = 500
+
+type Entry struct {
+	Rank       int       `json:"rank"` // 从 1 开始
+	UserID     uint      `json:"user_id"`
+	Score      int64     `json:"score"`
+	AchievedAt time.Time `json:"achieved_at"`
+}
+
+type boardSet struct {
+	byName  map[string]*Leaderboard
+	byEvent map[string][]*Leaderboard
+}
+
+// Service 维护排行榜定义的内存快照，计分与查询只访问 Redis 有序集合，复杂度均为 O(log N)
+type Service struct {
+	repo       LeaderboardRepository
+	rdb        goredis.Cmdable
+	archiveTop int
+	archiveTTL time.Duration
+	boards     atomic.Pointer[boardSet]
+	logger     logs.Logger
+}
+
+func NewService(repo LeaderboardRepository, rdb goredis.Cmdable, archiveTop int, archiveTTL time.Duration, logger logs.Logger) *Service {
+	s := &Service{
+		repo:       repo,
+		rdb:        rdb,
+		archiveTop: archiveTop,
+		archiveTTL: archiveTTL,
+		logger:     logger,
+	}
+	s.boards.Store(&boardSet{byName: map[string]*Leaderboard{}, byEvent: map[string][]*Leaderboard{}})
+	return s
+}
+
+func seasonKey(board *Leaderboard, season int) string {
+	return fmt.Sprintf("leaderboard:%d:s%d", board.BoardID, season)
+}
+
+// seasonMetaKey 记录当前赛季号与开始时间，计分时以它为准，各实例缓存的定义可能落后
+func seasonMetaKey(board *Leaderboard) string {
+	return fmt.Sprintf("leaderboard:%d:season", board.BoardID)
+}
+
+func (s *Service) syncSeason(ctx context.Context, board *Leaderboard) error {
+	return syncSeasonScript.Run(ctx, s.rdb, []string{seasonMetaKey(board)}, board.CurrentSeason, board.SeasonStart.Unix()).Err()
+}
+
+// Reload 从 MySQL 重新加载排行榜定义，并把 Redis 中落后的赛季元数据推进到库中的赛季
+func (s *Service) Reload() error {
+	boards, err := s.repo.List()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	for i := range boards {
+		if err = s.syncSeason(ctx, &boards[i]); err != nil {
+			s.logger.Error(ctx, "leaderboard season sync failed:", boards[i].Name, err.Error())
+		}
+	}
+	set := &boardSet{
+		byName:  make(map[string]*Leaderboard, len(boards)),
+		byEvent: make(map[string][]*Leaderboard),
+	}
+	for i := range boards {
+		b := &boards[i]
+		set.byName[b.Name] = b
+		set.byEvent[b.EventName] = append(set.byEvent[b.EventName], b)
+	}
+	s.boards.Store(set)
+	return nil
+}
+
+func (s *Service) Board(name string) (*Leaderboard, bool) {
+	b, ok := s.boards.Load().byName[name]
+	return b, ok
+}
+
+// eventValue 返回事件对应的计分值，属性缺失或非法时不计分
+func eventValue(board *Leaderboard, e *event.UserEvent) (int64, bool) {
+	if board.Aggregate == AggregateCount {
+		return 1, true
+	}
+	if e.Properties == "" {
+		return 0, false
+	}
+	var props map[string]any
+	if err := json.Unmarshal([]byte(e.Properties), &props); err != nil {
+		return 0, false
+	}
+	v, ok := props[board.Property]
+	if !ok {
+		return 0, false
+	}
+	return gconv.Int64(v), true
+}
+
+// OnEvent 注册为服务端事件监听器，积分榜可按 points_changed 事件的 delta 属性计分
+func (s *Service) OnEvent(ctx context.Context, e *event.UserEvent) {
+	if err := s.observe(ctx, e, false); err != nil {
+		s.logger.Error(ctx, "leaderboard score failed:", e.UserID, e.Name, err.Error())
+	}
+}
+
+// Write 让 Service 可以作为埋点管道的 Sink，只统计 source 为 any 的排行榜，匿名事件不计分
+func (s *Service) Write(ctx context.Context, events []event.UserEvent) error {
+	for i := range events {
+		if err := s.observe(ctx, &events[i], true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observe 为事件计分，client 表示事件来自埋点上报，此时以服务端接收时间代替客户端时间
+func (s *Service) observe(ctx context.Context, e *event.UserEvent, client bool) error {
+	if e.UserID == 0 {
+		return nil
+	}
+	at := e.CreatedAt
+	if client {
+		at = e.ReceivedAt
+	}
+	for _, board := range s.boards.Load().byEvent[e.Name] {
+		if !board.accepts(client) {
+			continue
+		}
+		value, ok := eventValue(board, e)
+		if !ok {
+			continue
+		}
+		if err := s.apply(ctx, board, e.UserID, value, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) Close() error {
+	return nil
+}
+
+func (s *Service) apply(ctx context.Context, board *Leaderboard, userID uint, value int64, at time.Time) error {
+	mode := "add"
+	if board.Aggregate == AggregateMax {
+		mode = "max"
+	}
+	for range 2 {
+		points, err := applyScript.Run(ctx, s.rdb,
+			[]string{seasonMetaKey(board), seasonKey(board, board.CurrentSeason), seasonKey(board, board.CurrentSeason+1)},
+			strconv.FormatUint(uint64(userID), 10), mode, value, at.Unix(), timeScale, MaxPoints,
+			board.CurrentSeason, board.SeasonStart.Unix()).Int64()
+		if err != nil || points >= 0 {
+			return err
+		}
+		// 本实例的定义落后多个赛季，刷新后重试
+		if err = s.Reload(); err != nil {
+			return err
+		}
+		if board = s.boards.Load().byName[board.Name]; board == nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *Service) toEntry(board *Leaderboard, rank int, z goredis.Z) Entry {
+	uid, _ := strconv.ParseUint(fmt.Sprint(z.Member), 10, 64)
+	points, elapsed := decodeScore(z.Score)
+	return Entry{
+		Rank:       rank,
+		UserID:     uint(uid),
+		Score:      points,
+		AchievedAt: board.SeasonStart.Add(time.Duration(elapsed) * time.Second),
+	}
+}
+
+// Top 返回当前赛季前 limit 名
+func (s *Service) Top(ctx context.Context, board *Leaderboard, limit int) ([]Entry, error) {
+	return s.page(ctx, board, board.CurrentSeason, 0, limit)
+}
+
+func (s *Service) page(ctx context.Context, board *Leaderboard, season int, offset, limit int) ([]Entry, error) {
+	zs, err := s.rdb.ZRevRangeWithScores(ctx, seasonKey(board, season), int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(zs))
+	for i, z := range zs {
+		entries = append(entries, s.toEntry(board, offset+i+1, z))
+	}
+	return entries, nil
+}
+
+// Rank 返回用户在当前赛季的名次，未上榜时返回 nil
+func (s *Service) Rank(ctx context.Context, board *Leaderboard, userID uint) (*Entry, error) {
+	key := seasonKey(board, board.CurrentSeason)
+	member := strconv.FormatUint(uint64(userID), 10)
+
+	pipe := s.rdb.Pipeline()
+	rankCmd := pipe.ZRevRank(ctx, key, member)
+	scoreCmd := pipe.ZScore(ctx, key, member)
+	if _, err := pipe.Exec(ctx); err != nil {
+		if err == goredis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	entry := s.toEntry(board, int(rankCmd.Val())+1, goredis.Z{Member: member, Score: scoreCmd.Val()})
+	return &entry, nil
+}
+
+// RunSeasons 作为定时任务执行：刷新定义并结束所有已到期的赛季
+func (s *Service) RunSeasons(ctx context.Context) {
+	if err := s.Reload(); err != nil {
+		s.logger.Error(ctx, "leaderboard reload failed:", err.Error())
+		return
+	}
+	now := time.Now()
+	for _, board := range s.boards.Load().byName {
+		end := board.SeasonEnd()
+		if end.IsZero() || now.Before(end) {
+			continue
+		}
+		// 服务停机跨过多个赛季时，新赛季从最近一个边界开始
+		for !now.Before(end.AddDate(0, 0, board.SeasonDays)) {
+			end = end.AddDate(0, 0, board.SeasonDays)
+		}
+		if _, err := s.EndSeason(ctx, board, end); err != nil {
+			s.logger.Error(ctx, "leaderboard season reset failed:", board.Name, err.Error())
+		}
+	}
+}
+
+// EndSeason 推进赛季并归档上一赛季前 archiveTop 名，旧的有序集合保留 archiveTTL 后过期。
+// 返回 false 表示赛季已被其他实例推进
+func (s *Service) EndSeason(ctx context.Context, board *Leaderboard, nextStart time.Time) (bool, error) {
+	ctx, span := gtrace.NewSpan(ctx, "Leaderboard.EndSeason")
+	defer span.End()
+
+	season := board.CurrentSeason
+	ok, err := s.repo.AdvanceSeason(board.BoardID, season, nextStart)
+	if err != nil || !ok {
+		return false, err
+	}
+	// 先推进 Redis 中的赛季，之后任何实例的计分都写入新赛季，归档读取时旧赛季不再变化
+	next := *board
+	next.CurrentSeason, next.SeasonStart = season+1, nextStart
+	if err = s.syncSeason(ctx, &next); err != nil {
+		return true, err
+	}
+	if err = s.Reload(); err != nil {
+		return true, err
+	}
+
+	archived := 0
+	for archived < s.archiveTop {
+		limit := min(archivePageSize, s.archiveTop-archived)
+		entries, err := s.page(ctx, board, season, archived, limit)
+		if err != nil {
+			return true, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		archives := make([]Archive, 0, len(entries))
+		for _, e := range entries {
+			archives = append(archives, Archive{
+				BoardID:    board.BoardID,
+				Season:     season,
+				Rank:       e.Rank,
+				UserID:     e.UserID,
+				Score:      e.Score,
+				AchievedAt: e.AchievedAt,
+			})
+		}
+		if err = s.repo.SaveArchives(archives); err != nil {
+			return true, err
+		}
+		archived += len(entries)
+		if len(entries) < limit {
+			break
+		}
+	}
+	s.logger.Info(ctx, "leaderboard season ended:", board.Name, season, "archived", archived)
+	return true, s.rdb.Expire(ctx, seasonKey(board, season), s.archiveTTL).Err()
+}
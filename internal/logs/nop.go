@@ -0,0 +1,15 @@
+package logs
+
+import "context"
+
+type nopLogger struct{}
+
+// Nop 返回丢弃全部输出的 Logger，供测试及不关心日志的调用方使用
+func Nop() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Info(ctx context.Context, v ...any)  {}
+func (nopLogger) Debug(ctx context.Context, v ...any) {}
+func (nopLogger) Error(ctx context.Context, v ...any) {}
+func (nopLogger) Fatal(ctx context.Context, v ...any) {}
@@ -0,0 +1,141 @@
+package lottery
+
+import (
+	"context"
+	"errors"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+type PrizeInput struct {
+	Name        string  `json:"name" v:"required|max-length:255#奖品名称不能为空|奖品名称过长"`
+	Probability float64 `json:"probability" v:"required|between:0.000001,1#中奖概率不能为空|中奖概率应在0.000001到1之间"`
+	Stock       int64   `json:"stock" v:"min:-1#库存不能小于-1"` // -1 表示不限量
+	Points      int64   `json:"points" v:"min:0"`
+}
+
+type CreatePoolReq struct {
+	g.Meta        `path:"/api/admin/lottery/pools" method:"post"`
+	Key           string       `json:"key" v:"required|regex:^[a-z0-9_]{1,64}$#奖池标识不能为空|奖池标识只能包含小写字母、数字和下划线"`
+	Name          string       `json:"name" v:"required|max-length:255#奖池名称不能为空|奖池名称过长"`
+	CostPoints    int64        `json:"cost_points" v:"min:0"`
+	FreeDaily     int          `json:"free_daily" v:"min:0"`
+	PityThreshold int          `json:"pity_threshold" v:"min:0"`
+	Prizes        []PrizeInput `json:"prizes" v:"required|max-length:50#奖品不能为空|奖品最多50个"`
+}
+
+type CreatePoolRes struct {
+}
+
+type ListPoolsReq struct {
+	g.Meta `path:"/api/admin/lottery/pools" method:"get"`
+}
+
+type ListPoolsRes struct {
+}
+
+type ReconcileReq struct {
+	g.Meta `path:"/api/admin/lottery/pools/{key}/reconcile" method:"post"`
+	Key    string `p:"key" v:"required"`
+}
+
+type ReconcileRes struct {
+}
+
+type Admin struct {
+	repo       LotteryRepository
+	service    *Service
+	userLogger logs.Logger
+}
+
+func NewAdmin(repo LotteryRepository, service *Service, logger logs.Logger) *Admin {
+	return &Admin{
+		repo:       repo,
+		service:    service,
+		userLogger: logger,
+	}
+}
+
+func (params *Admin) Create(ctx context.Context, req *CreatePoolReq) (res *CreatePoolRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Lottery.CreatePool")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	pool := &Pool{
+		Key:           req.Key,
+		Name:          req.Name,
+		CostPoints:    req.CostPoints,
+		FreeDaily:     req.FreeDaily,
+		PityThreshold: req.PityThreshold,
+		Active:        true,
+	}
+	prizes := make([]Prize, 0, len(req.Prizes))
+	for _, p := range req.Prizes {
+		prizes = append(prizes, Prize{
+			Name:   p.Name,
+			Odds:   int(p.Probability*oddsScale + 0.5),
+			Stock:  p.Stock,
+			Points: p.Points,
+		})
+	}
+	if err = params.service.CreatePool(ctx, pool, prizes); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidPrizes):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "奖品概率之和不能超过1")
+		case errors.Is(err, ErrDuplicatePool):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "奖池标识已存在")
+		}
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Lottery pool created:", pool.PoolID, pool.Key, "prizes:", len(prizes))
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "pool created",
+		"data": g.Map{
+			"pool":   pool,
+			"prizes": prizes,
+		},
+	})
+	return nil, nil
+}
+
+func (params *Admin) List(ctx context.Context, req *ListPoolsReq) (res *ListPoolsRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	pools, err := params.repo.ListPools()
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    pools,
+	})
+	return nil, nil
+}
+
+// Reconcile 核对 Redis 库存与 MySQL 抽奖记录并返回各奖品的库存情况
+func (params *Admin) Reconcile(ctx context.Context, req *ReconcileReq) (res *ReconcileRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	statuses, err := params.service.Reconcile(ctx, req.Key)
+	if err != nil {
+		if errors.Is(err, ErrPoolNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "奖池不存在")
+		}
+		return nil, err
+	}
+	params.userLogger.Info(ctx, "Lottery reconciled:", req.Key, "operator:", r.GetCtxVar("userid").String())
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    statuses,
+	})
+	return nil, nil
+}
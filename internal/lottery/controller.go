@@ -0,0 +1,182 @@
+package lottery
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/points"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type PoolReq struct {
+	g.Meta `path:"/api/lottery/{key}" method:"get"`
+	Key    string `p:"key" v:"required"`
+}
+
+type PoolRes struct {
+}
+
+type DrawReq struct {
+	g.Meta `path:"/api/lottery/{key}/draw" method:"post"`
+	Key    string `p:"key" v:"required"`
+}
+
+type DrawRes struct {
+}
+
+type HistoryReq struct {
+	g.Meta `path:"/api/lottery/{key}/draws" method:"get"`
+	Key    string `p:"key" v:"required"`
+	Limit  int    `p:"limit" d:"20" v:"between:1,100#条数应在1到100之间"`
+}
+
+type HistoryRes struct {
+}
+
+type Controller struct {
+	repo       LotteryRepository
+	service    *Service
+	userLogger logs.Logger
+}
+
+func NewController(repo LotteryRepository, service *Service, logger logs.Logger) *Controller {
+	return &Controller{
+		repo:       repo,
+		service:    service,
+		userLogger: logger,
+	}
+}
+
+func currentUser(ctx context.Context) (uint, error) {
+	userid := g.RequestFromCtx(ctx).GetCtxVar("userid").String()
+	uid, err := strconv.ParseUint(userid, 10, 64)
+	if err != nil {
+		return 0, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+	return uint(uid), nil
+}
+
+func (c *Controller) findPool(key string) (*Pool, error) {
+	pool, err := c.repo.FindPoolByKey(key)
+	if err != nil {
+		if errors.Is(err, ErrPoolNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "奖池不存在")
+		}
+		return nil, err
+	}
+	return pool, nil
+}
+
+// Pool 展示奖池、奖品概率以及当前用户的免费次数与保底进度
+func (c *Controller) Pool(ctx context.Context, req *PoolReq) (res *PoolRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	uid, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := c.findPool(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	prizes, err := c.repo.ListPrizes(pool.PoolID)
+	if err != nil {
+		return nil, err
+	}
+	freeLeft, misses, err := c.service.Status(ctx, pool, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]g.Map, 0, len(prizes))
+	for _, p := range prizes {
+		items = append(items, g.Map{
+			"prize_id":    p.PrizeID,
+			"name":        p.Name,
+			"probability": float64(p.Odds) / oddsScale,
+			"points":      p.Points,
+		})
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data": g.Map{
+			"key":            pool.Key,
+			"name":           pool.Name,
+			"active":         pool.Active,
+			"cost_points":    pool.CostPoints,
+			"free_left":      freeLeft,
+			"pity_threshold": pool.PityThreshold,
+			"misses":         misses,
+			"prizes":         items,
+		},
+	})
+	return nil, nil
+}
+
+func (c *Controller) Draw(ctx context.Context, req *DrawReq) (res *DrawRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Lottery.DrawHandler")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	uid, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int64("user.id", int64(uid)), attribute.String("lottery.pool", req.Key))
+
+	result, err := c.service.Draw(ctx, req.Key, uid)
+	if err != nil {
+		c.userLogger.Info(ctx, "Lottery draw rejected:", req.Key, "userid:", uid, "reason:", err.Error())
+		switch {
+		case errors.Is(err, ErrPoolNotFound):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "奖池不存在")
+		case errors.Is(err, ErrPoolInactive):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "抽奖活动未开启")
+		case errors.Is(err, ErrNoFreeDraws):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "今日免费次数已用完")
+		case errors.Is(err, points.ErrInsufficientBalance):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "积分不足")
+		}
+		return nil, err
+	}
+
+	c.userLogger.Info(ctx, "Lottery draw:", req.Key, "userid:", uid, "draw:", result.DrawID, "won:", result.Won, "pity:", result.Pity)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    result,
+	})
+	return nil, nil
+}
+
+// History 返回当前用户在该奖池的抽奖记录
+func (c *Controller) History(ctx context.Context, req *HistoryReq) (res *HistoryRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	uid, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := c.findPool(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	draws, err := c.repo.ListDraws(pool.PoolID, uid, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    draws,
+	})
+	return nil, nil
+}
@@ -0,0 +1,165 @@
+package lottery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// ErrStockNotLoaded 表示 Redis 中没有奖池库存，需要先从 MySQL 对账加载
+var ErrStockNotLoaded = errors.New("lottery stock not loaded")
+
+// key 使用 {id} 作为 hash tag，集群模式下同一奖池的 key 落在同一 slot，Lua 脚本可原子操作
+func stockKey(poolID uint) string {
+	return fmt.Sprintf("lottery:{%d}:stock", poolID)
+}
+
+func pityKey(poolID uint) string {
+	return fmt.Sprintf("lottery:{%d}:pity", poolID)
+}
+
+func freeKey(poolID, userID uint, day time.Time) string {
+	return fmt.Sprintf("lottery:{%d}:free:%d:%s", poolID, userID, day.Format("20060102"))
+}
+
+// Inventory 负责奖品库存与保底计数的原子更新
+type Inventory interface {
+	// Draw 优先扣减 primary 的库存；未中奖且达到保底次数时按 fallback 顺序扣减第一个有库存的奖品。
+	// 返回实际中奖的奖品（0 表示未中奖）以及是否由保底触发
+	Draw(ctx context.Context, pool *Pool, userID, primary uint, fallback []uint) (uint, bool, error)
+	// UseFree 占用一次当天免费次数，次数已用完返回 false
+	UseFree(ctx context.Context, pool *Pool, userID uint, day time.Time) (bool, error)
+	ReturnFree(ctx context.Context, pool *Pool, userID uint, day time.Time) error
+	FreeUsed(ctx context.Context, pool *Pool, userID uint, day time.Time) (int64, error)
+	Misses(ctx context.Context, pool *Pool, userID uint) (int64, error)
+	// Stock 返回 Redis 中各奖品的剩余库存，没有加载时返回空
+	Stock(ctx context.Context, pool *Pool) (map[uint]int64, error)
+	// Restore 在库存缺失时写入，或在 Redis 多于 MySQL 推算值时下调，不会上调已扣减的库存
+	Restore(ctx context.Context, pool *Pool, prizeID uint, remaining int64) error
+}
+
+// drawScript 返回 {奖品ID, 是否保底}，库存未加载时奖品ID为 -1；库存为 -1 的奖品不限量
+var drawScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return {-1, 0}
+end
+local function take(id)
+	local stock = tonumber(redis.call('HGET', KEYS[1], id) or '0')
+	if stock == -1 then
+		return true
+	end
+	if stock > 0 then
+		redis.call('HINCRBY', KEYS[1], id, -1)
+		return true
+	end
+	return false
+end
+if ARGV[3] ~= '0' and take(ARGV[3]) then
+	redis.call('HDEL', KEYS[2], ARGV[1])
+	return {tonumber(ARGV[3]), 0}
+end
+local threshold = tonumber(ARGV[2])
+local misses = tonumber(redis.call('HGET', KEYS[2], ARGV[1]) or '0')
+if threshold > 0 and misses >= threshold then
+	for i = 4, #ARGV do
+		if take(ARGV[i]) then
+			redis.call('HDEL', KEYS[2], ARGV[1])
+			return {tonumber(ARGV[i]), 1}
+		end
+	end
+end
+redis.call('HINCRBY', KEYS[2], ARGV[1], 1)
+return {0, 0}
+`)
+
+// restoreScript 库存缺失时写入，已有值大于目标值时下调
+var restoreScript = goredis.NewScript(`
+local current = redis.call('HGET', KEYS[1], ARGV[1])
+if not current or tonumber(current) > tonumber(ARGV[2]) then
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+type redisInventory struct {
+	rdb goredis.Cmdable
+}
+
+func NewRedisInventory(rdb goredis.Cmdable) Inventory {
+	return &redisInventory{rdb: rdb}
+}
+
+func (inv *redisInventory) Draw(ctx context.Context, pool *Pool, userID, primary uint, fallback []uint) (uint, bool, error) {
+	args := make([]any, 0, 3+len(fallback))
+	args = append(args, userID, pool.PityThreshold, primary)
+	for _, id := range fallback {
+		args = append(args, id)
+	}
+	res, err := drawScript.Run(ctx, inv.rdb, []string{stockKey(pool.PoolID), pityKey(pool.PoolID)}, args...).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	if res[0] < 0 {
+		return 0, false, ErrStockNotLoaded
+	}
+	return uint(res[0]), res[1] == 1, nil
+}
+
+func (inv *redisInventory) UseFree(ctx context.Context, pool *Pool, userID uint, day time.Time) (bool, error) {
+	key := freeKey(pool.PoolID, userID, day)
+	pipe := inv.rdb.TxPipeline()
+	used := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, 48*time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	if used.Val() > int64(pool.FreeDaily) {
+		return false, inv.ReturnFree(ctx, pool, userID, day)
+	}
+	return true, nil
+}
+
+func (inv *redisInventory) ReturnFree(ctx context.Context, pool *Pool, userID uint, day time.Time) error {
+	return inv.rdb.Decr(ctx, freeKey(pool.PoolID, userID, day)).Err()
+}
+
+func (inv *redisInventory) FreeUsed(ctx context.Context, pool *Pool, userID uint, day time.Time) (int64, error) {
+	n, err := inv.rdb.Get(ctx, freeKey(pool.PoolID, userID, day)).Int64()
+	if errors.Is(err, goredis.Nil) {
+		return 0, nil
+	}
+	return n, err
+}
+
+func (inv *redisInventory) Misses(ctx context.Context, pool *Pool, userID uint) (int64, error) {
+	n, err := inv.rdb.HGet(ctx, pityKey(pool.PoolID), strconv.FormatUint(uint64(userID), 10)).Int64()
+	if errors.Is(err, goredis.Nil) {
+		return 0, nil
+	}
+	return n, err
+}
+
+func (inv *redisInventory) Stock(ctx context.Context, pool *Pool) (map[uint]int64, error) {
+	raw, err := inv.rdb.HGetAll(ctx, stockKey(pool.PoolID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	stock := make(map[uint]int64, len(raw))
+	for field, value := range raw {
+		id, err1 := strconv.ParseUint(field, 10, 64)
+		n, err2 := strconv.ParseInt(value, 10, 64)
+		if err1 == nil && err2 == nil {
+			stock[uint(id)] = n
+		}
+	}
+	return stock, nil
+}
+
+func (inv *redisInventory) Restore(ctx context.Context, pool *Pool, prizeID uint, remaining int64) error {
+	return restoreScript.Run(ctx, inv.rdb, []string{stockKey(pool.PoolID)}, prizeID, remaining).Err()
+}
@@ -0,0 +1,64 @@
+package lottery
+
+import "errors"
+
+// 概率单位为百万分之一
+const oddsScale = 1_000_000
+
+var ErrInvalidPrizes = errors.New("invalid prize definitions")
+
+// validatePrizes 检查奖品概率与库存
+func validatePrizes(prizes []Prize) error {
+	if len(prizes) == 0 {
+		return ErrInvalidPrizes
+	}
+	total := 0
+	for _, p := range prizes {
+		if p.Odds <= 0 || p.Points < 0 || (p.Stock < 0 && p.Stock != UnlimitedStock) {
+			return ErrInvalidPrizes
+		}
+		total += p.Odds
+	}
+	if total > oddsScale {
+		return ErrInvalidPrizes
+	}
+	return nil
+}
+
+// pick 根据 [0, oddsScale) 内的随机数按概率选出奖品，落在剩余区间时返回 0 表示未中奖
+func pick(prizes []Prize, roll int) uint {
+	for _, p := range prizes {
+		if roll < p.Odds {
+			return p.PrizeID
+		}
+		roll -= p.Odds
+	}
+	return 0
+}
+
+// pityOrder 按概率加权不放回抽样得到保底时的候选顺序，概率越高的奖品越可能排在前面。
+// intn 返回 [0, n) 内的随机数
+func pityOrder(prizes []Prize, intn func(n int) int) []uint {
+	rest := make([]Prize, len(prizes))
+	copy(rest, prizes)
+	total := 0
+	for _, p := range rest {
+		total += p.Odds
+	}
+
+	order := make([]uint, 0, len(rest))
+	for len(rest) > 0 && total > 0 {
+		roll := intn(total)
+		i := 0
+		for ; i < len(rest)-1; i++ {
+			if roll < rest[i].Odds {
+				break
+			}
+			roll -= rest[i].Odds
+		}
+		order = append(order, rest[i].PrizeID)
+		total -= rest[i].Odds
+		rest = append(rest[:i], rest[i+1:]...)
+	}
+	return order
+}
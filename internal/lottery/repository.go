@@ -0,0 +1,139 @@
+package lottery
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPoolNotFound  = errors.New("lottery pool not found")
+	ErrDuplicatePool = errors.New("lottery pool already exists")
+)
+
+// UnlimitedStock 表示奖品不限量
+const UnlimitedStock = -1
+
+// Pool 是一个奖池，CostPoints 为 0 且 FreeDaily 为 0 时不限次免费抽
+type Pool struct {
+	PoolID        uint      `gorm:"primaryKey;autoIncrement" json:"pool_id"`
+	Key           string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"key"`
+	Name          string    `gorm:"type:varchar(255);not null" json:"name"`
+	CostPoints    int64     `gorm:"not null;default:0" json:"cost_points"`    // 每次消耗的积分
+	FreeDaily     int       `gorm:"not null;default:0" json:"free_daily"`     // 每天免费次数，用完后才扣积分
+	PityThreshold int       `gorm:"not null;default:0" json:"pity_threshold"` // 连续未中该次数后下一次必中，0 表示不保底
+	Active        bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Prize 的 Odds 以百万分之一为单位，奖池内所有奖品之和不超过 oddsScale，剩余概率为未中奖
+type Prize struct {
+	PrizeID uint   `gorm:"primaryKey;autoIncrement" json:"prize_id"`
+	PoolID  uint   `gorm:"not null;index" json:"pool_id"`
+	Name    string `gorm:"type:varchar(255);not null" json:"name"`
+	Odds    int    `gorm:"not null" json:"odds"`
+	Stock   int64  `gorm:"not null" json:"stock"`            // 初始库存，-1 表示不限量
+	Points  int64  `gorm:"not null;default:0" json:"points"` // 中奖后发放的积分，0 表示实物等其他奖品
+}
+
+// Draw 是一次抽奖记录，PrizeID 为 0 表示未中奖
+type Draw struct {
+	DrawID    uint      `gorm:"primaryKey;autoIncrement" json:"draw_id"`
+	PoolID    uint      `gorm:"not null;index:idx_pool_prize" json:"pool_id"`
+	UserID    uint      `gorm:"not null;index:idx_user_time" json:"user_id"`
+	PrizeID   uint      `gorm:"not null;default:0;index:idx_pool_prize" json:"prize_id"`
+	Cost      int64     `gorm:"not null;default:0" json:"cost"`
+	Free      bool      `gorm:"not null;default:false" json:"free"`
+	Pity      bool      `gorm:"not null;default:false" json:"pity"` // 保底触发
+	CreatedAt time.Time `gorm:"index:idx_user_time" json:"created_at"`
+}
+
+type lotteryRepository struct {
+	db *gorm.DB
+}
+
+type LotteryRepository interface {
+	CreatePool(pool *Pool, prizes []Prize) error
+	FindPoolByKey(key string) (*Pool, error)
+	ListPools() ([]Pool, error)
+	ListPrizes(poolID uint) ([]Prize, error)
+	CreateDraw(draw *Draw) error
+	ListDraws(poolID, userID uint, limit int) ([]Draw, error)
+	// CountIssued 返回奖池内各奖品已抽中的数量
+	CountIssued(poolID uint) (map[uint]int64, error)
+}
+
+func NewLotteryRepository(db *gorm.DB) LotteryRepository {
+	if err := db.AutoMigrate(&Pool{}, &Prize{}, &Draw{}); err != nil {
+		panic("failed to migrate lottery tables")
+	}
+	return &lotteryRepository{db: db}
+}
+
+func (repo *lotteryRepository) CreatePool(pool *Pool, prizes []Prize) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(pool).Error; err != nil {
+			var mysqlErr *mysql.MySQLError
+			if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 { // Error 1062: Duplicate entry
+				return ErrDuplicatePool
+			}
+			return err
+		}
+		for i := range prizes {
+			prizes[i].PoolID = pool.PoolID
+		}
+		return tx.Create(&prizes).Error
+	})
+}
+
+func (repo *lotteryRepository) FindPoolByKey(key string) (*Pool, error) {
+	var pool Pool
+	if err := repo.db.Where("`key` = ?", key).First(&pool).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPoolNotFound
+		}
+		return nil, err
+	}
+	return &pool, nil
+}
+
+func (repo *lotteryRepository) ListPools() ([]Pool, error) {
+	var pools []Pool
+	err := repo.db.Order("pool_id").Find(&pools).Error
+	return pools, err
+}
+
+func (repo *lotteryRepository) ListPrizes(poolID uint) ([]Prize, error) {
+	var prizes []Prize
+	err := repo.db.Where("pool_id = ?", poolID).Order("prize_id").Find(&prizes).Error
+	return prizes, err
+}
+
+func (repo *lotteryRepository) CreateDraw(draw *Draw) error {
+	return repo.db.Create(draw).Error
+}
+
+func (repo *lotteryRepository) ListDraws(poolID, userID uint, limit int) ([]Draw, error) {
+	var draws []Draw
+	err := repo.db.Where("pool_id = ? AND user_id = ?", poolID, userID).Order("draw_id DESC").Limit(limit).Find(&draws).Error
+	return draws, err
+}
+
+func (repo *lotteryRepository) CountIssued(poolID uint) (map[uint]int64, error) {
+	var rows []struct {
+		PrizeID uint
+		Issued  int64
+	}
+	err := repo.db.Model(&Draw{}).Select("prize_id, COUNT(*) AS issued").
+		Where("pool_id = ? AND prize_id > 0", poolID).Group("prize_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	issued := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		issued[row.PrizeID] = row.Issued
+	}
+	return issued, nil
+}
@@ -0,0 +1,272 @@
+package lottery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	mrand "math/rand/v2"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/points"
+
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+// 积分流水原因
+const (
+	ReasonCost   = "lottery_cost"
+	ReasonRefund = "lottery_refund"
+	ReasonPrize  = "lottery_prize"
+)
+
+var (
+	ErrPoolInactive = errors.New("lottery pool inactive")
+	ErrNoFreeDraws  = errors.New("no free draws left today")
+)
+
+// Result 是一次抽奖的结果
+type Result struct {
+	DrawID uint   `json:"draw_id"`
+	Won    bool   `json:"won"`
+	Prize  *Prize `json:"prize,omitempty"`
+	Free   bool   `json:"free"`
+	Cost   int64  `json:"cost"`
+	Pity   bool   `json:"pity"`
+}
+
+// StockStatus 是对账时单个奖品的库存情况，Drift 为 Redis 剩余减去 MySQL 推算的剩余
+type StockStatus struct {
+	PrizeID  uint   `json:"prize_id"`
+	Name     string `json:"name"`
+	Stock    int64  `json:"stock"`
+	Issued   int64  `json:"issued"`
+	Expected int64  `json:"expected"`
+	Redis    int64  `json:"redis"`
+	Drift    int64  `json:"drift"`
+}
+
+type Service struct {
+	repo   LotteryRepository
+	inv    Inventory
+	points *points.Service
+	// intn 返回 [0, n) 内的随机数，测试时可替换为固定种子
+	intn   func(n int) int
+	logger logs.Logger
+}
+
+func NewService(repo LotteryRepository, inv Inventory, pointsService *points.Service, logger logs.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		inv:    inv,
+		points: pointsService,
+		intn:   mrand.IntN,
+		logger: logger,
+	}
+}
+
+func newRefID() string {
+	buf := make([]byte, 12)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// CreatePool 保存奖池并把库存加载到 Redis
+func (s *Service) CreatePool(ctx context.Context, pool *Pool, prizes []Prize) error {
+	if err := validatePrizes(prizes); err != nil {
+		return err
+	}
+	if err := s.repo.CreatePool(pool, prizes); err != nil {
+		return err
+	}
+	_, err := s.reconcile(ctx, pool, prizes)
+	return err
+}
+
+// Draw 执行一次抽奖：优先使用当天免费次数，用完后扣积分；Redis 扣减库存失败时退还
+func (s *Service) Draw(ctx context.Context, key string, userID uint) (*Result, error) {
+	ctx, span := gtrace.NewSpan(ctx, "Lottery.Draw")
+	defer span.End()
+
+	pool, err := s.repo.FindPoolByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !pool.Active {
+		return nil, ErrPoolInactive
+	}
+	prizes, err := s.repo.ListPrizes(pool.PoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := &Result{}
+	if pool.FreeDaily > 0 {
+		if result.Free, err = s.inv.UseFree(ctx, pool, userID, now); err != nil {
+			return nil, err
+		}
+	}
+	refID := newRefID()
+	if !result.Free {
+		switch {
+		case pool.CostPoints > 0:
+			if _, err = s.points.Award(ctx, userID, -pool.CostPoints, ReasonCost, refID); err != nil {
+				return nil, err
+			}
+			result.Cost = pool.CostPoints
+		case pool.FreeDaily > 0:
+			return nil, ErrNoFreeDraws
+		}
+	}
+
+	prizeID, pity, err := s.draw(ctx, pool, prizes, userID)
+	if err != nil {
+		s.undo(ctx, pool, userID, result, refID, now)
+		return nil, err
+	}
+	result.Pity = pity
+
+	draw := &Draw{PoolID: pool.PoolID, UserID: userID, PrizeID: prizeID, Cost: result.Cost, Free: result.Free, Pity: pity}
+	if err = s.repo.CreateDraw(draw); err != nil {
+		// 库存已在 Redis 扣减，记录失败时由对账发现差异
+		s.logger.Error(ctx, "lottery draw record failed:", pool.Key, userID, prizeID, err.Error())
+	}
+	result.DrawID = draw.DrawID
+
+	for i := range prizes {
+		if prizes[i].PrizeID != prizeID {
+			continue
+		}
+		result.Won, result.Prize = true, &prizes[i]
+		if prizes[i].Points > 0 {
+			if _, err = s.points.Award(ctx, userID, prizes[i].Points, ReasonPrize, refID); err != nil {
+				s.logger.Error(ctx, "lottery prize award failed:", pool.Key, userID, prizeID, err.Error())
+			}
+		}
+	}
+	return result, nil
+}
+
+// draw 在 Go 中按概率抽出候选奖品，由 Inventory 原子扣减库存与更新保底计数
+func (s *Service) draw(ctx context.Context, pool *Pool, prizes []Prize, userID uint) (uint, bool, error) {
+	primary := pick(prizes, s.intn(oddsScale))
+	var fallback []uint
+	if pool.PityThreshold > 0 {
+		fallback = pityOrder(prizes, s.intn)
+	}
+	prizeID, pity, err := s.inv.Draw(ctx, pool, userID, primary, fallback)
+	if errors.Is(err, ErrStockNotLoaded) {
+		if _, err = s.reconcile(ctx, pool, prizes); err != nil {
+			return 0, false, err
+		}
+		prizeID, pity, err = s.inv.Draw(ctx, pool, userID, primary, fallback)
+	}
+	return prizeID, pity, err
+}
+
+// undo 退还免费次数或积分
+func (s *Service) undo(ctx context.Context, pool *Pool, userID uint, result *Result, refID string, now time.Time) {
+	if result.Free {
+		if err := s.inv.ReturnFree(ctx, pool, userID, now); err != nil {
+			s.logger.Error(ctx, "lottery free draw return failed:", pool.Key, userID, err.Error())
+		}
+	}
+	if result.Cost > 0 {
+		if _, err := s.points.Award(ctx, userID, result.Cost, ReasonRefund, refID); err != nil {
+			s.logger.Error(ctx, "lottery refund failed:", pool.Key, userID, refID, err.Error())
+		}
+	}
+}
+
+// Reconcile 以 MySQL 抽奖记录为准核对 Redis 库存
+func (s *Service) Reconcile(ctx context.Context, key string) ([]StockStatus, error) {
+	pool, err := s.repo.FindPoolByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	prizes, err := s.repo.ListPrizes(pool.PoolID)
+	if err != nil {
+		return nil, err
+	}
+	return s.reconcile(ctx, pool, prizes)
+}
+
+// reconcile 补齐缺失的库存并下调 Redis 多出的部分。Redis 少于 MySQL 推算值可能是扣减后记录写入失败，
+// 也可能是抽奖进行中，只报告不回补，宁可少发不超发
+func (s *Service) reconcile(ctx context.Context, pool *Pool, prizes []Prize) ([]StockStatus, error) {
+	issued, err := s.repo.CountIssued(pool.PoolID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.inv.Stock(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]StockStatus, 0, len(prizes))
+	for _, p := range prizes {
+		status := StockStatus{PrizeID: p.PrizeID, Name: p.Name, Stock: p.Stock, Issued: issued[p.PrizeID], Expected: UnlimitedStock}
+		if p.Stock != UnlimitedStock {
+			status.Expected = max(p.Stock-status.Issued, 0)
+		}
+		redisStock, loaded := current[p.PrizeID]
+		if !loaded || (p.Stock != UnlimitedStock && redisStock > status.Expected) {
+			if err = s.inv.Restore(ctx, pool, p.PrizeID, status.Expected); err != nil {
+				return nil, err
+			}
+			if loaded {
+				s.logger.Info(ctx, "Lottery stock corrected:", pool.Key, p.PrizeID, redisStock, "->", status.Expected)
+			}
+			redisStock = status.Expected
+		}
+		status.Redis = redisStock
+		if p.Stock != UnlimitedStock {
+			status.Drift = status.Redis - status.Expected
+		}
+		if status.Drift != 0 {
+			s.logger.Info(ctx, "Lottery stock drift:", pool.Key, p.PrizeID, "redis:", status.Redis, "expected:", status.Expected)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// RunReconcile 作为定时任务核对所有启用奖池的库存
+func (s *Service) RunReconcile(ctx context.Context) {
+	pools, err := s.repo.ListPools()
+	if err != nil {
+		s.logger.Error(ctx, "lottery pool list failed:", err.Error())
+		return
+	}
+	for i := range pools {
+		if !pools[i].Active {
+			continue
+		}
+		prizes, err := s.repo.ListPrizes(pools[i].PoolID)
+		if err != nil {
+			s.logger.Error(ctx, "lottery prize list failed:", pools[i].Key, err.Error())
+			continue
+		}
+		if _, err = s.reconcile(ctx, &pools[i], prizes); err != nil {
+			s.logger.Error(ctx, "lottery reconcile failed:", pools[i].Key, err.Error())
+		}
+	}
+}
+
+// Status 返回用户在奖池中的今日免费次数与保底进度
+func (s *Service) Status(ctx context.Context, pool *Pool, userID uint) (freeLeft int64, misses int64, err error) {
+	if pool.FreeDaily > 0 {
+		used, err := s.inv.FreeUsed(ctx, pool, userID, time.Now())
+		if err != nil {
+			return 0, 0, err
+		}
+		freeLeft = max(int64(pool.FreeDaily)-used, 0)
+	}
+	if pool.PityThreshold > 0 {
+		if misses, err = s.inv.Misses(ctx, pool, userID); err != nil {
+			return 0, 0, err
+		}
+	}
+	return freeLeft, misses, nil
+}
@@ -0,0 +1,214 @@
+package lottery
+
+import (
+	"context"
+	"math/rand/v2"
+	"testing"
+	"usergrowth/internal/logs"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRepo 只实现抽奖流程用到的方法
+type fakeRepo struct {
+	LotteryRepository
+	pool   *Pool
+	prizes []Prize
+	draws  []Draw
+}
+
+func (f *fakeRepo) FindPoolByKey(key string) (*Pool, error) {
+	if f.pool.Key != key {
+		return nil, ErrPoolNotFound
+	}
+	return f.pool, nil
+}
+
+func (f *fakeRepo) ListPrizes(poolID uint) ([]Prize, error) {
+	return f.prizes, nil
+}
+
+func (f *fakeRepo) CreateDraw(draw *Draw) error {
+	draw.DrawID = uint(len(f.draws) + 1)
+	f.draws = append(f.draws, *draw)
+	return nil
+}
+
+func (f *fakeRepo) CountIssued(poolID uint) (map[uint]int64, error) {
+	issued := make(map[uint]int64)
+	for _, d := range f.draws {
+		if d.PrizeID > 0 {
+			issued[d.PrizeID]++
+		}
+	}
+	return issued, nil
+}
+
+// newTestService 使用 miniredis 上的真实库存脚本
+func newTestService(t *testing.T, pool *Pool, prizes []Prize, seed uint64) (*Service, *fakeRepo, Inventory) {
+	mr := miniredis.RunT(t)
+	repo := &fakeRepo{pool: pool, prizes: prizes}
+	inv := NewRedisInventory(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}))
+	rng := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	s := &Service{repo: repo, inv: inv, intn: rng.IntN, logger: logs.Nop()}
+	return s, repo, inv
+}
+
+// chiSquare 计算观测频数相对期望概率的卡方统计量
+func chiSquare(observed []int, probs []float64, n int) float64 {
+	stat := 0.0
+	for i, p := range probs {
+		expected := p * float64(n)
+		diff := float64(observed[i]) - expected
+		stat += diff * diff / expected
+	}
+	return stat
+}
+
+// 自由度为 3 时显著性水平 0.001 的临界值
+const chiSquareCritical3 = 16.266
+
+func TestDrawDistribution(t *testing.T) {
+	pool := &Pool{PoolID: 1, Key: "daily", Active: true}
+	prizes := []Prize{
+		{PrizeID: 1, Odds: 10_000, Stock: UnlimitedStock},
+		{PrizeID: 2, Odds: 50_000, Stock: UnlimitedStock},
+		{PrizeID: 3, Odds: 200_000, Stock: UnlimitedStock},
+	}
+	s, _, _ := newTestService(t, pool, prizes, 42)
+	ctx := context.Background()
+
+	// 每次抽奖都经过 Lua 脚本，样本量取 2 万以控制耗时，最小期望频数仍有 200
+	const n = 20_000
+	observed := make([]int, 4) // 0 为未中奖
+	for i := 0; i < n; i++ {
+		res, err := s.Draw(ctx, "daily", uint(i%1000)+1)
+		if !assert.NoError(t, err) {
+			return
+		}
+		if res.Won {
+			observed[res.Prize.PrizeID]++
+		} else {
+			observed[0]++
+		}
+	}
+	probs := []float64{0.74, 0.01, 0.05, 0.20}
+	stat := chiSquare(observed, probs, n)
+	assert.Less(t, stat, chiSquareCritical3, "observed %v", observed)
+}
+
+func TestPityOrderDistribution(t *testing.T) {
+	prizes := []Prize{
+		{PrizeID: 1, Odds: 1},
+		{PrizeID: 2, Odds: 2},
+		{PrizeID: 3, Odds: 3},
+		{PrizeID: 4, Odds: 4},
+	}
+	rng := rand.New(rand.NewPCG(7, 11))
+	const n = 100_000
+	observed := make([]int, 4)
+	for i := 0; i < n; i++ {
+		order := pityOrder(prizes, rng.IntN)
+		assert.Len(t, order, 4)
+		observed[order[0]-1]++
+	}
+	stat := chiSquare(observed, []float64{0.1, 0.2, 0.3, 0.4}, n)
+	assert.Less(t, stat, chiSquareCritical3, "observed %v", observed)
+}
+
+func TestPityGuaranteesWin(t *testing.T) {
+	pool := &Pool{PoolID: 1, Key: "pity", Active: true, PityThreshold: 5}
+	prizes := []Prize{{PrizeID: 1, Odds: 1, Stock: UnlimitedStock}}
+	s, _, _ := newTestService(t, pool, prizes, 1)
+	ctx := context.Background()
+
+	streak, maxStreak, pityWins := 0, 0, 0
+	for i := 0; i < 600; i++ {
+		res, err := s.Draw(ctx, "pity", 9)
+		if !assert.NoError(t, err) {
+			return
+		}
+		if !res.Won {
+			streak++
+			maxStreak = max(maxStreak, streak)
+			continue
+		}
+		streak = 0
+		if res.Pity {
+			pityWins++
+		}
+	}
+	assert.Equal(t, 5, maxStreak)
+	assert.Equal(t, 100, pityWins)
+}
+
+func TestStockNeverOversold(t *testing.T) {
+	pool := &Pool{PoolID: 1, Key: "limited", Active: true, PityThreshold: 3}
+	prizes := []Prize{
+		{PrizeID: 1, Odds: 500_000, Stock: 3},
+		{PrizeID: 2, Odds: 100_000, Stock: 2},
+	}
+	s, repo, inv := newTestService(t, pool, prizes, 3)
+	ctx := context.Background()
+
+	for i := 0; i < 1000; i++ {
+		_, err := s.Draw(ctx, "limited", uint(i%10)+1)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+	issued, _ := repo.CountIssued(pool.PoolID)
+	assert.Equal(t, int64(3), issued[1])
+	assert.Equal(t, int64(2), issued[2])
+	stock, err := inv.Stock(ctx, pool)
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint]int64{1: 0, 2: 0}, stock)
+}
+
+func TestReconcile(t *testing.T) {
+	pool := &Pool{PoolID: 1, Key: "r", Active: true}
+	prizes := []Prize{
+		{PrizeID: 1, Name: "a", Odds: 1, Stock: 10},
+		{PrizeID: 2, Name: "b", Odds: 1, Stock: UnlimitedStock},
+		{PrizeID: 3, Name: "c", Odds: 1, Stock: 5},
+	}
+	s, repo, inv := newTestService(t, pool, prizes, 5)
+	repo.draws = []Draw{{PrizeID: 1}, {PrizeID: 1}, {PrizeID: 3}, {PrizeID: 0}}
+	ctx := context.Background()
+	assert.NoError(t, inv.Restore(ctx, pool, 1, 9))
+	assert.NoError(t, inv.Restore(ctx, pool, 3, 1))
+
+	statuses, err := s.reconcile(ctx, pool, prizes)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// Redis 多于推算值时下调，缺失时补齐，少于推算值时只报告
+	stock, err := inv.Stock(ctx, pool)
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint]int64{1: 8, 2: UnlimitedStock, 3: 1}, stock)
+	assert.Equal(t, StockStatus{PrizeID: 1, Name: "a", Stock: 10, Issued: 2, Expected: 8, Redis: 8}, statuses[0])
+	assert.Equal(t, int64(-3), statuses[2].Drift)
+}
+
+func TestValidatePrizes(t *testing.T) {
+	assert.ErrorIs(t, validatePrizes(nil), ErrInvalidPrizes)
+	assert.ErrorIs(t, validatePrizes([]Prize{{Odds: 600_000}, {Odds: 500_000}}), ErrInvalidPrizes)
+	assert.ErrorIs(t, validatePrizes([]Prize{{Odds: 1, Stock: -2}}), ErrInvalidPrizes)
+	assert.NoError(t, validatePrizes([]Prize{{Odds: 600_000, Stock: 1}, {Odds: 400_000, Stock: UnlimitedStock}}))
+}
+
+func TestDrawLoadsMissingStock(t *testing.T) {
+	pool := &Pool{PoolID: 1, Key: "lazy", Active: true}
+	prizes := []Prize{{PrizeID: 1, Odds: oddsScale, Stock: 1}}
+	s, _, _ := newTestService(t, pool, prizes, 9)
+	ctx := context.Background()
+
+	res, err := s.Draw(ctx, "lazy", 1)
+	assert.NoError(t, err)
+	assert.True(t, res.Won)
+	res, err = s.Draw(ctx, "lazy", 1)
+	assert.NoError(t, err)
+	assert.False(t, res.Won)
+}
@@ -0,0 +1,106 @@
+package notification
+
+import (
+	"context"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+type SendReq struct {
+	g.Meta   `path:"/api/admin/notifications" method:"post"`
+	UserIDs  []uint         `json:"user_ids" v:"required|max-length:1000#用户不能为空|单次最多发送1000个用户"`
+	Kind     string         `json:"kind" d:"system" v:"in:system,reward,security,campaign"`
+	Title    string         `json:"title" v:"required|max-length:255#标题不能为空|标题过长"`
+	Body     string         `json:"body" v:"max-length:4000"`
+	Data     map[string]any `json:"data"`
+	TTLHours int            `json:"ttl_hours" v:"between:0,8760"` // 0 表示使用默认有效期
+}
+
+type SendRes struct {
+}
+
+type BroadcastReq struct {
+	g.Meta    `path:"/api/admin/notifications/broadcasts" method:"post"`
+	SegmentID uint           `json:"segment_id"` // 0 表示全体用户
+	Kind      string         `json:"kind" d:"system" v:"in:system,reward,security,campaign"`
+	Title     string         `json:"title" v:"required|max-length:255#标题不能为空|标题过长"`
+	Body      string         `json:"body" v:"max-length:4000"`
+	Data      map[string]any `json:"data"`
+	TTLHours  int            `json:"ttl_hours" v:"between:0,8760"`
+}
+
+type BroadcastRes struct {
+}
+
+type Admin struct {
+	service    *Service
+	userLogger logs.Logger
+}
+
+func NewAdmin(service *Service, logger logs.Logger) *Admin {
+	return &Admin{
+		service:    service,
+		userLogger: logger,
+	}
+}
+
+func (params *Admin) Send(ctx context.Context, req *SendReq) (res *SendRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Notification.AdminSend")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	msg := Message{
+		Kind:  req.Kind,
+		Title: req.Title,
+		Body:  req.Body,
+		Data:  req.Data,
+		TTL:   time.Duration(req.TTLHours) * time.Hour,
+	}
+	sent := 0
+	for _, uid := range req.UserIDs {
+		if err = params.service.Send(ctx, uid, msg); err != nil {
+			return nil, err
+		}
+		sent++
+	}
+
+	params.userLogger.Info(ctx, "Notifications sent:", sent, "title:", req.Title)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "notifications sent",
+		"data":    g.Map{"sent": sent},
+	})
+	return nil, nil
+}
+
+func (params *Admin) Broadcast(ctx context.Context, req *BroadcastReq) (res *BroadcastRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	data, err := encodeData(req.Data)
+	if err != nil {
+		return nil, err
+	}
+	broadcast := &Broadcast{
+		SegmentID: req.SegmentID,
+		Kind:      req.Kind,
+		Title:     req.Title,
+		Body:      req.Body,
+		Data:      data,
+		ExpireAt:  params.service.expireAt(time.Duration(req.TTLHours) * time.Hour),
+	}
+	if err = params.service.Broadcast(ctx, broadcast); err != nil {
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Notification broadcast created:", broadcast.BroadcastID, "segment:", broadcast.SegmentID)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "broadcast created",
+		"data":    broadcast,
+	})
+	return nil, nil
+}
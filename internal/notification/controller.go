@@ -0,0 +1,213 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type ListReq struct {
+	g.Meta `path:"/api/notifications" method:"get"`
+	Cursor uint `p:"cursor"` // 上一页最后一条的 id，为空时从最新开始
+	Limit  int  `p:"limit" d:"20" v:"between:1,100#条数应在1到100之间"`
+}
+
+type ListRes struct {
+}
+
+type UnreadReq struct {
+	g.Meta `path:"/api/notifications/unread" method:"get"`
+}
+
+type UnreadRes struct {
+}
+
+type ReadReq struct {
+	g.Meta         `path:"/api/notifications/{id}/read" method:"post"`
+	NotificationID uint `p:"id" v:"required"`
+}
+
+type ReadRes struct {
+}
+
+type ReadAllReq struct {
+	g.Meta `path:"/api/notifications/read-all" method:"post"`
+}
+
+type ReadAllRes struct {
+}
+
+type StreamReq struct {
+	g.Meta `path:"/api/notifications/stream" method:"get"`
+}
+
+type StreamRes struct {
+}
+
+type Controller struct {
+	service   *Service
+	heartbeat time.Duration
+}
+
+func NewController(service *Service, heartbeat time.Duration) *Controller {
+	if heartbeat <= 0 {
+		heartbeat = 25 * time.Second
+	}
+	return &Controller{
+		service:   service,
+		heartbeat: heartbeat,
+	}
+}
+
+func currentUser(ctx context.Context) (uint, error) {
+	userid := g.RequestFromCtx(ctx).GetCtxVar("userid").String()
+	uid, err := strconv.ParseUint(userid, 10, 64)
+	if err != nil {
+		return 0, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+	return uint(uid), nil
+}
+
+func (c *Controller) List(ctx context.Context, req *ListReq) (res *ListRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Notification.ListHandler")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	uid, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int64("user.id", int64(uid)))
+
+	notifications, err := c.service.List(ctx, uid, req.Cursor, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := c.service.Unread(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	data := g.Map{
+		"items":  notifications,
+		"unread": unread,
+	}
+	if len(notifications) == req.Limit {
+		data["next_cursor"] = notifications[len(notifications)-1].NotificationID
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    data,
+	})
+	return nil, nil
+}
+
+func (c *Controller) Unread(ctx context.Context, req *UnreadReq) (res *UnreadRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	uid, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := c.service.Unread(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    g.Map{"unread": unread},
+	})
+	return nil, nil
+}
+
+func (c *Controller) Read(ctx context.Context, req *ReadReq) (res *ReadRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	uid, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.service.MarkRead(ctx, uid, req.NotificationID); err != nil {
+		if errors.Is(err, ErrNotificationNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "消息不存在")
+		}
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "marked as read",
+	})
+	return nil, nil
+}
+
+func (c *Controller) ReadAll(ctx context.Context, req *ReadAllReq) (res *ReadAllRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	uid, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, err := c.service.MarkAllRead(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "marked all as read",
+		"data":    g.Map{"updated": n},
+	})
+	return nil, nil
+}
+
+// Stream 以 SSE 推送未读数：连接建立时推送一次，之后每次消息变化或有新广播时推送
+func (c *Controller) Stream(ctx context.Context, req *StreamReq) (res *StreamRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	uid, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Response.Header().Set("Content-Type", "text/event-stream")
+	r.Response.Header().Set("Cache-Control", "no-cache")
+	r.Response.Header().Set("Connection", "keep-alive")
+	r.Response.Header().Set("X-Accel-Buffering", "no")
+
+	sub := c.service.Subscribe(uid)
+	defer c.service.Unsubscribe(sub)
+
+	last := int64(-1)
+	push := func() {
+		unread, err := c.service.Unread(ctx, uid)
+		if err != nil || unread == last {
+			return
+		}
+		last = unread
+		r.Response.Writef("event: unread\ndata: {\"unread\":%d}\n\n", unread)
+		r.Response.Flush()
+	}
+	push()
+
+	heartbeat := time.NewTicker(c.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-sub.C:
+			push()
+		case <-heartbeat.C:
+			r.Response.Write(": ping\n\n")
+			r.Response.Flush()
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package notification
+
+import (
+	"context"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"usergrowth/internal/logs"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// 新广播到达后各连接在该时间窗口内随机刷新，避免所有在线用户同时展开广播写库
+const broadcastJitter = 5 * time.Second
+
+// Client 是一个 SSE 连接的订阅，C 中收到信号表示需要重新推送未读数
+type Client struct {
+	C       chan struct{}
+	userID  uint
+	pending atomic.Bool // 已安排延迟刷新，未触发前不再重复安排
+}
+
+// notify 非阻塞地发送信号，连接尚未处理的信号会被合并
+func (c *Client) notify() {
+	select {
+	case c.C <- struct{}{}:
+	default:
+	}
+}
+
+// hub 每个进程只持有一个 Redis 订阅，按用户把消息变化分发到本进程的 SSE 连接
+type hub struct {
+	rdb     goredis.UniversalClient
+	jitter  time.Duration
+	mu      sync.Mutex
+	clients map[uint]map[*Client]struct{}
+	logger  logs.Logger
+}
+
+func newHub(rdb goredis.UniversalClient, logger logs.Logger) *hub {
+	return &hub{
+		rdb:     rdb,
+		jitter:  broadcastJitter,
+		clients: make(map[uint]map[*Client]struct{}),
+		logger:  logger,
+	}
+}
+
+func (h *hub) join(userID uint) *Client {
+	c := &Client{C: make(chan struct{}, 1), userID: userID}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[userID] == nil {
+		h.clients[userID] = make(map[*Client]struct{})
+	}
+	h.clients[userID][c] = struct{}{}
+	return c
+}
+
+func (h *hub) leave(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients[c.userID], c)
+	if len(h.clients[c.userID]) == 0 {
+		delete(h.clients, c.userID)
+	}
+}
+
+// start 订阅用户消息与广播频道，ctx 取消后退出；断线由 go-redis 自动重连
+func (h *hub) start(ctx context.Context) {
+	sub := h.rdb.PSubscribe(ctx, userChannelPrefix+"*")
+	if err := sub.Subscribe(ctx, broadcastChannel); err != nil {
+		h.logger.Error(ctx, "notification subscribe failed:", err.Error())
+	}
+	go func() {
+		defer func() {
+			_ = sub.Close()
+		}()
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				h.route(msg.Channel)
+			}
+		}
+	}()
+}
+
+func (h *hub) route(channel string) {
+	if channel == broadcastChannel {
+		h.broadcast()
+		return
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(channel, userChannelPrefix), 10, 64)
+	if err != nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients[uint(id)] {
+		c.notify()
+	}
+}
+
+// broadcast 让每个连接在抖动窗口内的随机时刻刷新
+func (h *hub) broadcast() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, clients := range h.clients {
+		for c := range clients {
+			if !c.pending.CompareAndSwap(false, true) {
+				continue
+			}
+			var delay time.Duration
+			if h.jitter > 0 {
+				delay = rand.N(h.jitter)
+			}
+			time.AfterFunc(delay, func() {
+				c.pending.Store(false)
+				c.notify()
+			})
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package notification
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// 消息类型
+const (
+	KindSystem   = "system"
+	KindReward   = "reward"
+	KindSecurity = "security"
+	KindCampaign = "campaign"
+)
+
+type Notification struct {
+	NotificationID uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID         uint       `gorm:"not null;uniqueIndex:idx_user_broadcast,priority:1" json:"-"`
+	BroadcastID    *uint      `gorm:"uniqueIndex:idx_user_broadcast,priority:2" json:"broadcast_id,omitempty"` // 广播展开的副本，同一广播每人最多一条
+	Kind           string     `gorm:"type:varchar(32);not null" json:"kind"`
+	Title          string     `gorm:"type:varchar(255);not null" json:"title"`
+	Body           string     `gorm:"type:text" json:"body"`
+	Data           string     `gorm:"type:text" json:"data,omitempty"` // 客户端跳转等附加信息的 JSON
+	ReadAt         *time.Time `json:"read_at"`
+	ExpireAt       *time.Time `gorm:"index" json:"expire_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Broadcast 面向分群（SegmentID 为 0 表示全体用户），用户读取收件箱时才展开为个人消息
+type Broadcast struct {
+	BroadcastID uint       `gorm:"primaryKey;autoIncrement" json:"broadcast_id"`
+	SegmentID   uint       `gorm:"not null;default:0" json:"segment_id"`
+	Kind        string     `gorm:"type:varchar(32);not null" json:"kind"`
+	Title       string     `gorm:"type:varchar(255);not null" json:"title"`
+	Body        string     `gorm:"type:text" json:"body"`
+	Data        string     `gorm:"type:text" json:"data,omitempty"`
+	ExpireAt    *time.Time `gorm:"index" json:"expire_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Cursor 记录用户已展开到的广播 ID
+type Cursor struct {
+	UserID          uint `gorm:"primaryKey;autoIncrement:false"`
+	LastBroadcastID uint `gorm:"not null"`
+}
+
+func (Cursor) TableName() string {
+	return "notification_cursors"
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+type NotificationRepository interface {
+	Create(notifications []Notification) error
+	// List 按 ID 倒序返回 beforeID 之前未过期的消息，beforeID 为 0 时从最新开始
+	List(userID uint, beforeID uint, limit int, now time.Time) ([]Notification, error)
+	CountUnread(userID uint, now time.Time) (int64, error)
+	MarkRead(userID, notificationID uint, now time.Time) error
+	MarkAllRead(userID uint, now time.Time) (int64, error)
+	DeleteExpired(now time.Time) (int64, error)
+
+	CreateBroadcast(broadcast *Broadcast) error
+	// PendingBroadcasts 返回 afterID 之后未过期的广播，按 ID 正序
+	PendingBroadcasts(afterID uint, now time.Time, limit int) ([]Broadcast, error)
+	// LatestBroadcastID 返回最新的广播 ID，没有广播时返回 0
+	LatestBroadcastID() (uint, error)
+	FindCursor(userID uint) (uint, error)
+	SaveCursor(userID, lastBroadcastID uint) error
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	if err := db.AutoMigrate(&Notification{}, &Broadcast{}, &Cursor{}); err != nil {
+		panic("failed to migrate notification tables")
+	}
+	return &notificationRepository{db: db}
+}
+
+func notExpired(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("expire_at IS NULL OR expire_at > ?", now)
+}
+
+// Create 对同一广播的重复展开静默忽略
+func (repo *notificationRepository) Create(notifications []Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return repo.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&notifications).Error
+}
+
+func (repo *notificationRepository) List(userID uint, beforeID uint, limit int, now time.Time) ([]Notification, error) {
+	var notifications []Notification
+	query := repo.db.Where("user_id = ?", userID)
+	if beforeID > 0 {
+		query = query.Where("notification_id < ?", beforeID)
+	}
+	err := notExpired(query, now).Order("notification_id DESC").Limit(limit).Find(&notifications).Error
+	return notifications, err
+}
+
+func (repo *notificationRepository) CountUnread(userID uint, now time.Time) (int64, error) {
+	var count int64
+	query := repo.db.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	err := notExpired(query, now).Count(&count).Error
+	return count, err
+}
+
+func (repo *notificationRepository) MarkRead(userID, notificationID uint, now time.Time) error {
+	var notification Notification
+	if err := repo.db.Where("notification_id = ? AND user_id = ?", notificationID, userID).First(&notification).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotificationNotFound
+		}
+		return err
+	}
+	if notification.ReadAt != nil {
+		return nil
+	}
+	return repo.db.Model(&Notification{}).Where("notification_id = ?", notificationID).Update("read_at", now).Error
+}
+
+func (repo *notificationRepository) MarkAllRead(userID uint, now time.Time) (int64, error) {
+	result := repo.db.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Update("read_at", now)
+	return result.RowsAffected, result.Error
+}
+
+// DeleteExpired 只清理个人消息，过期广播保留以免游标回退后重复展开
+func (repo *notificationRepository) DeleteExpired(now time.Time) (int64, error) {
+	result := repo.db.Where("expire_at IS NOT NULL AND expire_at <= ?", now).Delete(&Notification{})
+	return result.RowsAffected, result.Error
+}
+
+func (repo *notificationRepository) CreateBroadcast(broadcast *Broadcast) error {
+	return repo.db.Create(broadcast).Error
+}
+
+func (repo *notificationRepository) PendingBroadcasts(afterID uint, now time.Time, limit int) ([]Broadcast, error) {
+	var broadcasts []Broadcast
+	err := notExpired(repo.db.Where("broadcast_id > ?", afterID), now).
+		Order("broadcast_id").Limit(limit).Find(&broadcasts).Error
+	return broadcasts, err
+}
+
+func (repo *notificationRepository) LatestBroadcastID() (uint, error) {
+	var id uint
+	err := repo.db.Model(&Broadcast{}).Select("COALESCE(MAX(broadcast_id), 0)").Scan(&id).Error
+	return id, err
+}
+
+func (repo *notificationRepository) FindCursor(userID uint) (uint, error) {
+	var cursor Cursor
+	if err := repo.db.Where("user_id = ?", userID).First(&cursor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return cursor.LastBroadcastID, nil
+}
+
+func (repo *notificationRepository) SaveCursor(userID, lastBroadcastID uint) error {
+	return repo.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]any{"last_broadcast_id": gorm.Expr("GREATEST(last_broadcast_id, VALUES(last_broadcast_id))")}),
+	}).Create(&Cursor{UserID: userID, LastBroadcastID: lastBroadcastID}).Error
+}
@@ -0,0 +1,359 @@
+package notification
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/points"
+	"usergrowth/internal/user"
+
+	"github.com/gogf/gf/v2/net/gtrace"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	latestBroadcastKey = "notify:broadcast:latest"
+	broadcastChannel   = "notify:broadcast"
+	userChannelPrefix  = "notify:user:"
+	// 单次展开读取的广播条数
+	fanoutPageSize = 100
+	// 记录登录设备的保留时间，超过后同一设备再次登录会重新提醒
+	deviceTTL = 180 * 24 * time.Hour
+)
+
+func unreadKey(userID uint) string {
+	return "notify:unread:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+func userChannel(userID uint) string {
+	return userChannelPrefix + strconv.FormatUint(uint64(userID), 10)
+}
+
+func devicesKey(userID uint) string {
+	return "notify:devices:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// SegmentChecker 判断用户是否属于广播的目标分群，由 segment 模块提供
+type SegmentChecker interface {
+	IsMember(ctx context.Context, segmentID, userID uint) (bool, error)
+}
+
+// Message 是发给单个用户的消息，TTL 为 0 时使用默认有效期
+type Message struct {
+	Kind  string
+	Title string
+	Body  string
+	Data  map[string]any
+	TTL   time.Duration
+}
+
+type Service struct {
+	repo       NotificationRepository
+	rdb        goredis.UniversalClient
+	segments   SegmentChecker
+	defaultTTL time.Duration
+	unreadTTL  time.Duration
+	hub        *hub
+	logger     logs.Logger
+}
+
+func NewService(repo NotificationRepository, rdb goredis.UniversalClient, segments SegmentChecker, defaultTTL, unreadTTL time.Duration, logger logs.Logger) *Service {
+	return &Service{
+		repo:       repo,
+		rdb:        rdb,
+		segments:   segments,
+		defaultTTL: defaultTTL,
+		unreadTTL:  unreadTTL,
+		hub:        newHub(rdb, logger),
+		logger:     logger,
+	}
+}
+
+// Start 建立本进程唯一的 Redis 订阅，需在接受 SSE 连接前调用
+func (s *Service) Start(ctx context.Context) {
+	s.hub.start(ctx)
+}
+
+func encodeData(data map[string]any) (string, error) {
+	if len(data) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(data)
+	return string(b), err
+}
+
+func (s *Service) expireAt(ttl time.Duration) *time.Time {
+	if ttl <= 0 {
+		ttl = s.defaultTTL
+	}
+	if ttl <= 0 {
+		return nil
+	}
+	at := time.Now().Add(ttl)
+	return &at
+}
+
+// changed 使未读数缓存失效并通知该用户的 SSE 连接
+func (s *Service) changed(ctx context.Context, userID uint) {
+	pipe := s.rdb.Pipeline()
+	pipe.Del(ctx, unreadKey(userID))
+	pipe.Publish(ctx, userChannel(userID), "changed")
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.Error(ctx, "notification cache invalidate failed:", userID, err.Error())
+	}
+}
+
+// Send 给单个用户发送消息
+func (s *Service) Send(ctx context.Context, userID uint, msg Message) error {
+	ctx, span := gtrace.NewSpan(ctx, "Notification.Send")
+	defer span.End()
+
+	data, err := encodeData(msg.Data)
+	if err != nil {
+		return err
+	}
+	kind := msg.Kind
+	if kind == "" {
+		kind = KindSystem
+	}
+	err = s.repo.Create([]Notification{{
+		UserID:   userID,
+		Kind:     kind,
+		Title:    msg.Title,
+		Body:     msg.Body,
+		Data:     data,
+		ExpireAt: s.expireAt(msg.TTL),
+	}})
+	if err != nil {
+		return err
+	}
+	s.changed(ctx, userID)
+	return nil
+}
+
+// Broadcast 创建分群广播，不立即写入个人收件箱，用户下次读取时展开
+func (s *Service) Broadcast(ctx context.Context, broadcast *Broadcast) error {
+	ctx, span := gtrace.NewSpan(ctx, "Notification.Broadcast")
+	defer span.End()
+
+	if broadcast.Kind == "" {
+		broadcast.Kind = KindSystem
+	}
+	if err := s.repo.CreateBroadcast(broadcast); err != nil {
+		return err
+	}
+	pipe := s.rdb.Pipeline()
+	pipe.Set(ctx, latestBroadcastKey, broadcast.BroadcastID, 0)
+	pipe.Publish(ctx, broadcastChannel, broadcast.BroadcastID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// latestBroadcastID 读取缓存的最新广播 ID，缓存丢失（如 Redis 重启）时回源数据库并回填
+func (s *Service) latestBroadcastID(ctx context.Context) (uint, error) {
+	id, err := s.rdb.Get(ctx, latestBroadcastKey).Uint64()
+	if err != goredis.Nil {
+		return uint(id), err
+	}
+	latest, err := s.repo.LatestBroadcastID()
+	if err != nil {
+		return 0, err
+	}
+	// SetNX 不覆盖期间新广播写入的更大值
+	_ = s.rdb.SetNX(ctx, latestBroadcastKey, latest, 0).Err()
+	return latest, nil
+}
+
+// OnRegister 把新用户的游标设为当前最新广播，注册前发出的广播不再展开给新用户
+func (s *Service) OnRegister(ctx context.Context, reg *user.Registration) {
+	latest, err := s.repo.LatestBroadcastID()
+	if err == nil && latest > 0 {
+		err = s.repo.SaveCursor(reg.UserID, latest)
+	}
+	if err != nil {
+		s.logger.Error(ctx, "notification cursor init failed:", reg.UserID, err.Error())
+	}
+}
+
+// fanout 把用户游标之后的广播展开为个人消息，返回新展开的条数
+func (s *Service) fanout(ctx context.Context, userID, latest uint) (int, error) {
+	cursor, err := s.repo.FindCursor(userID)
+	if err != nil || cursor >= latest {
+		return 0, err
+	}
+
+	created := 0
+	now := time.Now()
+	for {
+		broadcasts, err := s.repo.PendingBroadcasts(cursor, now, fanoutPageSize)
+		if err != nil {
+			return created, err
+		}
+		if len(broadcasts) == 0 {
+			break
+		}
+		notifications := make([]Notification, 0, len(broadcasts))
+		for i := range broadcasts {
+			b := &broadcasts[i]
+			if b.SegmentID > 0 {
+				ok, err := s.segments.IsMember(ctx, b.SegmentID, userID)
+				if err != nil {
+					return created, err
+				}
+				if !ok {
+					continue
+				}
+			}
+			notifications = append(notifications, Notification{
+				UserID:      userID,
+				BroadcastID: &b.BroadcastID,
+				Kind:        b.Kind,
+				Title:       b.Title,
+				Body:        b.Body,
+				Data:        b.Data,
+				ExpireAt:    b.ExpireAt,
+			})
+		}
+		if err = s.repo.Create(notifications); err != nil {
+			return created, err
+		}
+		created += len(notifications)
+		cursor = broadcasts[len(broadcasts)-1].BroadcastID
+		if err = s.repo.SaveCursor(userID, cursor); err != nil {
+			return created, err
+		}
+		if len(broadcasts) < fanoutPageSize {
+			break
+		}
+	}
+	return created, nil
+}
+
+// Unread 返回未读数。缓存值带上缓存时最新的广播 ID，有新广播时重新展开并计数
+func (s *Service) Unread(ctx context.Context, userID uint) (int64, error) {
+	latest, err := s.latestBroadcastID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if cached, err := s.rdb.Get(ctx, unreadKey(userID)).Result(); err == nil {
+		if id, count, ok := strings.Cut(cached, ":"); ok && id == strconv.FormatUint(uint64(latest), 10) {
+			if n, err := strconv.ParseInt(count, 10, 64); err == nil {
+				return n, nil
+			}
+		}
+	}
+
+	if _, err = s.fanout(ctx, userID, latest); err != nil {
+		return 0, err
+	}
+	count, err := s.repo.CountUnread(userID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	_ = s.rdb.Set(ctx, unreadKey(userID), fmt.Sprintf("%d:%d", latest, count), s.unreadTTL).Err()
+	return count, nil
+}
+
+// List 先展开待处理的广播再分页读取
+func (s *Service) List(ctx context.Context, userID, beforeID uint, limit int) ([]Notification, error) {
+	ctx, span := gtrace.NewSpan(ctx, "Notification.List")
+	defer span.End()
+
+	latest, err := s.latestBroadcastID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.fanout(ctx, userID, latest)
+	if err != nil {
+		return nil, err
+	}
+	if created > 0 {
+		s.changed(ctx, userID)
+	}
+	return s.repo.List(userID, beforeID, limit, time.Now())
+}
+
+func (s *Service) MarkRead(ctx context.Context, userID, notificationID uint) error {
+	if err := s.repo.MarkRead(userID, notificationID, time.Now()); err != nil {
+		return err
+	}
+	s.changed(ctx, userID)
+	return nil
+}
+
+func (s *Service) MarkAllRead(ctx context.Context, userID uint) (int64, error) {
+	n, err := s.repo.MarkAllRead(userID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	s.changed(ctx, userID)
+	return n, nil
+}
+
+// Subscribe 订阅该用户的消息变化以及全局广播，连接结束时需调用 Unsubscribe
+func (s *Service) Subscribe(userID uint) *Client {
+	return s.hub.join(userID)
+}
+
+func (s *Service) Unsubscribe(c *Client) {
+	s.hub.leave(c)
+}
+
+// Cleanup 作为定时任务执行，删除已过期的个人消息
+func (s *Service) Cleanup(ctx context.Context) {
+	n, err := s.repo.DeleteExpired(time.Now())
+	if err != nil {
+		s.logger.Error(ctx, "notification cleanup failed:", err.Error())
+		return
+	}
+	s.logger.Info(ctx, "notification cleanup finished, deleted", n)
+}
+
+// OnPointsChanged 注册为积分变动监听器，获得积分时发送奖励通知
+func (s *Service) OnPointsChanged(ctx context.Context, account *points.Account, entry *points.Entry) {
+	if entry.Delta <= 0 {
+		return
+	}
+	err := s.Send(ctx, account.UserID, Message{
+		Kind:  KindReward,
+		Title: "积分到账",
+		Body:  fmt.Sprintf("您获得了 %d 积分，当前余额 %d", entry.Delta, account.Balance),
+		Data:  map[string]any{"reason": entry.Reason, "delta": entry.Delta},
+	})
+	if err != nil {
+		s.logger.Error(ctx, "reward notification failed:", account.UserID, err.Error())
+	}
+}
+
+// OnLogin 在登录成功后调用，首次出现的设备（按 User-Agent 区分）发送安全提醒，首次登录不提醒
+func (s *Service) OnLogin(ctx context.Context, userID uint, ip, userAgent string) {
+	sum := sha1.Sum([]byte(userAgent))
+	device := hex.EncodeToString(sum[:8])
+
+	pipe := s.rdb.TxPipeline()
+	added := pipe.SAdd(ctx, devicesKey(userID), device)
+	total := pipe.SCard(ctx, devicesKey(userID))
+	pipe.Expire(ctx, devicesKey(userID), deviceTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.Error(ctx, "login device check failed:", userID, err.Error())
+		return
+	}
+	if added.Val() == 0 || total.Val() <= 1 {
+		return
+	}
+	err := s.Send(ctx, userID, Message{
+		Kind:  KindSecurity,
+		Title: "新设备登录提醒",
+		Body:  fmt.Sprintf("您的账号于 %s 在新设备登录（IP：%s），如非本人操作请及时修改密码", time.Now().Format(time.DateTime), ip),
+		Data:  map[string]any{"ip": ip, "user_agent": userAgent},
+	})
+	if err != nil {
+		s.logger.Error(ctx, "login notification failed:", userID, err.Error())
+	}
+}
@@ -0,0 +1,186 @@
+package notification
+
+import (
+	"context"
+	"testing"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/user"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRepo struct {
+	NotificationRepository
+	broadcasts    []Broadcast
+	notifications []Notification
+	cursor        uint
+}
+
+func (f *fakeRepo) Create(notifications []Notification) error {
+	for _, n := range notifications {
+		duplicate := false
+		for _, existing := range f.notifications {
+			if n.BroadcastID != nil && existing.BroadcastID != nil && *n.BroadcastID == *existing.BroadcastID {
+				duplicate = true
+			}
+		}
+		if !duplicate {
+			f.notifications = append(f.notifications, n)
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepo) PendingBroadcasts(afterID uint, now time.Time, limit int) ([]Broadcast, error) {
+	var pending []Broadcast
+	for _, b := range f.broadcasts {
+		if b.BroadcastID > afterID && (b.ExpireAt == nil || b.ExpireAt.After(now)) && len(pending) < limit {
+			pending = append(pending, b)
+		}
+	}
+	return pending, nil
+}
+
+func (f *fakeRepo) LatestBroadcastID() (uint, error) {
+	var latest uint
+	for _, b := range f.broadcasts {
+		latest = max(latest, b.BroadcastID)
+	}
+	return latest, nil
+}
+
+func (f *fakeRepo) CountUnread(userID uint, now time.Time) (int64, error) {
+	return int64(len(f.notifications)), nil
+}
+
+func (f *fakeRepo) FindCursor(userID uint) (uint, error) {
+	return f.cursor, nil
+}
+
+func (f *fakeRepo) SaveCursor(userID, lastBroadcastID uint) error {
+	f.cursor = max(f.cursor, lastBroadcastID)
+	return nil
+}
+
+type fakeSegments map[uint]bool
+
+func (f fakeSegments) IsMember(ctx context.Context, segmentID, userID uint) (bool, error) {
+	return f[segmentID], nil
+}
+
+func TestFanout(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	repo := &fakeRepo{broadcasts: []Broadcast{
+		{BroadcastID: 1, Title: "all"},
+		{BroadcastID: 2, Title: "member", SegmentID: 7},
+		{BroadcastID: 3, Title: "other segment", SegmentID: 8},
+		{BroadcastID: 4, Title: "expired", ExpireAt: &expired},
+	}}
+	s := &Service{repo: repo, segments: fakeSegments{7: true}}
+
+	created, err := s.fanout(context.Background(), 42, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, created)
+	assert.Equal(t, uint(3), repo.cursor)
+	assert.Equal(t, "all", repo.notifications[0].Title)
+	assert.Equal(t, "member", repo.notifications[1].Title)
+
+	// 游标已追上最新广播时不再查询
+	created, err = s.fanout(context.Background(), 42, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, created)
+
+	repo.broadcasts = append(repo.broadcasts, Broadcast{BroadcastID: 5, Title: "new"})
+	created, err = s.fanout(context.Background(), 42, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created)
+	assert.Len(t, repo.notifications, 3)
+}
+
+func TestRegisterSkipsEarlierBroadcasts(t *testing.T) {
+	repo := &fakeRepo{broadcasts: []Broadcast{
+		{BroadcastID: 1, Title: "old"},
+		{BroadcastID: 2, Title: "old"},
+	}}
+	s := &Service{repo: repo, segments: fakeSegments{}}
+
+	s.OnRegister(context.Background(), &user.Registration{UserID: 42})
+	assert.Equal(t, uint(2), repo.cursor)
+	created, err := s.fanout(context.Background(), 42, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, created)
+
+	// 注册后的广播照常展开
+	repo.broadcasts = append(repo.broadcasts, Broadcast{BroadcastID: 3, Title: "new"})
+	created, err = s.fanout(context.Background(), 42, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created)
+	if assert.Len(t, repo.notifications, 1) {
+		assert.Equal(t, "new", repo.notifications[0].Title)
+	}
+}
+
+func TestLatestBroadcastFallsBackToRepo(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	repo := &fakeRepo{broadcasts: []Broadcast{{BroadcastID: 1}, {BroadcastID: 2}}}
+	s := NewService(repo, rdb, fakeSegments{}, 0, time.Minute, logs.Nop())
+	ctx := context.Background()
+
+	// 缓存丢失时回源数据库并回填，未读数仍包含待展开的广播
+	unread, err := s.Unread(ctx, 42)
+	assert.NoError(t, err)
+	assert.Len(t, repo.notifications, 2)
+	assert.Equal(t, uint(2), repo.cursor)
+	assert.Equal(t, int64(2), unread)
+	v, _ := mr.Get(latestBroadcastKey)
+	assert.Equal(t, "2", v)
+}
+
+func TestHubRoutesMessages(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	s := NewService(&fakeRepo{}, rdb, fakeSegments{}, 0, time.Minute, logs.Nop())
+	s.hub.jitter = 50 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+
+	alice, bob := s.Subscribe(1), s.Subscribe(2)
+	defer s.Unsubscribe(alice)
+	defer s.Unsubscribe(bob)
+	// 等待订阅生效
+	assert.Eventually(t, func() bool { return mr.PubSubNumPat() > 0 }, time.Second, 10*time.Millisecond)
+
+	// 个人消息只通知该用户的连接
+	s.changed(ctx, 1)
+	select {
+	case <-alice.C:
+	case <-time.After(time.Second):
+		t.Fatal("alice not notified")
+	}
+	select {
+	case <-bob.C:
+		t.Fatal("bob notified")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 广播在抖动窗口内通知所有连接，重复广播合并为一次
+	mr.Publish(broadcastChannel, "3")
+	mr.Publish(broadcastChannel, "4")
+	for _, c := range []*Client{alice, bob} {
+		select {
+		case <-c.C:
+		case <-time.After(time.Second):
+			t.Fatal("broadcast not delivered")
+		}
+	}
+	select {
+	case <-alice.C:
+		t.Fatal("broadcast not coalesced")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,73 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrEventNotFound = errors.New("outbox event not found")
+
+// Event 是待投递的领域事件，与业务状态变更写在同一个事务里，保证状态变更与事件要么都存在要么都不存在
+type Event struct {
+	EventID      uint64     `gorm:"primaryKey;autoIncrement" json:"event_id"`
+	Type         string     `gorm:"type:varchar(64);not null;index" json:"type"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	Payload      string     `gorm:"type:text" json:"payload"` // JSON 对象
+	CreatedAt    time.Time  `json:"created_at"`
+	DispatchedAt *time.Time `gorm:"index" json:"dispatched_at"` // 已展开为各订阅方的投递任务
+}
+
+func (Event) TableName() string {
+	return "outbox_events"
+}
+
+// Append 在调用方的事务 tx 中写入一条事件
+func Append(tx *gorm.DB, eventType string, userID uint, payload map[string]any) error {
+	props := "{}"
+	if len(payload) > 0 {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		props = string(b)
+	}
+	return tx.Create(&Event{Type: eventType, UserID: userID, Payload: props}).Error
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+type OutboxRepository interface {
+	// Pending 按写入顺序返回尚未展开的事件
+	Pending(limit int) ([]Event, error)
+	Find(eventID uint64) (*Event, error)
+}
+
+// NewOutboxRepository 需在任何写入事件的仓库使用前调用，负责建表
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	if err := db.AutoMigrate(&Event{}); err != nil {
+		panic("failed to migrate outbox table")
+	}
+	return &outboxRepository{db: db}
+}
+
+func (repo *outboxRepository) Pending(limit int) ([]Event, error) {
+	var events []Event
+	err := repo.db.Where("dispatched_at IS NULL").Order("event_id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+func (repo *outboxRepository) Find(eventID uint64) (*Event, error) {
+	var e Event
+	if err := repo.db.First(&e, eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEventNotFound
+		}
+		return nil, err
+	}
+	return &e, nil
+}
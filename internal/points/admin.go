@@ -0,0 +1,61 @@
+package points
+
+import (
+	"context"
+	"errors"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// 管理员调整积分使用的流水原因
+const ReasonAdminAdjust = "admin_adjust"
+
+type AdjustReq struct {
+	g.Meta `path:"/api/admin/points/adjust" method:"post"`
+	UserID uint   `json:"user_id" v:"required#用户ID不能为空"`
+	Delta  int64  `json:"delta" v:"required|not-in:0#调整值不能为空|调整值不能为0"`
+	RefID  string `json:"ref_id" v:"required|max-length:128#调整单号不能为空|调整单号过长"`
+}
+
+type AdjustRes struct {
+}
+
+type Admin struct {
+	service    *Service
+	userLogger logs.Logger
+}
+
+func NewAdmin(service *Service, logger logs.Logger) *Admin {
+	return &Admin{
+		service:    service,
+		userLogger: logger,
+	}
+}
+
+// Adjust 供运营手工补发或扣回积分，同一 ref_id 只生效一次
+func (params *Admin) Adjust(ctx context.Context, req *AdjustReq) (res *AdjustRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	account, err := params.service.Award(ctx, req.UserID, req.Delta, ReasonAdminAdjust, req.RefID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrDuplicateEntry):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "该调整单号已处理")
+		case errors.Is(err, ErrInsufficientBalance):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "积分余额不足")
+		}
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Points adjusted:", req.UserID, req.Delta, "ref:", req.RefID,
+		"operator:", r.GetCtxVar("userid").String())
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "points adjusted",
+		"data":    account,
+	})
+	return nil, nil
+}
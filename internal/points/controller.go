@@ -0,0 +1,64 @@
+package points
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type PointsReq struct {
+	g.Meta `path:"/api/points" method:"get"`
+	Limit  int `p:"limit" d:"20" v:"between:1,100#条数应在1到100之间"`
+}
+
+type PointsRes struct {
+}
+
+type Controller struct {
+	service *Service
+	repo    PointsRepository
+}
+
+func NewController(service *Service, repo PointsRepository) *Controller {
+	return &Controller{
+		service: service,
+		repo:    repo,
+	}
+}
+
+func (c *Controller) Points(ctx context.Context, req *PointsReq) (res *PointsRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Points.Points")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	userid := r.GetCtxVar("userid").String()
+	uid, err := strconv.ParseUint(userid, 10, 64)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+	span.SetAttributes(attribute.String("user.id", userid))
+
+	account, err := c.service.Account(uint(uid))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := c.repo.ListEntries(uint(uid), req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data": g.Map{
+			"account": account,
+			"entries": entries,
+		},
+	})
+	return nil, nil
+}
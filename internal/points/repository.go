@@ -0,0 +1,116 @@
+package points
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrDuplicateEntry      = errors.New("points entry already recorded")
+	ErrInsufficientBalance = errors.New("insufficient points balance")
+)
+
+type Account struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Balance   int64     `gorm:"not null;default:0" json:"balance"`
+	Lifetime  int64     `gorm:"not null;default:0" json:"lifetime"` // 累计获得，扣减不影响
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Entry 是积分流水，Reason + RefID 唯一，重复发放同一笔积分会被拒绝
+type Entry struct {
+	EntryID   uint      `gorm:"primaryKey;autoIncrement" json:"entry_id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Delta     int64     `gorm:"not null" json:"delta"`
+	Balance   int64     `gorm:"not null" json:"balance"` // 变动后的余额
+	Reason    string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_reason_ref" json:"reason"`
+	RefID     string    `gorm:"type:varchar(128);not null;uniqueIndex:idx_reason_ref" json:"ref_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type pointsRepository struct {
+	db *gorm.DB
+}
+
+type PointsRepository interface {
+	Apply(entry *Entry) (*Account, error)
+	FindAccount(userID uint) (*Account, error)
+	ListEntries(userID uint, limit int) ([]Entry, error)
+}
+
+func NewPointsRepository(db *gorm.DB) PointsRepository {
+	if err := db.AutoMigrate(&Account{}, &Entry{}); err != nil {
+		panic("failed to migrate points tables")
+	}
+	return &pointsRepository{db: db}
+}
+
+// Apply 在事务内锁定账户行、写流水并更新余额，扣减后余额不能为负
+func (repo *pointsRepository) Apply(entry *Entry) (*Account, error) {
+	var account Account
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		account = Account{UserID: entry.UserID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&account).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", entry.UserID).First(&account).Error; err != nil {
+			return err
+		}
+		if err := settle(&account, entry); err != nil {
+			return err
+		}
+		if err := tx.Create(entry).Error; err != nil {
+			return entryError(err)
+		}
+		return tx.Model(&Account{}).Where("user_id = ?", entry.UserID).Updates(map[string]any{
+			"balance":  account.Balance,
+			"lifetime": account.Lifetime,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// settle 把流水记到账户上，扣减后余额不能为负
+func settle(account *Account, entry *Entry) error {
+	if account.Balance+entry.Delta < 0 {
+		return ErrInsufficientBalance
+	}
+	account.Balance += entry.Delta
+	if entry.Delta > 0 {
+		account.Lifetime += entry.Delta
+	}
+	entry.Balance = account.Balance
+	return nil
+}
+
+// entryError 把 reason + ref_id 唯一索引冲突转换为 ErrDuplicateEntry
+func entryError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 { // Error 1062: Duplicate entry
+		return ErrDuplicateEntry
+	}
+	return err
+}
+
+// FindAccount 对没有积分记录的用户返回零值账户
+func (repo *pointsRepository) FindAccount(userID uint) (*Account, error) {
+	account := Account{UserID: userID}
+	if err := repo.db.Where("user_id = ?", userID).First(&account).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (repo *pointsRepository) ListEntries(userID uint, limit int) ([]Entry, error) {
+	var entries []Entry
+	err := repo.db.Where("user_id = ?", userID).Order("entry_id DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
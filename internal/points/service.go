@@ -0,0 +1,59 @@
+package points
+
+import (
+	"context"
+	"usergrowth/internal/event"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+// NameChanged 是积分变动时写入的服务端事件
+const NameChanged = "points_changed"
+
+// Listener 在积分变动成功后被同步调用，如会员等级重新评估、到账通知
+type Listener func(ctx context.Context, account *Account, entry *Entry)
+
+type Service struct {
+	repo      PointsRepository
+	events    event.EventRepository
+	listeners []Listener
+	logger    logs.Logger
+}
+
+func NewService(repo PointsRepository, events event.EventRepository, logger logs.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		events: events,
+		logger: logger,
+	}
+}
+
+// OnChange 需在启动阶段注册，运行期间不可再调用
+func (s *Service) OnChange(l Listener) {
+	s.listeners = append(s.listeners, l)
+}
+
+// Award 发放或扣减积分，reason + refID 用于幂等，重复调用返回 ErrDuplicateEntry
+func (s *Service) Award(ctx context.Context, userID uint, delta int64, reason, refID string) (*Account, error) {
+	ctx, span := gtrace.NewSpan(ctx, "Points.Award")
+	defer span.End()
+
+	entry := &Entry{UserID: userID, Delta: delta, Reason: reason, RefID: refID}
+	account, err := s.repo.Apply(entry)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.events.Record(ctx, userID, NameChanged, g.Map{"delta": delta, "reason": reason, "balance": account.Balance}); err != nil {
+		s.logger.Error(ctx, "points event record failed:", userID, err.Error())
+	}
+	for _, l := range s.listeners {
+		l(ctx, account, entry)
+	}
+	return account, nil
+}
+
+func (s *Service) Account(userID uint) (*Account, error) {
+	return s.repo.FindAccount(userID)
+}
@@ -0,0 +1,102 @@
+package points
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"usergrowth/internal/event"
+	"usergrowth/internal/logs"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRepo struct {
+	PointsRepository
+	account Account
+	err     error
+}
+
+func (f *fakeRepo) Apply(entry *Entry) (*Account, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if err := settle(&f.account, entry); err != nil {
+		return nil, err
+	}
+	account := f.account
+	return &account, nil
+}
+
+type fakeEvents struct {
+	event.EventRepository
+	recorded []map[string]any
+}
+
+func (f *fakeEvents) Record(ctx context.Context, userID uint, name string, properties map[string]any) error {
+	f.recorded = append(f.recorded, properties)
+	return nil
+}
+
+func TestSettle(t *testing.T) {
+	account := &Account{UserID: 1, Balance: 50, Lifetime: 80}
+
+	entry := &Entry{UserID: 1, Delta: 30}
+	assert.NoError(t, settle(account, entry))
+	assert.Equal(t, int64(80), account.Balance)
+	assert.Equal(t, int64(110), account.Lifetime)
+	assert.Equal(t, int64(80), entry.Balance)
+
+	// 扣减不影响累计获得
+	entry = &Entry{UserID: 1, Delta: -80}
+	assert.NoError(t, settle(account, entry))
+	assert.Zero(t, account.Balance)
+	assert.Equal(t, int64(110), account.Lifetime)
+
+	// 余额不足时账户与流水不变
+	entry = &Entry{UserID: 1, Delta: -1}
+	assert.ErrorIs(t, settle(account, entry), ErrInsufficientBalance)
+	assert.Zero(t, account.Balance)
+	assert.Zero(t, entry.Balance)
+}
+
+func TestEntryError(t *testing.T) {
+	dup := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'checkin-42' for key 'idx_reason_ref'"}
+	assert.ErrorIs(t, entryError(fmt.Errorf("insert: %w", dup)), ErrDuplicateEntry)
+
+	other := &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	assert.Equal(t, error(other), entryError(other))
+}
+
+func TestAwardNotifiesListeners(t *testing.T) {
+	repo := &fakeRepo{account: Account{UserID: 1}}
+	events := &fakeEvents{}
+	s := NewService(repo, events, logs.Nop())
+	var got []int64
+	s.OnChange(func(ctx context.Context, account *Account, entry *Entry) {
+		got = append(got, account.Balance)
+	})
+	s.OnChange(func(ctx context.Context, account *Account, entry *Entry) {
+		got = append(got, entry.Delta)
+	})
+	ctx := context.Background()
+
+	account, err := s.Award(ctx, 1, 100, "checkin", "1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(100), account.Balance)
+	assert.Equal(t, []int64{100, 100}, got)
+	if assert.Len(t, events.recorded, 1) {
+		assert.Equal(t, int64(100), events.recorded[0]["balance"])
+	}
+
+	// 失败（余额不足、重复发放）时不写事件、不通知监听器
+	_, err = s.Award(ctx, 1, -200, "redeem", "1")
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	repo.err = ErrDuplicateEntry
+	_, err = s.Award(ctx, 1, 100, "checkin", "1")
+	assert.ErrorIs(t, err, ErrDuplicateEntry)
+	assert.Len(t, got, 2)
+	assert.Len(t, events.recorded, 1)
+}
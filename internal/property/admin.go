@@ -0,0 +1,103 @@
+package property
+
+import (
+	"context"
+	"errors"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/user"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+type AdminGetPropertiesReq struct {
+	g.Meta `path:"/api/admin/users/{id}/properties" method:"get"`
+	UserID uint `p:"id" v:"required"`
+}
+
+type AdminGetPropertiesRes struct {
+}
+
+type AdminUpdatePropertiesReq struct {
+	g.Meta `path:"/api/admin/users/{id}/properties" method:"post"`
+	UserID uint `p:"id" v:"required"`
+	Ops    []Op `json:"ops" v:"required#修改内容不能为空"`
+}
+
+type AdminUpdatePropertiesRes struct {
+}
+
+type ListDefinitionsReq struct {
+	g.Meta `path:"/api/admin/user-properties" method:"get"`
+}
+
+type ListDefinitionsRes struct {
+}
+
+type Admin struct {
+	service    *Service
+	users      user.UserRepository
+	userLogger logs.Logger
+}
+
+func NewAdmin(service *Service, users user.UserRepository, logger logs.Logger) *Admin {
+	return &Admin{
+		service:    service,
+		users:      users,
+		userLogger: logger,
+	}
+}
+
+// Get 返回用户的全部属性，包括客户端不可见的
+func (params *Admin) Get(ctx context.Context, req *AdminGetPropertiesReq) (res *AdminGetPropertiesRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	props, err := params.service.Get(ctx, req.UserID, false)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    props,
+	})
+	return nil, nil
+}
+
+// Update 可以修改任意已定义的属性
+func (params *Admin) Update(ctx context.Context, req *AdminUpdatePropertiesReq) (res *AdminUpdatePropertiesRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	if _, err = params.users.FindUserByID(req.UserID); err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "用户不存在")
+		}
+		return nil, err
+	}
+	props, err := params.service.Apply(ctx, req.UserID, req.Ops, false)
+	if err != nil {
+		return nil, validationError(err)
+	}
+
+	params.userLogger.Info(ctx, "User properties updated:", req.UserID, "ops:", len(req.Ops),
+		"operator:", r.GetCtxVar("userid").String())
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "properties updated",
+		"data":    props,
+	})
+	return nil, nil
+}
+
+// ListDefinitions 返回当前生效的属性定义
+func (params *Admin) ListDefinitions(ctx context.Context, req *ListDefinitionsReq) (res *ListDefinitionsRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    params.service.Definitions().Properties,
+	})
+	return nil, nil
+}
@@ -0,0 +1,92 @@
+package property
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type GetPropertiesReq struct {
+	g.Meta `path:"/api/user/properties" method:"get"`
+}
+
+type GetPropertiesRes struct {
+}
+
+type UpdatePropertiesReq struct {
+	g.Meta `path:"/api/user/properties" method:"post"`
+	Ops    []Op `json:"ops" v:"required#修改内容不能为空"`
+}
+
+type UpdatePropertiesRes struct {
+}
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// Get 返回当前用户客户端可读的属性
+func (c *Controller) Get(ctx context.Context, req *GetPropertiesReq) (res *GetPropertiesRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	uid, err := strconv.ParseUint(r.GetCtxVar("userid").String(), 10, 64)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+	props, err := c.service.Get(ctx, uint(uid), true)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    props,
+	})
+	return nil, nil
+}
+
+// Update 只能修改定义中 access 为 write 的属性
+func (c *Controller) Update(ctx context.Context, req *UpdatePropertiesReq) (res *UpdatePropertiesRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Property.Update")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	userid := r.GetCtxVar("userid").String()
+	uid, err := strconv.ParseUint(userid, 10, 64)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+	span.SetAttributes(attribute.String("user.id", userid))
+
+	props, err := c.service.Apply(ctx, uint(uid), req.Ops, true)
+	if err != nil {
+		return nil, validationError(err)
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "properties updated",
+		"data":    props,
+	})
+	return nil, nil
+}
+
+// validationError 把定义、类型与大小校验失败转换为参数错误
+func validationError(err error) error {
+	for _, target := range []error{ErrUnknownProperty, ErrNotWritable, ErrTypeMismatch, ErrTooLarge, ErrInvalidOp} {
+		if errors.Is(err, target) {
+			return gerror.NewCode(gcode.CodeValidationFailed, err.Error())
+		}
+	}
+	return err
+}
@@ -0,0 +1,328 @@
+package property
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidDefinitions = errors.New("invalid property definitions")
+	ErrUnknownProperty    = errors.New("unknown property")
+	ErrNotWritable        = errors.New("property not writable by client")
+	ErrTypeMismatch       = errors.New("property type mismatch")
+	ErrTooLarge           = errors.New("property value too large")
+	ErrInvalidOp          = errors.New("invalid property operation")
+)
+
+// 属性类型
+const (
+	TypeString = "string"
+	TypeNumber = "number"
+	TypeBool   = "bool"
+	TypeDate   = "date"
+	TypeList   = "list" // 字符串列表
+)
+
+// 客户端访问权限，后端始终可以读写
+const (
+	AccessNone  = "none"
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+// 操作类型
+const (
+	OpSet       = "set"
+	OpSetOnce   = "set_once" // 已有值时忽略
+	OpIncrement = "increment"
+	OpAppend    = "append" // value 为单个字符串或字符串列表
+	OpUnset     = "unset"
+)
+
+// 字符串存放在 varchar(255) 上并建索引，列表整体序列化后存入 text
+const (
+	maxColumnLength = 255
+	maxListBytes    = 65535
+)
+
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// Definition 声明一个属性，未声明的 key 不能写入；MaxLength、MaxItems 不超过全局上限
+type Definition struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	Access      string `json:"access"`
+	MaxLength   int    `json:"maxLength"` // 字符串与列表元素的最大字符数
+	MaxItems    int    `json:"maxItems"`  // 列表最多元素数
+	Description string `json:"description"`
+}
+
+type Definitions struct {
+	Properties []Definition `json:"properties"`
+	byKey      map[string]*Definition
+}
+
+func (d *Definitions) validate() error {
+	d.byKey = make(map[string]*Definition, len(d.Properties))
+	for i := range d.Properties {
+		p := &d.Properties[i]
+		if !keyPattern.MatchString(p.Key) {
+			return fmt.Errorf("%w: invalid key %q", ErrInvalidDefinitions, p.Key)
+		}
+		if _, ok := d.byKey[p.Key]; ok {
+			return fmt.Errorf("%w: duplicate key %s", ErrInvalidDefinitions, p.Key)
+		}
+		d.byKey[p.Key] = p
+		switch p.Type {
+		case TypeString, TypeNumber, TypeBool, TypeDate, TypeList:
+		default:
+			return fmt.Errorf("%w: unknown type %q in %s", ErrInvalidDefinitions, p.Type, p.Key)
+		}
+		switch p.Access {
+		case "":
+			p.Access = AccessNone
+		case AccessNone, AccessRead, AccessWrite:
+		default:
+			return fmt.Errorf("%w: unknown access %q in %s", ErrInvalidDefinitions, p.Access, p.Key)
+		}
+		if p.MaxLength < 0 || p.MaxLength > maxColumnLength || p.MaxItems < 0 {
+			return fmt.Errorf("%w: invalid limits in %s", ErrInvalidDefinitions, p.Key)
+		}
+	}
+	return nil
+}
+
+func (d *Definitions) Get(key string) (*Definition, bool) {
+	p, ok := d.byKey[key]
+	return p, ok
+}
+
+// Readable 返回客户端能否读取该属性
+func (p *Definition) Readable() bool {
+	return p.Access == AccessRead || p.Access == AccessWrite
+}
+
+// Limits 是全局大小上限，单个属性可以在定义中收紧
+type Limits struct {
+	MaxStringLength int
+	MaxListItems    int
+}
+
+func (p *Definition) maxLength(l Limits) int {
+	n := min(l.MaxStringLength, maxColumnLength)
+	if n <= 0 {
+		n = maxColumnLength
+	}
+	if p.MaxLength > 0 {
+		n = min(n, p.MaxLength)
+	}
+	return n
+}
+
+func (p *Definition) maxItems(l Limits) int {
+	n := l.MaxListItems
+	if p.MaxItems > 0 && (n <= 0 || p.MaxItems < n) {
+		n = p.MaxItems
+	}
+	return n
+}
+
+// Op 是一次属性修改
+type Op struct {
+	Op    string `json:"op"`
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+// apply 在 current 上依次执行 ops，返回需要写入与删除的属性；任一操作非法时整体失败
+func (d *Definitions) apply(current map[string]any, ops []Op, limits Limits, client bool) (map[string]any, []string, error) {
+	state := make(map[string]any, len(current))
+	for k, v := range current {
+		state[k] = v
+	}
+	touched := make(map[string]bool)
+	for _, op := range ops {
+		def, ok := d.byKey[op.Key]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s", ErrUnknownProperty, op.Key)
+		}
+		if client && def.Access != AccessWrite {
+			return nil, nil, fmt.Errorf("%w: %s", ErrNotWritable, op.Key)
+		}
+		old, exists := state[op.Key]
+
+		var next any
+		switch op.Op {
+		case OpSet, OpSetOnce:
+			if op.Op == OpSetOnce && exists {
+				continue
+			}
+			v, err := normalize(def, op.Value, limits)
+			if err != nil {
+				return nil, nil, err
+			}
+			next = v
+		case OpIncrement:
+			if def.Type != TypeNumber {
+				return nil, nil, fmt.Errorf("%w: increment on %s %s", ErrInvalidOp, def.Type, op.Key)
+			}
+			delta, err := normalize(def, op.Value, limits)
+			if err != nil {
+				return nil, nil, err
+			}
+			base, _ := old.(float64)
+			sum := base + delta.(float64)
+			if math.IsInf(sum, 0) {
+				return nil, nil, fmt.Errorf("%w: %s overflows", ErrTooLarge, op.Key)
+			}
+			next = sum
+		case OpAppend:
+			if def.Type != TypeList {
+				return nil, nil, fmt.Errorf("%w: append on %s %s", ErrInvalidOp, def.Type, op.Key)
+			}
+			value := op.Value
+			if s, ok := value.(string); ok {
+				value = []string{s}
+			}
+			items, err := normalize(def, value, limits)
+			if err != nil {
+				return nil, nil, err
+			}
+			base, _ := old.([]string)
+			next, err = checkList(def, append(slices.Clone(base), items.([]string)...), limits)
+			if err != nil {
+				return nil, nil, err
+			}
+		case OpUnset:
+			delete(state, op.Key)
+			touched[op.Key] = true
+			continue
+		default:
+			return nil, nil, fmt.Errorf("%w: unknown op %q", ErrInvalidOp, op.Op)
+		}
+		state[op.Key] = next
+		touched[op.Key] = true
+	}
+
+	upserts := make(map[string]any)
+	var removes []string
+	for k := range touched {
+		if v, ok := state[k]; ok {
+			upserts[k] = v
+		} else {
+			removes = append(removes, k)
+		}
+	}
+	slices.Sort(removes)
+	return upserts, removes, nil
+}
+
+// normalize 把请求中的值转换为属性类型对应的 Go 类型：string、float64、bool、time.Time、[]string
+func normalize(def *Definition, v any, limits Limits) (any, error) {
+	mismatch := fmt.Errorf("%w: %s expects %s", ErrTypeMismatch, def.Key, def.Type)
+	switch def.Type {
+	case TypeString:
+		s, ok := v.(string)
+		if !ok {
+			return nil, mismatch
+		}
+		if utf8.RuneCountInString(s) > def.maxLength(limits) {
+			return nil, fmt.Errorf("%w: %s longer than %d", ErrTooLarge, def.Key, def.maxLength(limits))
+		}
+		return s, nil
+	case TypeNumber:
+		f, ok := toNumber(v)
+		if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, mismatch
+		}
+		return f, nil
+	case TypeBool:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, mismatch
+		}
+		return b, nil
+	case TypeDate:
+		switch t := v.(type) {
+		case time.Time:
+			return t.UTC(), nil
+		case string:
+			for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+				if parsed, err := time.Parse(layout, t); err == nil {
+					return parsed.UTC(), nil
+				}
+			}
+		}
+		return nil, mismatch
+	case TypeList:
+		var items []string
+		switch l := v.(type) {
+		case []string:
+			items = slices.Clone(l)
+		case []any:
+			items = make([]string, 0, len(l))
+			for _, item := range l {
+				s, ok := item.(string)
+				if !ok {
+					return nil, mismatch
+				}
+				items = append(items, s)
+			}
+		default:
+			return nil, mismatch
+		}
+		return checkList(def, items, limits)
+	}
+	return nil, mismatch
+}
+
+func checkList(def *Definition, items []string, limits Limits) ([]string, error) {
+	if n := def.maxItems(limits); n > 0 && len(items) > n {
+		return nil, fmt.Errorf("%w: %s has more than %d items", ErrTooLarge, def.Key, n)
+	}
+	for _, item := range items {
+		if utf8.RuneCountInString(item) > def.maxLength(limits) {
+			return nil, fmt.Errorf("%w: %s item longer than %d", ErrTooLarge, def.Key, def.maxLength(limits))
+		}
+	}
+	if data, _ := json.Marshal(items); len(data) > maxListBytes {
+		return nil, fmt.Errorf("%w: %s exceeds %d bytes", ErrTooLarge, def.Key, maxListBytes)
+	}
+	return items, nil
+}
+
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// AttrName 是属性在分群、灰度规则中的属性名，加前缀避免与固定字段冲突
+func AttrName(key string) string {
+	return "prop." + key
+}
@@ -0,0 +1,215 @@
+package property
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+	config "usergrowth/configs"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/segment"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDefinitions = `{"properties": [
+	{"key": "plan", "type": "string", "access": "read", "maxLength": 8},
+	{"key": "ltv", "type": "number"},
+	{"key": "opt_in", "type": "bool", "access": "write"},
+	{"key": "first_paid_at", "type": "date"},
+	{"key": "interests", "type": "list", "access": "write", "maxItems": 3}
+]}`
+
+// fakeRepo 在内存中保存属性，Update 与数据库实现一样整体成功或失败
+type fakeRepo struct {
+	values map[uint]map[string]Value
+}
+
+func (f *fakeRepo) List(userID uint) ([]Value, error) {
+	var values []Value
+	for _, v := range f.values[userID] {
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (f *fakeRepo) Update(userID uint, fn func(current []Value) ([]Value, []string, error)) error {
+	current, _ := f.List(userID)
+	upserts, removes, err := fn(current)
+	if err != nil {
+		return err
+	}
+	if f.values[userID] == nil {
+		f.values[userID] = make(map[string]Value)
+	}
+	for _, v := range upserts {
+		f.values[userID][v.Key] = v
+	}
+	for _, k := range removes {
+		delete(f.values[userID], k)
+	}
+	return nil
+}
+
+func newTestService(t *testing.T) (*Service, *fakeRepo) {
+	repo := &fakeRepo{values: map[uint]map[string]Value{}}
+	s := NewService(repo, &config.PropertyConfig{MaxStringLength: 255, MaxListItems: 100, MaxOps: 6}, logs.Nop())
+	_, err := s.Load([]byte(testDefinitions))
+	assert.NoError(t, err)
+	return s, repo
+}
+
+func TestLoadDefinitions(t *testing.T) {
+	s, _ := newTestService(t)
+	def, ok := s.Definitions().Get("plan")
+	if assert.True(t, ok) {
+		assert.True(t, def.Readable())
+	}
+	def, _ = s.Definitions().Get("ltv")
+	assert.Equal(t, AccessNone, def.Access)
+
+	for _, raw := range []string{
+		`{"properties": [{"key": "Plan", "type": "string"}]}`,
+		`{"properties": [{"key": "a", "type": "map"}]}`,
+		`{"properties": [{"key": "a", "type": "string", "access": "admin"}]}`,
+		`{"properties": [{"key": "a", "type": "string", "maxLength": 1000}]}`,
+		`{"properties": [{"key": "a", "type": "string"}, {"key": "a", "type": "number"}]}`,
+	} {
+		_, err := s.Load([]byte(raw))
+		assert.ErrorIs(t, err, ErrInvalidDefinitions, raw)
+	}
+	// 非法定义不替换旧定义
+	_, ok = s.Definitions().Get("plan")
+	assert.True(t, ok)
+}
+
+func TestApplyOperations(t *testing.T) {
+	s, repo := newTestService(t)
+	ctx := context.Background()
+
+	props, err := s.Apply(ctx, 1, []Op{
+		{Op: OpSet, Key: "plan", Value: "pro"},
+		{Op: OpIncrement, Key: "ltv", Value: json.Number("19.9")},
+		{Op: OpIncrement, Key: "ltv", Value: 10},
+		{Op: OpSetOnce, Key: "first_paid_at", Value: "2026-06-01T08:00:00+08:00"},
+		{Op: OpAppend, Key: "interests", Value: "go"},
+		{Op: OpAppend, Key: "interests", Value: []any{"redis", "mysql"}},
+	}, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "pro", props["plan"])
+	assert.InDelta(t, 29.9, props["ltv"], 1e-9)
+	assert.Equal(t, time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC), props["first_paid_at"])
+	assert.Equal(t, []string{"go", "redis", "mysql"}, props["interests"])
+
+	// set_once 已有值时忽略，unset 删除
+	props, err = s.Apply(ctx, 1, []Op{
+		{Op: OpSetOnce, Key: "first_paid_at", Value: "2027-01-01"},
+		{Op: OpUnset, Key: "plan"},
+		{Op: OpSet, Key: "opt_in", Value: false},
+	}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC), props["first_paid_at"])
+	assert.NotContains(t, props, "plan")
+	assert.Equal(t, false, props["opt_in"])
+	assert.NotContains(t, repo.values[1], "plan")
+
+	// 超出列表长度时整批不生效
+	_, err = s.Apply(ctx, 1, []Op{
+		{Op: OpSet, Key: "opt_in", Value: true},
+		{Op: OpAppend, Key: "interests", Value: "kafka"},
+	}, false)
+	assert.ErrorIs(t, err, ErrTooLarge)
+	assert.Equal(t, false, *repo.values[1]["opt_in"].Bool)
+}
+
+func TestApplyValidation(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	cases := []struct {
+		op     Op
+		client bool
+		err    error
+	}{
+		{Op{Op: OpSet, Key: "unknown", Value: "x"}, false, ErrUnknownProperty},
+		{Op{Op: OpSet, Key: "plan", Value: 1}, false, ErrTypeMismatch},
+		{Op{Op: OpSet, Key: "plan", Value: "enterprise"}, false, ErrTooLarge},
+		{Op{Op: OpSet, Key: "ltv", Value: "12"}, false, ErrTypeMismatch},
+		{Op{Op: OpSet, Key: "opt_in", Value: "true"}, false, ErrTypeMismatch},
+		{Op{Op: OpSet, Key: "first_paid_at", Value: "yesterday"}, false, ErrTypeMismatch},
+		{Op{Op: OpSet, Key: "interests", Value: []any{"a", 1}}, false, ErrTypeMismatch},
+		{Op{Op: OpSet, Key: "interests", Value: []any{strings.Repeat("a", 256)}}, false, ErrTooLarge},
+		{Op{Op: OpIncrement, Key: "plan", Value: 1}, false, ErrInvalidOp},
+		{Op{Op: OpAppend, Key: "ltv", Value: "x"}, false, ErrInvalidOp},
+		{Op{Op: "merge", Key: "plan", Value: "x"}, false, ErrInvalidOp},
+		// 客户端只能写 access 为 write 的属性
+		{Op{Op: OpSet, Key: "plan", Value: "pro"}, true, ErrNotWritable},
+		{Op{Op: OpIncrement, Key: "ltv", Value: 1}, true, ErrNotWritable},
+	}
+	for _, c := range cases {
+		_, err := s.Apply(ctx, 1, []Op{c.op}, c.client)
+		assert.ErrorIs(t, err, c.err, c.op)
+	}
+
+	ops := make([]Op, 7)
+	for i := range ops {
+		ops[i] = Op{Op: OpSet, Key: "opt_in", Value: true}
+	}
+	_, err := s.Apply(ctx, 1, ops, true)
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestClientVisibilityAndTypeChange(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	_, err := s.Apply(ctx, 1, []Op{
+		{Op: OpSet, Key: "plan", Value: "pro"},
+		{Op: OpSet, Key: "ltv", Value: 5},
+	}, false)
+	assert.NoError(t, err)
+	props, err := s.Apply(ctx, 1, []Op{{Op: OpAppend, Key: "interests", Value: "go"}}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"plan": "pro", "interests": []string{"go"}}, props)
+
+	// 类型变更后旧值不再返回
+	_, err = s.Load([]byte(strings.Replace(testDefinitions, `"key": "ltv", "type": "number"`, `"key": "ltv", "type": "string"`, 1)))
+	assert.NoError(t, err)
+	props, _ = s.Get(ctx, 1, false)
+	assert.NotContains(t, props, "ltv")
+}
+
+func TestSegmentRules(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+	_, err := s.Apply(ctx, 7, []Op{
+		{Op: OpSet, Key: "plan", Value: "pro"},
+		{Op: OpSet, Key: "ltv", Value: 120},
+		{Op: OpSet, Key: "first_paid_at", Value: "2026-06-01"},
+		{Op: OpSet, Key: "interests", Value: []any{"go", "redis"}},
+	}, false)
+	assert.NoError(t, err)
+
+	profile, err := segment.NewProfiler(nil, s).Profile(ctx, 7)
+	if !assert.NoError(t, err) {
+		return
+	}
+	now := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	for rule, want := range map[string]bool{
+		`{"attr": "prop.plan", "op": "eq", "value": "pro"}`:                 true,
+		`{"attr": "prop.ltv", "op": "gte", "value": 100}`:                   true,
+		`{"attr": "prop.first_paid_at", "op": "lt", "value": "2026-05-01"}`: false,
+		`{"attr": "prop.interests", "op": "contains", "value": "redis"}`:    true,
+		`{"attr": "prop.opt_in", "op": "exists", "value": true}`:            false,
+	} {
+		var r segment.Rule
+		assert.NoError(t, json.Unmarshal([]byte(rule), &r))
+		assert.NoError(t, r.Validate())
+		got, err := r.Evaluate(ctx, profile, now)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, rule)
+	}
+}
@@ -0,0 +1,127 @@
+package property
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Value 按 user_id + key 存放一个属性，值按类型写入对应的列，(key, 值) 上的索引供按属性筛选用户
+type Value struct {
+	UserID    uint       `gorm:"primaryKey;autoIncrement:false"`
+	Key       string     `gorm:"primaryKey;size:64;index:idx_user_properties_str,priority:1;index:idx_user_properties_num,priority:1;index:idx_user_properties_time,priority:1"`
+	Type      string     `gorm:"size:16;not null"`
+	Str       *string    `gorm:"column:str_value;size:255;index:idx_user_properties_str,priority:2"`
+	Num       *float64   `gorm:"column:num_value;index:idx_user_properties_num,priority:2"`
+	Bool      *bool      `gorm:"column:bool_value"`
+	Time      *time.Time `gorm:"column:time_value;index:idx_user_properties_time,priority:2"`
+	List      *string    `gorm:"column:list_value;type:text"` // JSON 数组
+	UpdatedAt time.Time
+}
+
+func (Value) TableName() string {
+	return "user_properties"
+}
+
+// newValue 的 v 需已经过 normalize
+func newValue(userID uint, key, typ string, v any, now time.Time) Value {
+	value := Value{UserID: userID, Key: key, Type: typ, UpdatedAt: now}
+	switch x := v.(type) {
+	case string:
+		value.Str = &x
+	case float64:
+		value.Num = &x
+	case bool:
+		value.Bool = &x
+	case time.Time:
+		value.Time = &x
+	case []string:
+		data, _ := json.Marshal(x)
+		list := string(data)
+		value.List = &list
+	}
+	return value
+}
+
+// Interface 返回与 normalize 结果相同类型的值
+func (v *Value) Interface() any {
+	switch v.Type {
+	case TypeString:
+		if v.Str != nil {
+			return *v.Str
+		}
+	case TypeNumber:
+		if v.Num != nil {
+			return *v.Num
+		}
+	case TypeBool:
+		if v.Bool != nil {
+			return *v.Bool
+		}
+	case TypeDate:
+		if v.Time != nil {
+			return v.Time.UTC()
+		}
+	case TypeList:
+		items := []string{}
+		if v.List != nil {
+			_ = json.Unmarshal([]byte(*v.List), &items)
+		}
+		return items
+	}
+	return nil
+}
+
+type PropertyRepository interface {
+	List(userID uint) ([]Value, error)
+	// Update 锁定用户行后读取全部属性并调用 fn，按返回结果写入与删除，同一用户的修改串行执行
+	Update(userID uint, fn func(current []Value) (upserts []Value, removes []string, err error)) error
+}
+
+type propertyRepository struct {
+	db *gorm.DB
+}
+
+func NewPropertyRepository(db *gorm.DB) PropertyRepository {
+	if err := db.AutoMigrate(&Value{}); err != nil {
+		panic("failed to migrate user property table")
+	}
+	return &propertyRepository{db: db}
+}
+
+func (repo *propertyRepository) List(userID uint) ([]Value, error) {
+	var values []Value
+	err := repo.db.Where("user_id = ?", userID).Order("`key`").Find(&values).Error
+	return values, err
+}
+
+func (repo *propertyRepository) Update(userID uint, fn func(current []Value) ([]Value, []string, error)) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		// 锁用户行而不是属性行：用户还没有属性时 FOR UPDATE 会加间隙锁，并发的首次写入会互相死锁
+		var locked []uint
+		if err := tx.Table("users").Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).Pluck("user_id", &locked).Error; err != nil {
+			return err
+		}
+		var current []Value
+		if err := tx.Where("user_id = ?", userID).Find(&current).Error; err != nil {
+			return err
+		}
+		upserts, removes, err := fn(current)
+		if err != nil {
+			return err
+		}
+		if len(upserts) > 0 {
+			// 类型变化时其余列需要清空，因此覆盖全部列
+			if err = tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&upserts).Error; err != nil {
+				return err
+			}
+		}
+		if len(removes) > 0 {
+			return tx.Where("user_id = ? AND `key` IN ?", userID, removes).Delete(&Value{}).Error
+		}
+		return nil
+	})
+}
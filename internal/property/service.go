@@ -0,0 +1,182 @@
+package property
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	config "usergrowth/configs"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"github.com/gogf/gf/v2/os/gfsnotify"
+)
+
+// Service 持有当前生效的属性定义，对写入做类型与大小校验
+type Service struct {
+	defs    atomic.Pointer[Definitions]
+	repo    PropertyRepository
+	cfg     *config.PropertyConfig
+	now     func() time.Time
+	logger  logs.Logger
+	mu      sync.Mutex
+	lastRaw string
+}
+
+func NewService(repo PropertyRepository, cfg *config.PropertyConfig, logger logs.Logger) *Service {
+	s := &Service{
+		repo:   repo,
+		cfg:    cfg,
+		now:    time.Now,
+		logger: logger,
+	}
+	s.defs.Store(&Definitions{byKey: map[string]*Definition{}})
+	return s
+}
+
+// Load 解析 JSON 或 YAML 格式的定义，非法时保留旧定义；返回定义是否发生变化
+func (s *Service) Load(raw []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if string(raw) == s.lastRaw {
+		return false, nil
+	}
+
+	j, err := gjson.LoadContent(raw)
+	if err != nil {
+		return false, err
+	}
+	var defs Definitions
+	if err = json.Unmarshal(j.MustToJson(), &defs); err != nil {
+		return false, err
+	}
+	if err = defs.validate(); err != nil {
+		return false, err
+	}
+	s.defs.Store(&defs)
+	s.lastRaw = string(raw)
+	return true, nil
+}
+
+// WatchFile 加载文件并在文件变更时热更新；修改类型后旧类型的值不再可读，直到重新写入
+func (s *Service) WatchFile(path string) error {
+	load := func() (bool, error) {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		return s.Load(raw)
+	}
+	if _, err := load(); err != nil {
+		return err
+	}
+	_, err := gfsnotify.Add(path, func(e *gfsnotify.Event) {
+		if e.IsWrite() || e.IsCreate() || e.IsRename() {
+			ctx := context.Background()
+			changed, err := load()
+			if err != nil {
+				s.logger.Error(ctx, "property definitions reload failed:", path, err.Error())
+				return
+			}
+			if changed {
+				s.logger.Info(ctx, "property definitions reloaded from file:", path)
+			}
+		}
+	})
+	return err
+}
+
+func (s *Service) Definitions() *Definitions {
+	return s.defs.Load()
+}
+
+func (s *Service) limits() Limits {
+	return Limits{MaxStringLength: s.cfg.MaxStringLength, MaxListItems: s.cfg.MaxListItems}
+}
+
+// Get 返回用户的属性，client 为 true 时只返回客户端可读的属性
+func (s *Service) Get(ctx context.Context, userID uint, client bool) (map[string]any, error) {
+	values, err := s.repo.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.visible(values, client), nil
+}
+
+// Apply 在同一事务内依次执行 ops 并返回修改后的属性；client 为 true 时只能修改客户端可写的属性
+func (s *Service) Apply(ctx context.Context, userID uint, ops []Op, client bool) (map[string]any, error) {
+	_, span := gtrace.NewSpan(ctx, "Property.Apply")
+	defer span.End()
+
+	if s.cfg.MaxOps > 0 && len(ops) > s.cfg.MaxOps {
+		return nil, fmt.Errorf("%w: more than %d operations", ErrTooLarge, s.cfg.MaxOps)
+	}
+	defs := s.defs.Load()
+	var result []Value
+	err := s.repo.Update(userID, func(current []Value) ([]Value, []string, error) {
+		upserts, removes, err := defs.apply(s.visible(current, false), ops, s.limits(), client)
+		if err != nil {
+			return nil, nil, err
+		}
+		now := s.now()
+		values := make([]Value, 0, len(upserts))
+		for key, v := range upserts {
+			def, _ := defs.Get(key)
+			values = append(values, newValue(userID, key, def.Type, v, now))
+		}
+
+		// 拼出修改后的全部属性作为返回值
+		result = result[:0]
+		changed := make(map[string]bool, len(values)+len(removes))
+		for _, k := range removes {
+			changed[k] = true
+		}
+		for _, v := range values {
+			changed[v.Key] = true
+		}
+		for _, v := range current {
+			if !changed[v.Key] {
+				result = append(result, v)
+			}
+		}
+		result = append(result, values...)
+		return values, removes, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.visible(result, client), nil
+}
+
+// visible 转换为属性名到值的映射，跳过未定义或类型与定义不一致的值
+func (s *Service) visible(values []Value, client bool) map[string]any {
+	defs := s.defs.Load()
+	props := make(map[string]any, len(values))
+	for i := range values {
+		def, ok := defs.Get(values[i].Key)
+		if !ok || def.Type != values[i].Type || (client && !def.Readable()) {
+			continue
+		}
+		if v := values[i].Interface(); v != nil {
+			props[values[i].Key] = v
+		}
+	}
+	return props
+}
+
+// Attributes 实现 segment.AttributeSource，属性以 prop. 为前缀提供给分群与灰度规则
+func (s *Service) Attributes(ctx context.Context, userID uint) (map[string]any, error) {
+	props, err := s.Get(ctx, userID, false)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make(map[string]any, len(props))
+	for k, v := range props {
+		attrs[AttrName(k)] = v
+	}
+	return attrs, nil
+}
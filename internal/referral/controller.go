@@ -0,0 +1,71 @@
+package referral
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type ReferralsReq struct {
+	g.Meta `path:"/api/referrals" method:"get"`
+	Limit  int `p:"limit" d:"20" v:"between:1,100#条数应在1到100之间"`
+}
+
+type ReferralsRes struct {
+}
+
+type Controller struct {
+	service *Service
+	repo    ReferralRepository
+}
+
+func NewController(service *Service, repo ReferralRepository) *Controller {
+	return &Controller{
+		service: service,
+		repo:    repo,
+	}
+}
+
+// Referrals 返回当前用户的邀请码与最近邀请的用户
+func (c *Controller) Referrals(ctx context.Context, req *ReferralsReq) (res *ReferralsRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Referral.Referrals")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	userid := r.GetCtxVar("userid").String()
+	uid, err := strconv.ParseUint(userid, 10, 64)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+	span.SetAttributes(attribute.String("user.id", userid))
+
+	code, err := c.service.Code(uint(uid))
+	if err != nil {
+		return nil, err
+	}
+	total, err := c.repo.CountInvitees(uint(uid), time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	invitees, err := c.repo.ListInvitees(uint(uid), req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data": g.Map{
+			"code":     code,
+			"total":    total,
+			"invitees": invitees,
+		},
+	})
+	return nil, nil
+}
@@ -0,0 +1,115 @@
+package referral
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCodeNotFound  = errors.New("referral code not found")
+	ErrDuplicateCode = errors.New("referral code already exists")
+)
+
+// Code 是用户的专属邀请码，首次查看时生成
+type Code struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Code      string    `gorm:"type:varchar(32);not null;uniqueIndex" json:"code"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Code) TableName() string {
+	return "referral_codes"
+}
+
+// Referral 是邀请关系，每个被邀请人只有一个邀请人
+type Referral struct {
+	InviteeID uint      `gorm:"primaryKey;autoIncrement:false" json:"invitee_id"`
+	InviterID uint      `gorm:"not null;index:idx_inviter_created" json:"inviter_id"`
+	CreatedAt time.Time `gorm:"index:idx_inviter_created" json:"created_at"`
+}
+
+type referralRepository struct {
+	db *gorm.DB
+}
+
+type ReferralRepository interface {
+	// FindCode 用户还没有邀请码时返回 nil
+	FindCode(userID uint) (*Code, error)
+	CreateCode(code *Code) error
+	FindByCode(code string) (*Code, error)
+	CreateReferral(referral *Referral) error
+	// InviterOf 没有邀请人时返回 0
+	InviterOf(inviteeID uint) (uint, error)
+	CountInvitees(inviterID uint, since time.Time) (int64, error)
+	ListInvitees(inviterID uint, limit int) ([]Referral, error)
+}
+
+func NewReferralRepository(db *gorm.DB) ReferralRepository {
+	if err := db.AutoMigrate(&Code{}, &Referral{}); err != nil {
+		panic("failed to migrate referral tables")
+	}
+	return &referralRepository{db: db}
+}
+
+func (repo *referralRepository) FindCode(userID uint) (*Code, error) {
+	var code Code
+	if err := repo.db.Where("user_id = ?", userID).First(&code).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (repo *referralRepository) CreateCode(code *Code) error {
+	if err := repo.db.Create(code).Error; err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 { // Error 1062: Duplicate entry
+			return ErrDuplicateCode
+		}
+		return err
+	}
+	return nil
+}
+
+func (repo *referralRepository) FindByCode(code string) (*Code, error) {
+	var c Code
+	if err := repo.db.Where("code = ?", code).First(&c).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCodeNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (repo *referralRepository) CreateReferral(referral *Referral) error {
+	return repo.db.Create(referral).Error
+}
+
+func (repo *referralRepository) InviterOf(inviteeID uint) (uint, error) {
+	var referral Referral
+	if err := repo.db.Where("invitee_id = ?", inviteeID).First(&referral).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return referral.InviterID, nil
+}
+
+func (repo *referralRepository) CountInvitees(inviterID uint, since time.Time) (int64, error) {
+	var n int64
+	err := repo.db.Model(&Referral{}).Where("inviter_id = ? AND created_at >= ?", inviterID, since).Count(&n).Error
+	return n, err
+}
+
+func (repo *referralRepository) ListInvitees(inviterID uint, limit int) ([]Referral, error) {
+	var referrals []Referral
+	err := repo.db.Where("inviter_id = ?", inviterID).Order("created_at DESC").Limit(limit).Find(&referrals).Error
+	return referrals, err
+}
@@ -0,0 +1,200 @@
+package referral
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"usergrowth/internal/bus"
+	"usergrowth/internal/event"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/points"
+	"usergrowth/internal/risk"
+	"usergrowth/internal/user"
+)
+
+// 邀请奖励的积分流水原因，RefID 均为被邀请人 ID
+const (
+	ReasonInviter = "referral_inviter"
+	ReasonInvitee = "referral_invitee"
+)
+
+// NameInvited 是被邀请人通过风控评估后为邀请人写入的服务端事件，供邀请排行榜等按邀请数计分
+const NameInvited = "invite_accepted"
+
+const (
+	// 去掉 0/O/1/I 等易混淆字符，方便用户手动输入
+	codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	codeLength   = 8
+	// 生成邀请码撞码时的重试次数
+	codeAttempts = 5
+)
+
+func generateCode() (string, error) {
+	buf := make([]byte, codeLength)
+	max := big.NewInt(int64(len(codeAlphabet)))
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = codeAlphabet[n.Int64()]
+	}
+	return string(buf), nil
+}
+
+// Reward 是一笔待发放的邀请奖励
+type Reward struct {
+	UserID    uint   `json:"user_id"`
+	SubjectID uint   `json:"subject_id"`
+	Amount    int64  `json:"amount"`
+	Reason    string `json:"reason"`
+	RefID     string `json:"ref_id"`
+}
+
+// TopicRewardRetry 在邀请奖励发放失败时发布，由总线延迟重试，超过次数转入死信
+var TopicRewardRetry = bus.NewTopic[Reward]("referral.reward_retry")
+
+// RewardGate 按被邀请人的风险评估决定直接发放还是冻结奖励，由 risk 模块提供
+type RewardGate interface {
+	Grant(ctx context.Context, userID, subjectID uint, amount int64, reason, refID string) (bool, error)
+}
+
+type Service struct {
+	repo          ReferralRepository
+	gate          RewardGate
+	events        event.EventRepository
+	bus           bus.EventBus
+	inviterPoints int64
+	inviteePoints int64
+	logger        logs.Logger
+}
+
+func NewService(repo ReferralRepository, gate RewardGate, events event.EventRepository, inviterPoints, inviteePoints int64, logger logs.Logger) *Service {
+	return &Service{
+		repo:          repo,
+		gate:          gate,
+		events:        events,
+		inviterPoints: inviterPoints,
+		inviteePoints: inviteePoints,
+		logger:        logger,
+	}
+}
+
+// RetryRewards 订阅发放失败的奖励并重试，需在总线 Start 前调用；未调用时失败只记录日志
+func (s *Service) RetryRewards(b bus.EventBus) {
+	s.bus = b
+	TopicRewardRetry.Subscribe(b, "referral-reward", s.award)
+}
+
+// Code 返回用户的邀请码，没有则生成
+func (s *Service) Code(userID uint) (string, error) {
+	code, err := s.repo.FindCode(userID)
+	if err != nil {
+		return "", err
+	}
+	if code != nil {
+		return code.Code, nil
+	}
+	for range codeAttempts {
+		raw, err := generateCode()
+		if err != nil {
+			return "", err
+		}
+		code = &Code{UserID: userID, Code: raw}
+		err = s.repo.CreateCode(code)
+		if err == nil {
+			return code.Code, nil
+		}
+		if !errors.Is(err, ErrDuplicateCode) {
+			return "", err
+		}
+		// 可能是并发请求已为该用户生成了邀请码
+		if existing, findErr := s.repo.FindCode(userID); findErr == nil && existing != nil {
+			return existing.Code, nil
+		}
+	}
+	return "", ErrDuplicateCode
+}
+
+// OnRegister 注册为用户注册钩子，根据邀请码建立邀请关系，需排在风控评估之前
+func (s *Service) OnRegister(ctx context.Context, reg *user.Registration) {
+	if reg.InviteCode == "" {
+		return
+	}
+	code, err := s.repo.FindByCode(strings.ToUpper(reg.InviteCode))
+	if err != nil {
+		s.logger.Info(ctx, "Referral code invalid:", reg.InviteCode, "userid:", reg.UserID, err.Error())
+		return
+	}
+	if err = s.repo.CreateReferral(&Referral{InviteeID: reg.UserID, InviterID: code.UserID}); err != nil {
+		s.logger.Error(ctx, "referral create failed:", reg.UserID, code.UserID, err.Error())
+	}
+}
+
+// OnAssessed 注册为风控评估监听器，评估完成后发放邀请双方的奖励
+func (s *Service) OnAssessed(ctx context.Context, reg *user.Registration, assessment *risk.Assessment) {
+	if assessment.InviterID == 0 {
+		return
+	}
+	refID := strconv.FormatUint(uint64(reg.UserID), 10)
+	s.grant(ctx, assessment.InviterID, reg.UserID, s.inviterPoints, ReasonInviter, refID)
+	s.grant(ctx, reg.UserID, reg.UserID, s.inviteePoints, ReasonInvitee, refID)
+	// 被标记的邀请不计入邀请数，审核放行只补发奖励
+	if assessment.Flagged {
+		return
+	}
+	if err := s.events.Record(ctx, assessment.InviterID, NameInvited, map[string]any{"invitee_id": reg.UserID}); err != nil {
+		s.logger.Error(ctx, "referral invited event record failed:", assessment.InviterID, reg.UserID, err.Error())
+	}
+}
+
+func (s *Service) grant(ctx context.Context, userID, subjectID uint, amount int64, reason, refID string) {
+	if amount <= 0 {
+		return
+	}
+	reward := &Reward{UserID: userID, SubjectID: subjectID, Amount: amount, Reason: reason, RefID: refID}
+	if s.award(ctx, reward) == nil {
+		return
+	}
+	if s.bus == nil {
+		return
+	}
+	if err := TopicRewardRetry.Publish(ctx, s.bus, reward); err != nil {
+		s.logger.Error(ctx, "referral reward retry publish failed:", reason, userID, refID, err.Error())
+	}
+}
+
+// award 发放一笔奖励，已经到账的视为成功，重试时幂等
+func (s *Service) award(ctx context.Context, reward *Reward) error {
+	held, err := s.gate.Grant(ctx, reward.UserID, reward.SubjectID, reward.Amount, reward.Reason, reward.RefID)
+	if errors.Is(err, points.ErrDuplicateEntry) {
+		return nil
+	}
+	if err != nil {
+		s.logger.Error(ctx, "referral reward failed:", reward.Reason, reward.UserID, reward.RefID, err.Error())
+		return err
+	}
+	s.logger.Info(ctx, "Referral reward:", reward.Reason, "userid:", reward.UserID, "points:", reward.Amount, "held:", held)
+	return nil
+}
+
+// Graph 基于邀请关系表实现 risk.InviteGraph
+type Graph struct {
+	repo ReferralRepository
+}
+
+func NewGraph(repo ReferralRepository) *Graph {
+	return &Graph{repo: repo}
+}
+
+func (g *Graph) InviterOf(ctx context.Context, inviteeID uint) (uint, error) {
+	return g.repo.InviterOf(inviteeID)
+}
+
+func (g *Graph) CountInviteesSince(ctx context.Context, inviterID uint, since time.Time) (int64, error) {
+	return g.repo.CountInvitees(inviterID, since)
+}
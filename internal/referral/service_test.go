@@ -0,0 +1,282 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"usergrowth/internal/bus"
+	"usergrowth/internal/event"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/points"
+	"usergrowth/internal/risk"
+	"usergrowth/internal/user"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePointsRepo struct {
+	points.PointsRepository
+	entries  map[string]*points.Entry
+	balances map[uint]int64
+	err      error
+}
+
+func (f *fakePointsRepo) Apply(entry *points.Entry) (*points.Account, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	key := entry.Reason + "/" + entry.RefID
+	if f.entries[key] != nil {
+		return nil, points.ErrDuplicateEntry
+	}
+	f.entries[key] = entry
+	f.balances[entry.UserID] += entry.Delta
+	entry.Balance = f.balances[entry.UserID]
+	return &points.Account{UserID: entry.UserID, Balance: entry.Balance}, nil
+}
+
+type fakeEvents struct {
+	event.EventRepository
+	recorded []string
+}
+
+func (f *fakeEvents) Record(ctx context.Context, userID uint, name string, properties map[string]any) error {
+	f.recorded = append(f.recorded, name+"/"+strconv.FormatUint(uint64(userID), 10))
+	return nil
+}
+
+type fakeRiskRepo struct {
+	risk.RiskRepository
+	assessments map[uint]*risk.Assessment
+	holds       []*risk.Hold
+	decisions   []risk.Decision
+	reopened    []uint
+}
+
+func (f *fakeRiskRepo) FindAssessment(userID uint) (*risk.Assessment, error) {
+	return f.assessments[userID], nil
+}
+
+func (f *fakeRiskRepo) CreateHold(hold *risk.Hold) error {
+	hold.HoldID = uint(len(f.holds) + 1)
+	f.holds = append(f.holds, hold)
+	return nil
+}
+
+func (f *fakeRiskRepo) FindHold(holdID uint) (*risk.Hold, error) {
+	if holdID == 0 || int(holdID) > len(f.holds) {
+		return nil, risk.ErrHoldNotFound
+	}
+	hold := *f.holds[holdID-1]
+	return &hold, nil
+}
+
+func (f *fakeRiskRepo) Review(holdID uint, status string, operator uint, at time.Time) error {
+	hold := f.holds[holdID-1]
+	if hold.Status != risk.HoldPending {
+		return risk.ErrHoldReviewed
+	}
+	hold.Status, hold.ReviewedBy, hold.ReviewedAt = status, operator, &at
+	return nil
+}
+
+func (f *fakeRiskRepo) Reopen(holdID uint) error {
+	f.reopened = append(f.reopened, holdID)
+	f.holds[holdID-1].Status = risk.HoldPending
+	return nil
+}
+
+func (f *fakeRiskRepo) LogDecision(decision *risk.Decision) error {
+	f.decisions = append(f.decisions, *decision)
+	return nil
+}
+
+const (
+	inviterID = 7
+	inviteeID = 42
+)
+
+// newTestService 返回邀请奖励 100 / 20 积分、经由真实风控与积分服务发放的 Service
+func newTestService() (*Service, *risk.Service, *fakeRiskRepo, *fakePointsRepo) {
+	s, riskService, riskRepo, pointsRepo, _ := newTestServiceWithEvents()
+	return s, riskService, riskRepo, pointsRepo
+}
+
+func newTestServiceWithEvents() (*Service, *risk.Service, *fakeRiskRepo, *fakePointsRepo, *fakeEvents) {
+	riskRepo := &fakeRiskRepo{assessments: map[uint]*risk.Assessment{}}
+	pointsRepo := &fakePointsRepo{entries: map[string]*points.Entry{}, balances: map[uint]int64{}}
+	events := &fakeEvents{}
+	pointsService := points.NewService(pointsRepo, &fakeEvents{}, logs.Nop())
+	riskService := risk.NewService(riskRepo, nil, nil, nil, pointsService, risk.Thresholds{}, 0, nil, logs.Nop())
+	return NewService(nil, riskService, events, 100, 20, logs.Nop()), riskService, riskRepo, pointsRepo, events
+}
+
+func assess(s *Service, riskRepo *fakeRiskRepo, flagged bool) {
+	assessment := &risk.Assessment{UserID: inviteeID, Score: 10, Flagged: flagged, InviterID: inviterID}
+	riskRepo.assessments[inviteeID] = assessment
+	s.OnAssessed(context.Background(), &user.Registration{UserID: inviteeID}, assessment)
+}
+
+func TestOnAssessedAwardsDirectly(t *testing.T) {
+	s, _, riskRepo, pointsRepo, events := newTestServiceWithEvents()
+	assess(s, riskRepo, false)
+
+	assert.Empty(t, riskRepo.holds)
+	assert.Equal(t, int64(100), pointsRepo.balances[inviterID])
+	assert.Equal(t, int64(20), pointsRepo.balances[inviteeID])
+	assert.NotNil(t, pointsRepo.entries[ReasonInviter+"/42"])
+	assert.NotNil(t, pointsRepo.entries[ReasonInvitee+"/42"])
+	assert.Equal(t, []string{NameInvited + "/7"}, events.recorded)
+
+	// 没有邀请人时不发放
+	s.OnAssessed(context.Background(), &user.Registration{UserID: 43}, &risk.Assessment{UserID: 43})
+	assert.Len(t, pointsRepo.entries, 2)
+	assert.Len(t, events.recorded, 1)
+}
+
+func TestOnAssessedHoldsFlaggedInvitee(t *testing.T) {
+	s, _, riskRepo, pointsRepo, events := newTestServiceWithEvents()
+	assess(s, riskRepo, true)
+
+	assert.Empty(t, pointsRepo.entries)
+	assert.Empty(t, events.recorded)
+	if !assert.Len(t, riskRepo.holds, 2) {
+		return
+	}
+	inviter, invitee := riskRepo.holds[0], riskRepo.holds[1]
+	assert.Equal(t, uint(inviterID), inviter.UserID)
+	assert.Equal(t, uint(inviteeID), inviter.SubjectID)
+	assert.Equal(t, int64(100), inviter.Points)
+	assert.Equal(t, ReasonInviter, inviter.Reason)
+	assert.Equal(t, uint(inviteeID), invitee.UserID)
+	assert.Equal(t, int64(20), invitee.Points)
+	for _, hold := range riskRepo.holds {
+		assert.Equal(t, risk.HoldPending, hold.Status)
+		assert.Equal(t, "42", hold.RefID)
+	}
+	for _, d := range riskRepo.decisions {
+		assert.Equal(t, risk.ActionHold, d.Action)
+	}
+}
+
+func TestReviewHolds(t *testing.T) {
+	ctx := context.Background()
+	s, riskService, riskRepo, pointsRepo := newTestService()
+	assess(s, riskRepo, true)
+
+	// 放行邀请人奖励
+	hold, err := riskService.Review(ctx, 1, true, 99, "ok")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, risk.HoldReleased, hold.Status)
+	assert.Equal(t, uint(99), hold.ReviewedBy)
+	assert.Equal(t, int64(100), pointsRepo.balances[inviterID])
+
+	// 驳回被邀请人奖励，不发放积分
+	hold, err = riskService.Review(ctx, 2, false, 99, "fraud")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, risk.HoldRejected, hold.Status)
+	assert.Zero(t, pointsRepo.balances[inviteeID])
+
+	// 已审核过的不能再次审核
+	_, err = riskService.Review(ctx, 1, true, 99, "")
+	assert.ErrorIs(t, err, risk.ErrHoldReviewed)
+	_, err = riskService.Review(ctx, 2, true, 99, "")
+	assert.ErrorIs(t, err, risk.ErrHoldReviewed)
+	_, err = riskService.Review(ctx, 3, true, 99, "")
+	assert.ErrorIs(t, err, risk.ErrHoldNotFound)
+
+	actions := make([]string, 0, len(riskRepo.decisions))
+	for _, d := range riskRepo.decisions {
+		actions = append(actions, d.Action)
+	}
+	assert.Equal(t, []string{risk.ActionHold, risk.ActionHold, risk.ActionRelease, risk.ActionReject}, actions)
+}
+
+func TestReviewReleaseAlreadyAwarded(t *testing.T) {
+	ctx := context.Background()
+	s, riskService, riskRepo, pointsRepo := newTestService()
+	assess(s, riskRepo, true)
+
+	// 同一笔奖励已经到账（如上次放行发放成功后审核状态写入前重试），放行仍然成功且不重复发放
+	_, err := pointsRepo.Apply(&points.Entry{UserID: inviterID, Delta: 100, Reason: ReasonInviter, RefID: "42"})
+	assert.NoError(t, err)
+	hold, err := riskService.Review(ctx, 1, true, 99, "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, risk.HoldReleased, hold.Status)
+	}
+	assert.Equal(t, int64(100), pointsRepo.balances[inviterID])
+	assert.Empty(t, riskRepo.reopened)
+}
+
+func TestReviewReleaseFailureReopens(t *testing.T) {
+	ctx := context.Background()
+	s, riskService, riskRepo, pointsRepo := newTestService()
+	assess(s, riskRepo, true)
+
+	pointsRepo.err = errors.New("db down")
+	_, err := riskService.Review(ctx, 1, true, 99, "")
+	assert.EqualError(t, err, "db down")
+	assert.Equal(t, []uint{1}, riskRepo.reopened)
+	assert.Equal(t, risk.HoldPending, riskRepo.holds[0].Status)
+
+	// 恢复后可以重新审核放行
+	pointsRepo.err = nil
+	_, err = riskService.Review(ctx, 1, true, 99, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), pointsRepo.balances[inviterID])
+}
+
+// flakyGate 前 failures 次发放失败
+type flakyGate struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	granted  []string
+}
+
+func (f *flakyGate) Grant(ctx context.Context, userID, subjectID uint, amount int64, reason, refID string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls <= f.failures {
+		return false, errors.New("db down")
+	}
+	f.granted = append(f.granted, reason)
+	return false, nil
+}
+
+func (f *flakyGate) snapshot() (int, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls, append([]string(nil), f.granted...)
+}
+
+func TestGrantFailureRetried(t *testing.T) {
+	gate := &flakyGate{failures: 3}
+	s := NewService(nil, gate, &fakeEvents{}, 100, 0, logs.Nop())
+	b := bus.NewMemory(bus.Options{MaxAttempts: 5, RetryDelay: 10 * time.Millisecond}, logs.Nop())
+	s.RetryRewards(b)
+	if !assert.NoError(t, b.Start(context.Background())) {
+		return
+	}
+	defer func() {
+		_ = b.Close()
+	}()
+
+	s.OnAssessed(context.Background(), &user.Registration{UserID: inviteeID}, &risk.Assessment{UserID: inviteeID, InviterID: inviterID})
+	// 首次发放与前两次重试失败，第三次重试成功
+	assert.Eventually(t, func() bool {
+		_, granted := gate.snapshot()
+		return len(granted) == 1
+	}, time.Second, 10*time.Millisecond)
+	calls, granted := gate.snapshot()
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, []string{ReasonInviter}, granted)
+}
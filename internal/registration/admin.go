@@ -0,0 +1,105 @@
+package registration
+
+import (
+	"context"
+	"errors"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+type IssueReq struct {
+	g.Meta     `path:"/api/admin/invitations" method:"post"`
+	Email      string `json:"email" v:"email|max-length:255#邮箱格式不正确|邮箱过长"` // 为空表示不限邮箱
+	Note       string `json:"note" v:"max-length:255#备注过长"`
+	MaxUses    int    `json:"max_uses" d:"1" v:"between:1,10000#可用次数应在1到10000之间"`
+	ExpireDays int    `json:"expire_days" v:"between:0,365#有效天数应在0到365之间"` // 0 表示永不过期
+}
+
+type IssueRes struct {
+}
+
+type ListInvitationsReq struct {
+	g.Meta `path:"/api/admin/invitations" method:"get"`
+	Before uint `p:"before"` // 上一页最后一条的 invitation_id
+	Limit  int  `p:"limit" d:"50" v:"between:1,200#条数应在1到200之间"`
+}
+
+type ListInvitationsRes struct {
+}
+
+type RevokeReq struct {
+	g.Meta       `path:"/api/admin/invitations/{id}/revoke" method:"post"`
+	InvitationID uint `p:"id" v:"required"`
+}
+
+type RevokeRes struct {
+}
+
+type Admin struct {
+	repo        InvitationRepository
+	invitations *Invitations
+	userLogger  logs.Logger
+}
+
+func NewAdmin(repo InvitationRepository, invitations *Invitations, logger logs.Logger) *Admin {
+	return &Admin{
+		repo:        repo,
+		invitations: invitations,
+		userLogger:  logger,
+	}
+}
+
+// Issue 签发注册邀请，返回的 token 由管理员分发给被邀请人
+func (params *Admin) Issue(ctx context.Context, req *IssueReq) (res *IssueRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	adminID := r.GetCtxVar("userid").Uint()
+	invitation, err := params.invitations.Issue(ctx, adminID, req.Email, req.Note, req.MaxUses, req.ExpireDays)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "invitation issued",
+		"data":    invitation,
+	})
+	return nil, nil
+}
+
+func (params *Admin) List(ctx context.Context, req *ListInvitationsReq) (res *ListInvitationsRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	invitations, err := params.repo.List(req.Before, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    invitations,
+	})
+	return nil, nil
+}
+
+// Revoke 作废邀请，已注册的用户不受影响
+func (params *Admin) Revoke(ctx context.Context, req *RevokeReq) (res *RevokeRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	if err = params.repo.Revoke(req.InvitationID, time.Now()); err != nil {
+		if errors.Is(err, ErrInvitationNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "邀请不存在")
+		}
+		return nil, err
+	}
+	params.userLogger.Info(ctx, "Invitation revoked:", req.InvitationID, "by:", r.GetCtxVar("userid").String())
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "invitation revoked",
+		"data":    nil,
+	})
+	return nil, nil
+}
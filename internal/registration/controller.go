@@ -0,0 +1,39 @@
+package registration
+
+import (
+	"context"
+
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+type ModeReq struct {
+	g.Meta `path:"/user/registration" method:"get"`
+}
+
+type ModeRes struct {
+}
+
+// Controller 向前端公开当前注册模式，便于在提交前展示邀请码输入框等
+type Controller struct {
+	gate *Gate
+}
+
+func NewController(gate *Gate) *Controller {
+	return &Controller{gate: gate}
+}
+
+func (c *Controller) Mode(ctx context.Context, req *ModeReq) (res *ModeRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	current := c.gate.settings.Load()
+	data := g.Map{"mode": current.mode}
+	if current.mode == ModeDomain {
+		data["allowed_domains"] = current.domains
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    data,
+	})
+	return nil, nil
+}
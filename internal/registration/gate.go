@@ -0,0 +1,183 @@
+package registration
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
+	config "usergrowth/configs"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/user"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// 注册模式
+const (
+	ModeOpen   = "open"   // 开放注册
+	ModeInvite = "invite" // 必须携带有效的邀请凭证
+	ModeDomain = "domain" // 邮箱域名在白名单内，或携带有效的邀请凭证
+	ModeClosed = "closed" // 暂停注册
+)
+
+// 拒绝原因，放在错误响应的 data.reason 中供前端展示对应文案
+const (
+	ReasonClosed           = "registration_closed"
+	ReasonInviteRequired   = "invite_required"
+	ReasonInviteInvalid    = "invite_invalid"
+	ReasonInviteExpired    = "invite_expired"
+	ReasonInviteUsedUp     = "invite_used_up"
+	ReasonInviteEmail      = "invite_email_mismatch"
+	ReasonEmailRequired    = "email_required"
+	ReasonDomainNotAllowed = "email_domain_not_allowed"
+)
+
+// 凭证来源返回的错误，Gate 据此给出拒绝原因
+var (
+	ErrTokenNotFound      = errors.New("invite token not found")
+	ErrTokenInvalid       = errors.New("invite token revoked")
+	ErrTokenExpired       = errors.New("invite token expired")
+	ErrTokenUsedUp        = errors.New("invite token used up")
+	ErrTokenEmailMismatch = errors.New("invite token bound to another email")
+)
+
+// Redeemer 是一种邀请凭证来源，凭证不属于该来源时返回 ErrTokenNotFound；
+// 占用成功时返回 release，注册失败时调用以归还
+type Redeemer interface {
+	Redeem(ctx context.Context, token, email string) (release func(), err error)
+}
+
+// settings 是配置重新加载时生成的不可变快照，请求中只读取快照，不与配置热更新竞争
+type settings struct {
+	mode    string
+	domains []string
+}
+
+// Gate 按当前注册模式实现 user.RegisterGate，模式随配置热更新即时生效
+type Gate struct {
+	settings  atomic.Pointer[settings]
+	redeemers []Redeemer
+	logger    logs.Logger
+}
+
+func NewGate(cfg *config.Config, logger logs.Logger) *Gate {
+	gate := &Gate{logger: logger}
+	gate.Reload(cfg)
+	return gate
+}
+
+// Reload 注册为配置热更新回调
+func (gate *Gate) Reload(cfg *config.Config) {
+	gate.settings.Store(&settings{
+		mode:    mode(cfg),
+		domains: slices.Clone(cfg.Registration.AllowedDomains),
+	})
+}
+
+// mode 规范化配置的模式，无法识别时按 closed 处理；
+// 旧配置 waitlist.enabled 在模式为 open 时等同于 invite
+func mode(cfg *config.Config) string {
+	mode := strings.ToLower(strings.TrimSpace(cfg.Registration.Mode))
+	switch mode {
+	case "", ModeOpen:
+		if cfg.Waitlist.Enabled {
+			return ModeInvite
+		}
+		return ModeOpen
+	case ModeInvite, ModeDomain, ModeClosed:
+		return mode
+	}
+	return ModeClosed
+}
+
+// AddRedeemer 需在启动阶段注册，按注册顺序查找凭证
+func (gate *Gate) AddRedeemer(r Redeemer) {
+	gate.redeemers = append(gate.redeemers, r)
+}
+
+// Mode 返回当前生效的模式
+func (gate *Gate) Mode() string {
+	return gate.settings.Load().mode
+}
+
+func reject(reason, message string) error {
+	return gerror.NewCode(gcode.WithCode(gcode.CodeNotAuthorized, g.Map{"reason": reason}), message)
+}
+
+// Reason 取出拒绝原因，不是注册拒绝时返回空字符串
+func Reason(err error) string {
+	detail, ok := gerror.Code(err).Detail().(g.Map)
+	if !ok {
+		return ""
+	}
+	reason, _ := detail["reason"].(string)
+	return reason
+}
+
+func (gate *Gate) Admit(ctx context.Context, req *user.RegisterReq) (func(), error) {
+	token := strings.TrimSpace(req.InviteToken)
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	current := gate.settings.Load()
+	switch current.mode {
+	case ModeOpen:
+		return nil, nil
+	case ModeInvite:
+		if token == "" {
+			return nil, reject(ReasonInviteRequired, "当前仅限受邀用户注册")
+		}
+		return gate.redeem(ctx, token, email)
+	case ModeDomain:
+		if token != "" {
+			return gate.redeem(ctx, token, email)
+		}
+		if email == "" {
+			return nil, reject(ReasonEmailRequired, "请填写邮箱")
+		}
+		if !domainAllowed(email, current.domains) {
+			return nil, reject(ReasonDomainNotAllowed, "该邮箱域名暂不支持注册")
+		}
+		return nil, nil
+	}
+	return nil, reject(ReasonClosed, "暂未开放注册")
+}
+
+func (gate *Gate) redeem(ctx context.Context, token, email string) (func(), error) {
+	for _, r := range gate.redeemers {
+		release, err := r.Redeem(ctx, token, email)
+		switch {
+		case err == nil:
+			return release, nil
+		case errors.Is(err, ErrTokenNotFound):
+			continue
+		case errors.Is(err, ErrTokenExpired):
+			return nil, reject(ReasonInviteExpired, "邀请凭证已过期")
+		case errors.Is(err, ErrTokenUsedUp):
+			return nil, reject(ReasonInviteUsedUp, "邀请凭证已被使用")
+		case errors.Is(err, ErrTokenEmailMismatch):
+			return nil, reject(ReasonInviteEmail, "邀请凭证与邮箱不匹配")
+		case errors.Is(err, ErrTokenInvalid):
+			return nil, reject(ReasonInviteInvalid, "邀请凭证无效")
+		default:
+			return nil, err
+		}
+	}
+	return nil, reject(ReasonInviteInvalid, "邀请凭证无效")
+}
+
+// domainAllowed 允许白名单域名及其子域名
+func domainAllowed(email string, domains []string) bool {
+	_, domain, ok := strings.Cut(email, "@")
+	if !ok || domain == "" {
+		return false
+	}
+	for _, allowed := range domains {
+		allowed = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(allowed), "@"))
+		if allowed != "" && (domain == allowed || strings.HasSuffix(domain, "."+allowed)) {
+			return true
+		}
+	}
+	return false
+}
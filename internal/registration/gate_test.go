@@ -0,0 +1,203 @@
+package registration
+
+import (
+	"context"
+	"testing"
+	"time"
+	config "usergrowth/configs"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/user"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRepo 在内存中实现与 invitationRepository.Consume 相同的条件
+type fakeRepo struct {
+	InvitationRepository
+	invitations []*Invitation
+	uses        []InvitationUse
+}
+
+func (f *fakeRepo) Create(invitation *Invitation) error {
+	invitation.InvitationID = uint(len(f.invitations) + 1)
+	f.invitations = append(f.invitations, invitation)
+	return nil
+}
+
+func (f *fakeRepo) FindByToken(token string) (*Invitation, error) {
+	for _, inv := range f.invitations {
+		if inv.Token == token {
+			out := *inv
+			return &out, nil
+		}
+	}
+	return nil, ErrInvitationNotFound
+}
+
+func (f *fakeRepo) Consume(token, email string, now time.Time) (bool, error) {
+	for _, inv := range f.invitations {
+		if inv.Token == token && inv.RevokedAt == nil && inv.Uses < inv.MaxUses &&
+			(inv.ExpiresAt == nil || inv.ExpiresAt.After(now)) && (inv.Email == "" || inv.Email == email) {
+			inv.Uses++
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeRepo) Restore(token string) error {
+	for _, inv := range f.invitations {
+		if inv.Token == token && inv.Uses > 0 {
+			inv.Uses--
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepo) RecordUse(token string, userID uint, at time.Time) error {
+	inv, err := f.FindByToken(token)
+	if err != nil {
+		return err
+	}
+	f.uses = append(f.uses, InvitationUse{UserID: userID, InvitationID: inv.InvitationID, CreatedAt: at})
+	return nil
+}
+
+// tokenSource 模拟候补名单等其他凭证来源
+type tokenSource map[string]error
+
+func (t tokenSource) Redeem(ctx context.Context, token, email string) (func(), error) {
+	err, ok := t[token]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return nil, err
+}
+
+var testNow = time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+
+func newTestGate(mode string) (*Gate, *Invitations, *fakeRepo, *config.Config) {
+	repo := &fakeRepo{}
+	invitations := NewInvitations(repo, logs.Nop())
+	invitations.now = func() time.Time { return testNow }
+	cfg := &config.Config{Registration: config.RegistrationConfig{Mode: mode, AllowedDomains: []string{"corp.example", "@partner.example"}}}
+	gate := NewGate(cfg, logs.Nop())
+	gate.AddRedeemer(invitations)
+	gate.AddRedeemer(tokenSource{"waitlist-ok": nil, "waitlist-expired": ErrTokenExpired})
+	return gate, invitations, repo, cfg
+}
+
+func admit(gate *Gate, email, token string) (func(), string) {
+	release, err := gate.Admit(context.Background(), &user.RegisterReq{Email: email, InviteToken: token})
+	if err != nil && gerror.Code(err).Code() != gcode.CodeNotAuthorized.Code() {
+		return release, "unexpected: " + err.Error()
+	}
+	return release, Reason(err)
+}
+
+func TestModes(t *testing.T) {
+	gate, _, _, cfg := newTestGate(ModeOpen)
+	_, reason := admit(gate, "", "")
+	assert.Equal(t, "", reason)
+
+	// 修改配置后需重新加载才生效
+	cfg.Registration.Mode = ModeClosed
+	_, reason = admit(gate, "a@corp.example", "waitlist-ok")
+	assert.Equal(t, "", reason)
+	gate.Reload(cfg)
+	_, reason = admit(gate, "a@corp.example", "waitlist-ok")
+	assert.Equal(t, ReasonClosed, reason)
+
+	// 无法识别的模式按关闭处理
+	cfg.Registration.Mode = "invite-only"
+	gate.Reload(cfg)
+	assert.Equal(t, ModeClosed, gate.Mode())
+	cfg.Registration.Mode = " Invite "
+	gate.Reload(cfg)
+	assert.Equal(t, ModeInvite, gate.Mode())
+
+	_, reason = admit(gate, "a@x.com", "")
+	assert.Equal(t, ReasonInviteRequired, reason)
+	_, reason = admit(gate, "a@x.com", "bogus")
+	assert.Equal(t, ReasonInviteInvalid, reason)
+	_, reason = admit(gate, "a@x.com", "waitlist-ok")
+	assert.Equal(t, "", reason)
+	_, reason = admit(gate, "a@x.com", "waitlist-expired")
+	assert.Equal(t, ReasonInviteExpired, reason)
+
+	cfg.Registration.Mode = ModeDomain
+	gate.Reload(cfg)
+	_, reason = admit(gate, "", "")
+	assert.Equal(t, ReasonEmailRequired, reason)
+	_, reason = admit(gate, "A@Corp.Example", "")
+	assert.Equal(t, "", reason)
+	_, reason = admit(gate, "a@eu.partner.example", "")
+	assert.Equal(t, "", reason)
+	_, reason = admit(gate, "a@evilcorp.example", "")
+	assert.Equal(t, ReasonDomainNotAllowed, reason)
+	// 域名不在白名单时可以凭邀请注册
+	_, reason = admit(gate, "a@evilcorp.example", "waitlist-ok")
+	assert.Equal(t, "", reason)
+}
+
+func TestWaitlistEnabledAlias(t *testing.T) {
+	gate, _, _, cfg := newTestGate(ModeOpen)
+	cfg.Waitlist.Enabled = true
+	gate.Reload(cfg)
+	assert.Equal(t, ModeInvite, gate.Mode())
+
+	// 显式配置的其它模式优先
+	cfg.Registration.Mode = ModeDomain
+	gate.Reload(cfg)
+	assert.Equal(t, ModeDomain, gate.Mode())
+}
+
+func TestInvitationRedeem(t *testing.T) {
+	gate, invitations, repo, _ := newTestGate(ModeInvite)
+	ctx := context.Background()
+
+	multi, err := invitations.Issue(ctx, 1, "", "launch", 2, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	release, reason := admit(gate, "a@x.com", multi.Token)
+	assert.Equal(t, "", reason)
+	_, reason = admit(gate, "b@x.com", multi.Token)
+	assert.Equal(t, "", reason)
+	_, reason = admit(gate, "c@x.com", multi.Token)
+	assert.Equal(t, ReasonInviteUsedUp, reason)
+	// 注册失败归还一次
+	release()
+	_, reason = admit(gate, "c@x.com", multi.Token)
+	assert.Equal(t, "", reason)
+
+	bound, _ := invitations.Issue(ctx, 1, " VIP@x.com ", "", 1, 3)
+	assert.Equal(t, testNow.AddDate(0, 0, 3), *bound.ExpiresAt)
+	_, reason = admit(gate, "other@x.com", bound.Token)
+	assert.Equal(t, ReasonInviteEmail, reason)
+
+	invitations.now = func() time.Time { return testNow.AddDate(0, 0, 3) }
+	_, reason = admit(gate, "vip@x.com", bound.Token)
+	assert.Equal(t, ReasonInviteExpired, reason)
+
+	revoked, _ := invitations.Issue(ctx, 1, "", "", 5, 0)
+	at := testNow
+	repo.invitations[revoked.InvitationID-1].RevokedAt = &at
+	_, reason = admit(gate, "a@x.com", revoked.Token)
+	assert.Equal(t, ReasonInviteInvalid, reason)
+
+	invitations.OnRegister(ctx, &user.Registration{UserID: 9, InviteToken: multi.Token, CreatedAt: testNow})
+	invitations.OnRegister(ctx, &user.Registration{UserID: 10, InviteToken: "waitlist-ok", CreatedAt: testNow})
+	assert.Equal(t, []InvitationUse{{UserID: 9, InvitationID: multi.InvitationID, CreatedAt: testNow}}, repo.uses)
+}
+
+func TestDomainAllowed(t *testing.T) {
+	domains := []string{"corp.example"}
+	assert.True(t, domainAllowed("a@corp.example", domains))
+	assert.True(t, domainAllowed("a@mail.corp.example", domains))
+	assert.False(t, domainAllowed("a@corp.example.io", domains))
+	assert.False(t, domainAllowed("corp.example", domains))
+	assert.False(t, domainAllowed("a@corp.example", nil))
+}
@@ -0,0 +1,101 @@
+package registration
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/user"
+)
+
+const tokenAttempts = 3
+
+func newToken() string {
+	buf := make([]byte, 24)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// Invitations 管理管理员签发的邀请，作为 Gate 的凭证来源与注册钩子
+type Invitations struct {
+	repo   InvitationRepository
+	now    func() time.Time
+	logger logs.Logger
+}
+
+func NewInvitations(repo InvitationRepository, logger logs.Logger) *Invitations {
+	return &Invitations{repo: repo, now: time.Now, logger: logger}
+}
+
+// Issue 签发邀请，expireDays 为 0 表示永不过期
+func (s *Invitations) Issue(ctx context.Context, adminID uint, email, note string, maxUses, expireDays int) (*Invitation, error) {
+	invitation := &Invitation{
+		Email:     strings.ToLower(strings.TrimSpace(email)),
+		Note:      note,
+		MaxUses:   maxUses,
+		CreatedBy: adminID,
+	}
+	if expireDays > 0 {
+		at := s.now().AddDate(0, 0, expireDays)
+		invitation.ExpiresAt = &at
+	}
+	for range tokenAttempts {
+		invitation.Token = newToken()
+		err := s.repo.Create(invitation)
+		if err == nil {
+			s.logger.Info(ctx, "Invitation issued:", invitation.InvitationID, "by:", adminID)
+			return invitation, nil
+		}
+		if !errors.Is(err, ErrDuplicateToken) {
+			return nil, err
+		}
+	}
+	return nil, ErrDuplicateToken
+}
+
+// Redeem 实现 Redeemer，占用失败时查询邀请给出具体原因
+func (s *Invitations) Redeem(ctx context.Context, token, email string) (func(), error) {
+	now := s.now()
+	ok, err := s.repo.Consume(token, email, now)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return func() {
+			if err := s.repo.Restore(token); err != nil {
+				s.logger.Error(ctx, "invitation restore failed:", err.Error())
+			}
+		}, nil
+	}
+
+	invitation, err := s.repo.FindByToken(token)
+	if err != nil {
+		if errors.Is(err, ErrInvitationNotFound) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+	switch {
+	case invitation.RevokedAt != nil:
+		return nil, ErrTokenInvalid
+	case invitation.ExpiresAt != nil && !now.Before(*invitation.ExpiresAt):
+		return nil, ErrTokenExpired
+	case invitation.Email != "" && invitation.Email != email:
+		return nil, ErrTokenEmailMismatch
+	}
+	return nil, ErrTokenUsedUp
+}
+
+// OnRegister 订阅用户注册事件，记录使用邀请注册的用户；凭证来自其他来源时忽略，其他错误返回后由总线重试
+func (s *Invitations) OnRegister(ctx context.Context, reg *user.Registration) error {
+	if reg.InviteToken == "" {
+		return nil
+	}
+	if err := s.repo.RecordUse(reg.InviteToken, reg.UserID, reg.CreatedAt); err != nil && !errors.Is(err, ErrInvitationNotFound) {
+		return err
+	}
+	return nil
+}
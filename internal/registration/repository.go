@@ -0,0 +1,143 @@
+package registration
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrDuplicateToken     = errors.New("invitation token already exists")
+)
+
+// Invitation 是管理员签发的注册邀请，可限定邮箱，Uses 达到 MaxUses 后失效
+type Invitation struct {
+	InvitationID uint       `gorm:"primaryKey;autoIncrement" json:"invitation_id"`
+	Token        string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"token"`
+	Email        string     `gorm:"type:varchar(255);not null;default:''" json:"email"` // 为空表示任何邮箱可用
+	Note         string     `gorm:"type:varchar(255)" json:"note"`
+	MaxUses      int        `gorm:"not null" json:"max_uses"`
+	Uses         int        `gorm:"not null;default:0" json:"uses"`
+	ExpiresAt    *time.Time `json:"expires_at"` // 为空表示永不过期
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedBy    uint       `gorm:"not null" json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (Invitation) TableName() string {
+	return "registration_invitations"
+}
+
+// InvitationUse 记录使用邀请注册的用户
+type InvitationUse struct {
+	UserID       uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	InvitationID uint      `gorm:"not null;index" json:"invitation_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (InvitationUse) TableName() string {
+	return "registration_invitation_uses"
+}
+
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+type InvitationRepository interface {
+	Create(invitation *Invitation) error
+	FindByToken(token string) (*Invitation, error)
+	List(beforeID uint, limit int) ([]Invitation, error)
+	Revoke(invitationID uint, at time.Time) error
+	// Consume 在邀请可用时原子占用一次，不可用时返回 false，由调用方查询具体原因
+	Consume(token, email string, now time.Time) (bool, error)
+	// Restore 归还一次占用，用于注册失败
+	Restore(token string) error
+	RecordUse(token string, userID uint, at time.Time) error
+}
+
+func NewInvitationRepository(db *gorm.DB) InvitationRepository {
+	if err := db.AutoMigrate(&Invitation{}, &InvitationUse{}); err != nil {
+		panic("failed to migrate invitation tables")
+	}
+	return &invitationRepository{db: db}
+}
+
+func (repo *invitationRepository) Create(invitation *Invitation) error {
+	if err := repo.db.Create(invitation).Error; err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 { // Error 1062: Duplicate entry
+			return ErrDuplicateToken
+		}
+		return err
+	}
+	return nil
+}
+
+func (repo *invitationRepository) FindByToken(token string) (*Invitation, error) {
+	var invitation Invitation
+	if err := repo.db.Where("token = ?", token).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (repo *invitationRepository) List(beforeID uint, limit int) ([]Invitation, error) {
+	var invitations []Invitation
+	db := repo.db
+	if beforeID > 0 {
+		db = db.Where("invitation_id < ?", beforeID)
+	}
+	err := db.Order("invitation_id DESC").Limit(limit).Find(&invitations).Error
+	return invitations, err
+}
+
+func (repo *invitationRepository) Revoke(invitationID uint, at time.Time) error {
+	res := repo.db.Model(&Invitation{}).
+		Where("invitation_id = ? AND revoked_at IS NULL", invitationID).
+		Update("revoked_at", at)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		var count int64
+		if err := repo.db.Model(&Invitation{}).Where("invitation_id = ?", invitationID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrInvitationNotFound
+		}
+	}
+	return nil
+}
+
+func (repo *invitationRepository) Consume(token, email string, now time.Time) (bool, error) {
+	res := repo.db.Model(&Invitation{}).
+		Where("token = ? AND revoked_at IS NULL AND uses < max_uses", token).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Where("email = '' OR email = ?", email).
+		Update("uses", gorm.Expr("uses + 1"))
+	return res.RowsAffected > 0, res.Error
+}
+
+func (repo *invitationRepository) Restore(token string) error {
+	return repo.db.Model(&Invitation{}).
+		Where("token = ? AND uses > 0", token).
+		Update("uses", gorm.Expr("uses - 1")).Error
+}
+
+func (repo *invitationRepository) RecordUse(token string, userID uint, at time.Time) error {
+	invitation, err := repo.FindByToken(token)
+	if err != nil {
+		return err
+	}
+	// 注册事件可能重复投递，已记录时忽略
+	return repo.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&InvitationUse{UserID: userID, InvitationID: invitation.InvitationID, CreatedAt: at}).Error
+}
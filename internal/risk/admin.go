@@ -0,0 +1,141 @@
+package risk
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+type ListHoldsReq struct {
+	g.Meta `path:"/api/admin/risk/holds" method:"get"`
+	Status string `p:"status" d:"pending" v:"in:pending,released,rejected#状态应为pending、released或rejected"`
+	After  uint   `p:"after"` // 上一页最后一条的 hold_id
+	Limit  int    `p:"limit" d:"50" v:"between:1,200#条数应在1到200之间"`
+}
+
+type ListHoldsRes struct {
+}
+
+type ApproveHoldReq struct {
+	g.Meta `path:"/api/admin/risk/holds/{id}/approve" method:"post"`
+	HoldID uint   `p:"id" v:"required"`
+	Note   string `json:"note" v:"max-length:1000"`
+}
+
+type ApproveHoldRes struct {
+}
+
+type RejectHoldReq struct {
+	g.Meta `path:"/api/admin/risk/holds/{id}/reject" method:"post"`
+	HoldID uint   `p:"id" v:"required"`
+	Note   string `json:"note" v:"required|max-length:1000#驳回原因不能为空|驳回原因过长"`
+}
+
+type RejectHoldRes struct {
+}
+
+type UserRiskReq struct {
+	g.Meta `path:"/api/admin/risk/users/{id}" method:"get"`
+	UserID uint `p:"id" v:"required"`
+}
+
+type UserRiskRes struct {
+}
+
+type Admin struct {
+	repo       RiskRepository
+	service    *Service
+	userLogger logs.Logger
+}
+
+func NewAdmin(repo RiskRepository, service *Service, logger logs.Logger) *Admin {
+	return &Admin{
+		repo:       repo,
+		service:    service,
+		userLogger: logger,
+	}
+}
+
+// Holds 是待审核队列，按冻结时间先后排列
+func (params *Admin) Holds(ctx context.Context, req *ListHoldsReq) (res *ListHoldsRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	holds, err := params.repo.ListHolds(req.Status, req.After, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    holds,
+	})
+	return nil, nil
+}
+
+func (params *Admin) review(ctx context.Context, holdID uint, approve bool, note string) error {
+	ctx, span := gtrace.NewSpan(ctx, "Risk.Review")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+	operator, _ := strconv.ParseUint(r.GetCtxVar("userid").String(), 10, 64)
+
+	hold, err := params.service.Review(ctx, holdID, approve, uint(operator), note)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrHoldNotFound):
+			return gerror.NewCode(gcode.CodeValidationFailed, "冻结记录不存在")
+		case errors.Is(err, ErrHoldReviewed):
+			return gerror.NewCode(gcode.CodeValidationFailed, "该记录已审核")
+		}
+		return err
+	}
+
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "hold " + hold.Status,
+		"data":    hold,
+	})
+	return nil
+}
+
+func (params *Admin) Approve(ctx context.Context, req *ApproveHoldReq) (res *ApproveHoldRes, err error) {
+	return nil, params.review(ctx, req.HoldID, true, req.Note)
+}
+
+func (params *Admin) Reject(ctx context.Context, req *RejectHoldReq) (res *RejectHoldRes, err error) {
+	return nil, params.review(ctx, req.HoldID, false, req.Note)
+}
+
+// User 展示用户的评估结果、决策日志以及因其冻结的奖励
+func (params *Admin) User(ctx context.Context, req *UserRiskReq) (res *UserRiskRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	assessment, err := params.repo.FindAssessment(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	decisions, err := params.repo.ListDecisions(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	holds, err := params.repo.ListHoldsBySubject(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data": g.Map{
+			"assessment": assessment,
+			"decisions":  decisions,
+			"holds":      holds,
+		},
+	})
+	return nil, nil
+}
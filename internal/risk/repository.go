@@ -0,0 +1,192 @@
+package risk
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrHoldNotFound  = errors.New("reward hold not found")
+	ErrHoldReviewed  = errors.New("reward hold already reviewed")
+	ErrDuplicateHold = errors.New("reward hold already recorded")
+)
+
+// 冻结奖励的审核状态
+const (
+	HoldPending  = "pending"
+	HoldReleased = "released"
+	HoldRejected = "rejected"
+)
+
+// 决策动作，Operator 为 0 表示系统自动决策
+const (
+	ActionPass    = "pass"
+	ActionFlag    = "flag"
+	ActionHold    = "hold"
+	ActionRelease = "release"
+	ActionReject  = "reject"
+)
+
+// Assessment 是用户注册时的风险评估结果
+type Assessment struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Score     int       `gorm:"not null" json:"score"`
+	Flagged   bool      `gorm:"not null;index" json:"flagged"`
+	Signals   string    `gorm:"type:text" json:"signals"` // []Signal 的 JSON
+	IP        string    `gorm:"type:varchar(64)" json:"ip"`
+	Subnet    string    `gorm:"type:varchar(64);index" json:"subnet"`
+	DeviceID  string    `gorm:"type:varchar(128);index" json:"device_id"`
+	InviterID uint      `gorm:"not null;default:0" json:"inviter_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Assessment) TableName() string {
+	return "risk_assessments"
+}
+
+// Hold 是被冻结待审核的奖励，SubjectID 是触发风控的用户，UserID 是奖励接收人
+type Hold struct {
+	HoldID     uint       `gorm:"primaryKey;autoIncrement" json:"hold_id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	SubjectID  uint       `gorm:"not null;index" json:"subject_id"`
+	Points     int64      `gorm:"not null" json:"points"`
+	Reason     string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_hold_reason_ref" json:"reason"`
+	RefID      string     `gorm:"type:varchar(128);not null;uniqueIndex:idx_hold_reason_ref" json:"ref_id"`
+	Status     string     `gorm:"type:varchar(16);not null;index" json:"status"`
+	ReviewedBy uint       `gorm:"not null;default:0" json:"reviewed_by"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (Hold) TableName() string {
+	return "risk_holds"
+}
+
+// Decision 记录每一次风控决策及其依据
+type Decision struct {
+	DecisionID uint      `gorm:"primaryKey;autoIncrement" json:"decision_id"`
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	HoldID     uint      `gorm:"not null;default:0" json:"hold_id"`
+	Action     string    `gorm:"type:varchar(16);not null" json:"action"`
+	Score      int       `gorm:"not null" json:"score"`
+	Reasons    string    `gorm:"type:text" json:"reasons"`
+	Operator   uint      `gorm:"not null;default:0" json:"operator"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (Decision) TableName() string {
+	return "risk_decisions"
+}
+
+type riskRepository struct {
+	db *gorm.DB
+}
+
+type RiskRepository interface {
+	SaveAssessment(assessment *Assessment) error
+	// FindAssessment 没有评估记录时返回 nil
+	FindAssessment(userID uint) (*Assessment, error)
+	CreateHold(hold *Hold) error
+	FindHold(holdID uint) (*Hold, error)
+	ListHolds(status string, afterID uint, limit int) ([]Hold, error)
+	ListHoldsBySubject(subjectID uint) ([]Hold, error)
+	// Review 只更新仍在待审核状态的冻结记录，已审核过返回 ErrHoldReviewed
+	Review(holdID uint, status string, operator uint, at time.Time) error
+	// Reopen 在放行发放失败时把冻结记录恢复为待审核
+	Reopen(holdID uint) error
+	LogDecision(decision *Decision) error
+	ListDecisions(userID uint) ([]Decision, error)
+}
+
+func NewRiskRepository(db *gorm.DB) RiskRepository {
+	if err := db.AutoMigrate(&Assessment{}, &Hold{}, &Decision{}); err != nil {
+		panic("failed to migrate risk tables")
+	}
+	return &riskRepository{db: db}
+}
+
+func (repo *riskRepository) SaveAssessment(assessment *Assessment) error {
+	return repo.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(assessment).Error
+}
+
+func (repo *riskRepository) FindAssessment(userID uint) (*Assessment, error) {
+	var assessment Assessment
+	if err := repo.db.Where("user_id = ?", userID).First(&assessment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &assessment, nil
+}
+
+func (repo *riskRepository) CreateHold(hold *Hold) error {
+	if err := repo.db.Create(hold).Error; err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 { // Error 1062: Duplicate entry
+			return ErrDuplicateHold
+		}
+		return err
+	}
+	return nil
+}
+
+func (repo *riskRepository) FindHold(holdID uint) (*Hold, error) {
+	var hold Hold
+	if err := repo.db.First(&hold, holdID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHoldNotFound
+		}
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (repo *riskRepository) ListHolds(status string, afterID uint, limit int) ([]Hold, error) {
+	var holds []Hold
+	err := repo.db.Where("status = ? AND hold_id > ?", status, afterID).Order("hold_id").Limit(limit).Find(&holds).Error
+	return holds, err
+}
+
+func (repo *riskRepository) ListHoldsBySubject(subjectID uint) ([]Hold, error) {
+	var holds []Hold
+	err := repo.db.Where("subject_id = ?", subjectID).Order("hold_id").Find(&holds).Error
+	return holds, err
+}
+
+func (repo *riskRepository) Review(holdID uint, status string, operator uint, at time.Time) error {
+	res := repo.db.Model(&Hold{}).Where("hold_id = ? AND status = ?", holdID, HoldPending).Updates(map[string]any{
+		"status":      status,
+		"reviewed_by": operator,
+		"reviewed_at": at,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrHoldReviewed
+	}
+	return nil
+}
+
+func (repo *riskRepository) Reopen(holdID uint) error {
+	return repo.db.Model(&Hold{}).Where("hold_id = ?", holdID).Updates(map[string]any{
+		"status":      HoldPending,
+		"reviewed_by": 0,
+		"reviewed_at": nil,
+	}).Error
+}
+
+func (repo *riskRepository) LogDecision(decision *Decision) error {
+	return repo.db.Create(decision).Error
+}
+
+func (repo *riskRepository) ListDecisions(userID uint) ([]Decision, error) {
+	var decisions []Decision
+	err := repo.db.Where("user_id = ?", userID).Order("decision_id").Find(&decisions).Error
+	return decisions, err
+}
@@ -0,0 +1,290 @@
+package risk
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/points"
+	"usergrowth/internal/user"
+
+	"github.com/gogf/gf/v2/net/gtrace"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// 设备与账号的关联保留时长
+const deviceTTL = 30 * 24 * time.Hour
+
+func ipKey(ip string, now time.Time) string {
+	return "risk:reg:ip:" + ip + ":" + now.Format("2006010215")
+}
+
+func subnetKey(subnet string, now time.Time) string {
+	return "risk:reg:subnet:" + subnet + ":" + now.Format("20060102")
+}
+
+func deviceKey(deviceID string) string {
+	sum := sha1.Sum([]byte(deviceID))
+	return "risk:device:" + hex.EncodeToString(sum[:])
+}
+
+// InviteGraph 提供邀请关系，由 referral 模块实现
+type InviteGraph interface {
+	InviterOf(ctx context.Context, inviteeID uint) (uint, error)
+	CountInviteesSince(ctx context.Context, inviterID uint, since time.Time) (int64, error)
+}
+
+// Listener 在注册评估完成后被同步调用，如按评估结果发放邀请奖励
+type Listener func(ctx context.Context, reg *user.Registration, assessment *Assessment)
+
+type Service struct {
+	repo        RiskRepository
+	rdb         goredis.Cmdable
+	graph       InviteGraph
+	users       user.UserRepository
+	points      *points.Service
+	thresholds  Thresholds
+	reviewScore int
+	disposable  map[string]struct{}
+	listeners   []Listener
+	logger      logs.Logger
+}
+
+func NewService(repo RiskRepository, rdb goredis.Cmdable, graph InviteGraph, users user.UserRepository, pointsService *points.Service,
+	thresholds Thresholds, reviewScore int, disposableDomains []string, logger logs.Logger) *Service {
+	return &Service{
+		repo:        repo,
+		rdb:         rdb,
+		graph:       graph,
+		users:       users,
+		points:      pointsService,
+		thresholds:  thresholds,
+		reviewScore: reviewScore,
+		disposable:  DisposableDomains(disposableDomains),
+		logger:      logger,
+	}
+}
+
+// OnAssessed 需在启动阶段注册
+func (s *Service) OnAssessed(l Listener) {
+	s.listeners = append(s.listeners, l)
+}
+
+// OnRegister 注册为用户注册钩子，需排在建立邀请关系的钩子之后
+func (s *Service) OnRegister(ctx context.Context, reg *user.Registration) {
+	ctx, span := gtrace.NewSpan(ctx, "Risk.Assess")
+	defer span.End()
+
+	assessment, err := s.assess(ctx, reg, time.Now())
+	if err != nil {
+		// 评估失败不影响注册，奖励转人工审核并记录日志
+		s.logger.Error(ctx, "risk assess failed:", reg.UserID, err.Error())
+		assessment = s.fallback(ctx, reg)
+	}
+	for _, l := range s.listeners {
+		l(ctx, reg, assessment)
+	}
+}
+
+// fallback 在评估失败时返回标记待审核的评估，邀请关系仍从邀请图读取，避免奖励丢失
+func (s *Service) fallback(ctx context.Context, reg *user.Registration) *Assessment {
+	assessment := &Assessment{UserID: reg.UserID, Signals: "[]", IP: reg.IP, Subnet: Subnet(reg.IP), DeviceID: reg.DeviceID, Flagged: true}
+	inviterID, err := s.graph.InviterOf(ctx, reg.UserID)
+	if err != nil {
+		s.logger.Error(ctx, "risk fallback inviter lookup failed:", reg.UserID, err.Error())
+	}
+	assessment.InviterID = inviterID
+	// 保存后 Grant 才会按标记冻结奖励
+	if err = s.repo.SaveAssessment(assessment); err != nil {
+		s.logger.Error(ctx, "risk fallback assessment save failed:", reg.UserID, err.Error())
+	}
+	s.log(ctx, &Decision{UserID: reg.UserID, Action: ActionFlag, Reasons: "assess failed"})
+	return assessment
+}
+
+func (s *Service) assess(ctx context.Context, reg *user.Registration, now time.Time) (*Assessment, error) {
+	facts, err := s.collect(ctx, reg, now)
+	if err != nil {
+		return nil, err
+	}
+	signals := Evaluate(facts, s.thresholds, s.disposable)
+	raw, err := json.Marshal(signals)
+	if err != nil {
+		return nil, err
+	}
+	assessment := &Assessment{
+		UserID:    reg.UserID,
+		Score:     Score(signals),
+		Signals:   string(raw),
+		IP:        reg.IP,
+		Subnet:    Subnet(reg.IP),
+		DeviceID:  reg.DeviceID,
+		InviterID: facts.InviterID,
+	}
+	assessment.Flagged = assessment.Score >= s.reviewScore
+	if err = s.repo.SaveAssessment(assessment); err != nil {
+		return nil, err
+	}
+
+	action := ActionPass
+	if assessment.Flagged {
+		action = ActionFlag
+	}
+	s.log(ctx, &Decision{UserID: reg.UserID, Action: action, Score: assessment.Score, Reasons: reasons(signals)})
+	return assessment, nil
+}
+
+// collect 更新注册计数并读取评估所需的数据
+func (s *Service) collect(ctx context.Context, reg *user.Registration, now time.Time) (*Facts, error) {
+	facts := &Facts{Email: reg.Email, UserAgent: reg.UserAgent}
+
+	pipe := s.rdb.TxPipeline()
+	var ipCount, subnetCount, deviceCount *goredis.IntCmd
+	if reg.IP != "" {
+		ipCount = pipe.Incr(ctx, ipKey(reg.IP, now))
+		pipe.Expire(ctx, ipKey(reg.IP, now), 2*time.Hour)
+	}
+	if subnet := Subnet(reg.IP); subnet != "" {
+		subnetCount = pipe.Incr(ctx, subnetKey(subnet, now))
+		pipe.Expire(ctx, subnetKey(subnet, now), 48*time.Hour)
+	}
+	if reg.DeviceID != "" {
+		pipe.SAdd(ctx, deviceKey(reg.DeviceID), reg.UserID)
+		deviceCount = pipe.SCard(ctx, deviceKey(reg.DeviceID))
+		pipe.Expire(ctx, deviceKey(reg.DeviceID), deviceTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	if ipCount != nil {
+		facts.IPHourly = ipCount.Val()
+	}
+	if subnetCount != nil {
+		facts.SubnetDaily = subnetCount.Val()
+	}
+	if deviceCount != nil {
+		facts.DeviceAccounts = deviceCount.Val()
+	}
+
+	inviterID, err := s.graph.InviterOf(ctx, reg.UserID)
+	if err != nil || inviterID == 0 {
+		return facts, err
+	}
+	facts.InviterID = inviterID
+	if facts.InviterRecent, err = s.graph.CountInviteesSince(ctx, inviterID, now.Add(-24*time.Hour)); err != nil {
+		return nil, err
+	}
+	inviter, err := s.users.FindUserByID(inviterID)
+	if err != nil {
+		return nil, err
+	}
+	facts.InviterAge = now.Sub(inviter.CreatedAt)
+	inviterAssessment, err := s.repo.FindAssessment(inviterID)
+	if err != nil {
+		return nil, err
+	}
+	facts.InviterFlagged = inviterAssessment != nil && inviterAssessment.Flagged
+	return facts, nil
+}
+
+func reasons(signals []Signal) string {
+	if len(signals) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(signals))
+	for _, sig := range signals {
+		parts = append(parts, sig.Name+": "+sig.Detail)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// log 写入决策日志，失败只记录不中断流程
+func (s *Service) log(ctx context.Context, decision *Decision) {
+	if err := s.repo.LogDecision(decision); err != nil {
+		s.logger.Error(ctx, "risk decision log failed:", decision.UserID, decision.Action, err.Error())
+		return
+	}
+	s.logger.Info(ctx, "Risk decision:", decision.Action, "userid:", decision.UserID, "hold:", decision.HoldID,
+		"score:", decision.Score, "reasons:", decision.Reasons)
+}
+
+// Grant 发放由 subjectID 的行为带来的奖励，subjectID 被标记时冻结奖励等待审核，返回是否被冻结
+func (s *Service) Grant(ctx context.Context, userID, subjectID uint, amount int64, reason, refID string) (bool, error) {
+	assessment, err := s.repo.FindAssessment(subjectID)
+	if err != nil {
+		return false, err
+	}
+	if assessment == nil || !assessment.Flagged {
+		_, err = s.points.Award(ctx, userID, amount, reason, refID)
+		return false, err
+	}
+
+	hold := &Hold{
+		UserID:    userID,
+		SubjectID: subjectID,
+		Points:    amount,
+		Reason:    reason,
+		RefID:     refID,
+		Status:    HoldPending,
+	}
+	if err = s.repo.CreateHold(hold); err != nil {
+		return false, err
+	}
+	s.log(ctx, &Decision{
+		UserID:  userID,
+		HoldID:  hold.HoldID,
+		Action:  ActionHold,
+		Score:   assessment.Score,
+		Reasons: "subject " + strconv.FormatUint(uint64(subjectID), 10) + " flagged",
+	})
+	return true, nil
+}
+
+// Review 审核冻结的奖励，approve 为 true 时放行并发放积分
+func (s *Service) Review(ctx context.Context, holdID uint, approve bool, operator uint, note string) (*Hold, error) {
+	hold, err := s.repo.FindHold(holdID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != HoldPending {
+		return nil, ErrHoldReviewed
+	}
+
+	status, action := HoldRejected, ActionReject
+	if approve {
+		status, action = HoldReleased, ActionRelease
+	}
+	now := time.Now()
+	if err = s.repo.Review(holdID, status, operator, now); err != nil {
+		return nil, err
+	}
+	if approve {
+		_, err = s.points.Award(ctx, hold.UserID, hold.Points, hold.Reason, hold.RefID)
+		if err != nil && !errors.Is(err, points.ErrDuplicateEntry) {
+			if reopenErr := s.repo.Reopen(holdID); reopenErr != nil {
+				s.logger.Error(ctx, "risk hold reopen failed:", holdID, reopenErr.Error())
+			}
+			return nil, err
+		}
+	}
+	hold.Status, hold.ReviewedBy, hold.ReviewedAt = status, operator, &now
+
+	score := 0
+	if assessment, err := s.repo.FindAssessment(hold.SubjectID); err == nil && assessment != nil {
+		score = assessment.Score
+	}
+	s.log(ctx, &Decision{
+		UserID:   hold.UserID,
+		HoldID:   hold.HoldID,
+		Action:   action,
+		Score:    score,
+		Reasons:  note,
+		Operator: operator,
+	})
+	return hold, nil
+}
@@ -0,0 +1,158 @@
+package risk
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 信号名称，写入评估结果与决策日志
+const (
+	SignalIPVelocity      = "ip_velocity"
+	SignalSubnetVelocity  = "subnet_velocity"
+	SignalSharedDevice    = "shared_device"
+	SignalDisposableEmail = "disposable_email"
+	SignalSuspiciousUA    = "suspicious_ua"
+	SignalInviterBurst    = "inviter_burst"
+	SignalFreshInviter    = "fresh_inviter"
+	SignalFlaggedInviter  = "flagged_inviter"
+)
+
+// 各信号的分值，总分封顶 100
+var weights = map[string]int{
+	SignalIPVelocity:      30,
+	SignalSubnetVelocity:  20,
+	SignalSharedDevice:    40,
+	SignalDisposableEmail: 30,
+	SignalSuspiciousUA:    25,
+	SignalInviterBurst:    25,
+	SignalFreshInviter:    15,
+	SignalFlaggedInviter:  30,
+}
+
+const maxScore = 100
+
+// 常见临时邮箱域名，配置中的 disposableDomains 会追加到此列表
+var builtinDisposableDomains = []string{
+	"10minutemail.com", "guerrillamail.com", "guerrillamail.net", "mailinator.com", "maildrop.cc",
+	"sharklasers.com", "temp-mail.org", "tempmail.com", "throwawaymail.com", "yopmail.com",
+	"trashmail.com", "getnada.com", "dispostable.com", "fakeinbox.com", "mohmal.com",
+	"mailnesia.com", "emailondeck.com", "moakt.com", "tempail.com", "burnermail.io",
+}
+
+// 自动化工具与无头浏览器的 UA 特征
+var suspiciousUAPattern = regexp.MustCompile(`(?i)(headless|phantomjs|selenium|webdriver|puppeteer|playwright|python-requests|python-urllib|aiohttp|curl/|wget/|go-http-client|okhttp|java/|libwww-perl|httpclient|scrapy|bot\b|spider|crawler)`)
+
+// Signal 是一条命中的风险信号
+type Signal struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+	Detail string `json:"detail"`
+}
+
+// Thresholds 是各信号的触发阈值
+type Thresholds struct {
+	IPHourly       int64         // 同一 IP 每小时注册数
+	SubnetDaily    int64         // 同一网段每天注册数
+	DeviceAccounts int64         // 同一设备关联的账号数
+	InviterDaily   int64         // 同一邀请人 24 小时内邀请数
+	FreshInviter   time.Duration // 邀请人注册时长小于该值视为新号
+}
+
+// Facts 是注册时采集到的原始数据，与信号判定分开便于测试
+type Facts struct {
+	IPHourly       int64
+	SubnetDaily    int64
+	DeviceAccounts int64
+	Email          string
+	UserAgent      string
+	InviterID      uint
+	InviterRecent  int64
+	InviterAge     time.Duration
+	InviterFlagged bool
+}
+
+// Evaluate 按阈值判定命中的信号
+func Evaluate(f *Facts, t Thresholds, disposable map[string]struct{}) []Signal {
+	var signals []Signal
+	hit := func(name, detail string) {
+		signals = append(signals, Signal{Name: name, Weight: weights[name], Detail: detail})
+	}
+
+	if t.IPHourly > 0 && f.IPHourly > t.IPHourly {
+		hit(SignalIPVelocity, fmt.Sprintf("%d registrations from ip in the last hour", f.IPHourly))
+	}
+	if t.SubnetDaily > 0 && f.SubnetDaily > t.SubnetDaily {
+		hit(SignalSubnetVelocity, fmt.Sprintf("%d registrations from subnet today", f.SubnetDaily))
+	}
+	if t.DeviceAccounts > 0 && f.DeviceAccounts > t.DeviceAccounts {
+		hit(SignalSharedDevice, fmt.Sprintf("device shared by %d accounts", f.DeviceAccounts))
+	}
+	if domain := emailDomain(f.Email); domain != "" {
+		if _, ok := disposable[domain]; ok {
+			hit(SignalDisposableEmail, "disposable email domain "+domain)
+		}
+	}
+	if ua := strings.TrimSpace(f.UserAgent); ua == "" {
+		hit(SignalSuspiciousUA, "empty user agent")
+	} else if m := suspiciousUAPattern.FindString(ua); m != "" {
+		hit(SignalSuspiciousUA, "automation user agent "+strings.ToLower(m))
+	}
+	if f.InviterID != 0 {
+		if t.InviterDaily > 0 && f.InviterRecent > t.InviterDaily {
+			hit(SignalInviterBurst, fmt.Sprintf("inviter %d invited %d users in 24h", f.InviterID, f.InviterRecent))
+		}
+		if t.FreshInviter > 0 && f.InviterAge < t.FreshInviter {
+			hit(SignalFreshInviter, fmt.Sprintf("inviter %d registered %s ago", f.InviterID, f.InviterAge.Truncate(time.Minute)))
+		}
+		if f.InviterFlagged {
+			hit(SignalFlaggedInviter, fmt.Sprintf("inviter %d is flagged", f.InviterID))
+		}
+	}
+	return signals
+}
+
+// Score 累加信号分值
+func Score(signals []Signal) int {
+	score := 0
+	for _, s := range signals {
+		score += s.Weight
+	}
+	return min(score, maxScore)
+}
+
+// DisposableDomains 合并内置与配置的临时邮箱域名
+func DisposableDomains(extra []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(builtinDisposableDomains)+len(extra))
+	for _, d := range builtinDisposableDomains {
+		set[d] = struct{}{}
+	}
+	for _, d := range extra {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			set[d] = struct{}{}
+		}
+	}
+	return set
+}
+
+func emailDomain(email string) string {
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[at+1:]))
+}
+
+// Subnet 返回 IPv4 的 /24 或 IPv6 的 /64 网段，无法解析时返回空
+func Subnet(ip string) string {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
@@ -0,0 +1,84 @@
+package risk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testThresholds = Thresholds{
+	IPHourly:       3,
+	SubnetDaily:    20,
+	DeviceAccounts: 2,
+	InviterDaily:   10,
+	FreshInviter:   24 * time.Hour,
+}
+
+const normalUA = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+
+func names(signals []Signal) []string {
+	out := make([]string, 0, len(signals))
+	for _, s := range signals {
+		out = append(out, s.Name)
+	}
+	return out
+}
+
+func TestEvaluateCleanRegistration(t *testing.T) {
+	f := &Facts{IPHourly: 1, SubnetDaily: 5, DeviceAccounts: 1, Email: "alice@gmail.com", UserAgent: normalUA}
+	signals := Evaluate(f, testThresholds, DisposableDomains(nil))
+	assert.Empty(t, signals)
+	assert.Equal(t, 0, Score(signals))
+}
+
+func TestEvaluateFarmedRegistration(t *testing.T) {
+	f := &Facts{
+		IPHourly:       8,
+		SubnetDaily:    50,
+		DeviceAccounts: 6,
+		Email:          "x1@Mailinator.com",
+		UserAgent:      "python-requests/2.31",
+		InviterID:      7,
+		InviterRecent:  30,
+		InviterAge:     2 * time.Hour,
+		InviterFlagged: true,
+	}
+	signals := Evaluate(f, testThresholds, DisposableDomains(nil))
+	assert.ElementsMatch(t, []string{
+		SignalIPVelocity, SignalSubnetVelocity, SignalSharedDevice, SignalDisposableEmail,
+		SignalSuspiciousUA, SignalInviterBurst, SignalFreshInviter, SignalFlaggedInviter,
+	}, names(signals))
+	assert.Equal(t, maxScore, Score(signals))
+}
+
+func TestEvaluateThresholdsAreExclusive(t *testing.T) {
+	f := &Facts{IPHourly: 3, SubnetDaily: 20, DeviceAccounts: 2, UserAgent: normalUA, InviterID: 7, InviterRecent: 10, InviterAge: 24 * time.Hour}
+	assert.Empty(t, Evaluate(f, testThresholds, DisposableDomains(nil)))
+}
+
+func TestEvaluateUserAgent(t *testing.T) {
+	for ua, want := range map[string]bool{
+		"":                                       true,
+		"curl/8.4.0":                             true,
+		"Mozilla/5.0 HeadlessChrome/120.0":       true,
+		"okhttp/4.12.0":                          true,
+		normalUA:                                 false,
+		"Mozilla/5.0 (Linux; Android 14) Chrome": false,
+	} {
+		signals := Evaluate(&Facts{UserAgent: ua}, testThresholds, nil)
+		assert.Equal(t, want, len(signals) == 1, ua)
+	}
+}
+
+func TestDisposableDomainsExtra(t *testing.T) {
+	set := DisposableDomains([]string{" Spam.Example "})
+	signals := Evaluate(&Facts{Email: "a@spam.example", UserAgent: normalUA}, testThresholds, set)
+	assert.Equal(t, []string{SignalDisposableEmail}, names(signals))
+}
+
+func TestSubnet(t *testing.T) {
+	assert.Equal(t, "203.0.113.0/24", Subnet("203.0.113.57"))
+	assert.Equal(t, "2001:db8:1:2::/64", Subnet("2001:db8:1:2:3:4:5:6"))
+	assert.Equal(t, "", Subnet("unknown"))
+}
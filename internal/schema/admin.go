@@ -0,0 +1,132 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+// 单次报表允许的最大日期跨度
+const maxReportDays = 93
+
+type RegisterSchemaReq struct {
+	g.Meta `path:"/api/admin/event-schemas" method:"post"`
+	Name   string `json:"name" v:"required|regex:^[a-z][a-z0-9_]{0,63}$#事件名不能为空|事件名不合法"`
+	Schema any    `json:"schema" v:"required#schema不能为空"` // JSON Schema 对象
+}
+
+type RegisterSchemaRes struct {
+}
+
+type ListSchemaReq struct {
+	g.Meta `path:"/api/admin/event-schemas" method:"get"`
+	Name   string `p:"name"` // 为空时列出所有事件
+}
+
+type ListSchemaRes struct {
+}
+
+type SchemaReportReq struct {
+	g.Meta `path:"/api/admin/event-schemas/report" method:"get"`
+	Start  string `p:"start" v:"required|date#开始日期不能为空|开始日期格式应为YYYY-MM-DD"`
+	End    string `p:"end" v:"required|date#结束日期不能为空|结束日期格式应为YYYY-MM-DD"`
+}
+
+type SchemaReportRes struct {
+}
+
+type Admin struct {
+	repo       SchemaRepository
+	registry   *Registry
+	userLogger logs.Logger
+}
+
+func NewAdmin(repo SchemaRepository, registry *Registry, logger logs.Logger) *Admin {
+	return &Admin{
+		repo:       repo,
+		registry:   registry,
+		userLogger: logger,
+	}
+}
+
+// Register 登记事件的新版本 schema，新上报的事件默认按最新版本校验
+func (params *Admin) Register(ctx context.Context, req *RegisterSchemaReq) (res *RegisterSchemaRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "EventSchema.Register")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	raw, err := json.Marshal(req.Schema)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "schema格式不正确")
+	}
+	adminID := r.GetCtxVar("userid").Uint()
+	def, created, err := params.registry.Register(ctx, req.Name, raw, adminID)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSchema) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, err.Error())
+		}
+		return nil, err
+	}
+	message := "schema registered"
+	if !created {
+		message = "schema unchanged"
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": message,
+		"data":    def,
+	})
+	return nil, nil
+}
+
+func (params *Admin) List(ctx context.Context, req *ListSchemaReq) (res *ListSchemaRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	schemas, err := params.repo.List(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    schemas,
+	})
+	return nil, nil
+}
+
+// Report 按天列出未注册事件与校验失败次数，日期区间包含两端
+func (params *Admin) Report(ctx context.Context, req *SchemaReportReq) (res *SchemaReportRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	start, err := time.ParseInLocation(time.DateOnly, req.Start, time.Local)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "开始日期格式应为YYYY-MM-DD")
+	}
+	end, err := time.ParseInLocation(time.DateOnly, req.End, time.Local)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "结束日期格式应为YYYY-MM-DD")
+	}
+	end = end.AddDate(0, 0, 1)
+	if !end.After(start) || end.Sub(start) > maxReportDays*24*time.Hour {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "日期区间不合法")
+	}
+
+	rows, err := params.registry.Report(start, end)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    rows,
+	})
+	return nil, nil
+}
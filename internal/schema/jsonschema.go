@@ -0,0 +1,295 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidSchema = errors.New("invalid schema")
+
+var validTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true,
+}
+
+// Schema 是 JSON Schema 的子集，覆盖事件属性常用的关键字；
+// 未支持的关键字在编译时报错，避免管理员误以为约束已生效
+type Schema struct {
+	Types                []string
+	Properties           map[string]*Schema
+	Required             []string
+	AdditionalProperties *bool
+	Enum                 []any
+	Minimum              *float64
+	Maximum              *float64
+	MinLength            *int
+	MaxLength            *int
+	Pattern              *regexp.Regexp
+	Items                *Schema
+	MinItems             *int
+	MaxItems             *int
+}
+
+type rawSchema struct {
+	Meta                 string                     `json:"$schema"`
+	ID                   string                     `json:"$id"`
+	Title                string                     `json:"title"`
+	Description          string                     `json:"description"`
+	Type                 json.RawMessage            `json:"type"`
+	Properties           map[string]json.RawMessage `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties *bool                      `json:"additionalProperties"`
+	Enum                 []any                      `json:"enum"`
+	Minimum              *float64                   `json:"minimum"`
+	Maximum              *float64                   `json:"maximum"`
+	MinLength            *int                       `json:"minLength"`
+	MaxLength            *int                       `json:"maxLength"`
+	Pattern              string                     `json:"pattern"`
+	Items                json.RawMessage            `json:"items"`
+	MinItems             *int                       `json:"minItems"`
+	MaxItems             *int                       `json:"maxItems"`
+}
+
+// Compile 解析事件属性的 schema，根节点必须是 object
+func Compile(raw []byte) (*Schema, error) {
+	s, err := compile(raw, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Types) != 1 || s.Types[0] != "object" {
+		return nil, fmt.Errorf("%w: root type must be object", ErrInvalidSchema)
+	}
+	return s, nil
+}
+
+func compile(raw []byte, path string) (*Schema, error) {
+	fail := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s%s", ErrInvalidSchema, pathPrefix(path), fmt.Sprintf(format, args...))
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var r rawSchema
+	if err := dec.Decode(&r); err != nil {
+		return nil, fail("%s", err.Error())
+	}
+
+	s := &Schema{
+		Required:             r.Required,
+		AdditionalProperties: r.AdditionalProperties,
+		Enum:                 r.Enum,
+		Minimum:              r.Minimum,
+		Maximum:              r.Maximum,
+		MinLength:            r.MinLength,
+		MaxLength:            r.MaxLength,
+		MinItems:             r.MinItems,
+		MaxItems:             r.MaxItems,
+	}
+	if len(r.Type) > 0 {
+		var one string
+		if err := json.Unmarshal(r.Type, &one); err == nil {
+			s.Types = []string{one}
+		} else if err := json.Unmarshal(r.Type, &s.Types); err != nil {
+			return nil, fail("type must be a string or an array of strings")
+		}
+		for _, t := range s.Types {
+			if !validTypes[t] {
+				return nil, fail("unknown type %q", t)
+			}
+		}
+	}
+	if r.Pattern != "" {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fail("invalid pattern: %s", err.Error())
+		}
+		s.Pattern = re
+	}
+	if len(r.Properties) > 0 {
+		s.Properties = make(map[string]*Schema, len(r.Properties))
+		for name, child := range r.Properties {
+			cs, err := compile(child, joinPath(path, name))
+			if err != nil {
+				return nil, err
+			}
+			s.Properties[name] = cs
+		}
+	}
+	if len(r.Items) > 0 {
+		items, err := compile(r.Items, path+"[]")
+		if err != nil {
+			return nil, err
+		}
+		s.Items = items
+	}
+	return s, nil
+}
+
+func pathPrefix(path string) string {
+	if path == "" {
+		return ""
+	}
+	return path + ": "
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// Validate 返回全部违反约束的描述，按路径排序；为空表示通过
+func (s *Schema) Validate(v any) []string {
+	var violations []string
+	s.validate(v, "", &violations)
+	sort.Strings(violations)
+	return violations
+}
+
+func (s *Schema) validate(v any, path string, out *[]string) {
+	report := func(format string, args ...any) {
+		p := path
+		if p == "" {
+			p = "(root)"
+		}
+		*out = append(*out, p+": "+fmt.Sprintf(format, args...))
+	}
+
+	if len(s.Types) > 0 && !s.matchType(v) {
+		report("应为 %s，实际为 %s", strings.Join(s.Types, "|"), typeOf(v))
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(v, s.Enum) {
+		report("不在允许的取值范围内")
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				report("缺少必填属性 %s", name)
+			}
+		}
+		for name, child := range val {
+			if ps, ok := s.Properties[name]; ok {
+				ps.validate(child, joinPath(path, name), out)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				report("不允许的属性 %s", name)
+			}
+		}
+	case []any:
+		if s.MinItems != nil && len(val) < *s.MinItems {
+			report("元素个数不能少于 %d", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(val) > *s.MaxItems {
+			report("元素个数不能多于 %d", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range val {
+				s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), out)
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(val)
+		if s.MinLength != nil && n < *s.MinLength {
+			report("长度不能小于 %d", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			report("长度不能大于 %d", *s.MaxLength)
+		}
+		if s.Pattern != nil && !s.Pattern.MatchString(val) {
+			report("不匹配 %s", s.Pattern.String())
+		}
+	default:
+		if f, ok := toNumber(v); ok {
+			if s.Minimum != nil && f < *s.Minimum {
+				report("不能小于 %v", *s.Minimum)
+			}
+			if s.Maximum != nil && f > *s.Maximum {
+				report("不能大于 %v", *s.Maximum)
+			}
+		}
+	}
+}
+
+func (s *Schema) matchType(v any) bool {
+	actual := typeOf(v)
+	for _, t := range s.Types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	if f, ok := toNumber(v); ok {
+		if f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return "integer"
+		}
+		return "number"
+	}
+	return reflect.TypeOf(v).String()
+}
+
+// toNumber 兼容 encoding/json 默认解码与 UseNumber 两种数字表示
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func inEnum(v any, enum []any) bool {
+	f, isNumber := toNumber(v)
+	for _, e := range enum {
+		if isNumber {
+			if ef, ok := toNumber(e); ok && ef == f {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(v, e) {
+			return true
+		}
+	}
+	return false
+}
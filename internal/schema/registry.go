@@ -0,0 +1,258 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	config "usergrowth/configs"
+	"usergrowth/internal/event"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/text"
+)
+
+// 事件上的校验结果
+const (
+	StatusValid   = "valid"
+	StatusInvalid = "invalid"
+	StatusUnknown = "unknown"
+)
+
+// 未注册或校验失败时的处理策略
+const (
+	PolicyTag    = "tag"    // 照常入库，只在事件上标记结果
+	PolicyReject = "reject" // 拒绝整批上报
+)
+
+const (
+	registerAttempts = 3
+	maxErrorLength   = 512
+)
+
+type compiled struct {
+	def    *EventSchema
+	schema *Schema
+}
+
+// schemaSet 按事件名索引各版本，版本号升序
+type schemaSet struct {
+	byName map[string][]*compiled
+}
+
+func (set *schemaSet) find(name string, version int) (*compiled, bool) {
+	versions := set.byName[name]
+	if len(versions) == 0 {
+		return nil, false
+	}
+	if version <= 0 {
+		return versions[len(versions)-1], true
+	}
+	for _, c := range versions {
+		if c.def.Version == version {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+type statKey struct {
+	date    string
+	name    string
+	kind    string
+	version int
+}
+
+// Registry 在内存中缓存全部 schema，定时从 MySQL 刷新；统计先在内存累积，随刷新一起写入
+type Registry struct {
+	repo    SchemaRepository
+	cfg     *config.SchemaConfig
+	schemas atomic.Pointer[schemaSet]
+	mu      sync.Mutex
+	pending map[statKey]*Stat
+	now     func() time.Time
+	logger  logs.Logger
+}
+
+func NewRegistry(repo SchemaRepository, cfg *config.SchemaConfig, logger logs.Logger) *Registry {
+	r := &Registry{
+		repo:    repo,
+		cfg:     cfg,
+		pending: make(map[statKey]*Stat),
+		now:     time.Now,
+		logger:  logger,
+	}
+	r.schemas.Store(&schemaSet{byName: map[string][]*compiled{}})
+	return r
+}
+
+// Reload 从 MySQL 重新加载全部版本，无法编译的版本跳过并记录日志
+func (r *Registry) Reload() error {
+	defs, err := r.repo.List("")
+	if err != nil {
+		return err
+	}
+	set := &schemaSet{byName: make(map[string][]*compiled)}
+	for i := range defs {
+		def := &defs[i]
+		s, err := Compile([]byte(def.Schema))
+		if err != nil {
+			r.logger.Error(context.Background(), "event schema compile failed:", def.Name, def.Version, err.Error())
+			continue
+		}
+		set.byName[def.Name] = append(set.byName[def.Name], &compiled{def: def, schema: s})
+	}
+	r.schemas.Store(set)
+	return nil
+}
+
+// Register 为事件登记新版本；与最新版本内容相同时直接返回最新版本，created 为 false
+func (r *Registry) Register(ctx context.Context, name string, raw []byte, adminID uint) (*EventSchema, bool, error) {
+	if _, err := Compile(raw); err != nil {
+		return nil, false, err
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return nil, false, err
+	}
+
+	for range registerAttempts {
+		version := 1
+		latest, err := r.repo.Latest(name)
+		switch {
+		case err == nil:
+			if latest.Schema == buf.String() {
+				return latest, false, nil
+			}
+			version = latest.Version + 1
+		case !errors.Is(err, ErrSchemaNotFound):
+			return nil, false, err
+		}
+
+		def := &EventSchema{Name: name, Version: version, Schema: buf.String(), CreatedBy: adminID}
+		err = r.repo.Create(def)
+		if err == nil {
+			r.logger.Info(ctx, "Event schema registered:", name, "version:", version, "by:", adminID)
+			if err = r.Reload(); err != nil {
+				r.logger.Error(ctx, "event schema reload failed:", err.Error())
+			}
+			return def, true, nil
+		}
+		// 并发登记同一事件时重新读取最新版本
+		if !errors.Is(err, ErrDuplicateVersion) {
+			return nil, false, err
+		}
+	}
+	return nil, false, ErrDuplicateVersion
+}
+
+// Check 实现 track.Validator，按客户端指定的版本校验，未指定时使用最新版本；
+// 结果写入事件的 SchemaVersion 与 SchemaStatus，策略为 reject 时返回展示给调用方的错误
+func (r *Registry) Check(ctx context.Context, e *event.UserEvent, props map[string]any, version int) error {
+	c, ok := r.schemas.Load().find(e.Name, version)
+	if !ok {
+		if version > 0 {
+			e.SchemaStatus = StatusInvalid
+			msg := fmt.Sprintf("schema 版本 %d 不存在", version)
+			r.count(e.Name, KindInvalid, version, msg)
+			return r.reject(r.cfg.OnInvalid, fmt.Errorf("事件 %s 的 %s", e.Name, msg))
+		}
+		e.SchemaStatus = StatusUnknown
+		r.count(e.Name, KindUnknown, 0, "")
+		return r.reject(r.cfg.OnUnknown, fmt.Errorf("事件 %s 未注册 schema", e.Name))
+	}
+
+	e.SchemaVersion = c.def.Version
+	if props == nil {
+		props = map[string]any{}
+	}
+	violations := c.schema.Validate(props)
+	if len(violations) == 0 {
+		e.SchemaStatus = StatusValid
+		return nil
+	}
+	e.SchemaStatus = StatusInvalid
+	r.count(e.Name, KindInvalid, c.def.Version, violations[0])
+	return r.reject(r.cfg.OnInvalid, fmt.Errorf("事件 %s 不符合 schema v%d: %s", e.Name, c.def.Version, violations[0]))
+}
+
+func (r *Registry) reject(policy string, err error) error {
+	if policy == PolicyReject {
+		return err
+	}
+	return nil
+}
+
+func (r *Registry) count(name, kind string, version int, msg string) {
+	now := r.now()
+	key := statKey{date: now.Format(time.DateOnly), name: name, kind: kind, version: version}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stat, ok := r.pending[key]
+	if !ok {
+		day, _ := time.ParseInLocation(time.DateOnly, key.date, now.Location())
+		stat = &Stat{Date: day, Name: name, Kind: kind, Version: version}
+		r.pending[key] = stat
+	}
+	stat.Count++
+	stat.LastError = text.Truncate(msg, maxErrorLength)
+	stat.UpdatedAt = now
+}
+
+// Flush 把内存中的统计写入 MySQL，失败时并回下一次重试；进程异常退出会丢失未写入的部分
+func (r *Registry) Flush(ctx context.Context) {
+	r.mu.Lock()
+	batch := r.pending
+	r.pending = make(map[statKey]*Stat)
+	r.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	stats := make([]Stat, 0, len(batch))
+	for _, stat := range batch {
+		stats = append(stats, *stat)
+	}
+	if err := r.repo.AddStats(stats); err != nil {
+		r.logger.Error(ctx, "event schema stats flush failed:", err.Error())
+		r.mu.Lock()
+		for key, stat := range batch {
+			if cur, ok := r.pending[key]; ok {
+				cur.Count += stat.Count
+			} else {
+				r.pending[key] = stat
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// Run 作为定时任务执行：刷新定义并写入统计
+func (r *Registry) Run(ctx context.Context) {
+	if err := r.Reload(); err != nil {
+		r.logger.Error(ctx, "event schema reload failed:", err.Error())
+	}
+	r.Flush(ctx)
+}
+
+// ReportRow 是按天汇总的未注册事件或校验失败
+type ReportRow struct {
+	Date string `json:"date"`
+	Stat
+}
+
+// Report 汇总 [start, end) 内的统计，尚未刷入的部分不包含在内
+func (r *Registry) Report(start, end time.Time) ([]ReportRow, error) {
+	stats, err := r.repo.ListStats(start, end)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]ReportRow, 0, len(stats))
+	for _, stat := range stats {
+		rows = append(rows, ReportRow{Date: stat.Date.Format(time.DateOnly), Stat: stat})
+	}
+	return rows, nil
+}
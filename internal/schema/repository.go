@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSchemaNotFound   = errors.New("event schema not found")
+	ErrDuplicateVersion = errors.New("duplicate event schema version")
+)
+
+// 统计类别
+const (
+	KindUnknown = "unknown" // 事件名没有注册 schema
+	KindInvalid = "invalid" // 属性不符合 schema
+)
+
+// EventSchema 同一事件名的多个版本只增不改，客户端可以指定按旧版本校验
+type EventSchema struct {
+	SchemaID  uint      `gorm:"primaryKey;autoIncrement" json:"schema_id"`
+	Name      string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_name_version,priority:1" json:"name"`
+	Version   int       `gorm:"not null;uniqueIndex:idx_name_version,priority:2" json:"version"`
+	Schema    string    `gorm:"type:text;not null" json:"schema"` // JSON Schema 原文
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Stat 按天汇总的未注册事件与校验失败次数，LastError 为最近一次的失败原因
+type Stat struct {
+	Date      time.Time `gorm:"type:date;primaryKey" json:"-"`
+	Name      string    `gorm:"type:varchar(64);primaryKey" json:"name"`
+	Kind      string    `gorm:"type:varchar(16);primaryKey" json:"kind"`
+	Version   int       `gorm:"primaryKey;autoIncrement:false" json:"version"` // 未注册事件为 0
+	Count     int64     `gorm:"not null" json:"count"`
+	LastError string    `gorm:"type:varchar(512)" json:"last_error"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Stat) TableName() string {
+	return "event_schema_stats"
+}
+
+type schemaRepository struct {
+	db *gorm.DB
+}
+
+type SchemaRepository interface {
+	Create(schema *EventSchema) error
+	Latest(name string) (*EventSchema, error)
+	List(name string) ([]EventSchema, error)
+	// AddStats 把增量累加到按天汇总的统计上
+	AddStats(stats []Stat) error
+	ListStats(start, end time.Time) ([]Stat, error)
+}
+
+func NewSchemaRepository(db *gorm.DB) SchemaRepository {
+	if err := db.AutoMigrate(&EventSchema{}, &Stat{}); err != nil {
+		panic("failed to migrate event schema tables")
+	}
+	return &schemaRepository{db: db}
+}
+
+func (repo *schemaRepository) Create(schema *EventSchema) error {
+	if err := repo.db.Create(schema).Error; err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 { // Error 1062: Duplicate entry
+			return ErrDuplicateVersion
+		}
+		return err
+	}
+	return nil
+}
+
+func (repo *schemaRepository) Latest(name string) (*EventSchema, error) {
+	var schema EventSchema
+	if err := repo.db.Where("name = ?", name).Order("version DESC").First(&schema).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSchemaNotFound
+		}
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// List 返回全部版本，name 为空时列出所有事件
+func (repo *schemaRepository) List(name string) ([]EventSchema, error) {
+	var schemas []EventSchema
+	db := repo.db
+	if name != "" {
+		db = db.Where("name = ?", name)
+	}
+	err := db.Order("name, version").Find(&schemas).Error
+	return schemas, err
+}
+
+func (repo *schemaRepository) AddStats(stats []Stat) error {
+	if len(stats) == 0 {
+		return nil
+	}
+	return repo.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "date"}, {Name: "name"}, {Name: "kind"}, {Name: "version"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"count":      gorm.Expr("count + VALUES(count)"),
+			"last_error": gorm.Expr("VALUES(last_error)"),
+			"updated_at": gorm.Expr("VALUES(updated_at)"),
+		}),
+	}).Create(&stats).Error
+}
+
+func (repo *schemaRepository) ListStats(start, end time.Time) ([]Stat, error) {
+	var stats []Stat
+	err := repo.db.Where("date >= ? AND date < ?", start, end).Order("date, kind, name, version").Find(&stats).Error
+	return stats, err
+}
@@ -0,0 +1,204 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+	config "usergrowth/configs"
+	"usergrowth/internal/event"
+	"usergrowth/internal/logs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const purchaseSchema = `{
+	"type": "object",
+	"required": ["amount", "currency"],
+	"additionalProperties": false,
+	"properties": {
+		"amount": {"type": "number", "minimum": 0},
+		"currency": {"type": "string", "enum": ["CNY", "USD"]},
+		"quantity": {"type": "integer", "minimum": 1, "maximum": 99},
+		"coupon": {"type": ["string", "null"], "pattern": "^[A-Z0-9]{4,12}$"},
+		"tags": {"type": "array", "maxItems": 2, "items": {"type": "string", "maxLength": 4}}
+	}
+}`
+
+func TestCompile(t *testing.T) {
+	_, err := Compile([]byte(purchaseSchema))
+	assert.NoError(t, err)
+
+	for _, raw := range []string{
+		`{"type": "string"}`,
+		`{"type": "object", "properties": {"a": {"oneOf": []}}}`,
+		`{"type": "object", "properties": {"a": {"type": "decimal"}}}`,
+		`{"type": "object", "properties": {"a": {"type": "string", "pattern": "("}}}`,
+		`[]`,
+	} {
+		_, err := Compile([]byte(raw))
+		assert.ErrorIs(t, err, ErrInvalidSchema, raw)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, err := Compile([]byte(purchaseSchema))
+	if !assert.NoError(t, err) {
+		return
+	}
+	decode := func(raw string) map[string]any {
+		var v map[string]any
+		_ = json.Unmarshal([]byte(raw), &v)
+		return v
+	}
+
+	assert.Empty(t, s.Validate(decode(`{"amount": 9.9, "currency": "CNY", "quantity": 2, "coupon": null, "tags": ["a"]}`)))
+	// UseNumber 解码的数字同样按数值校验
+	assert.Empty(t, s.Validate(map[string]any{"amount": json.Number("10"), "currency": "USD", "quantity": json.Number("3")}))
+
+	assert.Equal(t, []string{
+		"(root): 不允许的属性 extra",
+		"(root): 缺少必填属性 currency",
+		"amount: 不能小于 0",
+		"coupon: 不匹配 ^[A-Z0-9]{4,12}$",
+		"quantity: 应为 integer，实际为 number",
+		"tags: 元素个数不能多于 2",
+		"tags[2]: 长度不能大于 4",
+	}, s.Validate(decode(`{"amount": -1, "quantity": 1.5, "coupon": "bad", "tags": ["a", "b", "longer"], "extra": 1}`)))
+
+	assert.Equal(t, []string{"currency: 不在允许的取值范围内"}, s.Validate(decode(`{"amount": 1, "currency": "EUR"}`)))
+}
+
+type fakeRepo struct {
+	schemas []EventSchema
+	stats   []Stat
+	failAdd bool
+}
+
+func (f *fakeRepo) Create(s *EventSchema) error {
+	for _, e := range f.schemas {
+		if e.Name == s.Name && e.Version == s.Version {
+			return ErrDuplicateVersion
+		}
+	}
+	s.SchemaID = uint(len(f.schemas) + 1)
+	f.schemas = append(f.schemas, *s)
+	return nil
+}
+
+func (f *fakeRepo) Latest(name string) (*EventSchema, error) {
+	var latest *EventSchema
+	for i := range f.schemas {
+		if f.schemas[i].Name == name && (latest == nil || f.schemas[i].Version > latest.Version) {
+			latest = &f.schemas[i]
+		}
+	}
+	if latest == nil {
+		return nil, ErrSchemaNotFound
+	}
+	out := *latest
+	return &out, nil
+}
+
+func (f *fakeRepo) List(name string) ([]EventSchema, error) {
+	return append([]EventSchema(nil), f.schemas...), nil
+}
+
+func (f *fakeRepo) AddStats(stats []Stat) error {
+	if f.failAdd {
+		return errors.New("db down")
+	}
+	for _, s := range stats {
+		merged := false
+		for i := range f.stats {
+			if f.stats[i].Date.Equal(s.Date) && f.stats[i].Name == s.Name && f.stats[i].Kind == s.Kind && f.stats[i].Version == s.Version {
+				f.stats[i].Count += s.Count
+				f.stats[i].LastError = s.LastError
+				merged = true
+			}
+		}
+		if !merged {
+			f.stats = append(f.stats, s)
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepo) ListStats(start, end time.Time) ([]Stat, error) {
+	return f.stats, nil
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeRepo{}
+	cfg := &config.SchemaConfig{OnUnknown: PolicyTag, OnInvalid: PolicyTag}
+	registry := NewRegistry(repo, cfg, logs.Nop())
+	registry.now = func() time.Time { return time.Date(2026, 6, 1, 9, 0, 0, 0, time.Local) }
+
+	v1, created, err := registry.Register(ctx, "purchase", []byte(`{"type": "object", "required": ["amount"]}`), 1)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, created)
+	assert.Equal(t, 1, v1.Version)
+	// 内容相同（忽略空白）不产生新版本
+	_, created, _ = registry.Register(ctx, "purchase", []byte(`{"type":"object","required":["amount"]}`), 1)
+	assert.False(t, created)
+	v2, _, _ := registry.Register(ctx, "purchase", []byte(purchaseSchema), 1)
+	assert.Equal(t, 2, v2.Version)
+	_, _, err = registry.Register(ctx, "purchase", []byte(`{"type": "array"}`), 1)
+	assert.ErrorIs(t, err, ErrInvalidSchema)
+
+	check := func(name string, props map[string]any, version int) (*event.UserEvent, error) {
+		e := &event.UserEvent{Name: name}
+		return e, registry.Check(ctx, e, props, version)
+	}
+
+	e, err := check("purchase", map[string]any{"amount": 1.0, "currency": "CNY"}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusValid, e.SchemaStatus)
+	assert.Equal(t, 2, e.SchemaVersion)
+
+	// 旧客户端按 v1 校验
+	e, _ = check("purchase", map[string]any{"amount": 1.0}, 1)
+	assert.Equal(t, StatusValid, e.SchemaStatus)
+	assert.Equal(t, 1, e.SchemaVersion)
+
+	e, err = check("purchase", map[string]any{"amount": 1.0}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusInvalid, e.SchemaStatus)
+	e, _ = check("purchase", nil, 7)
+	assert.Equal(t, StatusInvalid, e.SchemaStatus)
+	e, err = check("page_view", nil, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusUnknown, e.SchemaStatus)
+
+	cfg.OnInvalid = PolicyReject
+	_, err = check("purchase", nil, 0)
+	assert.EqualError(t, err, "事件 purchase 不符合 schema v2: (root): 缺少必填属性 amount")
+	_, err = check("page_view", nil, 0)
+	assert.NoError(t, err)
+	cfg.OnUnknown = PolicyReject
+	_, err = check("page_view", nil, 0)
+	assert.EqualError(t, err, "事件 page_view 未注册 schema")
+
+	// 写入失败时统计保留到下一次
+	repo.failAdd = true
+	registry.Flush(ctx)
+	assert.Empty(t, repo.stats)
+	repo.failAdd = false
+	registry.Flush(ctx)
+	rows, _ := registry.Report(time.Time{}, time.Now())
+	counts := map[string]int64{}
+	for _, row := range rows {
+		assert.Equal(t, "2026-06-01", row.Date)
+		counts[row.Kind+":"+row.Name+":"+strconv.Itoa(row.Version)] = row.Count
+	}
+	assert.Equal(t, map[string]int64{
+		"invalid:purchase:2":  2,
+		"invalid:purchase:7":  1,
+		"unknown:page_view:0": 3,
+	}, counts)
+}
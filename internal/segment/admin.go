@@ -0,0 +1,333 @@
+package segment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/user"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+type CreateSegmentReq struct {
+	g.Meta      `path:"/api/segments" method:"post"`
+	Name        string `json:"name" v:"required|max-length:255#分群名称不能为空|分群名称过长"`
+	Description string `json:"description" v:"max-length:1024"`
+	Rule        any    `json:"rule" v:"required#分群规则不能为空"`
+}
+
+type CreateSegmentRes struct {
+}
+
+type ListSegmentReq struct {
+	g.Meta `path:"/api/segments" method:"get"`
+}
+
+type ListSegmentRes struct {
+}
+
+type GetSegmentReq struct {
+	g.Meta    `path:"/api/segments/{id}" method:"get"`
+	SegmentID uint `p:"id" v:"required"`
+}
+
+type GetSegmentRes struct {
+}
+
+type UpdateSegmentReq struct {
+	g.Meta      `path:"/api/segments/{id}" method:"put"`
+	SegmentID   uint   `p:"id" v:"required"`
+	Name        string `json:"name" v:"required|max-length:255#分群名称不能为空|分群名称过长"`
+	Description string `json:"description" v:"max-length:1024"`
+	Rule        any    `json:"rule" v:"required#分群规则不能为空"`
+}
+
+type UpdateSegmentRes struct {
+}
+
+type DeleteSegmentReq struct {
+	g.Meta    `path:"/api/segments/{id}" method:"delete"`
+	SegmentID uint `p:"id" v:"required"`
+}
+
+type DeleteSegmentRes struct {
+}
+
+type SegmentUsersReq struct {
+	g.Meta    `path:"/api/segments/{id}/users" method:"get"`
+	SegmentID uint   `p:"id" v:"required"`
+	Cursor    uint64 `p:"cursor" d:"0"`
+	Count     int64  `p:"count" d:"100" v:"between:1,1000"`
+}
+
+type SegmentUsersRes struct {
+	Total       int64    `json:"total"`
+	RefreshedAt int64    `json:"refreshed_at"`
+	UserIDs     []string `json:"user_ids"`
+	NextCursor  uint64   `json:"next_cursor"`
+}
+
+type EvaluateSegmentReq struct {
+	g.Meta    `path:"/api/segments/{id}/evaluate" method:"get"`
+	SegmentID uint `p:"id" v:"required"`
+	UserID    uint `p:"user_id" v:"required#用户ID不能为空"`
+}
+
+type EvaluateSegmentRes struct {
+	UserID  uint `json:"user_id"`
+	Matched bool `json:"matched"`
+}
+
+type RefreshSegmentReq struct {
+	g.Meta    `path:"/api/segments/{id}/refresh" method:"post"`
+	SegmentID uint `p:"id" v:"required"`
+}
+
+type RefreshSegmentRes struct {
+}
+
+type Admin struct {
+	repo         SegmentRepository
+	profiler     *Profiler
+	materializer *Materializer
+	userLogger   logs.Logger
+}
+
+func NewAdmin(repo SegmentRepository, profiler *Profiler, materializer *Materializer, logger logs.Logger) *Admin {
+	return &Admin{
+		repo:         repo,
+		profiler:     profiler,
+		materializer: materializer,
+		userLogger:   logger,
+	}
+}
+
+// parseRule 把请求中的任意 JSON 规范化成 Rule 并校验
+func parseRule(raw any) (string, error) {
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return "", gerror.NewCode(gcode.CodeValidationFailed, "分群规则格式错误")
+	}
+	var rule Rule
+	if err = json.Unmarshal(b, &rule); err != nil {
+		return "", gerror.NewCode(gcode.CodeValidationFailed, "分群规则格式错误")
+	}
+	if err = rule.Validate(); err != nil {
+		return "", gerror.NewCode(gcode.CodeValidationFailed, err.Error())
+	}
+	b, _ = json.Marshal(&rule)
+	return string(b), nil
+}
+
+func (params *Admin) findSegment(segmentID uint) (*Segment, error) {
+	segment, err := params.repo.Find(segmentID)
+	if err != nil {
+		if errors.Is(err, ErrSegmentNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "分群不存在")
+		}
+		return nil, err
+	}
+	return segment, nil
+}
+
+func (params *Admin) Create(ctx context.Context, req *CreateSegmentReq) (res *CreateSegmentRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Segment.Create")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	rule, err := parseRule(req.Rule)
+	if err != nil {
+		return nil, err
+	}
+	segment := &Segment{
+		Name:        req.Name,
+		Description: req.Description,
+		Rule:        rule,
+	}
+	if err = params.repo.Create(segment); err != nil {
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Segment created:", segment.SegmentID, segment.Name)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "segment created",
+		"data":    segment,
+	})
+	return nil, nil
+}
+
+func (params *Admin) List(ctx context.Context, req *ListSegmentReq) (res *ListSegmentRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	segments, err := params.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    segments,
+	})
+	return nil, nil
+}
+
+func (params *Admin) Get(ctx context.Context, req *GetSegmentReq) (res *GetSegmentRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	segment, err := params.findSegment(req.SegmentID)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    segment,
+	})
+	return nil, nil
+}
+
+func (params *Admin) Update(ctx context.Context, req *UpdateSegmentReq) (res *UpdateSegmentRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Segment.Update")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	segment, err := params.findSegment(req.SegmentID)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := parseRule(req.Rule)
+	if err != nil {
+		return nil, err
+	}
+	segment.Name = req.Name
+	segment.Description = req.Description
+	segment.Rule = rule
+	if err = params.repo.Update(segment); err != nil {
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Segment updated:", segment.SegmentID, segment.Name)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "segment updated",
+		"data":    segment,
+	})
+	return nil, nil
+}
+
+func (params *Admin) Delete(ctx context.Context, req *DeleteSegmentReq) (res *DeleteSegmentRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Segment.Delete")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	if err = params.repo.Delete(req.SegmentID); err != nil {
+		if errors.Is(err, ErrSegmentNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "分群不存在")
+		}
+		return nil, err
+	}
+	if err = params.materializer.Drop(ctx, req.SegmentID); err != nil {
+		params.userLogger.Info(ctx, "Segment members drop failed:", req.SegmentID, err.Error())
+	}
+
+	params.userLogger.Info(ctx, "Segment deleted:", req.SegmentID)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "segment deleted",
+	})
+	return nil, nil
+}
+
+func (params *Admin) Users(ctx context.Context, req *SegmentUsersReq) (res *SegmentUsersRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Segment.Users")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	if _, err = params.findSegment(req.SegmentID); err != nil {
+		return nil, err
+	}
+	total, refreshedAt, err := params.materializer.Stats(ctx, req.SegmentID)
+	if err != nil {
+		return nil, err
+	}
+	ids, next, err := params.materializer.Members(ctx, req.SegmentID, req.Cursor, req.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data": &SegmentUsersRes{
+			Total:       total,
+			RefreshedAt: refreshedAt,
+			UserIDs:     ids,
+			NextCursor:  next,
+		},
+	})
+	return nil, nil
+}
+
+func (params *Admin) Evaluate(ctx context.Context, req *EvaluateSegmentReq) (res *EvaluateSegmentRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Segment.Evaluate")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	segment, err := params.findSegment(req.SegmentID)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := segment.ParseRule()
+	if err != nil {
+		return nil, err
+	}
+	profile, err := params.profiler.Profile(ctx, req.UserID)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "用户不存在")
+		}
+		return nil, err
+	}
+	matched, err := rule.Evaluate(ctx, profile, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data": &EvaluateSegmentRes{
+			UserID:  req.UserID,
+			Matched: matched,
+		},
+	})
+	return nil, nil
+}
+
+func (params *Admin) Refresh(ctx context.Context, req *RefreshSegmentReq) (res *RefreshSegmentRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	segment, err := params.findSegment(req.SegmentID)
+	if err != nil {
+		return nil, err
+	}
+	if err = params.materializer.Materialize(ctx, segment); err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "segment refreshed",
+	})
+	return nil, nil
+}
@@ -0,0 +1,221 @@
+package segment
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/user"
+
+	"github.com/gogf/gf/v2/net/gtrace"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	materializeLockKey = "segment:materialize:lock"
+	// 锁的有效期需长于一次全量物化，进程退出时锁自动过期
+	materializeLockTTL = 30 * time.Minute
+)
+
+// unlockScript 只释放自己持有的锁
+var unlockScript = goredis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func membersKey(segmentID uint) string {
+	return fmt.Sprintf("segment:%d:members", segmentID)
+}
+
+func refreshedAtKey(segmentID uint) string {
+	return fmt.Sprintf("segment:%d:refreshed_at", segmentID)
+}
+
+// Materializer 定期把分群结果写入 Redis Set，供实时判断成员关系
+type Materializer struct {
+	rdb       goredis.Cmdable
+	repo      SegmentRepository
+	users     user.UserRepository
+	profiler  *Profiler
+	batchSize int
+	logger    logs.Logger
+}
+
+func NewMaterializer(rdb goredis.Cmdable, repo SegmentRepository, users user.UserRepository, profiler *Profiler, batchSize int, logger logs.Logger) *Materializer {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	return &Materializer{
+		rdb:       rdb,
+		repo:      repo,
+		users:     users,
+		profiler:  profiler,
+		batchSize: batchSize,
+		logger:    logger,
+	}
+}
+
+// RunAll 是定时任务入口，通过 Redis 锁保证同一时间只有一个实例物化；所有分群共用一次用户遍历
+func (m *Materializer) RunAll(ctx context.Context) {
+	token := strconv.FormatInt(time.Now().UnixNano(), 36)
+	ok, err := m.rdb.SetNX(ctx, materializeLockKey, token, materializeLockTTL).Result()
+	if err != nil {
+		m.logger.Error(ctx, "segment materialize lock failed:", err.Error())
+		return
+	}
+	if !ok {
+		return
+	}
+	defer unlockScript.Run(context.Background(), m.rdb, []string{materializeLockKey}, token)
+
+	segments, err := m.repo.List()
+	if err != nil {
+		m.logger.Error(ctx, "segment materialize list failed:", err.Error())
+		return
+	}
+	list := make([]*Segment, len(segments))
+	for i := range segments {
+		list[i] = &segments[i]
+	}
+	m.materialize(ctx, list)
+}
+
+// Materialize 立即物化单个分群
+func (m *Materializer) Materialize(ctx context.Context, segment *Segment) error {
+	return m.materialize(ctx, []*Segment{segment})[segment.SegmentID]
+}
+
+// pass 是一次物化中单个分群的进度
+type pass struct {
+	segment *Segment
+	rule    *Rule
+	tmpKey  string
+	matched int
+	err     error
+}
+
+// materialize 分批遍历用户，每个用户只构建一次画像并对所有分群求值；
+// 结果先写入临时 key 再 RENAME，读方不会看到半成品。单个用户画像失败时跳过该用户，返回各分群的错误
+func (m *Materializer) materialize(ctx context.Context, segments []*Segment) map[uint]error {
+	ctx, span := gtrace.NewSpan(ctx, "Segment.Materialize")
+	defer span.End()
+
+	now := time.Now()
+	errs := make(map[uint]error)
+	passes := make([]*pass, 0, len(segments))
+	for _, segment := range segments {
+		rule, err := segment.ParseRule()
+		if err != nil {
+			errs[segment.SegmentID] = err
+			m.logger.Error(ctx, "segment materialize failed:", segment.SegmentID, err.Error())
+			continue
+		}
+		tmpKey := fmt.Sprintf("%s:tmp:%d", membersKey(segment.SegmentID), now.UnixNano())
+		defer m.rdb.Del(context.Background(), tmpKey)
+		passes = append(passes, &pass{segment: segment, rule: rule, tmpKey: tmpKey})
+	}
+	if len(passes) == 0 {
+		return errs
+	}
+
+	var afterID uint
+	skipped := 0
+	for {
+		users, err := m.users.ListUsers(afterID, m.batchSize)
+		if err != nil {
+			for _, p := range passes {
+				p.err = err
+			}
+			break
+		}
+		if len(users) == 0 {
+			break
+		}
+		members := make(map[*pass][]any, len(passes))
+		for _, u := range users {
+			profile, err := m.profiler.Profile(ctx, u.UserID)
+			if err != nil {
+				skipped++
+				m.logger.Error(ctx, "segment profile failed:", u.UserID, err.Error())
+				continue
+			}
+			for _, p := range passes {
+				ok, err := p.rule.Evaluate(ctx, profile, now)
+				if err != nil {
+					skipped++
+					m.logger.Error(ctx, "segment evaluate failed:", p.segment.SegmentID, u.UserID, err.Error())
+					continue
+				}
+				if ok {
+					members[p] = append(members[p], strconv.Itoa(int(u.UserID)))
+				}
+			}
+		}
+		for _, p := range passes {
+			if len(members[p]) == 0 || p.err != nil {
+				continue
+			}
+			if p.err = m.rdb.SAdd(ctx, p.tmpKey, members[p]...).Err(); p.err == nil {
+				p.matched += len(members[p])
+			}
+		}
+		afterID = users[len(users)-1].UserID
+	}
+
+	for _, p := range passes {
+		if p.err == nil {
+			p.err = m.publish(ctx, p, now)
+		}
+		if p.err != nil {
+			errs[p.segment.SegmentID] = p.err
+			m.logger.Error(ctx, "segment materialize failed:", p.segment.SegmentID, p.err.Error())
+			continue
+		}
+		m.logger.Info(ctx, "segment materialized:", p.segment.SegmentID, "members:", p.matched)
+	}
+	if skipped > 0 {
+		m.logger.Error(ctx, "segment materialize skipped users:", skipped)
+	}
+	return errs
+}
+
+func (m *Materializer) publish(ctx context.Context, p *pass, now time.Time) error {
+	var err error
+	if p.matched > 0 {
+		err = m.rdb.Rename(ctx, p.tmpKey, membersKey(p.segment.SegmentID)).Err()
+	} else {
+		err = m.rdb.Del(ctx, membersKey(p.segment.SegmentID)).Err()
+	}
+	if err != nil {
+		return err
+	}
+	return m.rdb.Set(ctx, refreshedAtKey(p.segment.SegmentID), now.Unix(), 0).Err()
+}
+
+// IsMember 读取最近一次物化的结果
+func (m *Materializer) IsMember(ctx context.Context, segmentID, userID uint) (bool, error) {
+	return m.rdb.SIsMember(ctx, membersKey(segmentID), strconv.Itoa(int(userID))).Result()
+}
+
+func (m *Materializer) Members(ctx context.Context, segmentID uint, cursor uint64, count int64) ([]string, uint64, error) {
+	return m.rdb.SScan(ctx, membersKey(segmentID), cursor, "", count).Result()
+}
+
+func (m *Materializer) Stats(ctx context.Context, segmentID uint) (total int64, refreshedAt int64, err error) {
+	total, err = m.rdb.SCard(ctx, membersKey(segmentID)).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	refreshedAt, err = m.rdb.Get(ctx, refreshedAtKey(segmentID)).Int64()
+	if err != nil && err != goredis.Nil {
+		return 0, 0, err
+	}
+	return total, refreshedAt, nil
+}
+
+func (m *Materializer) Drop(ctx context.Context, segmentID uint) error {
+	return m.rdb.Del(ctx, membersKey(segmentID), refreshedAtKey(segmentID)).Err()
+}
@@ -0,0 +1,90 @@
+package segment
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/user"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeUsers struct {
+	user.UserRepository
+	ids []uint
+}
+
+func (f *fakeUsers) ListUsers(afterID uint, limit int) ([]user.Users, error) {
+	var users []user.Users
+	for _, id := range f.ids {
+		if id > afterID && len(users) < limit {
+			users = append(users, user.Users{UserID: id})
+		}
+	}
+	return users, nil
+}
+
+// fakeSource 返回 channel 属性并记录调用次数，broken 中的用户返回错误
+type fakeSource struct {
+	channels map[uint]string
+	broken   map[uint]bool
+	calls    int
+}
+
+func (f *fakeSource) Attributes(ctx context.Context, userID uint) (map[string]any, error) {
+	f.calls++
+	if f.broken[userID] {
+		return nil, errors.New("source down")
+	}
+	return map[string]any{"channel": f.channels[userID]}, nil
+}
+
+func TestMaterializeAll(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	source := &fakeSource{
+		channels: map[uint]string{1: "wechat", 2: "douyin", 3: "wechat", 4: "wechat"},
+		broken:   map[uint]bool{3: true},
+	}
+	m := NewMaterializer(rdb, nil, &fakeUsers{ids: []uint{1, 2, 3, 4}}, NewProfiler(nil, source), 3, logs.Nop())
+	ctx := context.Background()
+
+	wechat := &Segment{SegmentID: 1, Rule: `{"attr": "channel", "op": "eq", "value": "wechat"}`}
+	douyin := &Segment{SegmentID: 2, Rule: `{"attr": "channel", "op": "eq", "value": "douyin"}`}
+	broken := &Segment{SegmentID: 3, Rule: `{"attr": `}
+	errs := m.materialize(ctx, []*Segment{wechat, douyin, broken})
+
+	// 每个用户只构建一次画像；画像失败的用户被跳过，不影响其它用户
+	assert.Equal(t, 4, source.calls)
+	assert.Len(t, errs, 1)
+	assert.Error(t, errs[3])
+	members, _, err := m.Members(ctx, 1, 0, 100)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1", "4"}, members)
+	ok, _ := m.IsMember(ctx, 2, 2)
+	assert.True(t, ok)
+	// 规则无法解析的分群不刷新
+	total, refreshedAt, err := m.Stats(ctx, 3)
+	assert.NoError(t, err)
+	assert.Zero(t, total)
+	assert.Zero(t, refreshedAt)
+	// 临时 key 已清理
+	assert.Len(t, mr.Keys(), 4)
+}
+
+func TestRunAllLocked(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	source := &fakeSource{}
+	m := NewMaterializer(rdb, nil, &fakeUsers{ids: []uint{1}}, NewProfiler(nil, source), 10, logs.Nop())
+
+	// 其它实例持有锁时直接返回
+	assert.NoError(t, mr.Set(materializeLockKey, "other"))
+	m.RunAll(context.Background())
+	assert.Zero(t, source.calls)
+	v, _ := mr.Get(materializeLockKey)
+	assert.Equal(t, "other", v)
+}
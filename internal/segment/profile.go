@@ -0,0 +1,88 @@
+package segment
+
+import (
+	"context"
+	"time"
+	"usergrowth/internal/event"
+	"usergrowth/internal/user"
+)
+
+// AttributeSource 为规则提供一组用户属性，新的属性来源实现该接口后注册到 Profiler 即可
+type AttributeSource interface {
+	Attributes(ctx context.Context, userID uint) (map[string]any, error)
+}
+
+type Profile struct {
+	userID uint
+	attrs  map[string]any
+	events event.EventRepository
+}
+
+func (p *Profile) UserID() uint {
+	return p.userID
+}
+
+func (p *Profile) Attr(name string) (any, bool) {
+	v, ok := p.attrs[name]
+	return v, ok
+}
+
+func (p *Profile) EventCount(ctx context.Context, name string, since time.Time) (int64, error) {
+	return p.events.CountByUser(ctx, p.userID, name, since)
+}
+
+type Profiler struct {
+	sources []AttributeSource
+	events  event.EventRepository
+}
+
+func NewProfiler(events event.EventRepository, sources ...AttributeSource) *Profiler {
+	return &Profiler{
+		sources: sources,
+		events:  events,
+	}
+}
+
+func (p *Profiler) AddSource(source AttributeSource) {
+	p.sources = append(p.sources, source)
+}
+
+func (p *Profiler) Profile(ctx context.Context, userID uint) (*Profile, error) {
+	attrs := make(map[string]any)
+	for _, source := range p.sources {
+		values, err := source.Attributes(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range values {
+			attrs[k] = v
+		}
+	}
+	return &Profile{
+		userID: userID,
+		attrs:  attrs,
+		events: p.events,
+	}, nil
+}
+
+type userAttributes struct {
+	users user.UserRepository
+}
+
+// NewUserAttributes 提供用户表上的固定字段
+func NewUserAttributes(users user.UserRepository) AttributeSource {
+	return &userAttributes{users: users}
+}
+
+func (s *userAttributes) Attributes(ctx context.Context, userID uint) (map[string]any, error) {
+	u, err := s.users.FindUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"user_id":         u.UserID,
+		"username":        u.Username,
+		"registered_at":   u.CreatedAt,
+		"registered_days": int(time.Since(u.CreatedAt).Hours() / 24),
+	}, nil
+}
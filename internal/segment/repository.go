@@ -0,0 +1,94 @@
+package segment
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrSegmentNotFound = errors.New("segment not found")
+
+type Segment struct {
+	SegmentID   uint      `gorm:"primaryKey;autoIncrement" json:"segment_id"`
+	Name        string    `gorm:"type:varchar(255);not null;uniqueIndex" json:"name"`
+	Description string    `gorm:"type:varchar(1024)" json:"description"`
+	Rule        string    `gorm:"type:text;not null" json:"rule"` // Rule 的 JSON
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (s *Segment) ParseRule() (*Rule, error) {
+	var rule Rule
+	if err := json.Unmarshal([]byte(s.Rule), &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+type segmentRepository struct {
+	db *gorm.DB
+}
+
+type SegmentRepository interface {
+	Create(segment *Segment) error
+	Update(segment *Segment) error
+	Delete(segmentID uint) error
+	Find(segmentID uint) (*Segment, error)
+	List() ([]Segment, error)
+}
+
+func NewSegmentRepository(db *gorm.DB) SegmentRepository {
+	if err := db.AutoMigrate(&Segment{}); err != nil {
+		panic("failed to migrate segment table")
+	}
+	return &segmentRepository{db: db}
+}
+
+func (repo *segmentRepository) Create(segment *Segment) error {
+	return repo.db.Create(segment).Error
+}
+
+func (repo *segmentRepository) Update(segment *Segment) error {
+	result := repo.db.Model(&Segment{}).Where("segment_id = ?", segment.SegmentID).Updates(map[string]any{
+		"name":        segment.Name,
+		"description": segment.Description,
+		"rule":        segment.Rule,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSegmentNotFound
+	}
+	return nil
+}
+
+func (repo *segmentRepository) Delete(segmentID uint) error {
+	result := repo.db.Delete(&Segment{}, segmentID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSegmentNotFound
+	}
+	return nil
+}
+
+func (repo *segmentRepository) Find(segmentID uint) (*Segment, error) {
+	var segment Segment
+	if err := repo.db.First(&segment, segmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSegmentNotFound
+		}
+		return nil, err
+	}
+	return &segment, nil
+}
+
+func (repo *segmentRepository) List() ([]Segment, error) {
+	var segments []Segment
+	err := repo.db.Order("segment_id").Find(&segments).Error
+	return segments, err
+}
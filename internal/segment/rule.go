@@ -0,0 +1,223 @@
+package segment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/util/gconv"
+)
+
+var ErrInvalidRule = errors.New("invalid segment rule")
+
+// Rule 是分群定义的 JSON 规则树，每个节点只能是以下一种：
+//
+//	{"and": [...]} / {"or": [...]} / {"not": {...}}
+//	{"attr": "registered_days", "op": "lte", "value": 7}
+//	{"attr": "channel", "op": "in", "value": ["douyin", "wechat"]}
+//	{"event": "check_in", "within_days": 7, "op": "eq", "value": 0}
+type Rule struct {
+	And        []Rule `json:"and,omitempty"`
+	Or         []Rule `json:"or,omitempty"`
+	Not        *Rule  `json:"not,omitempty"`
+	Attr       string `json:"attr,omitempty"`
+	Event      string `json:"event,omitempty"`
+	WithinDays int    `json:"within_days,omitempty"` // 事件统计窗口，0 表示全部历史
+	Op         string `json:"op,omitempty"`
+	Value      any    `json:"value,omitempty"`
+}
+
+const (
+	OpEq       = "eq"
+	OpNe       = "ne"
+	OpGt       = "gt"
+	OpGte      = "gte"
+	OpLt       = "lt"
+	OpLte      = "lte"
+	OpIn       = "in"
+	OpContains = "contains"
+	OpExists   = "exists"
+)
+
+// Subject 是被评估的用户，属性与事件次数由调用方按需提供
+type Subject interface {
+	UserID() uint
+	Attr(name string) (any, bool)
+	EventCount(ctx context.Context, name string, since time.Time) (int64, error)
+}
+
+func (rule *Rule) Validate() error {
+	kinds := 0
+	if len(rule.And) > 0 {
+		kinds++
+	}
+	if len(rule.Or) > 0 {
+		kinds++
+	}
+	if rule.Not != nil {
+		kinds++
+	}
+	if rule.Attr != "" {
+		kinds++
+	}
+	if rule.Event != "" {
+		kinds++
+	}
+	if kinds != 1 {
+		return fmt.Errorf("%w: node must have exactly one of and/or/not/attr/event", ErrInvalidRule)
+	}
+
+	children := append(append([]Rule{}, rule.And...), rule.Or...)
+	if rule.Not != nil {
+		children = append(children, *rule.Not)
+	}
+	if len(children) > 0 {
+		for i := range children {
+			if err := children[i].Validate(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch rule.Op {
+	case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte:
+	case OpIn, OpContains, OpExists:
+		if rule.Event != "" {
+			return fmt.Errorf("%w: op %q not supported on event counts", ErrInvalidRule, rule.Op)
+		}
+	default:
+		return fmt.Errorf("%w: unknown op %q", ErrInvalidRule, rule.Op)
+	}
+	if rule.Op == OpIn && reflect.ValueOf(rule.Value).Kind() != reflect.Slice {
+		return fmt.Errorf("%w: op in requires a list value", ErrInvalidRule)
+	}
+	if rule.WithinDays < 0 {
+		return fmt.Errorf("%w: within_days must not be negative", ErrInvalidRule)
+	}
+	return nil
+}
+
+func (rule *Rule) Evaluate(ctx context.Context, subject Subject, now time.Time) (bool, error) {
+	switch {
+	case len(rule.And) > 0:
+		for i := range rule.And {
+			ok, err := rule.And[i].Evaluate(ctx, subject, now)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case len(rule.Or) > 0:
+		for i := range rule.Or {
+			ok, err := rule.Or[i].Evaluate(ctx, subject, now)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	case rule.Not != nil:
+		ok, err := rule.Not.Evaluate(ctx, subject, now)
+		return !ok && err == nil, err
+	case rule.Event != "":
+		var since time.Time
+		if rule.WithinDays > 0 {
+			since = now.AddDate(0, 0, -rule.WithinDays)
+		}
+		count, err := subject.EventCount(ctx, rule.Event, since)
+		if err != nil {
+			return false, err
+		}
+		return compare(count, rule.Op, rule.Value), nil
+	default:
+		actual, ok := subject.Attr(rule.Attr)
+		if rule.Op == OpExists {
+			return ok == gconv.Bool(rule.Value), nil
+		}
+		if !ok {
+			return false, nil
+		}
+		return compare(actual, rule.Op, rule.Value), nil
+	}
+}
+
+// compare 按期望值的类型比较：数字按数值，时间按先后，其余按字符串
+func compare(actual any, op string, expected any) bool {
+	switch op {
+	case OpIn:
+		for _, v := range gconv.SliceAny(expected) {
+			if compare(actual, OpEq, v) {
+				return true
+			}
+		}
+		return false
+	case OpContains:
+		if reflect.ValueOf(actual).Kind() == reflect.Slice {
+			return compare(expected, OpIn, actual)
+		}
+		return strings.Contains(gconv.String(actual), gconv.String(expected))
+	}
+
+	var c int
+	switch a := actual.(type) {
+	case time.Time:
+		e := gtime.New(expected)
+		if e == nil {
+			return false
+		}
+		c = a.Compare(e.Time)
+	case bool:
+		if a == gconv.Bool(expected) {
+			c = 0
+		} else {
+			c = 1
+		}
+	default:
+		if isNumber(actual) && isNumber(expected) {
+			c = compareFloat(gconv.Float64(actual), gconv.Float64(expected))
+		} else {
+			c = strings.Compare(gconv.String(actual), gconv.String(expected))
+		}
+	}
+
+	switch op {
+	case OpEq:
+		return c == 0
+	case OpNe:
+		return c != 0
+	case OpGt:
+		return c > 0
+	case OpGte:
+		return c >= 0
+	case OpLt:
+		return c < 0
+	case OpLte:
+		return c <= 0
+	}
+	return false
+}
+
+func isNumber(v any) bool {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	}
+	return false
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+package segment
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSubject struct {
+	attrs  map[string]any
+	events map[string]int64
+}
+
+func (s *fakeSubject) UserID() uint { return 1 }
+
+func (s *fakeSubject) Attr(name string) (any, bool) {
+	v, ok := s.attrs[name]
+	return v, ok
+}
+
+func (s *fakeSubject) EventCount(ctx context.Context, name string, since time.Time) (int64, error) {
+	return s.events[name], nil
+}
+
+func TestRuleEvaluate(t *testing.T) {
+	raw := `{"and": [
+		{"attr": "registered_days", "op": "lte", "value": 7},
+		{"event": "check_in", "within_days": 7, "op": "eq", "value": 0},
+		{"or": [
+			{"attr": "channel", "op": "eq", "value": "wechat"},
+			{"attr": "channel", "op": "in", "value": ["douyin", "weibo"]}
+		]},
+		{"not": {"attr": "banned", "op": "eq", "value": true}}
+	]}`
+	var rule Rule
+	assert.NoError(t, json.Unmarshal([]byte(raw), &rule))
+	assert.NoError(t, rule.Validate())
+
+	subject := &fakeSubject{
+		attrs:  map[string]any{"registered_days": 3, "channel": "douyin", "banned": false},
+		events: map[string]int64{},
+	}
+	ok, err := rule.Evaluate(context.Background(), subject, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	subject.events["check_in"] = 2
+	ok, _ = rule.Evaluate(context.Background(), subject, time.Now())
+	assert.False(t, ok)
+
+	subject.events["check_in"] = 0
+	subject.attrs["channel"] = "organic"
+	ok, _ = rule.Evaluate(context.Background(), subject, time.Now())
+	assert.False(t, ok)
+}
+
+func TestRuleCompareTime(t *testing.T) {
+	rule := Rule{Attr: "registered_at", Op: OpGte, Value: "2026-01-01 00:00:00"}
+	assert.NoError(t, rule.Validate())
+
+	subject := &fakeSubject{attrs: map[string]any{"registered_at": time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)}}
+	ok, err := rule.Evaluate(context.Background(), subject, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestRuleValidate(t *testing.T) {
+	cases := []Rule{
+		{},
+		{Attr: "a", Event: "b", Op: OpEq},
+		{Attr: "a", Op: "between"},
+		{Event: "login", Op: OpContains, Value: 1},
+		{Attr: "a", Op: OpIn, Value: "x"},
+		{And: []Rule{{Attr: "a"}}},
+	}
+	for _, c := range cases {
+		assert.ErrorIs(t, c.Validate(), ErrInvalidRule)
+	}
+}
@@ -0,0 +1,212 @@
+package shortlink
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 访客 cookie 的有效期，独立访客去重窗口由 VisitorTTL 单独控制
+const visitorCookieTTL = 365 * 24 * time.Hour
+
+type CreateReq struct {
+	g.Meta     `path:"/api/links" method:"post"`
+	TargetURL  string `json:"target_url" v:"max-length:2048#目标地址过长"` // 为空时指向邀请落地页
+	ExpireDays int    `json:"expire_days" v:"between:0,365#有效天数应在0到365之间"`
+}
+
+type CreateRes struct {
+}
+
+type ListReq struct {
+	g.Meta `path:"/api/links" method:"get"`
+	Before uint `p:"before"` // 上一页最后一条的 link_id
+	Limit  int  `p:"limit" d:"20" v:"between:1,100#条数应在1到100之间"`
+}
+
+type ListRes struct {
+}
+
+type StatsReq struct {
+	g.Meta `path:"/api/links/{code}/stats" method:"get"`
+	Code   string `p:"code" v:"required"`
+	Days   int    `p:"days" d:"30" v:"between:1,90#天数应在1到90之间"`
+}
+
+type StatsRes struct {
+}
+
+type Controller struct {
+	repo       ShortlinkRepository
+	service    *Service
+	userLogger logs.Logger
+}
+
+func NewController(repo ShortlinkRepository, service *Service, logger logs.Logger) *Controller {
+	return &Controller{
+		repo:       repo,
+		service:    service,
+		userLogger: logger,
+	}
+}
+
+func currentUser(ctx context.Context) (uint, error) {
+	userid := g.RequestFromCtx(ctx).GetCtxVar("userid").String()
+	uid, err := strconv.ParseUint(userid, 10, 64)
+	if err != nil {
+		return 0, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+	return uint(uid), nil
+}
+
+func (c *Controller) Create(ctx context.Context, req *CreateReq) (res *CreateRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Shortlink.CreateHandler")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	uid, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int64("user.id", int64(uid)))
+
+	link, err := c.service.Create(ctx, uid, req.TargetURL, req.ExpireDays)
+	if err != nil {
+		if errors.Is(err, ErrInvalidTarget) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "目标地址不在允许范围内")
+		}
+		return nil, err
+	}
+	c.userLogger.Info(ctx, "Short link created:", link.Code, "userid:", uid)
+	stats, err := c.service.WithStats(ctx, []Link{*link})
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "link created",
+		"data":    stats[0],
+	})
+	return nil, nil
+}
+
+// List 返回当前用户创建的短链及其点击、注册转化
+func (c *Controller) List(ctx context.Context, req *ListReq) (res *ListRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	uid, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	links, err := c.repo.ListByUser(uid, req.Before, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := c.service.WithStats(ctx, links)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    stats,
+	})
+	return nil, nil
+}
+
+// Stats 返回单个链接最近若干天的每日点击与注册，只有创建者可以查看
+func (c *Controller) Stats(ctx context.Context, req *StatsReq) (res *StatsRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	uid, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	link, err := c.repo.FindByCode(req.Code)
+	if err != nil || link.UserID != uid {
+		if err == nil || errors.Is(err, ErrLinkNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "链接不存在")
+		}
+		return nil, err
+	}
+	stats, err := c.service.WithStats(ctx, []Link{*link})
+	if err != nil {
+		return nil, err
+	}
+	end := time.Now()
+	y, m, d := end.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, end.Location()).AddDate(0, 0, 1-req.Days)
+	daily, err := c.repo.Daily(link.LinkID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data": g.Map{
+			"link":  stats[0],
+			"daily": daily,
+		},
+	})
+	return nil, nil
+}
+
+type RedirectReq struct {
+	g.Meta `path:"/s/{code}" method:"get"`
+	Code   string `p:"code" v:"required"`
+}
+
+type RedirectRes struct {
+}
+
+// Redirector 处理短链跳转，不需要登录
+type Redirector struct {
+	service *Service
+}
+
+func NewRedirector(service *Service) *Redirector {
+	return &Redirector{service: service}
+}
+
+func (c *Redirector) Redirect(ctx context.Context, req *RedirectReq) (res *RedirectRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Shortlink.Redirect")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+	span.SetAttributes(attribute.String("shortlink.code", req.Code))
+
+	link, err := c.service.Resolve(req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrLinkNotFound):
+			r.Response.WriteStatus(http.StatusNotFound, "链接不存在")
+			return nil, nil
+		case errors.Is(err, ErrLinkExpired):
+			r.Response.WriteStatus(http.StatusGone, "链接已过期")
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	visitorID := r.Cookie.Get(VisitorCookie).String()
+	if visitorID == "" {
+		visitorID = newVisitorID()
+		r.Cookie.SetCookie(VisitorCookie, visitorID, "", "/", visitorCookieTTL)
+	}
+	r.Cookie.SetCookie(SourceCookie, link.Code, "", "/", c.service.opts.AttributionWindow)
+	c.service.Click(ctx, link, visitorID, r.GetClientIp(), r.UserAgent(), r.Referer())
+
+	r.Response.Header().Set("Cache-Control", "no-store")
+	r.Response.RedirectTo(link.TargetURL, http.StatusFound)
+	return nil, nil
+}
@@ -0,0 +1,207 @@
+package shortlink
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// 所有 key 使用同一个 hash tag，集群模式下 Lua 脚本可以同时操作
+const (
+	countsKey   = "shortlink:{c}:counts"
+	flushingKey = "shortlink:{c}:counts:flushing"
+	clicksKey   = "shortlink:{c}:clicks"
+)
+
+func visitorsKey(linkID uint) string {
+	return "shortlink:{c}:visitors:" + strconv.FormatUint(uint64(linkID), 10)
+}
+
+// Counter 在 Redis 中累积点击，定期由 Service.Flush 刷入 MySQL
+type Counter interface {
+	// Record 累加点击数，访客首次点击该链接时同时累加独立访客数，并把明细放入待写队列
+	Record(ctx context.Context, click *Click, visitorTTL time.Duration) (bool, error)
+	// TakeCounts 取出待刷入的累积计数及批次 ID，上一次未确认的批次会以相同 ID 再次返回；没有计数时 ID 为空
+	TakeCounts(ctx context.Context) (string, map[uint]Counts, error)
+	// AckCounts 在计数写入 MySQL 后删除该批次，批次已被确认时不做任何操作
+	AckCounts(ctx context.Context, batchID string) error
+	// TakeClicks 弹出至多 limit 条点击明细
+	TakeClicks(ctx context.Context, limit int) ([]Click, error)
+	// Pending 返回尚未刷入 MySQL 的计数，用于展示实时数据
+	Pending(ctx context.Context, linkIDs []uint) (map[uint]Counts, error)
+}
+
+// recordScript 返回 1 表示访客首次点击；明细队列超过上限时丢弃最早的明细，计数不受影响
+var recordScript = goredis.NewScript(`
+local first = redis.call('SADD', KEYS[1], ARGV[1])
+if redis.call('TTL', KEYS[1]) < 0 then
+	redis.call('EXPIRE', KEYS[1], ARGV[2])
+end
+redis.call('HINCRBY', KEYS[2], ARGV[3] .. ':c', 1)
+if first == 1 then
+	redis.call('HINCRBY', KEYS[2], ARGV[3] .. ':u', 1)
+end
+redis.call('RPUSH', KEYS[3], first .. '|' .. ARGV[4])
+redis.call('LTRIM', KEYS[3], -tonumber(ARGV[5]), -1)
+return first
+`)
+
+// batchField 记录在 flushing 中的批次 ID，不会与 "{linkID}:c" 形式的计数字段冲突
+const batchField = "batch"
+
+// takeScript 在没有未确认批次时把累积计数改名为新批次，返回当前批次的全部字段；
+// 升级前遗留的批次没有 ID，同样在此补上
+var takeScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 0 then
+	if redis.call('EXISTS', KEYS[1]) == 0 then
+		return {}
+	end
+	redis.call('RENAME', KEYS[1], KEYS[2])
+end
+redis.call('HSETNX', KEYS[2], ARGV[1], ARGV[2])
+return redis.call('HGETALL', KEYS[2])
+`)
+
+// ackScript 只删除 ID 一致的批次，避免晚到的确认删掉其他实例新取出的批次
+var ackScript = goredis.NewScript(`
+if redis.call('HGET', KEYS[1], ARGV[1]) == ARGV[2] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func newBatchID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+type redisCounter struct {
+	rdb      goredis.Cmdable
+	queueMax int
+}
+
+func NewRedisCounter(rdb goredis.Cmdable, queueMax int) Counter {
+	if queueMax <= 0 {
+		queueMax = 100000
+	}
+	return &redisCounter{rdb: rdb, queueMax: queueMax}
+}
+
+func (c *redisCounter) Record(ctx context.Context, click *Click, visitorTTL time.Duration) (bool, error) {
+	payload, err := json.Marshal(click)
+	if err != nil {
+		return false, err
+	}
+	first, err := recordScript.Run(ctx, c.rdb, []string{visitorsKey(click.LinkID), countsKey, clicksKey},
+		click.VisitorID, int64(visitorTTL/time.Second), click.LinkID, string(payload), c.queueMax).Int()
+	if err != nil {
+		return false, err
+	}
+	click.FirstVisit = first == 1
+	return click.FirstVisit, nil
+}
+
+// parseCounts 解析形如 "{linkID}:c" / "{linkID}:u" 的字段
+func parseCounts(fields map[string]string) map[uint]Counts {
+	counts := make(map[uint]Counts)
+	for field, raw := range fields {
+		id, kind, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		linkID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			continue
+		}
+		cnt := counts[uint(linkID)]
+		switch kind {
+		case "c":
+			cnt.Clicks += n
+		case "u":
+			cnt.Uniques += n
+		}
+		counts[uint(linkID)] = cnt
+	}
+	return counts
+}
+
+func (c *redisCounter) TakeCounts(ctx context.Context) (string, map[uint]Counts, error) {
+	items, err := takeScript.Run(ctx, c.rdb, []string{countsKey, flushingKey}, batchField, newBatchID()).StringSlice()
+	if err != nil {
+		return "", nil, err
+	}
+	fields := make(map[string]string, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		fields[items[i]] = items[i+1]
+	}
+	return fields[batchField], parseCounts(fields), nil
+}
+
+func (c *redisCounter) AckCounts(ctx context.Context, batchID string) error {
+	return ackScript.Run(ctx, c.rdb, []string{flushingKey}, batchField, batchID).Err()
+}
+
+func (c *redisCounter) TakeClicks(ctx context.Context, limit int) ([]Click, error) {
+	items, err := c.rdb.LPopCount(ctx, clicksKey, limit).Result()
+	if err != nil {
+		if err == goredis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	clicks := make([]Click, 0, len(items))
+	for _, item := range items {
+		first, payload, ok := strings.Cut(item, "|")
+		if !ok {
+			continue
+		}
+		var click Click
+		if err = json.Unmarshal([]byte(payload), &click); err != nil {
+			continue
+		}
+		click.FirstVisit = first == "1"
+		clicks = append(clicks, click)
+	}
+	return clicks, nil
+}
+
+func (c *redisCounter) Pending(ctx context.Context, linkIDs []uint) (map[uint]Counts, error) {
+	if len(linkIDs) == 0 {
+		return map[uint]Counts{}, nil
+	}
+	fields := make([]string, 0, 2*len(linkIDs))
+	for _, id := range linkIDs {
+		s := strconv.FormatUint(uint64(id), 10)
+		fields = append(fields, s+":c", s+":u")
+	}
+	pipe := c.rdb.Pipeline()
+	current := pipe.HMGet(ctx, countsKey, fields...)
+	flushing := pipe.HMGet(ctx, flushingKey, fields...)
+	if _, err := pipe.Exec(ctx); err != nil && err != goredis.Nil {
+		return nil, err
+	}
+	merged := make(map[string]string, len(fields))
+	for _, cmd := range []*goredis.SliceCmd{current, flushing} {
+		for i, v := range cmd.Val() {
+			s, ok := v.(string)
+			if !ok {
+				continue
+			}
+			prev, _ := strconv.ParseInt(merged[fields[i]], 10, 64)
+			n, _ := strconv.ParseInt(s, 10, 64)
+			merged[fields[i]] = strconv.FormatInt(prev+n, 10)
+		}
+	}
+	return parseCounts(merged), nil
+}
@@ -0,0 +1,228 @@
+package shortlink
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrLinkNotFound  = errors.New("short link not found")
+	ErrDuplicateCode = errors.New("short link code already exists")
+)
+
+// Link 是短链，Clicks 等计数由 Redis 定期刷入，可能比实时值略小
+type Link struct {
+	LinkID        uint       `gorm:"primaryKey;autoIncrement" json:"link_id"`
+	Code          string     `gorm:"type:varchar(16);not null;uniqueIndex" json:"code"`
+	UserID        uint       `gorm:"not null;index" json:"user_id"`
+	TargetURL     string     `gorm:"type:varchar(2048);not null" json:"target_url"`
+	ExpiresAt     *time.Time `json:"expires_at"` // 为空表示永不过期
+	Clicks        int64      `gorm:"not null;default:0" json:"clicks"`
+	UniqueClicks  int64      `gorm:"not null;default:0" json:"unique_clicks"`
+	Registrations int64      `gorm:"not null;default:0" json:"registrations"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (Link) TableName() string {
+	return "short_links"
+}
+
+func (l *Link) Expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// Click 是一次点击明细，FirstVisit 表示该访客第一次点击此链接
+type Click struct {
+	ClickID    uint      `gorm:"primaryKey;autoIncrement" json:"click_id"`
+	LinkID     uint      `gorm:"not null;index:idx_link_time" json:"link_id"`
+	VisitorID  string    `gorm:"type:varchar(32);not null" json:"visitor_id"`
+	FirstVisit bool      `gorm:"not null;default:false" json:"first_visit"`
+	IP         string    `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent  string    `gorm:"type:varchar(255)" json:"user_agent"`
+	Referrer   string    `gorm:"type:varchar(1024)" json:"referrer"`
+	CreatedAt  time.Time `gorm:"index:idx_link_time" json:"created_at"`
+}
+
+func (Click) TableName() string {
+	return "short_link_clicks"
+}
+
+// Conversion 记录点击短链后注册的用户，每个用户只归属一个链接
+type Conversion struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	LinkID    uint      `gorm:"not null;index:idx_link_time" json:"link_id"`
+	VisitorID string    `gorm:"type:varchar(32)" json:"visitor_id"`
+	CreatedAt time.Time `gorm:"index:idx_link_time" json:"created_at"`
+}
+
+func (Conversion) TableName() string {
+	return "short_link_conversions"
+}
+
+// Flush 记录已写入的计数批次，同一批次被多个实例或重试重复写入时只计入一次
+type Flush struct {
+	BatchID   string    `gorm:"primaryKey;type:varchar(32)" json:"batch_id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func (Flush) TableName() string {
+	return "short_link_flushes"
+}
+
+// Counts 是一段时间内累积的点击计数
+type Counts struct {
+	Clicks  int64 `json:"clicks"`
+	Uniques int64 `json:"uniques"`
+}
+
+// DailyRow 是单个链接按天汇总的点击与注册
+type DailyRow struct {
+	Date          string `json:"date"`
+	Clicks        int64  `json:"clicks"`
+	UniqueClicks  int64  `json:"unique_clicks"`
+	Registrations int64  `json:"registrations"`
+}
+
+type shortlinkRepository struct {
+	db *gorm.DB
+}
+
+type ShortlinkRepository interface {
+	Create(link *Link) error
+	FindByCode(code string) (*Link, error)
+	ListByUser(userID, beforeID uint, limit int) ([]Link, error)
+	SaveClicks(clicks []Click) error
+	// ApplyCounts 在一个事务中把累积计数加到各链接上，batchID 已写入过时不做任何操作
+	ApplyCounts(batchID string, counts map[uint]Counts) error
+	// CreateConversion 记录注册转化并累加链接的注册数，用户已有归属时返回 false
+	CreateConversion(conversion *Conversion) (bool, error)
+	Daily(linkID uint, start, end time.Time) ([]DailyRow, error)
+}
+
+func NewShortlinkRepository(db *gorm.DB) ShortlinkRepository {
+	if err := db.AutoMigrate(&Link{}, &Click{}, &Conversion{}, &Flush{}); err != nil {
+		panic("failed to migrate short link tables")
+	}
+	return &shortlinkRepository{db: db}
+}
+
+func (repo *shortlinkRepository) Create(link *Link) error {
+	if err := repo.db.Create(link).Error; err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 { // Error 1062: Duplicate entry
+			return ErrDuplicateCode
+		}
+		return err
+	}
+	return nil
+}
+
+func (repo *shortlinkRepository) FindByCode(code string) (*Link, error) {
+	var link Link
+	if err := repo.db.Where("code = ?", code).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLinkNotFound
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (repo *shortlinkRepository) ListByUser(userID, beforeID uint, limit int) ([]Link, error) {
+	var links []Link
+	db := repo.db.Where("user_id = ?", userID)
+	if beforeID > 0 {
+		db = db.Where("link_id < ?", beforeID)
+	}
+	err := db.Order("link_id DESC").Limit(limit).Find(&links).Error
+	return links, err
+}
+
+func (repo *shortlinkRepository) SaveClicks(clicks []Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	return repo.db.CreateInBatches(clicks, 500).Error
+}
+
+func (repo *shortlinkRepository) ApplyCounts(batchID string, counts map[uint]Counts) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Flush{BatchID: batchID})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		for linkID, c := range counts {
+			err := tx.Model(&Link{}).Where("link_id = ?", linkID).Updates(map[string]any{
+				"clicks":        gorm.Expr("clicks + ?", c.Clicks),
+				"unique_clicks": gorm.Expr("unique_clicks + ?", c.Uniques),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (repo *shortlinkRepository) CreateConversion(conversion *Conversion) (bool, error) {
+	created := false
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(conversion)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		created = true
+		return tx.Model(&Link{}).Where("link_id = ?", conversion.LinkID).
+			Update("registrations", gorm.Expr("registrations + 1")).Error
+	})
+	return created, err
+}
+
+func (repo *shortlinkRepository) Daily(linkID uint, start, end time.Time) ([]DailyRow, error) {
+	var clicks []DailyRow
+	err := repo.db.Model(&Click{}).
+		Select("DATE_FORMAT(created_at, '%Y-%m-%d') AS date, COUNT(*) AS clicks, SUM(first_visit) AS unique_clicks").
+		Where("link_id = ? AND created_at >= ? AND created_at < ?", linkID, start, end).
+		Group("date").Scan(&clicks).Error
+	if err != nil {
+		return nil, err
+	}
+	var regs []DailyRow
+	err = repo.db.Model(&Conversion{}).
+		Select("DATE_FORMAT(created_at, '%Y-%m-%d') AS date, COUNT(*) AS registrations").
+		Where("link_id = ? AND created_at >= ? AND created_at < ?", linkID, start, end).
+		Group("date").Scan(&regs).Error
+	if err != nil {
+		return nil, err
+	}
+	return mergeDaily(clicks, regs), nil
+}
+
+// mergeDaily 合并点击与注册两组按天汇总，按日期升序
+func mergeDaily(clicks, regs []DailyRow) []DailyRow {
+	index := make(map[string]int, len(clicks)+len(regs))
+	out := make([]DailyRow, 0, len(clicks)+len(regs))
+	for _, rows := range [][]DailyRow{clicks, regs} {
+		for _, row := range rows {
+			i, ok := index[row.Date]
+			if !ok {
+				i = len(out)
+				index[row.Date] = i
+				out = append(out, DailyRow{Date: row.Date})
+			}
+			out[i].Clicks += row.Clicks
+			out[i].UniqueClicks += row.UniqueClicks
+			out[i].Registrations += row.Registrations
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Date < out[j].Date })
+	return out
+}
@@ -0,0 +1,290 @@
+package shortlink
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/text"
+	"usergrowth/internal/user"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+var (
+	ErrInvalidTarget = errors.New("short link target not allowed")
+	ErrLinkExpired   = errors.New("short link expired")
+)
+
+// 访客 cookie 标识浏览器，来源 cookie 记录最近一次点击的短链用于注册归因
+const (
+	VisitorCookie = "ug_vid"
+	SourceCookie  = "ug_sl"
+)
+
+const (
+	codeAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	codeLength   = 7
+	codeAttempts = 5
+)
+
+func generateCode() (string, error) {
+	buf := make([]byte, codeLength)
+	max := big.NewInt(int64(len(codeAlphabet)))
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = codeAlphabet[n.Int64()]
+	}
+	return string(buf), nil
+}
+
+func newVisitorID() string {
+	buf := make([]byte, 12)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// InviteCoder 返回用户的邀请码，由 referral 模块提供
+type InviteCoder interface {
+	Code(userID uint) (string, error)
+}
+
+type Options struct {
+	InviteURL         string        // 未指定目标地址时的默认邀请落地页，{code} 替换为邀请码
+	AllowedHosts      []string      // 允许跳转的外部域名，为空时只允许站内相对路径
+	VisitorTTL        time.Duration // 独立访客去重的有效期
+	AttributionWindow time.Duration // 点击后在该时长内注册计为该链接的转化
+	FlushBatch        int
+}
+
+type Service struct {
+	repo    ShortlinkRepository
+	counter Counter
+	invites InviteCoder
+	opts    Options
+	now     func() time.Time
+	logger  logs.Logger
+}
+
+func NewService(repo ShortlinkRepository, counter Counter, invites InviteCoder, opts Options, logger logs.Logger) *Service {
+	if opts.VisitorTTL <= 0 {
+		opts.VisitorTTL = 30 * 24 * time.Hour
+	}
+	if opts.AttributionWindow <= 0 {
+		opts.AttributionWindow = 30 * 24 * time.Hour
+	}
+	if opts.FlushBatch <= 0 {
+		opts.FlushBatch = 1000
+	}
+	return &Service{
+		repo:    repo,
+		counter: counter,
+		invites: invites,
+		opts:    opts,
+		now:     time.Now,
+		logger:  logger,
+	}
+}
+
+// checkTarget 只允许站内路径或白名单域名下的 http(s) 地址，避免短链被用作任意跳转；未配置白名单时只允许站内路径
+func (s *Service) checkTarget(target string) error {
+	if strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") && !strings.HasPrefix(target, "/\\") {
+		return nil
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidTarget
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range s.opts.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return nil
+		}
+	}
+	return ErrInvalidTarget
+}
+
+// Create 为用户创建短链，target 为空时指向该用户的邀请落地页；expireDays 为 0 表示永不过期
+func (s *Service) Create(ctx context.Context, userID uint, target string, expireDays int) (*Link, error) {
+	ctx, span := gtrace.NewSpan(ctx, "Shortlink.Create")
+	defer span.End()
+
+	if target == "" {
+		code, err := s.invites.Code(userID)
+		if err != nil {
+			return nil, err
+		}
+		target = strings.ReplaceAll(s.opts.InviteURL, "{code}", url.QueryEscape(code))
+	}
+	if err := s.checkTarget(target); err != nil {
+		return nil, err
+	}
+	link := &Link{UserID: userID, TargetURL: target}
+	if expireDays > 0 {
+		at := s.now().AddDate(0, 0, expireDays)
+		link.ExpiresAt = &at
+	}
+	for range codeAttempts {
+		code, err := generateCode()
+		if err != nil {
+			return nil, err
+		}
+		link.Code = code
+		err = s.repo.Create(link)
+		if err == nil {
+			return link, nil
+		}
+		if !errors.Is(err, ErrDuplicateCode) {
+			return nil, err
+		}
+	}
+	return nil, ErrDuplicateCode
+}
+
+// Resolve 返回可跳转的短链
+func (s *Service) Resolve(code string) (*Link, error) {
+	link, err := s.repo.FindByCode(code)
+	if err != nil {
+		return nil, err
+	}
+	if link.Expired(s.now()) {
+		return nil, ErrLinkExpired
+	}
+	return link, nil
+}
+
+// Click 记录一次点击，记录失败不影响跳转
+func (s *Service) Click(ctx context.Context, link *Link, visitorID, ip, userAgent, referrer string) {
+	click := &Click{
+		LinkID:    link.LinkID,
+		VisitorID: visitorID,
+		IP:        text.Truncate(ip, 64),
+		UserAgent: text.Truncate(userAgent, 255),
+		Referrer:  text.Truncate(referrer, 1024),
+		CreatedAt: s.now(),
+	}
+	if _, err := s.counter.Record(ctx, click, s.opts.VisitorTTL); err != nil {
+		s.logger.Error(ctx, "short link click record failed:", link.Code, err.Error())
+	}
+}
+
+// OnRegister 注册为用户注册钩子，根据来源 cookie 记录注册转化
+func (s *Service) OnRegister(ctx context.Context, reg *user.Registration) {
+	r := g.RequestFromCtx(ctx)
+	if r == nil {
+		return
+	}
+	code := r.Cookie.Get(SourceCookie).String()
+	if code == "" {
+		return
+	}
+	link, err := s.repo.FindByCode(code)
+	if err != nil {
+		if !errors.Is(err, ErrLinkNotFound) {
+			s.logger.Error(ctx, "short link conversion lookup failed:", code, err.Error())
+		}
+		return
+	}
+	s.convert(ctx, link, reg.UserID, r.Cookie.Get(VisitorCookie).String())
+}
+
+func (s *Service) convert(ctx context.Context, link *Link, userID uint, visitorID string) {
+	if link.UserID == userID {
+		return
+	}
+	created, err := s.repo.CreateConversion(&Conversion{
+		UserID:    userID,
+		LinkID:    link.LinkID,
+		VisitorID: visitorID,
+		CreatedAt: s.now(),
+	})
+	if err != nil {
+		s.logger.Error(ctx, "short link conversion save failed:", link.Code, userID, err.Error())
+		return
+	}
+	if created {
+		s.logger.Info(ctx, "Short link conversion:", link.Code, "userid:", userID)
+	}
+}
+
+// Flush 供 gcron 调用，把 Redis 中累积的计数与点击明细写入 MySQL
+func (s *Service) Flush(ctx context.Context) {
+	if err := s.flushCounts(ctx); err != nil {
+		s.logger.Error(ctx, "short link counts flush failed:", err.Error())
+	}
+	if err := s.flushClicks(ctx); err != nil {
+		s.logger.Error(ctx, "short link clicks flush failed:", err.Error())
+	}
+}
+
+// flushCounts 在 MySQL 写入成功后才确认批次；多个实例同时刷入或确认前进程退出时，
+// 同一批次会被再次取出，由 MySQL 按批次 ID 去重
+func (s *Service) flushCounts(ctx context.Context) error {
+	batchID, counts, err := s.counter.TakeCounts(ctx)
+	if err != nil || batchID == "" {
+		return err
+	}
+	if err = s.repo.ApplyCounts(batchID, counts); err != nil {
+		return err
+	}
+	return s.counter.AckCounts(ctx, batchID)
+}
+
+// flushClicks 明细在写入前已从队列弹出，写入失败的批次会丢失，计数不受影响
+func (s *Service) flushClicks(ctx context.Context) error {
+	for {
+		clicks, err := s.counter.TakeClicks(ctx, s.opts.FlushBatch)
+		if err != nil {
+			return err
+		}
+		if err = s.repo.SaveClicks(clicks); err != nil {
+			return err
+		}
+		if len(clicks) < s.opts.FlushBatch {
+			return nil
+		}
+	}
+}
+
+// LinkStats 是链接及其含未刷入部分的实时计数
+type LinkStats struct {
+	Link
+	ShortURL       string  `json:"short_url"`
+	Expired        bool    `json:"expired"`
+	ConversionRate float64 `json:"conversion_rate"` // 注册数 / 独立访客数
+}
+
+// WithStats 合并 Redis 中尚未刷入的计数
+func (s *Service) WithStats(ctx context.Context, links []Link) ([]LinkStats, error) {
+	ids := make([]uint, 0, len(links))
+	for _, l := range links {
+		ids = append(ids, l.LinkID)
+	}
+	pending, err := s.counter.Pending(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	out := make([]LinkStats, 0, len(links))
+	for _, l := range links {
+		p := pending[l.LinkID]
+		l.Clicks += p.Clicks
+		l.UniqueClicks += p.Uniques
+		stats := LinkStats{Link: l, ShortURL: "/s/" + l.Code, Expired: l.Expired(now)}
+		if l.UniqueClicks > 0 {
+			stats.ConversionRate = float64(l.Registrations) / float64(l.UniqueClicks)
+		}
+		out = append(out, stats)
+	}
+	return out, nil
+}
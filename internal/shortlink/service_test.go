@@ -0,0 +1,318 @@
+package shortlink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRepo struct {
+	ShortlinkRepository
+	links       []Link
+	clicks      []Click
+	conversions map[uint]Conversion
+	applied     map[string]bool
+	applyErr    error
+}
+
+func (f *fakeRepo) Create(link *Link) error {
+	for _, l := range f.links {
+		if l.Code == link.Code {
+			return ErrDuplicateCode
+		}
+	}
+	link.LinkID = uint(len(f.links) + 1)
+	f.links = append(f.links, *link)
+	return nil
+}
+
+func (f *fakeRepo) FindByCode(code string) (*Link, error) {
+	for i := range f.links {
+		if f.links[i].Code == code {
+			return &f.links[i], nil
+		}
+	}
+	return nil, ErrLinkNotFound
+}
+
+func (f *fakeRepo) SaveClicks(clicks []Click) error {
+	f.clicks = append(f.clicks, clicks...)
+	return nil
+}
+
+func (f *fakeRepo) ApplyCounts(batchID string, counts map[uint]Counts) error {
+	if f.applyErr != nil {
+		return f.applyErr
+	}
+	if f.applied == nil {
+		f.applied = make(map[string]bool)
+	}
+	if f.applied[batchID] {
+		return nil
+	}
+	f.applied[batchID] = true
+	for id, c := range counts {
+		f.links[id-1].Clicks += c.Clicks
+		f.links[id-1].UniqueClicks += c.Uniques
+	}
+	return nil
+}
+
+func (f *fakeRepo) CreateConversion(conversion *Conversion) (bool, error) {
+	if f.conversions == nil {
+		f.conversions = make(map[uint]Conversion)
+	}
+	if _, ok := f.conversions[conversion.UserID]; ok {
+		return false, nil
+	}
+	f.conversions[conversion.UserID] = *conversion
+	f.links[conversion.LinkID-1].Registrations++
+	return true, nil
+}
+
+// fakeCounter 在内存中实现与 recordScript 相同的语义
+type fakeCounter struct {
+	visitors map[uint]map[string]bool
+	counts   map[uint]Counts
+	flushing map[uint]Counts
+	batchID  string
+	batches  int
+	queue    []Click
+}
+
+func newFakeCounter() *fakeCounter {
+	return &fakeCounter{visitors: make(map[uint]map[string]bool), counts: make(map[uint]Counts)}
+}
+
+func (f *fakeCounter) Record(ctx context.Context, click *Click, visitorTTL time.Duration) (bool, error) {
+	if f.visitors[click.LinkID] == nil {
+		f.visitors[click.LinkID] = make(map[string]bool)
+	}
+	click.FirstVisit = !f.visitors[click.LinkID][click.VisitorID]
+	f.visitors[click.LinkID][click.VisitorID] = true
+	c := f.counts[click.LinkID]
+	c.Clicks++
+	if click.FirstVisit {
+		c.Uniques++
+	}
+	f.counts[click.LinkID] = c
+	f.queue = append(f.queue, *click)
+	return click.FirstVisit, nil
+}
+
+func (f *fakeCounter) TakeCounts(ctx context.Context) (string, map[uint]Counts, error) {
+	if f.flushing == nil {
+		if len(f.counts) == 0 {
+			return "", nil, nil
+		}
+		f.batches++
+		f.flushing, f.counts = f.counts, make(map[uint]Counts)
+		f.batchID = fmt.Sprintf("b%d", f.batches)
+	}
+	return f.batchID, f.flushing, nil
+}
+
+func (f *fakeCounter) AckCounts(ctx context.Context, batchID string) error {
+	if batchID == f.batchID {
+		f.flushing, f.batchID = nil, ""
+	}
+	return nil
+}
+
+func (f *fakeCounter) TakeClicks(ctx context.Context, limit int) ([]Click, error) {
+	n := min(limit, len(f.queue))
+	out := f.queue[:n]
+	f.queue = f.queue[n:]
+	return out, nil
+}
+
+func (f *fakeCounter) Pending(ctx context.Context, linkIDs []uint) (map[uint]Counts, error) {
+	out := make(map[uint]Counts)
+	for _, id := range linkIDs {
+		c := f.counts[id]
+		if p, ok := f.flushing[id]; ok {
+			c.Clicks += p.Clicks
+			c.Uniques += p.Uniques
+		}
+		out[id] = c
+	}
+	return out, nil
+}
+
+type fakeInvites struct{}
+
+func (fakeInvites) Code(userID uint) (string, error) {
+	return "ABCD2345", nil
+}
+
+var testNow = time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestService(opts Options) (*Service, *fakeRepo, *fakeCounter) {
+	repo := &fakeRepo{}
+	counter := newFakeCounter()
+	opts.InviteURL = "/register.html?invite={code}"
+	s := NewService(repo, counter, fakeInvites{}, opts, logs.Nop())
+	s.now = func() time.Time { return testNow }
+	return s, repo, counter
+}
+
+func TestCheckTarget(t *testing.T) {
+	s, _, _ := newTestService(Options{})
+	assert.NoError(t, s.checkTarget("/register.html?invite=X"))
+	// 未配置白名单时不允许外部域名
+	assert.ErrorIs(t, s.checkTarget("https://anywhere.example/path"), ErrInvalidTarget)
+	assert.ErrorIs(t, s.checkTarget("//evil.example/"), ErrInvalidTarget)
+	assert.ErrorIs(t, s.checkTarget("/\\evil.example/"), ErrInvalidTarget)
+	assert.ErrorIs(t, s.checkTarget("javascript:alert(1)"), ErrInvalidTarget)
+	assert.ErrorIs(t, s.checkTarget("ftp://files.example/"), ErrInvalidTarget)
+
+	s, _, _ = newTestService(Options{AllowedHosts: []string{"growth.example"}})
+	assert.NoError(t, s.checkTarget("https://growth.example/a"))
+	assert.NoError(t, s.checkTarget("https://m.growth.example/a"))
+	assert.NoError(t, s.checkTarget("/invite"))
+	assert.ErrorIs(t, s.checkTarget("https://evilgrowth.example/a"), ErrInvalidTarget)
+	assert.ErrorIs(t, s.checkTarget("https://growth.example.evil.io/a"), ErrInvalidTarget)
+}
+
+func TestCreateAndResolve(t *testing.T) {
+	s, _, _ := newTestService(Options{})
+	ctx := context.Background()
+
+	link, err := s.Create(ctx, 7, "", 3)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "/register.html?invite=ABCD2345", link.TargetURL)
+	assert.Len(t, link.Code, codeLength)
+	assert.Equal(t, testNow.AddDate(0, 0, 3), *link.ExpiresAt)
+
+	_, err = s.Create(ctx, 7, "//evil.example", 0)
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+
+	got, err := s.Resolve(link.Code)
+	assert.NoError(t, err)
+	assert.Equal(t, link.LinkID, got.LinkID)
+
+	s.now = func() time.Time { return testNow.AddDate(0, 0, 3) }
+	_, err = s.Resolve(link.Code)
+	assert.ErrorIs(t, err, ErrLinkExpired)
+	_, err = s.Resolve("missing")
+	assert.ErrorIs(t, err, ErrLinkNotFound)
+}
+
+func TestClicksFlushAndStats(t *testing.T) {
+	s, repo, counter := newTestService(Options{FlushBatch: 2})
+	ctx := context.Background()
+	link, _ := s.Create(ctx, 1, "/landing", 0)
+
+	for _, vid := range []string{"a", "b", "a", "c", "a"} {
+		s.Click(ctx, link, vid, "1.2.3.4", "ua", "")
+	}
+	// 刷入前的实时数据来自 Redis
+	stats, err := s.WithStats(ctx, []Link{repo.links[0]})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), stats[0].Clicks)
+	assert.Equal(t, int64(3), stats[0].UniqueClicks)
+
+	// MySQL 写入失败时不确认批次，下次重试不会丢失或重复
+	repo.applyErr = errors.New("db down")
+	s.Flush(ctx)
+	assert.Equal(t, int64(0), repo.links[0].Clicks)
+	assert.NotNil(t, counter.flushing)
+	s.Click(ctx, link, "d", "", "", "")
+
+	repo.applyErr = nil
+	s.Flush(ctx)
+	assert.Equal(t, int64(5), repo.links[0].Clicks)
+	s.Flush(ctx)
+	assert.Equal(t, int64(6), repo.links[0].Clicks)
+	assert.Equal(t, int64(4), repo.links[0].UniqueClicks)
+	assert.Len(t, repo.clicks, 6)
+	assert.Empty(t, counter.queue)
+
+	firsts := 0
+	for _, c := range repo.clicks {
+		if c.FirstVisit {
+			firsts++
+		}
+	}
+	assert.Equal(t, 4, firsts)
+
+	stats, err = s.WithStats(ctx, []Link{repo.links[0]})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), stats[0].Clicks)
+	assert.Equal(t, "/s/"+link.Code, stats[0].ShortURL)
+}
+
+func TestFlushCountsOnce(t *testing.T) {
+	s, repo, counter := newTestService(Options{FlushBatch: 10})
+	ctx := context.Background()
+	link, _ := s.Create(ctx, 1, "/landing", 0)
+	s.Click(ctx, link, "a", "", "", "")
+	s.Click(ctx, link, "b", "", "", "")
+
+	// 两个实例取到同一批次并先后写入，只计入一次
+	first, counts, err := counter.TakeCounts(ctx)
+	assert.NoError(t, err)
+	second, _, _ := counter.TakeCounts(ctx)
+	assert.Equal(t, first, second)
+	assert.NoError(t, repo.ApplyCounts(first, counts))
+	assert.NoError(t, counter.AckCounts(ctx, first))
+
+	s.Click(ctx, link, "c", "", "", "")
+	third, _, _ := counter.TakeCounts(ctx)
+	assert.NotEqual(t, first, third)
+	// 晚到的写入与确认不影响新批次
+	assert.NoError(t, repo.ApplyCounts(second, counts))
+	assert.NoError(t, counter.AckCounts(ctx, second))
+	assert.Equal(t, int64(2), repo.links[0].Clicks)
+	assert.NotNil(t, counter.flushing)
+
+	s.Flush(ctx)
+	assert.Equal(t, int64(3), repo.links[0].Clicks)
+	assert.Equal(t, int64(3), repo.links[0].UniqueClicks)
+	assert.Nil(t, counter.flushing)
+
+	// 没有新点击时不产生批次
+	s.Flush(ctx)
+	assert.Len(t, repo.applied, 2)
+}
+
+func TestConversion(t *testing.T) {
+	s, repo, _ := newTestService(Options{})
+	ctx := context.Background()
+	link, _ := s.Create(ctx, 1, "/landing", 0)
+	s.Click(ctx, link, "a", "", "", "")
+	s.Click(ctx, link, "b", "", "", "")
+
+	s.convert(ctx, &repo.links[0], 10, "a")
+	s.convert(ctx, &repo.links[0], 10, "a") // 重复注册回调不重复计数
+	s.convert(ctx, &repo.links[0], 1, "b")  // 创建者自己不计入
+	assert.Equal(t, int64(1), repo.links[0].Registrations)
+
+	stats, err := s.WithStats(ctx, []Link{repo.links[0]})
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.5, stats[0].ConversionRate, 1e-9)
+}
+
+func TestParseCounts(t *testing.T) {
+	counts := parseCounts(map[string]string{"1:c": "5", "1:u": "2", "2:c": "1", "bad": "1", "x:c": "3"})
+	assert.Equal(t, map[uint]Counts{1: {Clicks: 5, Uniques: 2}, 2: {Clicks: 1}}, counts)
+}
+
+func TestMergeDaily(t *testing.T) {
+	rows := mergeDaily(
+		[]DailyRow{{Date: "2026-05-02", Clicks: 3, UniqueClicks: 2}, {Date: "2026-05-01", Clicks: 1, UniqueClicks: 1}},
+		[]DailyRow{{Date: "2026-05-02", Registrations: 1}, {Date: "2026-04-30", Registrations: 2}},
+	)
+	assert.Equal(t, []DailyRow{
+		{Date: "2026-04-30", Registrations: 2},
+		{Date: "2026-05-01", Clicks: 1, UniqueClicks: 1},
+		{Date: "2026-05-02", Clicks: 3, UniqueClicks: 2, Registrations: 1},
+	}, rows)
+}
@@ -0,0 +1,22 @@
+// Package text 提供写入数据库前的字符串处理
+package text
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Truncate 把 s 截断到不超过 n 字节，截断点回退到字符边界，并去掉非法的 UTF-8 字节，
+// 避免 MySQL 以 1366 (Incorrect string value) 拒绝写入
+func Truncate(s string, n int) string {
+	if len(s) > n {
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		s = s[:n]
+	}
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "")
+	}
+	return s
+}
@@ -0,0 +1,25 @@
+package text
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "abc", Truncate("abc", 3))
+	assert.Equal(t, "ab", Truncate("abc", 2))
+	assert.Equal(t, "", Truncate("abc", 0))
+
+	// "微信" 每个字 3 字节，不在字符中间截断
+	assert.Equal(t, "微", Truncate("微信", 5))
+	assert.Equal(t, "微", Truncate("微信", 3))
+	assert.Equal(t, "", Truncate("微信", 2))
+	assert.Equal(t, "a😀", Truncate("a😀b", 5))
+
+	// 查询参数解码出的非法字节被去掉
+	got := Truncate("utm\xffsource\xc3", 255)
+	assert.Equal(t, "utmsource", got)
+	assert.True(t, utf8.ValidString(got))
+}
@@ -0,0 +1,77 @@
+package tier
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type MembershipReq struct {
+	g.Meta `path:"/api/membership" method:"get"`
+}
+
+type MembershipRes struct {
+}
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// Membership 返回当前等级、权益以及升到下一级所需的门槛
+func (c *Controller) Membership(ctx context.Context, req *MembershipReq) (res *MembershipRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Tier.Membership")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	userid := r.GetCtxVar("userid").String()
+	uid, err := strconv.ParseUint(userid, 10, 64)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeNotAuthorized, "未登录或Token已过期")
+	}
+	span.SetAttributes(attribute.String("user.id", userid))
+
+	userTier, t, err := c.service.current(ctx, uint(uid))
+	if err != nil {
+		return nil, err
+	}
+	if userTier == nil {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "会员等级未配置")
+	}
+
+	data := g.Map{
+		"tier":     userTier.TierKey,
+		"points":   userTier.Points,
+		"activity": userTier.Activity,
+	}
+	if t != nil {
+		data["tier_name"] = t.Name
+		data["benefits"] = t.Benefits
+	}
+	defs := c.service.Definitions()
+	if idx := defs.index(userTier.TierKey); idx+1 < len(defs.Tiers) {
+		next := defs.Tiers[idx+1]
+		data["next"] = g.Map{
+			"tier":         next.Key,
+			"tier_name":    next.Name,
+			"min_points":   next.MinPoints,
+			"min_activity": next.MinActivity,
+		}
+	}
+
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    data,
+	})
+	return nil, nil
+}
@@ -0,0 +1,68 @@
+package tier
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UserTier struct {
+	UserID      uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	TierKey     string    `gorm:"type:varchar(64);not null;index" json:"tier"`
+	Points      int64     `gorm:"not null" json:"points"`   // 评估时的累计积分
+	Activity    int64     `gorm:"not null" json:"activity"` // 评估时窗口内的活跃事件数
+	EvaluatedAt time.Time `gorm:"not null" json:"evaluated_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type TierChange struct {
+	ChangeID  uint      `gorm:"primaryKey;autoIncrement" json:"change_id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	FromTier  string    `gorm:"type:varchar(64);not null" json:"from_tier"`
+	ToTier    string    `gorm:"type:varchar(64);not null" json:"to_tier"`
+	Reason    string    `gorm:"type:varchar(32);not null" json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type tierRepository struct {
+	db *gorm.DB
+}
+
+type TierRepository interface {
+	Find(userID uint) (*UserTier, error)
+	// Save 保存评估结果，change 非空时在同一事务内记录等级变更
+	Save(userTier *UserTier, change *TierChange) error
+}
+
+func NewTierRepository(db *gorm.DB) TierRepository {
+	if err := db.AutoMigrate(&UserTier{}, &TierChange{}); err != nil {
+		panic("failed to migrate tier tables")
+	}
+	return &tierRepository{db: db}
+}
+
+// Find 对尚未评估过的用户返回 nil
+func (repo *tierRepository) Find(userID uint) (*UserTier, error) {
+	var userTier UserTier
+	if err := repo.db.Where("user_id = ?", userID).First(&userTier).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &userTier, nil
+}
+
+func (repo *tierRepository) Save(userTier *UserTier, change *TierChange) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(userTier).Error; err != nil {
+			return err
+		}
+		if change == nil {
+			return nil
+		}
+		return tx.Create(change).Error
+	})
+}
@@ -0,0 +1,269 @@
+package tier
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"usergrowth/internal/event"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/points"
+	"usergrowth/internal/user"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+	"github.com/gogf/gf/v2/os/gfsnotify"
+)
+
+// NameChanged 是等级变化时写入的服务端事件
+const NameChanged = "tier_changed"
+
+// 触发评估的原因，记录在变更表与事件属性中
+const (
+	ReasonPoints      = "points"
+	ReasonScheduled   = "scheduled"
+	ReasonDefinitions = "definitions"
+	ReasonInitial     = "initial"
+)
+
+// Service 持有当前生效的等级定义，定义变更时整体原子替换并触发全量重新评估
+type Service struct {
+	defs      atomic.Pointer[Definitions]
+	repo      TierRepository
+	points    *points.Service
+	events    event.EventRepository
+	users     user.UserRepository
+	batchSize int
+	logger    logs.Logger
+	mu        sync.Mutex
+	lastRaw   string
+	running   atomic.Bool
+}
+
+func NewService(repo TierRepository, pointsService *points.Service, events event.EventRepository, users user.UserRepository, batchSize int, logger logs.Logger) *Service {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	s := &Service{
+		repo:      repo,
+		points:    pointsService,
+		events:    events,
+		users:     users,
+		batchSize: batchSize,
+		logger:    logger,
+	}
+	s.defs.Store(&Definitions{})
+	return s
+}
+
+// Load 解析 JSON 或 YAML 格式的定义，非法时保留旧定义；返回定义是否发生变化
+func (s *Service) Load(raw []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if string(raw) == s.lastRaw {
+		return false, nil
+	}
+
+	j, err := gjson.LoadContent(raw)
+	if err != nil {
+		return false, err
+	}
+	var defs Definitions
+	if err = json.Unmarshal(j.MustToJson(), &defs); err != nil {
+		return false, err
+	}
+	if err = defs.validate(); err != nil {
+		return false, err
+	}
+	s.defs.Store(&defs)
+	s.lastRaw = string(raw)
+	return true, nil
+}
+
+// WatchFile 加载文件并在文件变更时热更新，更新成功后在后台重新评估全部用户
+func (s *Service) WatchFile(path string) error {
+	load := func() (bool, error) {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		return s.Load(raw)
+	}
+	if _, err := load(); err != nil {
+		return err
+	}
+	_, err := gfsnotify.Add(path, func(e *gfsnotify.Event) {
+		if e.IsWrite() || e.IsCreate() || e.IsRename() {
+			ctx := context.Background()
+			changed, err := load()
+			if err != nil {
+				s.logger.Error(ctx, "tier definitions reload failed:", path, err.Error())
+				return
+			}
+			if changed {
+				s.logger.Info(ctx, "tier definitions reloaded from file:", path)
+				go s.evaluateAll(ctx, ReasonDefinitions)
+			}
+		}
+	})
+	return err
+}
+
+func (s *Service) Definitions() *Definitions {
+	return s.defs.Load()
+}
+
+// Evaluate 按当前定义计算用户等级，等级变化时记录变更并写入 tier_changed 事件
+func (s *Service) Evaluate(ctx context.Context, userID uint, reason string) (*UserTier, error) {
+	ctx, span := gtrace.NewSpan(ctx, "Tier.Evaluate")
+	defer span.End()
+
+	defs := s.defs.Load()
+	if len(defs.Tiers) == 0 {
+		return nil, nil
+	}
+	account, err := s.points.Account(userID)
+	if err != nil {
+		return nil, err
+	}
+	activity := int64(0)
+	if defs.needsActivity() {
+		since := time.Now().AddDate(0, 0, -defs.Activity.WindowDays)
+		if activity, err = s.events.CountByUser(ctx, userID, defs.Activity.Event, since); err != nil {
+			return nil, err
+		}
+	}
+	current, err := s.repo.Find(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	next := &UserTier{UserID: userID, Points: account.Lifetime, Activity: activity, EvaluatedAt: time.Now()}
+	if idx := defs.Resolve(account.Lifetime, activity); idx >= 0 {
+		next.TierKey = defs.Tiers[idx].Key
+	}
+	from := ""
+	if current != nil {
+		from = current.TierKey
+	}
+	var change *TierChange
+	if current == nil || from != next.TierKey {
+		if current == nil {
+			reason = ReasonInitial
+		}
+		change = &TierChange{UserID: userID, FromTier: from, ToTier: next.TierKey, Reason: reason}
+	}
+	if err = s.repo.Save(next, change); err != nil {
+		return nil, err
+	}
+
+	if change != nil {
+		s.logger.Info(ctx, "Tier changed:", userID, from, "->", next.TierKey, "reason:", reason)
+		err = s.events.Record(ctx, userID, NameChanged, g.Map{
+			"from":     from,
+			"to":       next.TierKey,
+			"upgrade":  defs.index(next.TierKey) > defs.index(from),
+			"reason":   reason,
+			"points":   account.Lifetime,
+			"activity": activity,
+		})
+		if err != nil {
+			s.logger.Error(ctx, "tier event record failed:", userID, err.Error())
+		}
+	}
+	return next, nil
+}
+
+// OnPointsChanged 注册为积分变动监听器，积分增加后立即升级
+func (s *Service) OnPointsChanged(ctx context.Context, account *points.Account, entry *points.Entry) {
+	if _, err := s.Evaluate(ctx, account.UserID, ReasonPoints); err != nil {
+		s.logger.Error(ctx, "tier evaluate failed:", account.UserID, err.Error())
+	}
+}
+
+// RunAll 作为定时任务执行，活跃度下降导致的降级在此生效
+func (s *Service) RunAll(ctx context.Context) {
+	s.evaluateAll(ctx, ReasonScheduled)
+}
+
+func (s *Service) evaluateAll(ctx context.Context, reason string) {
+	if !s.running.CompareAndSwap(false, true) {
+		s.logger.Info(ctx, "tier evaluation already running, skipped:", reason)
+		return
+	}
+	defer s.running.Store(false)
+
+	afterID := uint(0)
+	evaluated := 0
+	for {
+		users, err := s.users.ListUsers(afterID, s.batchSize)
+		if err != nil {
+			s.logger.Error(ctx, "tier evaluation list users failed:", err.Error())
+			return
+		}
+		if len(users) == 0 {
+			break
+		}
+		for _, u := range users {
+			if _, err = s.Evaluate(ctx, u.UserID, reason); err != nil {
+				s.logger.Error(ctx, "tier evaluate failed:", u.UserID, err.Error())
+			}
+		}
+		evaluated += len(users)
+		afterID = users[len(users)-1].UserID
+	}
+	s.logger.Info(ctx, "tier evaluation finished:", reason, "users", evaluated)
+}
+
+// current 返回用户已保存的等级，从未评估过时立即评估一次
+func (s *Service) current(ctx context.Context, userID uint) (*UserTier, *Tier, error) {
+	userTier, err := s.repo.Find(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if userTier == nil {
+		if userTier, err = s.Evaluate(ctx, userID, ReasonInitial); err != nil || userTier == nil {
+			return nil, nil, err
+		}
+	}
+	defs := s.defs.Load()
+	if idx := defs.index(userTier.TierKey); idx >= 0 {
+		return userTier, &defs.Tiers[idx], nil
+	}
+	return userTier, nil, nil
+}
+
+// Membership 实现 user.MembershipProvider，供认证接口展示
+func (s *Service) Membership(ctx context.Context, userID uint) (*user.Membership, error) {
+	userTier, t, err := s.current(ctx, userID)
+	if err != nil || userTier == nil {
+		return nil, err
+	}
+	membership := &user.Membership{Tier: userTier.TierKey, Points: userTier.Points}
+	if t != nil {
+		membership.TierName = t.Name
+	}
+	return membership, nil
+}
+
+// Benefits 返回用户当前等级的权益，供其他模块查询
+func (s *Service) Benefits(ctx context.Context, userID uint) (map[string]any, error) {
+	_, t, err := s.current(ctx, userID)
+	if err != nil || t == nil {
+		return map[string]any{}, err
+	}
+	return t.Benefits, nil
+}
+
+// Benefit 查询单项权益，未配置时返回 false
+func (s *Service) Benefit(ctx context.Context, userID uint, key string) (any, bool, error) {
+	benefits, err := s.Benefits(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	v, ok := benefits[key]
+	return v, ok, nil
+}
@@ -0,0 +1,84 @@
+package tier
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidDefinitions = errors.New("invalid tier definitions")
+
+// Tier 的门槛需同时满足：累计积分不低于 MinPoints，且活跃窗口内的活跃事件数不低于 MinActivity
+type Tier struct {
+	Key         string         `json:"key"`
+	Name        string         `json:"name"`
+	MinPoints   int64          `json:"minPoints"`
+	MinActivity int64          `json:"minActivity"`
+	Benefits    map[string]any `json:"benefits"`
+}
+
+// ActivityRule 定义用于计算活跃度的事件与统计窗口
+type ActivityRule struct {
+	Event      string `json:"event"`
+	WindowDays int    `json:"windowDays"`
+}
+
+// Definitions 中的等级按从低到高排列，门槛需单调不减
+type Definitions struct {
+	Activity ActivityRule `json:"activity"`
+	Tiers    []Tier       `json:"tiers"`
+}
+
+func (d *Definitions) validate() error {
+	if len(d.Tiers) == 0 {
+		return fmt.Errorf("%w: no tiers", ErrInvalidDefinitions)
+	}
+	seen := make(map[string]bool, len(d.Tiers))
+	for i, t := range d.Tiers {
+		if t.Key == "" {
+			return fmt.Errorf("%w: tier %d has empty key", ErrInvalidDefinitions, i)
+		}
+		if seen[t.Key] {
+			return fmt.Errorf("%w: duplicate key %s", ErrInvalidDefinitions, t.Key)
+		}
+		seen[t.Key] = true
+		if t.MinPoints < 0 || t.MinActivity < 0 {
+			return fmt.Errorf("%w: negative threshold in %s", ErrInvalidDefinitions, t.Key)
+		}
+		if i > 0 && (t.MinPoints < d.Tiers[i-1].MinPoints || t.MinActivity < d.Tiers[i-1].MinActivity) {
+			return fmt.Errorf("%w: thresholds of %s lower than previous tier", ErrInvalidDefinitions, t.Key)
+		}
+	}
+	if d.needsActivity() && (d.Activity.Event == "" || d.Activity.WindowDays <= 0) {
+		return fmt.Errorf("%w: activity rule required by minActivity", ErrInvalidDefinitions)
+	}
+	return nil
+}
+
+func (d *Definitions) needsActivity() bool {
+	for _, t := range d.Tiers {
+		if t.MinActivity > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve 返回满足门槛的最高等级下标，一个都不满足时返回 -1
+func (d *Definitions) Resolve(points, activity int64) int {
+	idx := -1
+	for i, t := range d.Tiers {
+		if points >= t.MinPoints && activity >= t.MinActivity {
+			idx = i
+		}
+	}
+	return idx
+}
+
+func (d *Definitions) index(key string) int {
+	for i, t := range d.Tiers {
+		if t.Key == key {
+			return i
+		}
+	}
+	return -1
+}
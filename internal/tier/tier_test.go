@@ -0,0 +1,37 @@
+package tier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefinitionsResolve(t *testing.T) {
+	defs := &Definitions{
+		Activity: ActivityRule{Event: "login", WindowDays: 30},
+		Tiers: []Tier{
+			{Key: "bronze"},
+			{Key: "silver", MinPoints: 1000, MinActivity: 4},
+			{Key: "gold", MinPoints: 5000, MinActivity: 8},
+		},
+	}
+	assert.NoError(t, defs.validate())
+
+	assert.Equal(t, 0, defs.Resolve(0, 0))
+	assert.Equal(t, 1, defs.Resolve(1200, 4))
+	// 积分达到金卡但活跃不足时停留在银卡
+	assert.Equal(t, 1, defs.Resolve(8000, 5))
+	assert.Equal(t, 2, defs.Resolve(8000, 10))
+}
+
+func TestDefinitionsValidate(t *testing.T) {
+	cases := map[string]*Definitions{
+		"empty":     {},
+		"duplicate": {Tiers: []Tier{{Key: "a"}, {Key: "a"}}},
+		"decrease":  {Tiers: []Tier{{Key: "a", MinPoints: 10}, {Key: "b", MinPoints: 5}}},
+		"activity":  {Tiers: []Tier{{Key: "a"}, {Key: "b", MinActivity: 3}}},
+	}
+	for name, defs := range cases {
+		assert.ErrorIs(t, defs.validate(), ErrInvalidDefinitions, name)
+	}
+}
@@ -0,0 +1,169 @@
+package track
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+	"usergrowth/internal/event"
+	"usergrowth/internal/logs"
+)
+
+var ErrQueueFull = errors.New("track queue full")
+
+// 队列满时的处理策略
+const (
+	OverflowBlock  = "block"  // 等待至多 BlockTimeout，超时后拒绝
+	OverflowDrop   = "drop"   // 丢弃新事件，请求照常成功
+	OverflowReject = "reject" // 立即拒绝，由客户端重试
+)
+
+// Sink 是事件的最终落地位置，Write 需要能处理一整批事件
+type Sink interface {
+	Write(ctx context.Context, events []event.UserEvent) error
+	Close() error
+}
+
+type PipelineOptions struct {
+	QueueSize     int
+	Workers       int
+	BatchSize     int
+	FlushInterval time.Duration
+	Overflow      string
+	BlockTimeout  time.Duration
+}
+
+// Pipeline 是进程内有界队列，worker 攒批后异步写入 Sink
+type Pipeline struct {
+	queue   chan event.UserEvent
+	sink    Sink
+	opts    PipelineOptions
+	logger  logs.Logger
+	wg      sync.WaitGroup
+	dropped atomic.Int64
+	mu      sync.RWMutex // 保护 queue 关闭，避免向已关闭的 channel 写入
+	closed  bool
+}
+
+func NewPipeline(sink Sink, opts PipelineOptions, logger logs.Logger) *Pipeline {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 10000
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 200
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	p := &Pipeline{
+		queue:  make(chan event.UserEvent, opts.QueueSize),
+		sink:   sink,
+		opts:   opts,
+		logger: logger,
+	}
+	for i := 0; i < opts.Workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	return p
+}
+
+// Enqueue 按配置的策略处理背压，返回实际入队条数
+func (p *Pipeline) Enqueue(ctx context.Context, events []event.UserEvent) (int, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return 0, ErrQueueFull
+	}
+	// reject 模式下整批要么全部入队要么全部拒绝，避免客户端重试造成重复
+	if p.opts.Overflow == OverflowReject && cap(p.queue)-len(p.queue) < len(events) {
+		return 0, ErrQueueFull
+	}
+
+	accepted := 0
+	for _, e := range events {
+		select {
+		case p.queue <- e:
+			accepted++
+			continue
+		default:
+		}
+
+		switch p.opts.Overflow {
+		case OverflowBlock:
+			timer := time.NewTimer(p.opts.BlockTimeout)
+			select {
+			case p.queue <- e:
+				timer.Stop()
+				accepted++
+			case <-timer.C:
+				return accepted, ErrQueueFull
+			case <-ctx.Done():
+				timer.Stop()
+				return accepted, ctx.Err()
+			}
+		case OverflowDrop:
+			p.dropped.Add(1)
+		default:
+			return accepted, ErrQueueFull
+		}
+	}
+	return accepted, nil
+}
+
+func (p *Pipeline) Dropped() int64 {
+	return p.dropped.Load()
+}
+
+func (p *Pipeline) worker() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]event.UserEvent, 0, p.opts.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx := context.Background()
+		if err := p.sink.Write(ctx, batch); err != nil {
+			p.logger.Error(ctx, "track sink write failed:", len(batch), "events", err.Error())
+		}
+		batch = make([]event.UserEvent, 0, p.opts.BatchSize)
+	}
+
+	for {
+		select {
+		case e, ok := <-p.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= p.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Close 停止接收新事件并等待队列内事件写完
+func (p *Pipeline) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	return p.sink.Close()
+}
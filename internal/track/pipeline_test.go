@@ -0,0 +1,112 @@
+package track
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	config "usergrowth/configs"
+	"usergrowth/internal/event"
+	"usergrowth/internal/logs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memorySink struct {
+	mu      sync.Mutex
+	events  []event.UserEvent
+	release chan struct{}
+}
+
+func (s *memorySink) Write(ctx context.Context, events []event.UserEvent) error {
+	if s.release != nil {
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *memorySink) Close() error { return nil }
+
+func batchOf(n int) []event.UserEvent {
+	events := make([]event.UserEvent, n)
+	for i := range events {
+		events[i] = event.UserEvent{Name: "page_view"}
+	}
+	return events
+}
+
+func TestPipelineFlushOnClose(t *testing.T) {
+	sink := &memorySink{}
+	p := NewPipeline(sink, PipelineOptions{QueueSize: 100, Workers: 2, BatchSize: 7, FlushInterval: time.Hour}, logs.Nop())
+
+	n, err := p.Enqueue(context.Background(), batchOf(50))
+	assert.NoError(t, err)
+	assert.Equal(t, 50, n)
+	assert.NoError(t, p.Close())
+	assert.Len(t, sink.events, 50)
+
+	_, err = p.Enqueue(context.Background(), batchOf(1))
+	assert.ErrorIs(t, err, ErrQueueFull)
+}
+
+func TestPipelineOverflow(t *testing.T) {
+	cases := []struct {
+		overflow string
+		accepted int
+		dropped  int64
+		err      error
+	}{
+		{OverflowReject, 0, 0, ErrQueueFull},
+		{OverflowDrop, 3, 2, nil},
+		{OverflowBlock, 3, 0, ErrQueueFull},
+	}
+	for _, c := range cases {
+		// worker 取出第一条后阻塞在 sink，队列容量 3，因此最多再入队 3 条
+		sink := &memorySink{release: make(chan struct{})}
+		p := NewPipeline(sink, PipelineOptions{
+			QueueSize: 3, Workers: 1, BatchSize: 1, FlushInterval: time.Hour,
+			Overflow: c.overflow, BlockTimeout: 20 * time.Millisecond,
+		}, logs.Nop())
+		_, err := p.Enqueue(context.Background(), batchOf(1))
+		assert.NoError(t, err)
+		time.Sleep(20 * time.Millisecond)
+
+		n, err := p.Enqueue(context.Background(), batchOf(5))
+		assert.Equal(t, c.err, err, c.overflow)
+		assert.Equal(t, c.accepted, n, c.overflow)
+		assert.Equal(t, c.dropped, p.Dropped(), c.overflow)
+
+		close(sink.release)
+		assert.NoError(t, p.Close())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tr := NewTrack(nil, &config.TrackConfig{MaxEventAge: 24 * time.Hour, MaxPropertyBytes: 64}, nil, logs.Nop())
+	now := time.Now()
+
+	e, err := tr.validate(&ClientEvent{Name: "page_view", Properties: map[string]any{"path": "/"}}, 1, now)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"path":"/"}`, e.Properties)
+		assert.Equal(t, now, e.CreatedAt)
+	}
+
+	for _, c := range []ClientEvent{
+		{Name: "Page-View"},
+		// 服务端事件名不能由客户端上报
+		{Name: event.NameLogin},
+		{Name: event.NameRegister},
+		{Name: "points_changed"},
+		{Name: "page_view", Timestamp: now.Add(-48 * time.Hour).UnixMilli()},
+		{Name: "page_view", Timestamp: now.Add(time.Hour).UnixMilli()},
+		{Name: "page_view", Properties: map[string]any{"x": string(make([]byte, 100))}},
+	} {
+		_, err = tr.validate(&c, 1, now)
+		assert.Error(t, err, c.Name)
+	}
+	_, err = tr.validate(&ClientEvent{Name: "page_view"}, 0, now)
+	assert.ErrorContains(t, err, "anonymous_id")
+}
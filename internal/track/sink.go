@@ -0,0 +1,176 @@
+package track
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	config "usergrowth/configs"
+	"usergrowth/internal/event"
+
+	elastic "github.com/elastic/go-elasticsearch/v8"
+)
+
+const (
+	SinkMySQL         = "mysql"
+	SinkFile          = "file"
+	SinkElasticsearch = "elasticsearch"
+)
+
+// NewSink 按配置选择落地方式
+func NewSink(cfg *config.Config, events event.EventRepository) (Sink, error) {
+	switch cfg.Track.Sink {
+	case SinkMySQL:
+		return NewMySQLSink(events), nil
+	case SinkFile:
+		return NewFileSink(cfg.Track.FilePath)
+	case SinkElasticsearch:
+		return NewElasticsearchSink(cfg, cfg.Track.EsIndex)
+	}
+	return nil, fmt.Errorf("unknown track sink: %s", cfg.Track.Sink)
+}
+
+type mysqlSink struct {
+	events event.EventRepository
+}
+
+// NewMySQLSink 写入 user_events 表，与服务端事件共用，便于分群与漏斗统一查询
+func NewMySQLSink(events event.EventRepository) Sink {
+	return &mysqlSink{events: events}
+}
+
+func (s *mysqlSink) Write(ctx context.Context, events []event.UserEvent) error {
+	return s.events.InsertBatch(ctx, events)
+}
+
+func (s *mysqlSink) Close() error {
+	return nil
+}
+
+type fileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink 以 NDJSON 追加写入本地文件，可由 filebeat 采集
+func NewFileSink(path string) (Sink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{file: f}, nil
+}
+
+func (s *fileSink) Write(ctx context.Context, events []event.UserEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := bufio.NewWriter(s.file)
+	enc := json.NewEncoder(w)
+	for i := range events {
+		if err := enc.Encode(&events[i]); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+type elasticsearchSink struct {
+	client *elastic.Client
+	index  string
+}
+
+func NewElasticsearchSink(cfg *config.Config, index string) (Sink, error) {
+	client, err := elastic.NewClient(elastic.Config{
+		Addresses: []string{fmt.Sprintf("http://%s:%s", cfg.Elasticsearch.Host, strconv.Itoa(cfg.Elasticsearch.Port))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &elasticsearchSink{client: client, index: index}, nil
+}
+
+// Write 使用 _bulk 接口一次写入整批事件
+func (s *elasticsearchSink) Write(ctx context.Context, events []event.UserEvent) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range events {
+		if err := enc.Encode(map[string]any{"index": map[string]any{"_index": s.index}}); err != nil {
+			return err
+		}
+		if err := enc.Encode(&events[i]); err != nil {
+			return err
+		}
+	}
+
+	res, err := s.client.Bulk(&buf, s.client.Bulk.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(res.Body)
+
+	if res.IsError() {
+		return fmt.Errorf("es bulk error: %s", res.String())
+	}
+	var body struct {
+		Errors bool `json:"errors"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return err
+	}
+	if body.Errors {
+		return fmt.Errorf("es bulk partially failed")
+	}
+	return nil
+}
+
+func (s *elasticsearchSink) Close() error {
+	return nil
+}
+
+type multiSink struct {
+	sinks []Sink
+}
+
+// NewMultiSink 把同一批事件依次写入多个 Sink，单个失败不影响其余
+func NewMultiSink(sinks ...Sink) Sink {
+	return &multiSink{sinks: sinks}
+}
+
+func (s *multiSink) Write(ctx context.Context, events []event.UserEvent) error {
+	var errs []error
+	for _, sink := range s.sinks {
+		if err := sink.Write(ctx, events); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *multiSink) Close() error {
+	var errs []error
+	for _, sink := range s.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,188 @@
+package track
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+	config "usergrowth/configs"
+	"usergrowth/internal/badge"
+	"usergrowth/internal/event"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/points"
+	"usergrowth/internal/referral"
+	"usergrowth/internal/tier"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+var eventNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// reservedNames 是服务端写入 user_events 的事件名，活跃度、归因激活、漏斗与实验转化按名字统计，
+// 不允许客户端以这些名字上报；新增服务端事件时需加入此列表
+var reservedNames = map[string]struct{}{
+	event.NameRegister:   {},
+	event.NameLogin:      {},
+	points.NameChanged:   {},
+	tier.NameChanged:     {},
+	badge.NameEarned:     {},
+	referral.NameInvited: {},
+}
+
+// 事件时间允许的时钟偏差
+const maxClockSkew = 5 * time.Minute
+
+type ClientEvent struct {
+	Name          string         `json:"name"`
+	Timestamp     int64          `json:"timestamp"` // 毫秒时间戳，缺省为服务端接收时间
+	Properties    map[string]any `json:"properties"`
+	AnonymousID   string         `json:"anonymous_id"`
+	SchemaVersion int            `json:"schema_version"` // 按指定版本校验属性，缺省为最新版本
+}
+
+type TrackReq struct {
+	g.Meta `path:"/api/track" method:"post"`
+	Events []ClientEvent `json:"events" v:"required#事件列表不能为空"`
+}
+
+type TrackRes struct {
+	Accepted int `json:"accepted"`
+}
+
+// Validator 在入队前按事件 schema 校验属性并标记结果，返回错误时拒绝整批
+type Validator interface {
+	Check(ctx context.Context, e *event.UserEvent, props map[string]any, version int) error
+}
+
+type Track struct {
+	pipeline   *Pipeline
+	cfg        *config.TrackConfig
+	validator  Validator
+	userLogger logs.Logger
+}
+
+// NewTrack 的 validator 为 nil 时不做 schema 校验
+func NewTrack(pipeline *Pipeline, cfg *config.TrackConfig, validator Validator, logger logs.Logger) *Track {
+	return &Track{
+		pipeline:   pipeline,
+		cfg:        cfg,
+		validator:  validator,
+		userLogger: logger,
+	}
+}
+
+// validate 校验并补全单个事件，返回的错误信息会直接展示给调用方
+func (t *Track) validate(e *ClientEvent, userID uint, now time.Time) (*event.UserEvent, error) {
+	if !eventNamePattern.MatchString(e.Name) {
+		return nil, fmt.Errorf("事件名不合法: %q", e.Name)
+	}
+	if _, ok := reservedNames[e.Name]; ok {
+		return nil, fmt.Errorf("事件名 %s 为服务端保留", e.Name)
+	}
+	if userID == 0 && e.AnonymousID == "" {
+		return nil, fmt.Errorf("事件 %s 缺少 anonymous_id", e.Name)
+	}
+	if len(e.AnonymousID) > 64 {
+		return nil, fmt.Errorf("事件 %s 的 anonymous_id 过长", e.Name)
+	}
+
+	occurred := now
+	if e.Timestamp > 0 {
+		occurred = time.UnixMilli(e.Timestamp)
+	}
+	if occurred.After(now.Add(maxClockSkew)) || occurred.Before(now.Add(-t.cfg.MaxEventAge)) {
+		return nil, fmt.Errorf("事件 %s 的时间超出允许范围", e.Name)
+	}
+
+	props := "{}"
+	if len(e.Properties) > 0 {
+		b, err := json.Marshal(e.Properties)
+		if err != nil {
+			return nil, fmt.Errorf("事件 %s 的属性无法序列化", e.Name)
+		}
+		if len(b) > t.cfg.MaxPropertyBytes {
+			return nil, fmt.Errorf("事件 %s 的属性超过 %d 字节", e.Name, t.cfg.MaxPropertyBytes)
+		}
+		props = string(b)
+	}
+
+	return &event.UserEvent{
+		UserID:      userID,
+		Name:        e.Name,
+		Properties:  props,
+		AnonymousID: e.AnonymousID,
+		CreatedAt:   occurred,
+		ReceivedAt:  now,
+	}, nil
+}
+
+func (t *Track) Track(ctx context.Context, req *TrackReq) (res *TrackRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Track")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	if len(req.Events) > t.cfg.MaxBatchEvents {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, fmt.Sprintf("单次最多上报 %d 个事件", t.cfg.MaxBatchEvents))
+	}
+
+	// 登录态可选，未登录时依赖 anonymous_id
+	var userID uint
+	if uid, err := strconv.ParseUint(r.GetCtxVar("userid").String(), 10, 64); err == nil {
+		userID = uint(uid)
+	}
+
+	now := time.Now()
+	ip := r.GetClientIp()
+	ua := r.UserAgent()
+	if len(ua) > 512 {
+		ua = ua[:512]
+	}
+	traceID := gtrace.GetTraceID(ctx)
+
+	events := make([]event.UserEvent, 0, len(req.Events))
+	for i := range req.Events {
+		e, err := t.validate(&req.Events[i], userID, now)
+		if err != nil {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, err.Error())
+		}
+		if t.validator != nil {
+			if err = t.validator.Check(ctx, e, req.Events[i].Properties, req.Events[i].SchemaVersion); err != nil {
+				return nil, gerror.NewCode(gcode.CodeValidationFailed, err.Error())
+			}
+		}
+		e.IP = ip
+		e.UserAgent = ua
+		e.TraceID = traceID
+		events = append(events, *e)
+	}
+
+	accepted, err := t.pipeline.Enqueue(ctx, events)
+	if err != nil {
+		t.userLogger.Info(ctx, "Track rejected:", err.Error(), "accepted:", accepted, "total:", len(events))
+		if errors.Is(err, ErrQueueFull) {
+			r.Response.WriteStatus(http.StatusTooManyRequests)
+			r.Response.WriteJson(g.Map{
+				"code":    http.StatusTooManyRequests,
+				"message": "事件队列繁忙，请稍后重试",
+				"data":    &TrackRes{Accepted: accepted},
+			})
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    &TrackRes{Accepted: accepted},
+	})
+	return nil, nil
+}
@@ -0,0 +1,67 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/middleware"
+	"usergrowth/redis"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+type BanReq struct {
+	g.Meta `path:"/api/admin/users/{id}/ban" method:"post"`
+	UserID uint   `p:"id" v:"required"`
+	Reason string `json:"reason" v:"required|max-length:255#封禁原因不能为空|封禁原因过长"`
+}
+
+type BanRes struct {
+}
+
+type Admin struct {
+	repo       UserRepository
+	rdb        redis.Cache
+	userLogger logs.Logger
+}
+
+func NewAdmin(repo UserRepository, rdb redis.Cache, logger logs.Logger) *Admin {
+	return &Admin{
+		repo:       repo,
+		rdb:        rdb,
+		userLogger: logger,
+	}
+}
+
+// Ban 封禁用户，之后无法再登录，已签发的 token 同时吊销
+func (params *Admin) Ban(ctx context.Context, req *BanReq) (res *BanRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	err = params.repo.Ban(req.UserID, req.Reason, time.Now())
+	if err != nil && !errors.Is(err, ErrUserBanned) {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "用户不存在")
+		}
+		return nil, err
+	}
+	// 已封禁时也重新吊销，上次吊销失败后可以重试
+	if err2 := middleware.RevokeTokens(ctx, params.rdb, strconv.FormatUint(uint64(req.UserID), 10)); err2 != nil {
+		params.userLogger.Error(ctx, "User ban token revoke failed:", req.UserID, err2.Error())
+		return nil, err2
+	}
+	if errors.Is(err, ErrUserBanned) {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "用户已被封禁")
+	}
+
+	params.userLogger.Info(ctx, "User banned:", req.UserID, "reason:", req.Reason,
+		"operator:", r.GetCtxVar("userid").String())
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "user banned",
+	})
+	return nil, nil
+}
@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/gogf/gf/v2/frame/g"
 )
@@ -11,27 +12,47 @@ type AuthReq struct {
 }
 
 type AuthRes struct {
-	UserID string `json:"userid"`
+	UserID     string      `json:"userid"`
+	Membership *Membership `json:"membership,omitempty"`
+}
+
+// Membership 是会员等级摘要，由会员模块提供
+type Membership struct {
+	Tier     string `json:"tier"`
+	TierName string `json:"tier_name"`
+	Points   int64  `json:"points"`
+}
+
+type MembershipProvider interface {
+	Membership(ctx context.Context, userID uint) (*Membership, error)
 }
 
 type AuthController struct {
+	memberships MembershipProvider
 }
 
-func NewAuthController() *AuthController {
-	return &AuthController{}
+func NewAuthController(memberships MembershipProvider) *AuthController {
+	return &AuthController{memberships: memberships}
 }
 
 func (c *AuthController) Check(ctx context.Context, req *AuthReq) (res *AuthRes, err error) {
 	r := g.RequestFromCtx(ctx)
 
 	userid := r.GetCtxVar("userid").String()
+	data := &AuthRes{
+		UserID: userid,
+	}
+	// 等级信息查询失败不影响登录态校验
+	if uid, err := strconv.ParseUint(userid, 10, 64); err == nil && c.memberships != nil {
+		if membership, err := c.memberships.Membership(ctx, uint(uid)); err == nil {
+			data.Membership = membership
+		}
+	}
 
 	r.Response.WriteJson(g.Map{
 		"code":    200,
 		"message": "authenticated",
-		"data": &AuthRes{
-			UserID: userid,
-		},
+		"data":    data,
 	})
 	return nil, nil
 }
@@ -0,0 +1,26 @@
+package user
+
+import (
+	"context"
+	"usergrowth/internal/bus"
+	"usergrowth/internal/logs"
+)
+
+// TopicRegistered 在注册成功后发布，适合不依赖请求上下文、可以异步完成的后续处理
+var TopicRegistered = bus.NewTopic[Registration]("user.registered")
+
+type registeredPublisher struct {
+	bus    bus.EventBus
+	logger logs.Logger
+}
+
+// PublishRegistered 返回把注册结果发布到总线的钩子，发布失败只记录日志
+func PublishRegistered(b bus.EventBus, logger logs.Logger) RegisterHook {
+	return &registeredPublisher{bus: b, logger: logger}
+}
+
+func (p *registeredPublisher) OnRegister(ctx context.Context, reg *Registration) {
+	if err := TopicRegistered.Publish(ctx, p.bus, reg); err != nil {
+		p.logger.Error(ctx, "user registered publish failed:", reg.UserID, err.Error())
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
+	"usergrowth/internal/event"
 	"usergrowth/internal/logs"
 	"usergrowth/middleware"
 	"usergrowth/redis"
@@ -28,16 +30,25 @@ type LoginReq struct {
 type LoginRes struct {
 }
 
+// LoginNotifier 在登录成功后被调用，如新设备登录提醒
+type LoginNotifier interface {
+	OnLogin(ctx context.Context, userID uint, ip, userAgent string)
+}
+
 type Login struct {
 	rdb        redis.Cache
 	repo       UserRepository
+	events     event.EventRepository
+	notifier   LoginNotifier
 	userLogger logs.Logger
 }
 
-func NewLogin(rdb redis.Cache, repo UserRepository, logger logs.Logger) *Login {
+func NewLogin(rdb redis.Cache, repo UserRepository, events event.EventRepository, notifier LoginNotifier, logger logs.Logger) *Login {
 	return &Login{
 		rdb:        rdb,
 		repo:       repo,
+		events:     events,
+		notifier:   notifier,
 		userLogger: logger,
 	}
 }
@@ -66,6 +77,10 @@ func (params *Login) Login(ctx context.Context, req *LoginReq) (res *LoginRes, e
 		params.userLogger.Info(ctx, "Login wrong password: ", req.Username)
 		return nil, gerror.NewCode(gcode.CodeNotAuthorized, "用户名或密码错误")
 	}
+	if user.BannedAt != nil {
+		params.userLogger.Info(ctx, "Login banned user: ", req.Username)
+		return nil, gerror.NewCode(gcode.CodeNotAuthorized, "账号已被封禁")
+	}
 
 	token, err := middleware.GenerateToken(strconv.Itoa(int(user.UserID)))
 	if err != nil {
@@ -90,6 +105,16 @@ func (params *Login) Login(ctx context.Context, req *LoginReq) (res *LoginRes, e
 
 	params.userLogger.Info(ctx, "Login success: ", req.Username, "userid: ", user.UserID)
 
+	if err = params.events.Record(ctx, user.UserID, event.NameLogin, nil); err != nil {
+		params.userLogger.Info(ctx, "Login event record failed: ", err.Error())
+	}
+	if err = params.repo.RecordLogin(user.UserID, r.GetClientIp(), r.UserAgent(), time.Now()); err != nil {
+		params.userLogger.Info(ctx, "Login outbox record failed: ", err.Error())
+	}
+	if params.notifier != nil {
+		params.notifier.OnLogin(ctx, user.UserID, r.GetClientIp(), r.UserAgent())
+	}
+
 	r.Response.WriteJson(g.Map{
 		"code":    200,
 		"message": "login success",
@@ -6,6 +6,9 @@ import (
 	"encoding/hex"
 	"errors"
 	"strconv"
+	"strings"
+	"time"
+	"usergrowth/internal/event"
 	"usergrowth/internal/logs"
 
 	"github.com/gogf/gf/v2/errors/gcode"
@@ -19,20 +22,55 @@ type RegisterReq struct {
 	g.Meta   `path:"/user/register" method:"post"`
 	Username string `json:"username" v:"required#用户名不能为空"`
 	Password string `json:"password" v:"required#密码不能为空"`
+	Email    string `json:"email" v:"email#邮箱格式不正确"`
+	// 注册前的匿名 ID，用于把注册前的埋点事件归到该用户
+	AnonymousID string `json:"anonymous_id" v:"max-length:64"`
+	InviteCode  string `json:"invite_code" v:"max-length:32"`
+	DeviceID    string `json:"device_id" v:"max-length:128"`   // 客户端生成的设备指纹
+	InviteToken string `json:"invite_token" v:"max-length:64"` // 候补名单放行后发放的注册凭证
 }
 type RegisterRes struct {
 }
 
+// Registration 是注册成功后传给其他模块的上下文
+type Registration struct {
+	UserID      uint
+	Username    string
+	Email       string
+	InviteCode  string
+	InviteToken string
+	DeviceID    string
+	AnonymousID string
+	IP          string
+	UserAgent   string
+	CreatedAt   time.Time
+}
+
+// RegisterHook 在用户创建成功后按注册顺序同步调用，失败由实现方自行记录，不影响注册结果
+type RegisterHook interface {
+	OnRegister(ctx context.Context, reg *Registration)
+}
+
+// RegisterGate 在创建用户前决定是否允许注册，返回的错误直接作为接口错误；
+// 占用了名额（如邀请凭证）时返回 release，创建失败时调用以归还
+type RegisterGate interface {
+	Admit(ctx context.Context, req *RegisterReq) (release func(), err error)
+}
+
 type Register struct {
 	repo       UserRepository
+	events     event.EventRepository
 	userLogger logs.Logger
+	hooks      []RegisterHook
+	gate       RegisterGate
 }
 
-func NewRegister(repo UserRepository, logger logs.Logger) *Register {
-	return &Register{repo, logger}
+// NewRegister 的 gate 为 nil 时开放注册，hooks 在注册成功后按顺序调用
+func NewRegister(repo UserRepository, events event.EventRepository, gate RegisterGate, hooks []RegisterHook, logger logs.Logger) *Register {
+	return &Register{repo: repo, events: events, gate: gate, hooks: hooks, userLogger: logger}
 }
 
-func (params Register) Register(ctx context.Context, req *RegisterReq) (res *RegisterRes, err error) {
+func (params *Register) Register(ctx context.Context, req *RegisterReq) (res *RegisterRes, err error) {
 
 	ctx, span := gtrace.NewSpan(ctx, "Register")
 	defer span.End()
@@ -44,9 +82,21 @@ func (params Register) Register(ctx context.Context, req *RegisterReq) (res *Reg
 	user := &Users{
 		Username: req.Username,
 		Password: hashPass,
+		Email:    strings.ToLower(strings.TrimSpace(req.Email)),
+	}
+
+	var release func()
+	if params.gate != nil {
+		if release, err = params.gate.Admit(ctx, req); err != nil {
+			params.userLogger.Info(ctx, "Register rejected:", req.Username, err.Error())
+			return nil, err
+		}
 	}
 
 	if err = params.repo.CreateUser(user); err != nil {
+		if release != nil {
+			release()
+		}
 		if errors.Is(err, ErrDuplicateUser) {
 			params.userLogger.Info(ctx, "Register user exists:", req.Username)
 			return nil, gerror.NewCode(gcode.CodeValidationFailed, "用户已存在")
@@ -59,6 +109,32 @@ func (params Register) Register(ctx context.Context, req *RegisterReq) (res *Reg
 	span.SetAttributes(attribute.String("user.id", strconv.Itoa(int(user.UserID))))
 	params.userLogger.Info(ctx, "Register success:", req.Username)
 
+	if err = params.events.Create(ctx, &event.UserEvent{
+		UserID:      user.UserID,
+		Name:        event.NameRegister,
+		AnonymousID: req.AnonymousID,
+		IP:          r.GetClientIp(),
+		UserAgent:   r.UserAgent(),
+	}); err != nil {
+		params.userLogger.Info(ctx, "Register event record failed:", err.Error())
+	}
+
+	reg := &Registration{
+		UserID:      user.UserID,
+		Username:    user.Username,
+		Email:       user.Email,
+		InviteCode:  strings.TrimSpace(req.InviteCode),
+		InviteToken: strings.TrimSpace(req.InviteToken),
+		DeviceID:    req.DeviceID,
+		AnonymousID: req.AnonymousID,
+		IP:          r.GetClientIp(),
+		UserAgent:   r.UserAgent(),
+		CreatedAt:   user.CreatedAt,
+	}
+	for _, hook := range params.hooks {
+		hook.OnRegister(ctx, reg)
+	}
+
 	r.Response.WriteJson(g.Map{
 		"code":    200,
 		"message": "register success",
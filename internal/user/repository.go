@@ -2,6 +2,9 @@ package user
 
 import (
 	"errors"
+	"time"
+
+	"usergrowth/internal/outbox"
 
 	"github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
@@ -11,10 +14,24 @@ var ErrUserNotFound = errors.New("user not found")
 
 var ErrDuplicateUser = errors.New("user already exists")
 
+var ErrUserBanned = errors.New("user banned")
+
+// 写入 outbox 的用户生命周期事件；本服务没有邮箱/手机验证流程，不产生验证事件
+const (
+	EventRegistered = "user.registered"
+	EventLoggedIn   = "user.logged_in"
+	EventBanned     = "user.banned"
+)
+
 type Users struct {
-	UserID   uint   `gorm:"primaryKey;autoIncrement"`               // 自增主键
-	Username string `gorm:"type:varchar(255);not null;uniqueIndex"` // 唯一索引
-	Password string `gorm:"type:varchar(255);not null"`
+	UserID      uint       `gorm:"primaryKey;autoIncrement"`               // 自增主键
+	Username    string     `gorm:"type:varchar(255);not null;uniqueIndex"` // 唯一索引
+	Password    string     `gorm:"type:varchar(255);not null"`
+	Email       string     `gorm:"type:varchar(255);index"` // 选填
+	CreatedAt   time.Time  // 注册时间
+	LastLoginAt *time.Time // 最近登录时间
+	BannedAt    *time.Time // 封禁时间，非空表示已封禁
+	BanReason   string     `gorm:"type:varchar(255)"`
 }
 type userRepository struct {
 	db *gorm.DB
@@ -22,9 +39,20 @@ type userRepository struct {
 type UserRepository interface {
 	CreateUser(user *Users) error
 	FindUserByUsername(username string) (*Users, error)
+	FindUserByID(userID uint) (*Users, error)
+	ListUsers(afterID uint, limit int) ([]Users, error)
+	// ListDormant 返回最近登录（从未登录则为注册）早于 cutoff 且未封禁的用户
+	ListDormant(cutoff time.Time, afterID uint, limit int) ([]Users, error)
+	// RecordLogin 与 Ban 在同一事务中更新用户并写入 outbox 事件
+	RecordLogin(userID uint, ip, userAgent string, at time.Time) error
+	Ban(userID uint, reason string, at time.Time) error
 }
 
 func NewUserRepository(db *gorm.DB) UserRepository {
+	// 登录、封禁会写入新增的列，启动时即迁移
+	if err := db.AutoMigrate(&Users{}); err != nil {
+		panic("failed to migrate table")
+	}
 	return &userRepository{db: db}
 }
 
@@ -34,7 +62,16 @@ func (repo *userRepository) CreateUser(user *Users) error {
 	if err != nil {
 		panic("failed to migrate table")
 	}
-	if err = repo.db.Create(user).Error; err != nil {
+	err = repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return outbox.Append(tx, EventRegistered, user.UserID, map[string]any{
+			"username": user.Username,
+			"email":    user.Email,
+		})
+	})
+	if err != nil {
 		var mysqlErr *mysql.MySQLError
 		if errors.As(err, &mysqlErr) {
 			if mysqlErr.Number == 1062 { // Error 1062: Duplicate entry
@@ -60,3 +97,64 @@ func (repo *userRepository) FindUserByUsername(username string) (*Users, error)
 
 	return &user, nil
 }
+
+func (repo *userRepository) FindUserByID(userID uint) (*Users, error) {
+	var user Users
+	err := repo.db.First(&user, userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// ListUsers 按主键游标分页，避免大表 OFFSET 扫描
+func (repo *userRepository) ListUsers(afterID uint, limit int) ([]Users, error) {
+	var users []Users
+	err := repo.db.Where("user_id > ?", afterID).Order("user_id").Limit(limit).Find(&users).Error
+	return users, err
+}
+
+func (repo *userRepository) ListDormant(cutoff time.Time, afterID uint, limit int) ([]Users, error) {
+	var users []Users
+	err := repo.db.Where("user_id > ? AND banned_at IS NULL AND COALESCE(last_login_at, created_at) < ?", afterID, cutoff).
+		Order("user_id").Limit(limit).Find(&users).Error
+	return users, err
+}
+
+func (repo *userRepository) RecordLogin(userID uint, ip, userAgent string, at time.Time) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Users{}).Where("user_id = ?", userID).Update("last_login_at", at).Error; err != nil {
+			return err
+		}
+		return outbox.Append(tx, EventLoggedIn, userID, map[string]any{
+			"ip":         ip,
+			"user_agent": userAgent,
+		})
+	})
+}
+
+// Ban 对已封禁的用户不重复写入事件
+func (repo *userRepository) Ban(userID uint, reason string, at time.Time) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Users{}).Where("user_id = ? AND banned_at IS NULL", userID).
+			Updates(map[string]any{"banned_at": at, "ban_reason": reason})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			var n int64
+			if err := tx.Model(&Users{}).Where("user_id = ?", userID).Count(&n).Error; err != nil {
+				return err
+			}
+			if n == 0 {
+				return ErrUserNotFound
+			}
+			return ErrUserBanned
+		}
+		return outbox.Append(tx, EventBanned, userID, map[string]any{"reason": reason})
+	})
+}
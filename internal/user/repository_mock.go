@@ -20,3 +20,21 @@ func (m *MockUserRepository) CreateUser(user *Users) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
+
+func (m *MockUserRepository) FindUserByID(userID uint) (*Users, error) {
+	args := m.Called(userID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Users), args.Error(1)
+}
+
+func (m *MockUserRepository) ListUsers(afterID uint, limit int) ([]Users, error) {
+	args := m.Called(afterID, limit)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Users), args.Error(1)
+}
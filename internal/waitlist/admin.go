@@ -0,0 +1,81 @@
+package waitlist
+
+import (
+	"context"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+type ReleaseReq struct {
+	g.Meta `path:"/api/admin/waitlist/release" method:"post"`
+	Count  int `json:"count" v:"required|between:1,1000#放行人数不能为空|放行人数应在1到1000之间"`
+}
+
+type ReleaseRes struct {
+}
+
+type ListReq struct {
+	g.Meta `path:"/api/admin/waitlist" method:"get"`
+	Status string `p:"status" v:"in:waiting,invited,claimed,registered#状态不合法"` // 为空表示全部
+	After  uint   `p:"after"`                                                  // 上一页最后一条的 entry_id
+	Limit  int    `p:"limit" d:"50" v:"between:1,200#条数应在1到200之间"`
+}
+
+type ListRes struct {
+}
+
+type Admin struct {
+	repo       WaitlistRepository
+	service    *Service
+	userLogger logs.Logger
+}
+
+func NewAdmin(repo WaitlistRepository, service *Service, logger logs.Logger) *Admin {
+	return &Admin{
+		repo:       repo,
+		service:    service,
+		userLogger: logger,
+	}
+}
+
+// Release 放行排在最前的若干候补，返回的凭证同时通过 waitlist.invited 事件推送给下游
+func (params *Admin) Release(ctx context.Context, req *ReleaseReq) (res *ReleaseRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	entries, err := params.service.Release(ctx, req.Count)
+	if err != nil {
+		return nil, err
+	}
+	invited := make([]g.Map, 0, len(entries))
+	for _, e := range entries {
+		invited = append(invited, g.Map{
+			"entry_id":          e.EntryID,
+			"email":             e.Email,
+			"invite_token":      *e.InviteToken,
+			"invite_expires_at": e.InviteExpiresAt,
+		})
+	}
+	params.userLogger.Info(ctx, "Waitlist release by admin:", r.GetCtxVar("userid").String(), "count:", len(entries))
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "released",
+		"data":    invited,
+	})
+	return nil, nil
+}
+
+func (params *Admin) List(ctx context.Context, req *ListReq) (res *ListRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	entries, err := params.repo.List(req.Status, req.After, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    entries,
+	})
+	return nil, nil
+}
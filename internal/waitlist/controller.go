@@ -0,0 +1,85 @@
+package waitlist
+
+import (
+	"context"
+	"errors"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+type JoinReq struct {
+	g.Meta       `path:"/user/waitlist" method:"post"`
+	Email        string `json:"email" v:"required|email|max-length:255#邮箱不能为空|邮箱格式不正确|邮箱过长"`
+	Name         string `json:"name" v:"max-length:64#称呼过长"`
+	ReferralCode string `json:"referral_code" v:"max-length:16"` // 推荐人的候补推荐码
+}
+
+type JoinRes struct {
+}
+
+type StatusReq struct {
+	g.Meta `path:"/user/waitlist/{code}" method:"get"`
+	Code   string `p:"code" v:"required"`
+}
+
+type StatusRes struct {
+}
+
+// Controller 处理候补名单的公开接口，不需要登录
+type Controller struct {
+	service    *Service
+	userLogger logs.Logger
+}
+
+func NewController(service *Service, logger logs.Logger) *Controller {
+	return &Controller{
+		service:    service,
+		userLogger: logger,
+	}
+}
+
+func entryData(entry *Entry, position int64) g.Map {
+	return g.Map{
+		"status":        entry.Status,
+		"position":      position,
+		"referral_code": entry.ReferralCode,
+		"referrals":     entry.Referrals,
+	}
+}
+
+// Join 加入候补名单并返回当前名次，分享推荐码可以提前名次
+func (c *Controller) Join(ctx context.Context, req *JoinReq) (res *JoinRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	entry, position, err := c.service.Join(ctx, req.Email, req.Name, req.ReferralCode)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "joined",
+		"data":    entryData(entry, position),
+	})
+	return nil, nil
+}
+
+func (c *Controller) Status(ctx context.Context, req *StatusReq) (res *StatusRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	entry, position, err := c.service.Status(req.Code)
+	if err != nil {
+		if errors.Is(err, ErrEntryNotFound) {
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "候补记录不存在")
+		}
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    entryData(entry, position),
+	})
+	return nil, nil
+}
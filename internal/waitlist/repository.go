@@ -0,0 +1,228 @@
+package waitlist
+
+import (
+	"errors"
+	"time"
+	"usergrowth/internal/outbox"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrEntryNotFound  = errors.New("waitlist entry not found")
+	ErrDuplicateCode  = errors.New("waitlist referral code already exists")
+	ErrDuplicateEmail = errors.New("waitlist email already exists")
+	ErrInvalidToken   = errors.New("waitlist invite token invalid or expired")
+)
+
+// EventInvited 是放行候补用户时写入 outbox 的事件，由邮件等下游服务订阅后投递凭证；
+// 凭证在注册模式为 invite 时可用于注册
+const EventInvited = "waitlist.invited"
+
+// 候补状态，claimed 表示凭证正在被一次注册占用
+const (
+	StatusWaiting    = "waiting"
+	StatusInvited    = "invited"
+	StatusClaimed    = "claimed"
+	StatusRegistered = "registered"
+)
+
+// Entry 按 Priority 升序排队，Priority 初始为 entry_id，每成功推荐一人减去 ReferralBoost
+type Entry struct {
+	EntryID         uint       `gorm:"primaryKey;autoIncrement" json:"entry_id"`
+	Email           string     `gorm:"type:varchar(255);not null;uniqueIndex" json:"email"`
+	Name            string     `gorm:"type:varchar(64)" json:"name"`
+	ReferralCode    string     `gorm:"type:varchar(16);not null;uniqueIndex" json:"referral_code"`
+	ReferredBy      uint       `gorm:"not null;default:0;index" json:"referred_by"`
+	Referrals       int64      `gorm:"not null;default:0" json:"referrals"`
+	Priority        int64      `gorm:"not null;default:0;index:idx_status_priority,priority:2" json:"-"`
+	Status          string     `gorm:"type:varchar(16);not null;index:idx_status_priority,priority:1" json:"status"`
+	InviteToken     *string    `gorm:"type:varchar(64);uniqueIndex" json:"-"`
+	InvitedAt       *time.Time `json:"invited_at"`
+	InviteExpiresAt *time.Time `json:"invite_expires_at"`
+	UserID          uint       `gorm:"not null;default:0" json:"user_id"`
+	RegisteredAt    *time.Time `json:"registered_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func (Entry) TableName() string {
+	return "waitlist_entries"
+}
+
+type waitlistRepository struct {
+	db *gorm.DB
+}
+
+type WaitlistRepository interface {
+	// Join 写入新的候补，referrerID 非 0 时同一事务内让推荐人前进 boost 名
+	Join(entry *Entry, referrerID uint, boost int64) error
+	FindByEmail(email string) (*Entry, error)
+	FindByCode(code string) (*Entry, error)
+	// Position 返回候补中的名次，从 1 开始；已放行时返回 0
+	Position(entryID uint) (int64, error)
+	// Release 按名次放行前 n 个候补并生成凭证，同一事务内写入 outbox 事件
+	Release(n int, tokens func() string, expiresAt, now time.Time) ([]Entry, error)
+	// Claim 占用绑定到 email 且未过期的凭证，凭证存在但不可用时同时返回记录与 ErrInvalidToken；Unclaim 在注册失败时归还
+	Claim(token, email string, now time.Time) (*Entry, error)
+	Unclaim(token string) error
+	Complete(token string, userID uint, at time.Time) error
+	List(status string, afterID uint, limit int) ([]Entry, error)
+}
+
+func NewWaitlistRepository(db *gorm.DB) WaitlistRepository {
+	if err := db.AutoMigrate(&Entry{}); err != nil {
+		panic("failed to migrate waitlist table")
+	}
+	return &waitlistRepository{db: db}
+}
+
+func (repo *waitlistRepository) Join(entry *Entry, referrerID uint, boost int64) error {
+	entry.Status = StatusWaiting
+	entry.ReferredBy = referrerID
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		entry.Priority = int64(entry.EntryID)
+		if err := tx.Model(entry).Update("priority", entry.Priority).Error; err != nil {
+			return err
+		}
+		if referrerID == 0 {
+			return nil
+		}
+		// 推荐人已被放行时只累计推荐数
+		return tx.Model(&Entry{}).Where("entry_id = ?", referrerID).Updates(map[string]any{
+			"referrals": gorm.Expr("referrals + 1"),
+			"priority":  gorm.Expr("CASE WHEN status = ? THEN priority - ? ELSE priority END", StatusWaiting, boost),
+		}).Error
+	})
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 { // Error 1062: Duplicate entry
+			if _, findErr := repo.FindByEmail(entry.Email); findErr == nil {
+				return ErrDuplicateEmail
+			}
+			return ErrDuplicateCode
+		}
+		return err
+	}
+	return nil
+}
+
+func (repo *waitlistRepository) find(query string, arg any) (*Entry, error) {
+	var entry Entry
+	if err := repo.db.Where(query, arg).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEntryNotFound
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (repo *waitlistRepository) FindByEmail(email string) (*Entry, error) {
+	return repo.find("email = ?", email)
+}
+
+func (repo *waitlistRepository) FindByCode(code string) (*Entry, error) {
+	return repo.find("referral_code = ?", code)
+}
+
+func (repo *waitlistRepository) Position(entryID uint) (int64, error) {
+	var current Entry
+	if err := repo.db.Select("priority", "status").Where("entry_id = ?", entryID).First(&current).Error; err != nil {
+		return 0, err
+	}
+	if current.Status != StatusWaiting {
+		return 0, nil
+	}
+	var ahead int64
+	err := repo.db.Model(&Entry{}).
+		Where("status = ? AND (priority < ? OR (priority = ? AND entry_id < ?))", StatusWaiting, current.Priority, current.Priority, entryID).
+		Count(&ahead).Error
+	return ahead + 1, err
+}
+
+func (repo *waitlistRepository) Release(n int, tokens func() string, expiresAt, now time.Time) ([]Entry, error) {
+	var entries []Entry
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", StatusWaiting).
+			Order("priority, entry_id").Limit(n).Find(&entries).Error
+		if err != nil {
+			return err
+		}
+		for i := range entries {
+			token := tokens()
+			entries[i].Status = StatusInvited
+			entries[i].InviteToken = &token
+			entries[i].InvitedAt = &now
+			entries[i].InviteExpiresAt = &expiresAt
+			err = tx.Model(&entries[i]).Updates(map[string]any{
+				"status":            StatusInvited,
+				"invite_token":      token,
+				"invited_at":        now,
+				"invite_expires_at": expiresAt,
+			}).Error
+			if err != nil {
+				return err
+			}
+			err = outbox.Append(tx, EventInvited, 0, map[string]any{
+				"entry_id":   entries[i].EntryID,
+				"email":      entries[i].Email,
+				"name":       entries[i].Name,
+				"token":      token,
+				"expires_at": expiresAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (repo *waitlistRepository) Claim(token, email string, now time.Time) (*Entry, error) {
+	res := repo.db.Model(&Entry{}).
+		Where("invite_token = ? AND email = ? AND status = ? AND invite_expires_at > ?", token, email, StatusInvited, now).
+		Update("status", StatusClaimed)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	entry, err := repo.find("invite_token = ?", token)
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected == 0 {
+		return entry, ErrInvalidToken
+	}
+	return entry, nil
+}
+
+func (repo *waitlistRepository) Unclaim(token string) error {
+	return repo.db.Model(&Entry{}).
+		Where("invite_token = ? AND status = ?", token, StatusClaimed).
+		Update("status", StatusInvited).Error
+}
+
+func (repo *waitlistRepository) Complete(token string, userID uint, at time.Time) error {
+	return repo.db.Model(&Entry{}).
+		Where("invite_token = ? AND status IN ?", token, []string{StatusInvited, StatusClaimed}).
+		Updates(map[string]any{"status": StatusRegistered, "user_id": userID, "registered_at": at}).Error
+}
+
+func (repo *waitlistRepository) List(status string, afterID uint, limit int) ([]Entry, error) {
+	var entries []Entry
+	db := repo.db.Where("entry_id > ?", afterID)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	err := db.Order("entry_id").Limit(limit).Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,171 @@
+package waitlist
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+	config "usergrowth/configs"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/registration"
+	"usergrowth/internal/user"
+
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+const (
+	codeAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	codeLength   = 8
+	codeAttempts = 5
+)
+
+func generateCode() (string, error) {
+	buf := make([]byte, codeLength)
+	max := big.NewInt(int64(len(codeAlphabet)))
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = codeAlphabet[n.Int64()]
+	}
+	return string(buf), nil
+}
+
+func newToken() string {
+	buf := make([]byte, 24)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// Service 管理候补名单，放行凭证作为邀请注册的凭证来源
+type Service struct {
+	repo   WaitlistRepository
+	cfg    *config.WaitlistConfig
+	now    func() time.Time
+	logger logs.Logger
+}
+
+func NewService(repo WaitlistRepository, cfg *config.WaitlistConfig, logger logs.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		cfg:    cfg,
+		now:    time.Now,
+		logger: logger,
+	}
+}
+
+// Join 加入候补，同一邮箱重复提交返回已有记录；推荐码无效时忽略，不影响加入
+func (s *Service) Join(ctx context.Context, email, name, referralCode string) (*Entry, int64, error) {
+	ctx, span := gtrace.NewSpan(ctx, "Waitlist.Join")
+	defer span.End()
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	if entry, err := s.repo.FindByEmail(email); err == nil {
+		return s.withPosition(entry)
+	} else if !errors.Is(err, ErrEntryNotFound) {
+		return nil, 0, err
+	}
+
+	referrerID := uint(0)
+	if referralCode = strings.TrimSpace(referralCode); referralCode != "" {
+		referrer, err := s.repo.FindByCode(referralCode)
+		switch {
+		case err == nil:
+			referrerID = referrer.EntryID
+		case !errors.Is(err, ErrEntryNotFound):
+			return nil, 0, err
+		}
+	}
+
+	for range codeAttempts {
+		code, err := generateCode()
+		if err != nil {
+			return nil, 0, err
+		}
+		entry := &Entry{Email: email, Name: strings.TrimSpace(name), ReferralCode: code}
+		err = s.repo.Join(entry, referrerID, s.cfg.ReferralBoost)
+		switch {
+		case err == nil:
+			s.logger.Info(ctx, "Waitlist joined:", entry.EntryID, "referred by:", referrerID)
+			return s.withPosition(entry)
+		case errors.Is(err, ErrDuplicateEmail):
+			// 并发提交同一邮箱
+			existing, err := s.repo.FindByEmail(email)
+			if err != nil {
+				return nil, 0, err
+			}
+			return s.withPosition(existing)
+		case !errors.Is(err, ErrDuplicateCode):
+			return nil, 0, err
+		}
+	}
+	return nil, 0, ErrDuplicateCode
+}
+
+// Status 按推荐码查询候补状态与名次，推荐码同时作为查询凭据，避免按邮箱探测
+func (s *Service) Status(code string) (*Entry, int64, error) {
+	entry, err := s.repo.FindByCode(code)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.withPosition(entry)
+}
+
+func (s *Service) withPosition(entry *Entry) (*Entry, int64, error) {
+	position, err := s.repo.Position(entry.EntryID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entry, position, nil
+}
+
+// Release 放行排在最前的 n 个候补，凭证经 outbox 事件交给下游投递
+func (s *Service) Release(ctx context.Context, n int) ([]Entry, error) {
+	ctx, span := gtrace.NewSpan(ctx, "Waitlist.Release")
+	defer span.End()
+
+	now := s.now()
+	entries, err := s.repo.Release(n, newToken, now.Add(s.cfg.InviteTTL), now)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info(ctx, "Waitlist released:", len(entries))
+	return entries, nil
+}
+
+// Redeem 实现 registration.Redeemer，占用放行凭证；凭证只能由候补时登记的邮箱使用
+func (s *Service) Redeem(ctx context.Context, token, email string) (func(), error) {
+	now := s.now()
+	email = strings.ToLower(strings.TrimSpace(email))
+	entry, err := s.repo.Claim(token, email, now)
+	switch {
+	case errors.Is(err, ErrEntryNotFound):
+		return nil, registration.ErrTokenNotFound
+	case errors.Is(err, ErrInvalidToken) && entry.Email != email:
+		return nil, registration.ErrTokenEmailMismatch
+	case errors.Is(err, ErrInvalidToken):
+		if entry.Status == StatusInvited && entry.InviteExpiresAt != nil && !now.Before(*entry.InviteExpiresAt) {
+			return nil, registration.ErrTokenExpired
+		}
+		return nil, registration.ErrTokenUsedUp
+	case err != nil:
+		return nil, err
+	}
+	return func() {
+		if err := s.repo.Unclaim(token); err != nil {
+			s.logger.Error(ctx, "waitlist token unclaim failed:", err.Error())
+		}
+	}, nil
+}
+
+// OnRegister 订阅用户注册事件，把凭证标记为已使用；开放注册时携带凭证注册同样记录
+func (s *Service) OnRegister(ctx context.Context, reg *user.Registration) error {
+	if reg.InviteToken == "" {
+		return nil
+	}
+	return s.repo.Complete(reg.InviteToken, reg.UserID, reg.CreatedAt)
+}
@@ -0,0 +1,236 @@
+package waitlist
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+	config "usergrowth/configs"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/registration"
+	"usergrowth/internal/user"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRepo 在内存中实现与 waitlistRepository 相同的排队语义
+type fakeRepo struct {
+	WaitlistRepository
+	entries []*Entry
+}
+
+func (f *fakeRepo) Join(entry *Entry, referrerID uint, boost int64) error {
+	for _, e := range f.entries {
+		if e.Email == entry.Email {
+			return ErrDuplicateEmail
+		}
+		if e.ReferralCode == entry.ReferralCode {
+			return ErrDuplicateCode
+		}
+	}
+	entry.EntryID = uint(len(f.entries) + 1)
+	entry.Priority = int64(entry.EntryID)
+	entry.Status = StatusWaiting
+	entry.ReferredBy = referrerID
+	f.entries = append(f.entries, entry)
+	if referrerID > 0 {
+		referrer := f.entries[referrerID-1]
+		referrer.Referrals++
+		if referrer.Status == StatusWaiting {
+			referrer.Priority -= boost
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepo) find(match func(e *Entry) bool) (*Entry, error) {
+	for _, e := range f.entries {
+		if match(e) {
+			out := *e
+			return &out, nil
+		}
+	}
+	return nil, ErrEntryNotFound
+}
+
+func (f *fakeRepo) FindByEmail(email string) (*Entry, error) {
+	return f.find(func(e *Entry) bool { return e.Email == email })
+}
+
+func (f *fakeRepo) FindByCode(code string) (*Entry, error) {
+	return f.find(func(e *Entry) bool { return e.ReferralCode == code })
+}
+
+func (f *fakeRepo) waiting() []*Entry {
+	var out []*Entry
+	for _, e := range f.entries {
+		if e.Status == StatusWaiting {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Priority != out[j].Priority {
+			return out[i].Priority < out[j].Priority
+		}
+		return out[i].EntryID < out[j].EntryID
+	})
+	return out
+}
+
+func (f *fakeRepo) Position(entryID uint) (int64, error) {
+	for i, e := range f.waiting() {
+		if e.EntryID == entryID {
+			return int64(i + 1), nil
+		}
+	}
+	return 0, nil
+}
+
+func (f *fakeRepo) Release(n int, tokens func() string, expiresAt, now time.Time) ([]Entry, error) {
+	var out []Entry
+	for _, e := range f.waiting() {
+		if len(out) == n {
+			break
+		}
+		token := tokens()
+		e.Status, e.InviteToken, e.InvitedAt, e.InviteExpiresAt = StatusInvited, &token, &now, &expiresAt
+		out = append(out, *e)
+	}
+	return out, nil
+}
+
+func (f *fakeRepo) byToken(token string) *Entry {
+	for _, e := range f.entries {
+		if e.InviteToken != nil && *e.InviteToken == token {
+			return e
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepo) Claim(token, email string, now time.Time) (*Entry, error) {
+	e := f.byToken(token)
+	if e == nil {
+		return nil, ErrEntryNotFound
+	}
+	if e.Email != email || e.Status != StatusInvited || !e.InviteExpiresAt.After(now) {
+		return e, ErrInvalidToken
+	}
+	e.Status = StatusClaimed
+	return e, nil
+}
+
+func (f *fakeRepo) Unclaim(token string) error {
+	if e := f.byToken(token); e != nil && e.Status == StatusClaimed {
+		e.Status = StatusInvited
+	}
+	return nil
+}
+
+func (f *fakeRepo) Complete(token string, userID uint, at time.Time) error {
+	if e := f.byToken(token); e != nil && (e.Status == StatusInvited || e.Status == StatusClaimed) {
+		e.Status, e.UserID, e.RegisteredAt = StatusRegistered, userID, &at
+	}
+	return nil
+}
+
+var testNow = time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+
+func newTestService() (*Service, *fakeRepo) {
+	repo := &fakeRepo{}
+	cfg := &config.WaitlistConfig{ReferralBoost: 3, InviteTTL: 24 * time.Hour}
+	s := NewService(repo, cfg, logs.Nop())
+	s.now = func() time.Time { return testNow }
+	return s, repo
+}
+
+func TestJoinAndReferral(t *testing.T) {
+	s, _ := newTestService()
+	ctx := context.Background()
+
+	var codes []string
+	for i, email := range []string{"a@x.com", "b@x.com", "c@x.com", "d@x.com"} {
+		entry, position, err := s.Join(ctx, email, "", "")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, int64(i+1), position)
+		codes = append(codes, entry.ReferralCode)
+	}
+
+	// 同一邮箱重复提交返回原名次，大小写不敏感
+	entry, position, err := s.Join(ctx, " A@X.com ", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, codes[0], entry.ReferralCode)
+	assert.Equal(t, int64(1), position)
+
+	// d 推荐一人前进三名，与 a 同分时先加入者在前，无效推荐码被忽略
+	_, position, err = s.Join(ctx, "e@x.com", "", codes[3])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), position)
+	_, _, err = s.Join(ctx, "f@x.com", "", "missing")
+	assert.NoError(t, err)
+
+	entry, position, err = s.Status(codes[3])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), position)
+	assert.Equal(t, int64(1), entry.Referrals)
+	_, position, _ = s.Status(codes[1])
+	assert.Equal(t, int64(3), position)
+
+	_, _, err = s.Status("missing")
+	assert.ErrorIs(t, err, ErrEntryNotFound)
+}
+
+func TestReleaseAndRedeem(t *testing.T) {
+	s, repo := newTestService()
+	ctx := context.Background()
+	for _, email := range []string{"a@x.com", "b@x.com", "c@x.com"} {
+		_, _, _ = s.Join(ctx, email, "", "")
+	}
+	third, _ := repo.FindByEmail("c@x.com")
+	_, _, _ = s.Join(ctx, "d@x.com", "", third.ReferralCode)
+	_, _, _ = s.Join(ctx, "e@x.com", "", third.ReferralCode)
+
+	released, err := s.Release(ctx, 2)
+	if !assert.NoError(t, err) || !assert.Len(t, released, 2) {
+		return
+	}
+	assert.Equal(t, "c@x.com", released[0].Email)
+	assert.Equal(t, "a@x.com", released[1].Email)
+	assert.Equal(t, testNow.Add(24*time.Hour), *released[0].InviteExpiresAt)
+	_, position, _ := s.Status(third.ReferralCode)
+	assert.Equal(t, int64(0), position)
+
+	token := *released[0].InviteToken
+	_, err = s.Redeem(ctx, "bogus", "")
+	assert.ErrorIs(t, err, registration.ErrTokenNotFound)
+
+	// 凭证只能由候补邮箱使用
+	_, err = s.Redeem(ctx, token, "")
+	assert.ErrorIs(t, err, registration.ErrTokenEmailMismatch)
+	_, err = s.Redeem(ctx, token, "a@x.com")
+	assert.ErrorIs(t, err, registration.ErrTokenEmailMismatch)
+
+	// 注册失败归还凭证，可再次使用
+	release, err := s.Redeem(ctx, token, " C@x.com ")
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = s.Redeem(ctx, token, "c@x.com")
+	assert.ErrorIs(t, err, registration.ErrTokenUsedUp)
+	release()
+	_, err = s.Redeem(ctx, token, "c@x.com")
+	assert.NoError(t, err)
+
+	s.OnRegister(ctx, &user.Registration{UserID: 42, InviteToken: token, CreatedAt: testNow})
+	entry, _ := repo.FindByEmail("c@x.com")
+	assert.Equal(t, StatusRegistered, entry.Status)
+	assert.Equal(t, uint(42), entry.UserID)
+	_, err = s.Redeem(ctx, token, "c@x.com")
+	assert.ErrorIs(t, err, registration.ErrTokenUsedUp)
+
+	s.now = func() time.Time { return testNow.Add(25 * time.Hour) }
+	_, err = s.Redeem(ctx, *released[1].InviteToken, "a@x.com")
+	assert.ErrorIs(t, err, registration.ErrTokenExpired)
+}
@@ -0,0 +1,158 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+type CreateEndpointReq struct {
+	g.Meta     `path:"/api/admin/webhooks" method:"post"`
+	URL        string   `json:"url" v:"required|url|max-length:1024#回调地址不能为空|回调地址格式错误|回调地址过长"`
+	EventTypes []string `json:"event_types"` // 为空表示订阅全部事件
+}
+
+type CreateEndpointRes struct {
+}
+
+type ListEndpointsReq struct {
+	g.Meta `path:"/api/admin/webhooks" method:"get"`
+}
+
+type ListEndpointsRes struct {
+}
+
+type ListDeadLettersReq struct {
+	g.Meta `path:"/api/admin/webhooks/dead-letters" method:"get"`
+	After  uint `p:"after"` // 上一页最后一条的 dead_letter_id
+	Limit  int  `p:"limit" d:"50" v:"between:1,200#条数应在1到200之间"`
+}
+
+type ListDeadLettersRes struct {
+}
+
+type ReplayReq struct {
+	g.Meta       `path:"/api/admin/webhooks/dead-letters/{id}/replay" method:"post"`
+	DeadLetterID uint `p:"id" v:"required"`
+}
+
+type ReplayRes struct {
+}
+
+type Admin struct {
+	repo       WebhookRepository
+	userLogger logs.Logger
+}
+
+func NewAdmin(repo WebhookRepository, logger logs.Logger) *Admin {
+	return &Admin{
+		repo:       repo,
+		userLogger: logger,
+	}
+}
+
+func newSecret() string {
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// Create 注册回调地址，签名密钥只在创建时返回一次
+func (params *Admin) Create(ctx context.Context, req *CreateEndpointReq) (res *CreateEndpointRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "回调地址必须为http或https")
+	}
+	types := make([]string, 0, len(req.EventTypes))
+	for _, t := range req.EventTypes {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	endpoint := &Endpoint{
+		URL:        req.URL,
+		Secret:     newSecret(),
+		EventTypes: strings.Join(types, ","),
+		Active:     true,
+	}
+	if err = params.repo.CreateEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Webhook endpoint created:", endpoint.EndpointID, endpoint.URL,
+		"operator:", r.GetCtxVar("userid").String())
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "endpoint created",
+		"data": g.Map{
+			"endpoint": endpoint,
+			"secret":   endpoint.Secret,
+		},
+	})
+	return nil, nil
+}
+
+func (params *Admin) List(ctx context.Context, req *ListEndpointsReq) (res *ListEndpointsRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	endpoints, err := params.repo.ListEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    endpoints,
+	})
+	return nil, nil
+}
+
+func (params *Admin) DeadLetters(ctx context.Context, req *ListDeadLettersReq) (res *ListDeadLettersRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	letters, err := params.repo.ListDeadLetters(req.After, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    letters,
+	})
+	return nil, nil
+}
+
+// Replay 将死信重新放回投递队列，由 Dispatcher 下一轮轮询时投递
+func (params *Admin) Replay(ctx context.Context, req *ReplayReq) (res *ReplayRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	letter, err := params.repo.Replay(req.DeadLetterID, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrDeadLetterNotFound), errors.Is(err, ErrDeliveryNotFound):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "死信不存在")
+		case errors.Is(err, ErrAlreadyReplayed):
+			return nil, gerror.NewCode(gcode.CodeValidationFailed, "死信已重放")
+		}
+		return nil, err
+	}
+
+	params.userLogger.Info(ctx, "Webhook dead letter replayed:", letter.DeadLetterID, "delivery:", letter.DeliveryID,
+		"operator:", r.GetCtxVar("userid").String())
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "replayed",
+		"data":    letter,
+	})
+	return nil, nil
+}
@@ -0,0 +1,239 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/outbox"
+)
+
+// 投递请求头，订阅方按 X-Webhook-Id 去重
+const (
+	HeaderID        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const maxErrorLen = 1024
+
+// Sign 对 "timestamp.body" 做 HMAC-SHA256，时间戳参与签名以便订阅方拒绝重放
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 供订阅方校验签名
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Backoff 返回第 attempt 次失败后的等待时间：base * 2^(attempt-1)，不超过 max
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= max {
+			return max
+		}
+	}
+	return min(d, max)
+}
+
+// Payload 是投递给订阅方的请求体
+type Payload struct {
+	ID        uint64          `json:"id"`
+	Type      string          `json:"type"`
+	UserID    uint            `json:"user_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type Options struct {
+	PollInterval time.Duration
+	Timeout      time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	MaxAttempts  int
+	BatchSize    int
+}
+
+// Dispatcher 先把 outbox 事件展开为投递任务，再投递到期的任务
+type Dispatcher struct {
+	repo   WebhookRepository
+	events outbox.OutboxRepository
+	client *http.Client
+	opts   Options
+	now    func() time.Time
+	logger logs.Logger
+}
+
+func NewDispatcher(repo WebhookRepository, events outbox.OutboxRepository, opts Options, logger logs.Logger) *Dispatcher {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = 10 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Hour
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	return &Dispatcher{
+		repo:   repo,
+		events: events,
+		client: &http.Client{Timeout: opts.Timeout},
+		opts:   opts,
+		now:    time.Now,
+		logger: logger,
+	}
+}
+
+// Start 在后台轮询，ctx 取消后退出
+func (d *Dispatcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.opts.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				d.RunOnce(ctx)
+			}
+		}
+	}()
+}
+
+func (d *Dispatcher) RunOnce(ctx context.Context) {
+	if err := d.fanOut(); err != nil {
+		d.logger.Error(ctx, "webhook fan out failed:", err.Error())
+	}
+	if err := d.deliverDue(ctx); err != nil {
+		d.logger.Error(ctx, "webhook delivery failed:", err.Error())
+	}
+}
+
+func (d *Dispatcher) fanOut() error {
+	events, err := d.events.Pending(d.opts.BatchSize)
+	if err != nil || len(events) == 0 {
+		return err
+	}
+	endpoints, err := d.repo.ListEndpoints()
+	if err != nil {
+		return err
+	}
+	for i := range events {
+		if err = d.repo.FanOut(&events[i], endpoints, d.now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	deliveries, err := d.repo.DueDeliveries(d.now(), d.opts.BatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range deliveries {
+		// 逐个领取时重新取时间，前面的慢请求不会让后面的租约一领取就已过期；
+		// 租约略长于请求超时，worker 崩溃后任务会在租约到期后被重新领取
+		now := d.now()
+		ok, err := d.repo.Claim(deliveries[i].DeliveryID, now, now.Add(2*d.opts.Timeout))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err = d.deliver(ctx, &deliveries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver 只在写库失败时返回错误，投递失败记入任务本身
+func (d *Dispatcher) deliver(ctx context.Context, delivery *Delivery) error {
+	attempts := delivery.Attempts + 1
+	status, sendErr := d.send(ctx, delivery)
+	if sendErr == nil {
+		return d.repo.MarkDelivered(delivery.DeliveryID, attempts, status)
+	}
+	msg := sendErr.Error()
+	if len(msg) > maxErrorLen {
+		msg = msg[:maxErrorLen]
+	}
+	if attempts >= d.opts.MaxAttempts || errors.Is(sendErr, ErrEndpointNotFound) {
+		d.logger.Error(ctx, "webhook dead letter:", delivery.DeliveryID, "event:", delivery.EventID,
+			"endpoint:", delivery.EndpointID, "attempts:", attempts, "error:", msg)
+		return d.repo.MarkDead(delivery, attempts, status, msg)
+	}
+	next := d.now().Add(Backoff(attempts, d.opts.BaseBackoff, d.opts.MaxBackoff))
+	return d.repo.MarkRetry(delivery.DeliveryID, attempts, status, msg, next)
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery *Delivery) (int, error) {
+	endpoint, err := d.repo.FindEndpoint(delivery.EndpointID)
+	if err != nil {
+		return 0, err
+	}
+	event, err := d.events.Find(delivery.EventID)
+	if err != nil {
+		return 0, err
+	}
+	body, err := json.Marshal(Payload{
+		ID:        event.EventID,
+		Type:      event.Type,
+		UserID:    event.UserID,
+		CreatedAt: event.CreatedAt,
+		Data:      json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	ts := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, strconv.FormatUint(event.EventID, 10))
+	req.Header.Set(HeaderEvent, event.Type)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(endpoint.Secret, ts, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
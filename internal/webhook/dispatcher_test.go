@@ -0,0 +1,343 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/outbox"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRepo 在内存中实现投递任务的状态流转
+type fakeRepo struct {
+	WebhookRepository
+	outbox     *fakeOutbox
+	endpoints  []Endpoint
+	deliveries []Delivery
+	dead       []DeadLetter
+	claims     []claim
+}
+
+// claim 记录领取时的时间与租约
+type claim struct {
+	at, leaseUntil time.Time
+}
+
+func (f *fakeRepo) FindEndpoint(endpointID uint) (*Endpoint, error) {
+	for i := range f.endpoints {
+		if f.endpoints[i].EndpointID == endpointID {
+			return &f.endpoints[i], nil
+		}
+	}
+	return nil, ErrEndpointNotFound
+}
+
+func (f *fakeRepo) ListEndpoints() ([]Endpoint, error) {
+	return f.endpoints, nil
+}
+
+func (f *fakeRepo) FanOut(event *outbox.Event, endpoints []Endpoint, at time.Time) error {
+	for _, e := range endpoints {
+		if e.Active && e.Subscribes(event.Type) {
+			f.deliveries = append(f.deliveries, Delivery{
+				DeliveryID:    uint(len(f.deliveries) + 1),
+				EventID:       event.EventID,
+				EndpointID:    e.EndpointID,
+				Status:        StatusPending,
+				NextAttemptAt: at,
+			})
+		}
+	}
+	for i := range f.outbox.events {
+		if f.outbox.events[i].EventID == event.EventID {
+			f.outbox.events[i].DispatchedAt = &at
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepo) DueDeliveries(now time.Time, limit int) ([]Delivery, error) {
+	var due []Delivery
+	for _, d := range f.deliveries {
+		if d.Status == StatusPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (f *fakeRepo) Claim(deliveryID uint, now, leaseUntil time.Time) (bool, error) {
+	d := &f.deliveries[deliveryID-1]
+	if d.Status != StatusPending || d.NextAttemptAt.After(now) {
+		return false, nil
+	}
+	d.NextAttemptAt = leaseUntil
+	f.claims = append(f.claims, claim{at: now, leaseUntil: leaseUntil})
+	return true, nil
+}
+
+func (f *fakeRepo) MarkDelivered(deliveryID uint, attempts, status int) error {
+	d := &f.deliveries[deliveryID-1]
+	d.Status, d.Attempts, d.LastStatus, d.LastError = StatusDelivered, attempts, status, ""
+	return nil
+}
+
+func (f *fakeRepo) MarkRetry(deliveryID uint, attempts, status int, lastErr string, next time.Time) error {
+	d := &f.deliveries[deliveryID-1]
+	d.Attempts, d.LastStatus, d.LastError, d.NextAttemptAt = attempts, status, lastErr, next
+	return nil
+}
+
+func (f *fakeRepo) MarkDead(delivery *Delivery, attempts, status int, lastErr string) error {
+	d := &f.deliveries[delivery.DeliveryID-1]
+	d.Status, d.Attempts, d.LastStatus, d.LastError = StatusDead, attempts, status, lastErr
+	f.dead = append(f.dead, DeadLetter{
+		DeadLetterID: uint(len(f.dead) + 1),
+		DeliveryID:   d.DeliveryID,
+		EventID:      d.EventID,
+		EndpointID:   d.EndpointID,
+		Attempts:     attempts,
+		LastStatus:   status,
+		LastError:    lastErr,
+	})
+	return nil
+}
+
+func (f *fakeRepo) Replay(deadLetterID uint, at time.Time) (*DeadLetter, error) {
+	if int(deadLetterID) > len(f.dead) {
+		return nil, ErrDeadLetterNotFound
+	}
+	letter := &f.dead[deadLetterID-1]
+	if letter.ReplayedAt != nil {
+		return nil, ErrAlreadyReplayed
+	}
+	letter.ReplayedAt = &at
+	d := &f.deliveries[letter.DeliveryID-1]
+	d.Status, d.Attempts, d.NextAttemptAt = StatusPending, 0, at
+	return letter, nil
+}
+
+type fakeOutbox struct {
+	events []outbox.Event
+}
+
+func (f *fakeOutbox) Pending(limit int) ([]outbox.Event, error) {
+	var pending []outbox.Event
+	for _, e := range f.events {
+		if e.DispatchedAt == nil {
+			pending = append(pending, e)
+		}
+	}
+	return pending, nil
+}
+
+func (f *fakeOutbox) Find(eventID uint64) (*outbox.Event, error) {
+	for i := range f.events {
+		if f.events[i].EventID == eventID {
+			return &f.events[i], nil
+		}
+	}
+	return nil, outbox.ErrEventNotFound
+}
+
+// receiver 记录收到的请求并按 statuses 依次返回状态码
+type receiver struct {
+	mu       sync.Mutex
+	secret   string
+	statuses []int
+	bodies   []Payload
+	verified []bool
+	onServe  func()
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	ts, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	rc.verified = append(rc.verified, Verify(rc.secret, ts, body, r.Header.Get(HeaderSignature)))
+	var p Payload
+	_ = json.Unmarshal(body, &p)
+	rc.bodies = append(rc.bodies, p)
+	status := http.StatusOK
+	if n := len(rc.bodies); n <= len(rc.statuses) {
+		status = rc.statuses[n-1]
+	}
+	if rc.onServe != nil {
+		rc.onServe()
+	}
+	w.WriteHeader(status)
+}
+
+type clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *clock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newTestDispatcher(url, secret string, maxAttempts int) (*Dispatcher, *fakeRepo, *clock) {
+	events := &fakeOutbox{events: []outbox.Event{
+		{EventID: 7, Type: "user.registered", UserID: 42, Payload: `{"username":"alice"}`},
+	}}
+	repo := &fakeRepo{outbox: events, endpoints: []Endpoint{
+		{EndpointID: 1, URL: url, Secret: secret, Active: true},
+		{EndpointID: 2, URL: url, Secret: secret, EventTypes: "user.banned", Active: true},
+	}}
+	c := &clock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	d := NewDispatcher(repo, events, Options{
+		Timeout:     time.Second,
+		BaseBackoff: 10 * time.Second,
+		MaxBackoff:  time.Minute,
+		MaxAttempts: maxAttempts,
+	}, logs.Nop())
+	d.now = c.now
+	return d, repo, c
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := 10*time.Second, time.Minute
+	assert.Equal(t, 10*time.Second, Backoff(1, base, max))
+	assert.Equal(t, 20*time.Second, Backoff(2, base, max))
+	assert.Equal(t, 40*time.Second, Backoff(3, base, max))
+	assert.Equal(t, time.Minute, Backoff(4, base, max))
+	assert.Equal(t, time.Minute, Backoff(60, base, max))
+}
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	sig := Sign("s3cret", 1700000000, body)
+	assert.True(t, Verify("s3cret", 1700000000, body, sig))
+	assert.False(t, Verify("other", 1700000000, body, sig))
+	assert.False(t, Verify("s3cret", 1700000001, body, sig))
+	assert.False(t, Verify("s3cret", 1700000000, []byte(`{"id":2}`), sig))
+}
+
+func TestDeliverySignedAndFiltered(t *testing.T) {
+	rc := &receiver{secret: "s3cret"}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	d, repo, _ := newTestDispatcher(srv.URL, "s3cret", 3)
+
+	d.RunOnce(context.Background())
+
+	// 端点 2 只订阅封禁事件
+	if !assert.Len(t, repo.deliveries, 1) {
+		return
+	}
+	assert.Equal(t, StatusDelivered, repo.deliveries[0].Status)
+	assert.Equal(t, 1, repo.deliveries[0].Attempts)
+	assert.Equal(t, []bool{true}, rc.verified)
+	assert.Equal(t, uint64(7), rc.bodies[0].ID)
+	assert.Equal(t, "user.registered", rc.bodies[0].Type)
+	assert.JSONEq(t, `{"username":"alice"}`, string(rc.bodies[0].Data))
+
+	// 事件已展开，再次轮询不会重复投递
+	d.RunOnce(context.Background())
+	assert.Len(t, rc.bodies, 1)
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	rc := &receiver{secret: "s3cret", statuses: []int{500, 503}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	d, repo, c := newTestDispatcher(srv.URL, "s3cret", 5)
+	ctx := context.Background()
+
+	d.RunOnce(ctx)
+	assert.Equal(t, 1, repo.deliveries[0].Attempts)
+	assert.Equal(t, 500, repo.deliveries[0].LastStatus)
+	assert.Equal(t, c.t.Add(10*time.Second), repo.deliveries[0].NextAttemptAt)
+
+	// 未到重试时间不投递
+	c.t = c.t.Add(5 * time.Second)
+	d.RunOnce(ctx)
+	assert.Len(t, rc.bodies, 1)
+
+	c.t = c.t.Add(5 * time.Second)
+	d.RunOnce(ctx)
+	assert.Equal(t, 2, repo.deliveries[0].Attempts)
+	assert.Equal(t, c.t.Add(20*time.Second), repo.deliveries[0].NextAttemptAt)
+
+	c.t = c.t.Add(20 * time.Second)
+	d.RunOnce(ctx)
+	assert.Equal(t, StatusDelivered, repo.deliveries[0].Status)
+	assert.Equal(t, 3, repo.deliveries[0].Attempts)
+	assert.Len(t, rc.bodies, 3)
+	assert.Empty(t, repo.dead)
+}
+
+func TestDeadLetterAndReplay(t *testing.T) {
+	rc := &receiver{secret: "s3cret", statuses: []int{500, 500}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	d, repo, c := newTestDispatcher(srv.URL, "s3cret", 2)
+	ctx := context.Background()
+
+	d.RunOnce(ctx)
+	c.t = c.t.Add(time.Minute)
+	d.RunOnce(ctx)
+
+	assert.Equal(t, StatusDead, repo.deliveries[0].Status)
+	if !assert.Len(t, repo.dead, 1) {
+		return
+	}
+	assert.Equal(t, 2, repo.dead[0].Attempts)
+	assert.Equal(t, 500, repo.dead[0].LastStatus)
+
+	// 死信不再投递
+	c.t = c.t.Add(time.Hour)
+	d.RunOnce(ctx)
+	assert.Len(t, rc.bodies, 2)
+
+	_, err := repo.Replay(1, c.t)
+	assert.NoError(t, err)
+	_, err = repo.Replay(1, c.t)
+	assert.ErrorIs(t, err, ErrAlreadyReplayed)
+
+	d.RunOnce(ctx)
+	assert.Equal(t, StatusDelivered, repo.deliveries[0].Status)
+	assert.Equal(t, 1, repo.deliveries[0].Attempts)
+	assert.Len(t, rc.bodies, 3)
+	assert.Equal(t, []bool{true, true, true}, rc.verified)
+}
+
+func TestLeaseTakenPerClaim(t *testing.T) {
+	rc := &receiver{secret: "s3cret"}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	d, repo, c := newTestDispatcher(srv.URL, "s3cret", 3)
+	repo.outbox.events = append(repo.outbox.events, outbox.Event{EventID: 8, Type: "user.registered", UserID: 43, Payload: `{}`})
+	// 每次请求耗时超过租约（2 倍超时）
+	rc.onServe = func() { c.advance(3 * time.Second) }
+
+	d.RunOnce(context.Background())
+
+	if !assert.Len(t, repo.claims, 2) {
+		return
+	}
+	for _, cl := range repo.claims {
+		assert.Equal(t, cl.at.Add(2*time.Second), cl.leaseUntil)
+	}
+	// 第二个任务在第一个请求结束后领取，租约从领取时开始计算
+	assert.Equal(t, repo.claims[0].at.Add(3*time.Second), repo.claims[1].at)
+	assert.Equal(t, StatusDelivered, repo.deliveries[1].Status)
+}
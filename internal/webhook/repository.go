@@ -0,0 +1,253 @@
+package webhook
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"usergrowth/internal/outbox"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrEndpointNotFound   = errors.New("webhook endpoint not found")
+	ErrDeliveryNotFound   = errors.New("webhook delivery not found")
+	ErrDeadLetterNotFound = errors.New("webhook dead letter not found")
+	ErrAlreadyReplayed    = errors.New("webhook dead letter already replayed")
+)
+
+// 投递状态
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+// Endpoint 是订阅方注册的回调地址，EventTypes 为空表示订阅全部事件
+type Endpoint struct {
+	EndpointID uint      `gorm:"primaryKey;autoIncrement" json:"endpoint_id"`
+	URL        string    `gorm:"type:varchar(1024);not null" json:"url"`
+	Secret     string    `gorm:"type:varchar(128);not null" json:"-"`
+	EventTypes string    `gorm:"type:varchar(1024);not null;default:''" json:"event_types"` // 逗号分隔
+	Active     bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Subscribes 判断端点是否订阅了该类型的事件
+func (e *Endpoint) Subscribes(eventType string) bool {
+	if e.EventTypes == "" {
+		return true
+	}
+	for _, t := range strings.Split(e.EventTypes, ",") {
+		if strings.TrimSpace(t) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery 是一个事件到一个端点的投递任务
+type Delivery struct {
+	DeliveryID    uint      `gorm:"primaryKey;autoIncrement" json:"delivery_id"`
+	EventID       uint64    `gorm:"not null;uniqueIndex:idx_event_endpoint" json:"event_id"`
+	EndpointID    uint      `gorm:"not null;uniqueIndex:idx_event_endpoint" json:"endpoint_id"`
+	Status        string    `gorm:"type:varchar(16);not null;index:idx_status_next" json:"status"`
+	Attempts      int       `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time `gorm:"not null;index:idx_status_next" json:"next_attempt_at"`
+	LastStatus    int       `gorm:"not null;default:0" json:"last_status"` // 最近一次的 HTTP 状态码，0 表示未收到响应
+	LastError     string    `gorm:"type:varchar(1024)" json:"last_error"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// DeadLetter 记录重试耗尽的投递，重放后 ReplayedAt 非空
+type DeadLetter struct {
+	DeadLetterID uint       `gorm:"primaryKey;autoIncrement" json:"dead_letter_id"`
+	DeliveryID   uint       `gorm:"not null;index" json:"delivery_id"`
+	EventID      uint64     `gorm:"not null" json:"event_id"`
+	EndpointID   uint       `gorm:"not null;index" json:"endpoint_id"`
+	Attempts     int        `gorm:"not null" json:"attempts"`
+	LastStatus   int        `gorm:"not null;default:0" json:"last_status"`
+	LastError    string     `gorm:"type:varchar(1024)" json:"last_error"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ReplayedAt   *time.Time `json:"replayed_at"`
+}
+
+func (DeadLetter) TableName() string {
+	return "webhook_dead_letters"
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+type WebhookRepository interface {
+	CreateEndpoint(endpoint *Endpoint) error
+	FindEndpoint(endpointID uint) (*Endpoint, error)
+	ListEndpoints() ([]Endpoint, error)
+	// FanOut 为事件创建各订阅端点的投递任务并标记事件已展开，重复调用不会产生重复任务
+	FanOut(event *outbox.Event, endpoints []Endpoint, at time.Time) error
+	DueDeliveries(now time.Time, limit int) ([]Delivery, error)
+	// Claim 将到期任务的 NextAttemptAt 推到 leaseUntil 作为租约，只有一个 worker 能抢到同一次尝试
+	Claim(deliveryID uint, now, leaseUntil time.Time) (bool, error)
+	MarkDelivered(deliveryID uint, attempts, status int) error
+	MarkRetry(deliveryID uint, attempts, status int, lastErr string, next time.Time) error
+	// MarkDead 在同一事务中将任务置为 dead 并写入死信表
+	MarkDead(delivery *Delivery, attempts, status int, lastErr string) error
+	ListDeadLetters(afterID uint, limit int) ([]DeadLetter, error)
+	// Replay 将死信对应的任务重置为待投递，重试次数清零
+	Replay(deadLetterID uint, at time.Time) (*DeadLetter, error)
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	if err := db.AutoMigrate(&Endpoint{}, &Delivery{}, &DeadLetter{}); err != nil {
+		panic("failed to migrate webhook tables")
+	}
+	return &webhookRepository{db: db}
+}
+
+func (repo *webhookRepository) CreateEndpoint(endpoint *Endpoint) error {
+	return repo.db.Create(endpoint).Error
+}
+
+func (repo *webhookRepository) FindEndpoint(endpointID uint) (*Endpoint, error) {
+	var endpoint Endpoint
+	if err := repo.db.First(&endpoint, endpointID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEndpointNotFound
+		}
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (repo *webhookRepository) ListEndpoints() ([]Endpoint, error) {
+	var endpoints []Endpoint
+	err := repo.db.Order("endpoint_id").Find(&endpoints).Error
+	return endpoints, err
+}
+
+func (repo *webhookRepository) FanOut(event *outbox.Event, endpoints []Endpoint, at time.Time) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		deliveries := make([]Delivery, 0, len(endpoints))
+		for _, e := range endpoints {
+			if !e.Active || !e.Subscribes(event.Type) {
+				continue
+			}
+			deliveries = append(deliveries, Delivery{
+				EventID:       event.EventID,
+				EndpointID:    e.EndpointID,
+				Status:        StatusPending,
+				NextAttemptAt: at,
+			})
+		}
+		if len(deliveries) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&outbox.Event{}).Where("event_id = ?", event.EventID).Update("dispatched_at", at).Error
+	})
+}
+
+func (repo *webhookRepository) DueDeliveries(now time.Time, limit int) ([]Delivery, error) {
+	var deliveries []Delivery
+	err := repo.db.Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+		Order("next_attempt_at").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (repo *webhookRepository) Claim(deliveryID uint, now, leaseUntil time.Time) (bool, error) {
+	res := repo.db.Model(&Delivery{}).
+		Where("delivery_id = ? AND status = ? AND next_attempt_at <= ?", deliveryID, StatusPending, now).
+		Update("next_attempt_at", leaseUntil)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (repo *webhookRepository) MarkDelivered(deliveryID uint, attempts, status int) error {
+	return repo.db.Model(&Delivery{}).Where("delivery_id = ?", deliveryID).Updates(map[string]any{
+		"status":      StatusDelivered,
+		"attempts":    attempts,
+		"last_status": status,
+		"last_error":  "",
+	}).Error
+}
+
+func (repo *webhookRepository) MarkRetry(deliveryID uint, attempts, status int, lastErr string, next time.Time) error {
+	return repo.db.Model(&Delivery{}).Where("delivery_id = ?", deliveryID).Updates(map[string]any{
+		"attempts":        attempts,
+		"last_status":     status,
+		"last_error":      lastErr,
+		"next_attempt_at": next,
+	}).Error
+}
+
+func (repo *webhookRepository) MarkDead(delivery *Delivery, attempts, status int, lastErr string) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Delivery{}).Where("delivery_id = ?", delivery.DeliveryID).Updates(map[string]any{
+			"status":      StatusDead,
+			"attempts":    attempts,
+			"last_status": status,
+			"last_error":  lastErr,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&DeadLetter{
+			DeliveryID: delivery.DeliveryID,
+			EventID:    delivery.EventID,
+			EndpointID: delivery.EndpointID,
+			Attempts:   attempts,
+			LastStatus: status,
+			LastError:  lastErr,
+		}).Error
+	})
+}
+
+func (repo *webhookRepository) ListDeadLetters(afterID uint, limit int) ([]DeadLetter, error) {
+	var letters []DeadLetter
+	err := repo.db.Where("dead_letter_id > ?", afterID).Order("dead_letter_id").Limit(limit).Find(&letters).Error
+	return letters, err
+}
+
+func (repo *webhookRepository) Replay(deadLetterID uint, at time.Time) (*DeadLetter, error) {
+	var letter DeadLetter
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&letter, deadLetterID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDeadLetterNotFound
+			}
+			return err
+		}
+		res := tx.Model(&DeadLetter{}).Where("dead_letter_id = ? AND replayed_at IS NULL", deadLetterID).Update("replayed_at", at)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrAlreadyReplayed
+		}
+		letter.ReplayedAt = &at
+		res = tx.Model(&Delivery{}).Where("delivery_id = ? AND status = ?", letter.DeliveryID, StatusDead).Updates(map[string]any{
+			"status":          StatusPending,
+			"attempts":        0,
+			"next_attempt_at": at,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrDeliveryNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &letter, nil
+}
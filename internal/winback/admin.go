@@ -0,0 +1,136 @@
+package winback
+
+import (
+	"context"
+	"time"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+// 单次查询允许的最大日期跨度
+const maxQueryDays = 93
+
+type ReportReq struct {
+	g.Meta `path:"/api/admin/winback/report" method:"get"`
+	Start  string `p:"start" v:"required|date#开始日期不能为空|开始日期格式应为YYYY-MM-DD"`
+	End    string `p:"end" v:"required|date#结束日期不能为空|结束日期格式应为YYYY-MM-DD"`
+}
+
+type ReportRes struct {
+}
+
+type RunReq struct {
+	g.Meta `path:"/api/admin/winback/run" method:"post"`
+}
+
+type RunRes struct {
+}
+
+// CohortTotal 是区间内某分组的召回汇总
+type CohortTotal struct {
+	Cohort   string  `json:"cohort"`
+	Sent     int64   `json:"sent"`
+	Returned int64   `json:"returned"`
+	Pending  int64   `json:"pending"`
+	Rate     float64 `json:"rate"`
+}
+
+type Admin struct {
+	repo       WinbackRepository
+	job        *Job
+	userLogger logs.Logger
+}
+
+func NewAdmin(repo WinbackRepository, job *Job, logger logs.Logger) *Admin {
+	return &Admin{
+		repo:       repo,
+		job:        job,
+		userLogger: logger,
+	}
+}
+
+// totals 按配置中的分组顺序汇总，没有发送记录的分组也会出现
+func totals(cohorts []Cohort, rows []Row) []CohortTotal {
+	index := make(map[string]int, len(cohorts))
+	out := make([]CohortTotal, 0, len(cohorts))
+	for _, c := range cohorts {
+		index[c.Name] = len(out)
+		out = append(out, CohortTotal{Cohort: c.Name})
+	}
+	for _, row := range rows {
+		i, ok := index[row.Cohort]
+		if !ok {
+			i = len(out)
+			index[row.Cohort] = i
+			out = append(out, CohortTotal{Cohort: row.Cohort})
+		}
+		out[i].Sent += row.Sent
+		out[i].Returned += row.Returned
+		out[i].Pending += row.Pending
+	}
+	for i := range out {
+		if out[i].Sent > 0 {
+			out[i].Rate = float64(out[i].Returned) / float64(out[i].Sent)
+		}
+	}
+	return out
+}
+
+// Report 按发送日期与分组统计召回转化，日期区间包含两端
+func (params *Admin) Report(ctx context.Context, req *ReportReq) (res *ReportRes, err error) {
+	ctx, span := gtrace.NewSpan(ctx, "Winback.Report")
+	defer span.End()
+
+	r := g.RequestFromCtx(ctx)
+
+	start, err := time.ParseInLocation(time.DateOnly, req.Start, time.Local)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "开始日期格式应为YYYY-MM-DD")
+	}
+	end, err := time.ParseInLocation(time.DateOnly, req.End, time.Local)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "结束日期格式应为YYYY-MM-DD")
+	}
+	if end.Before(start) || end.Sub(start) >= maxQueryDays*24*time.Hour {
+		return nil, gerror.NewCode(gcode.CodeValidationFailed, "日期区间不合法")
+	}
+
+	rows, err := params.repo.Report(start, end.AddDate(0, 0, 1), time.Now(), params.job.opts.ReturnWindow)
+	if err != nil {
+		return nil, err
+	}
+	cohorts := params.job.Cohorts()
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data": g.Map{
+			"return_window": params.job.opts.ReturnWindow.String(),
+			"cohorts":       cohorts,
+			"rows":          rows,
+			"totals":        totals(cohorts, rows),
+		},
+	})
+	return nil, nil
+}
+
+// Run 立即执行一次召回任务，频控与去重规则与定时任务相同
+func (params *Admin) Run(ctx context.Context, req *RunReq) (res *RunRes, err error) {
+	r := g.RequestFromCtx(ctx)
+
+	stats, err := params.job.RunOnce(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params.userLogger.Info(ctx, "Winback run triggered, operator:", r.GetCtxVar("userid").String(),
+		"sent:", stats.Sent, "returned:", stats.Returned)
+	r.Response.WriteJson(g.Map{
+		"code":    200,
+		"message": "success",
+		"data":    stats,
+	})
+	return nil, nil
+}
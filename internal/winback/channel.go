@@ -0,0 +1,107 @@
+package winback
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"usergrowth/internal/notification"
+	"usergrowth/internal/user"
+)
+
+// ErrNoAddress 表示用户在该渠道没有可用的联系方式，记录为 skipped
+var ErrNoAddress = errors.New("user has no address for channel")
+
+// Message 是渲染后的召回消息
+type Message struct {
+	Cohort string
+	Title  string
+	Body   string
+}
+
+// Channel 是召回消息的发送渠道，按名称在分组配置中引用
+type Channel interface {
+	Send(ctx context.Context, u *user.Users, msg Message) error
+}
+
+// Notifier 由 notification 模块提供
+type Notifier interface {
+	Send(ctx context.Context, userID uint, msg notification.Message) error
+}
+
+// InApp 通过站内信发送
+type InApp struct {
+	notifier Notifier
+}
+
+func NewInApp(notifier Notifier) *InApp {
+	return &InApp{notifier: notifier}
+}
+
+func (c *InApp) Send(ctx context.Context, u *user.Users, msg Message) error {
+	return c.notifier.Send(ctx, u.UserID, notification.Message{
+		Kind:  notification.KindCampaign,
+		Title: msg.Title,
+		Body:  msg.Body,
+		Data:  map[string]any{"winback": msg.Cohort},
+	})
+}
+
+// Gateway 把消息 POST 给外部发送网关（邮件、短信服务商的适配层），address 取出用户在该渠道的地址
+type Gateway struct {
+	name    string
+	url     string
+	address func(u *user.Users) string
+	client  *http.Client
+}
+
+func NewGateway(name, url string, address func(u *user.Users) string, timeout time.Duration) *Gateway {
+	return &Gateway{
+		name:    name,
+		url:     url,
+		address: address,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// NewEmailGateway 以用户邮箱为地址
+func NewEmailGateway(url string, timeout time.Duration) *Gateway {
+	return NewGateway("email", url, func(u *user.Users) string { return u.Email }, timeout)
+}
+
+func (c *Gateway) Send(ctx context.Context, u *user.Users, msg Message) error {
+	to := c.address(u)
+	if to == "" {
+		return ErrNoAddress
+	}
+	body, err := json.Marshal(map[string]any{
+		"channel": c.name,
+		"user_id": u.UserID,
+		"to":      to,
+		"title":   msg.Title,
+		"body":    msg.Body,
+		"tag":     "winback:" + msg.Cohort,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s gateway status %d", c.name, resp.StatusCode)
+	}
+	return nil
+}
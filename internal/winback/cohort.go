@@ -0,0 +1,84 @@
+package winback
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var ErrInvalidCohort = errors.New("invalid win-back cohort")
+
+// Cohort 按沉睡天数划分召回分组，模板可使用 {{.Username}}、{{.Days}}、{{.Cohort}}
+type Cohort struct {
+	Name         string `json:"name"`
+	InactiveDays int    `json:"inactive_days"`
+	Channel      string `json:"channel"`
+	Title        string `json:"title"`
+	Body         string `json:"body"`
+}
+
+type cohort struct {
+	Cohort
+	title *template.Template
+	body  *template.Template
+}
+
+type templateData struct {
+	Username string
+	Days     int
+	Cohort   string
+}
+
+// compileCohorts 校验并编译模板，按沉睡天数从大到小排序以便优先匹配更深的分组
+func compileCohorts(cohorts []Cohort, channels map[string]Channel) ([]cohort, error) {
+	compiled := make([]cohort, 0, len(cohorts))
+	seen := make(map[string]bool, len(cohorts))
+	for _, c := range cohorts {
+		if c.Name == "" || len(c.Name) > 32 || seen[c.Name] || c.InactiveDays <= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidCohort, c.Name)
+		}
+		if _, ok := channels[c.Channel]; !ok {
+			return nil, fmt.Errorf("%w: %q uses unknown channel %q", ErrInvalidCohort, c.Name, c.Channel)
+		}
+		title, err := template.New(c.Name + ".title").Option("missingkey=error").Parse(c.Title)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q title: %v", ErrInvalidCohort, c.Name, err)
+		}
+		body, err := template.New(c.Name + ".body").Option("missingkey=error").Parse(c.Body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q body: %v", ErrInvalidCohort, c.Name, err)
+		}
+		seen[c.Name] = true
+		compiled = append(compiled, cohort{Cohort: c, title: title, body: body})
+	}
+	sort.Slice(compiled, func(i, j int) bool {
+		return compiled[i].InactiveDays > compiled[j].InactiveDays
+	})
+	return compiled, nil
+}
+
+// match 返回沉睡时长满足的最深分组
+func match(cohorts []cohort, inactive time.Duration) (*cohort, bool) {
+	days := int(inactive / (24 * time.Hour))
+	for i := range cohorts {
+		if days >= cohorts[i].InactiveDays {
+			return &cohorts[i], true
+		}
+	}
+	return nil, false
+}
+
+func (c *cohort) render(username string, days int) (Message, error) {
+	data := templateData{Username: username, Days: days, Cohort: c.Name}
+	var title, body strings.Builder
+	if err := c.title.Execute(&title, data); err != nil {
+		return Message{}, err
+	}
+	if err := c.body.Execute(&body, data); err != nil {
+		return Message{}, err
+	}
+	return Message{Cohort: c.Name, Title: title.String(), Body: body.String()}, nil
+}
@@ -0,0 +1,251 @@
+package winback
+
+import (
+	"context"
+	"errors"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/text"
+	"usergrowth/internal/user"
+
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+// ActivityChecker 由 activity 模块提供，按天粒度判断用户是否活跃过
+type ActivityChecker interface {
+	FirstActiveSince(ctx context.Context, userID uint, since, now time.Time) (time.Time, bool, error)
+}
+
+// pending 超过该时长视为发送中断，可以被后续运行重新领取；远大于渠道网关超时
+const pendingLease = 10 * time.Minute
+
+type Options struct {
+	ReturnWindow time.Duration // 发送后在该时长内回访视为召回成功
+	CapCount     int           // CapWindow 内每个用户最多收到的召回消息数
+	CapWindow    time.Duration
+	BatchSize    int
+}
+
+// Stats 是一次任务运行的结果
+type Stats struct {
+	Scanned  int `json:"scanned"`
+	Sent     int `json:"sent"`
+	Failed   int `json:"failed"`
+	Skipped  int `json:"skipped"`
+	Capped   int `json:"capped"`
+	Returned int `json:"returned"`
+}
+
+type Job struct {
+	repo     WinbackRepository
+	users    user.UserRepository
+	activity ActivityChecker
+	channels map[string]Channel
+	cohorts  []cohort
+	opts     Options
+	now      func() time.Time
+	logger   logs.Logger
+}
+
+func NewJob(repo WinbackRepository, users user.UserRepository, activity ActivityChecker, channels map[string]Channel,
+	cohorts []Cohort, opts Options, logger logs.Logger) (*Job, error) {
+	compiled, err := compileCohorts(cohorts, channels)
+	if err != nil {
+		return nil, err
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	return &Job{
+		repo:     repo,
+		users:    users,
+		activity: activity,
+		channels: channels,
+		cohorts:  compiled,
+		opts:     opts,
+		now:      time.Now,
+		logger:   logger,
+	}, nil
+}
+
+// Cohorts 返回按沉睡天数从大到小排列的分组配置
+func (j *Job) Cohorts() []Cohort {
+	cohorts := make([]Cohort, 0, len(j.cohorts))
+	for _, c := range j.cohorts {
+		cohorts = append(cohorts, c.Cohort)
+	}
+	return cohorts
+}
+
+// Run 供 gcron 调用，先统计上一轮的回访再触达新的沉睡用户
+func (j *Job) Run(ctx context.Context) {
+	stats, err := j.RunOnce(ctx)
+	if err != nil {
+		j.logger.Error(ctx, "winback run failed:", err.Error())
+		return
+	}
+	j.logger.Info(ctx, "winback run:", "scanned", stats.Scanned, "sent", stats.Sent, "failed", stats.Failed,
+		"skipped", stats.Skipped, "capped", stats.Capped, "returned", stats.Returned)
+}
+
+func (j *Job) RunOnce(ctx context.Context) (*Stats, error) {
+	ctx, span := gtrace.NewSpan(ctx, "Winback.Run")
+	defer span.End()
+
+	stats := &Stats{}
+	if len(j.cohorts) == 0 {
+		return stats, nil
+	}
+	now := j.now()
+	if err := j.trackReturns(ctx, now, stats); err != nil {
+		return stats, err
+	}
+	// 分组按天数降序，最后一个是门槛最低的分组
+	cutoff := now.AddDate(0, 0, -j.cohorts[len(j.cohorts)-1].InactiveDays)
+	var afterID uint
+	for {
+		users, err := j.users.ListDormant(cutoff, afterID, j.opts.BatchSize)
+		if err != nil {
+			return stats, err
+		}
+		for i := range users {
+			stats.Scanned++
+			if err = j.process(ctx, &users[i], now, stats); err != nil {
+				return stats, err
+			}
+		}
+		if len(users) < j.opts.BatchSize {
+			return stats, nil
+		}
+		afterID = users[len(users)-1].UserID
+	}
+}
+
+func lastSeen(u *user.Users) time.Time {
+	if u.LastLoginAt != nil {
+		return *u.LastLoginAt
+	}
+	return u.CreatedAt
+}
+
+func (j *Job) process(ctx context.Context, u *user.Users, now time.Time, stats *Stats) error {
+	since := lastSeen(u)
+	c, ok := match(j.cohorts, now.Sub(since))
+	if !ok {
+		return nil
+	}
+	// 登录态未过期的用户不会产生登录记录，用活跃数据兜底
+	if j.activity != nil {
+		_, active, err := j.activity.FirstActiveSince(ctx, u.UserID, now.AddDate(0, 0, -c.InactiveDays), now)
+		if err != nil {
+			return err
+		}
+		if active {
+			return nil
+		}
+	}
+	if j.opts.CapCount > 0 {
+		n, err := j.repo.CountSent(u.UserID, now.Add(-j.opts.CapWindow))
+		if err != nil {
+			return err
+		}
+		if n >= int64(j.opts.CapCount) {
+			stats.Capped++
+			return nil
+		}
+	}
+
+	days := int(now.Sub(since) / (24 * time.Hour))
+	msg, err := c.render(u.Username, days)
+	if err != nil {
+		return err
+	}
+	// 先以 pending 落库再发送，唯一索引保证同一次沉睡期内同一分组不会重复触达；
+	// 上次失败或中断在 pending 的记录在频控内重新发送
+	send := &Send{
+		UserID:       u.UserID,
+		Cohort:       c.Name,
+		DormantSince: since,
+		Channel:      c.Channel,
+		Status:       StatusPending,
+		SentAt:       now,
+	}
+	if err = j.repo.Create(send); err != nil {
+		if !errors.Is(err, ErrDuplicateSend) {
+			return err
+		}
+		reclaimed, err := j.repo.Reclaim(send, now.Add(-pendingLease))
+		if err != nil || !reclaimed {
+			return err
+		}
+	}
+	if sendErr := j.channels[c.Channel].Send(ctx, u, msg); sendErr != nil {
+		status := StatusFailed
+		if errors.Is(sendErr, ErrNoAddress) {
+			status = StatusSkipped
+			stats.Skipped++
+		} else {
+			stats.Failed++
+			j.logger.Error(ctx, "winback send failed:", u.UserID, c.Name, c.Channel, sendErr.Error())
+		}
+		return j.repo.UpdateStatus(send.SendID, status, text.Truncate(sendErr.Error(), 255))
+	}
+	if err = j.repo.UpdateStatus(send.SendID, StatusSent, ""); err != nil {
+		return err
+	}
+	stats.Sent++
+	return nil
+}
+
+// trackReturns 检查窗口期内已发送记录对应的用户是否回访
+func (j *Job) trackReturns(ctx context.Context, now time.Time, stats *Stats) error {
+	var afterID uint
+	for {
+		sends, err := j.repo.PendingReturns(now.Add(-j.opts.ReturnWindow), afterID, j.opts.BatchSize)
+		if err != nil {
+			return err
+		}
+		for _, s := range sends {
+			at, returned, err := j.returnedAt(ctx, &s, now)
+			if err != nil {
+				return err
+			}
+			if !returned {
+				continue
+			}
+			if err = j.repo.MarkReturned(s.SendID, at); err != nil {
+				return err
+			}
+			stats.Returned++
+		}
+		if len(sends) < j.opts.BatchSize {
+			return nil
+		}
+		afterID = sends[len(sends)-1].SendID
+	}
+}
+
+func (j *Job) returnedAt(ctx context.Context, s *Send, now time.Time) (time.Time, bool, error) {
+	u, err := j.users.FindUserByID(s.UserID)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+	if u.LastLoginAt != nil && u.LastLoginAt.After(s.SentAt) {
+		return *u.LastLoginAt, true, nil
+	}
+	if j.activity == nil {
+		return time.Time{}, false, nil
+	}
+	day, active, err := j.activity.FirstActiveSince(ctx, s.UserID, s.SentAt, now)
+	if err != nil || !active {
+		return time.Time{}, false, err
+	}
+	// 活跃数据只有天粒度，发送当天的活跃记为发送时刻
+	if day.Before(s.SentAt) {
+		day = s.SentAt
+	}
+	return day, true, nil
+}
@@ -0,0 +1,358 @@
+package winback
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"usergrowth/internal/logs"
+	"usergrowth/internal/user"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeUsers struct {
+	user.UserRepository
+	users []user.Users
+}
+
+func (f *fakeUsers) ListDormant(cutoff time.Time, afterID uint, limit int) ([]user.Users, error) {
+	var out []user.Users
+	for _, u := range f.users {
+		if u.UserID > afterID && u.BannedAt == nil && lastSeen(&u).Before(cutoff) {
+			out = append(out, u)
+		}
+		if len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeUsers) FindUserByID(userID uint) (*user.Users, error) {
+	for i := range f.users {
+		if f.users[i].UserID == userID {
+			return &f.users[i], nil
+		}
+	}
+	return nil, user.ErrUserNotFound
+}
+
+// fakeRepo 与 MySQL 实现一样按 (user, cohort, dormant_since) 去重
+type fakeRepo struct {
+	WinbackRepository
+	sends []Send
+}
+
+func (f *fakeRepo) Create(send *Send) error {
+	for _, s := range f.sends {
+		if s.UserID == send.UserID && s.Cohort == send.Cohort && s.DormantSince.Equal(send.DormantSince) {
+			return ErrDuplicateSend
+		}
+	}
+	send.SendID = uint(len(f.sends) + 1)
+	f.sends = append(f.sends, *send)
+	return nil
+}
+
+func (f *fakeRepo) Reclaim(send *Send, staleBefore time.Time) (bool, error) {
+	for i := range f.sends {
+		s := &f.sends[i]
+		if s.UserID != send.UserID || s.Cohort != send.Cohort || !s.DormantSince.Equal(send.DormantSince) {
+			continue
+		}
+		if s.Status != StatusFailed && (s.Status != StatusPending || !s.SentAt.Before(staleBefore)) {
+			return false, nil
+		}
+		s.Status, s.Channel, s.Error, s.SentAt = StatusPending, send.Channel, "", send.SentAt
+		send.SendID = s.SendID
+		return true, nil
+	}
+	return false, nil
+}
+
+func (f *fakeRepo) UpdateStatus(sendID uint, status, errMsg string) error {
+	f.sends[sendID-1].Status = status
+	f.sends[sendID-1].Error = errMsg
+	return nil
+}
+
+func (f *fakeRepo) CountSent(userID uint, since time.Time) (int64, error) {
+	var n int64
+	for _, s := range f.sends {
+		if s.UserID == userID && s.Status == StatusSent && !s.SentAt.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeRepo) PendingReturns(sentAfter time.Time, afterID uint, limit int) ([]Send, error) {
+	var out []Send
+	for _, s := range f.sends {
+		if s.SendID > afterID && s.Status == StatusSent && s.ReturnedAt == nil && !s.SentAt.Before(sentAfter) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeRepo) MarkReturned(sendID uint, at time.Time) error {
+	f.sends[sendID-1].ReturnedAt = &at
+	return nil
+}
+
+// fakeActivity 记录每个用户的活跃日
+type fakeActivity struct {
+	days map[uint][]time.Time
+}
+
+func (f *fakeActivity) FirstActiveSince(ctx context.Context, userID uint, since, now time.Time) (time.Time, bool, error) {
+	for _, d := range f.days[userID] {
+		if !d.Before(since.Truncate(24*time.Hour)) && !d.After(now) {
+			return d, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+type recordingChannel struct {
+	sent []uint
+	msgs []Message
+	err  error
+}
+
+func (c *recordingChannel) Send(ctx context.Context, u *user.Users, msg Message) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.sent = append(c.sent, u.UserID)
+	c.msgs = append(c.msgs, msg)
+	return nil
+}
+
+var testNow = time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func daysAgo(n int) *time.Time {
+	t := testNow.AddDate(0, 0, -n)
+	return &t
+}
+
+var testCohorts = []Cohort{
+	{Name: "d30", InactiveDays: 30, Channel: "email", Title: "想你了", Body: "{{.Username}} 已经 {{.Days}} 天没来了"},
+	{Name: "d7", InactiveDays: 7, Channel: "inapp", Title: "好久不见", Body: "{{.Username}}，{{.Cohort}}"},
+}
+
+func newTestJob(users []user.Users, opts Options) (*Job, *fakeRepo, *fakeUsers, *fakeActivity, *recordingChannel, *recordingChannel) {
+	repo := &fakeRepo{}
+	us := &fakeUsers{users: users}
+	act := &fakeActivity{days: make(map[uint][]time.Time)}
+	inapp, email := &recordingChannel{}, &recordingChannel{}
+	job, err := NewJob(repo, us, act, map[string]Channel{"inapp": inapp, "email": email}, testCohorts, opts, logs.Nop())
+	if err != nil {
+		panic(err)
+	}
+	job.now = func() time.Time { return testNow }
+	return job, repo, us, act, inapp, email
+}
+
+func TestCompileCohorts(t *testing.T) {
+	channels := map[string]Channel{"inapp": &recordingChannel{}}
+	_, err := compileCohorts([]Cohort{{Name: "a", InactiveDays: 7, Channel: "sms"}}, channels)
+	assert.ErrorIs(t, err, ErrInvalidCohort)
+	_, err = compileCohorts([]Cohort{{Name: "a", InactiveDays: 7, Channel: "inapp", Body: "{{.Username"}}, channels)
+	assert.ErrorIs(t, err, ErrInvalidCohort)
+	_, err = compileCohorts([]Cohort{{Name: "a", InactiveDays: 7, Channel: "inapp"}, {Name: "a", InactiveDays: 9, Channel: "inapp"}}, channels)
+	assert.ErrorIs(t, err, ErrInvalidCohort)
+
+	compiled, err := compileCohorts(testCohorts[1:], map[string]Channel{"inapp": &recordingChannel{}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	msg, err := compiled[0].render("alice", 8)
+	assert.NoError(t, err)
+	assert.Equal(t, Message{Cohort: "d7", Title: "好久不见", Body: "alice，d7"}, msg)
+}
+
+func TestMatchDeepestCohort(t *testing.T) {
+	compiled, _ := compileCohorts(testCohorts, map[string]Channel{"inapp": nil, "email": nil})
+	_, ok := match(compiled, 6*24*time.Hour)
+	assert.False(t, ok)
+	c, ok := match(compiled, 7*24*time.Hour)
+	assert.True(t, ok)
+	assert.Equal(t, "d7", c.Name)
+	c, _ = match(compiled, 45*24*time.Hour)
+	assert.Equal(t, "d30", c.Name)
+}
+
+func TestRunSendsToDormantUsers(t *testing.T) {
+	users := []user.Users{
+		{UserID: 1, Username: "fresh", LastLoginAt: daysAgo(2)},
+		{UserID: 2, Username: "week", LastLoginAt: daysAgo(10)},
+		{UserID: 3, Username: "month", LastLoginAt: daysAgo(40), Email: "m@example.com"},
+		{UserID: 4, Username: "never", CreatedAt: *daysAgo(8)},
+		{UserID: 5, Username: "tokenonly", LastLoginAt: daysAgo(20)},
+		{UserID: 6, Username: "banned", LastLoginAt: daysAgo(20), BannedAt: daysAgo(1)},
+	}
+	job, repo, _, act, inapp, email := newTestJob(users, Options{ReturnWindow: 7 * 24 * time.Hour, BatchSize: 2})
+	// 登录态未过期但仍在活跃的用户不算沉睡
+	act.days[5] = []time.Time{testNow.AddDate(0, 0, -1).Truncate(24 * time.Hour)}
+
+	stats, err := job.RunOnce(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []uint{2, 4}, inapp.sent)
+	assert.Equal(t, []uint{3}, email.sent)
+	assert.Equal(t, "month 已经 40 天没来了", email.msgs[0].Body)
+	assert.Equal(t, 3, stats.Sent)
+	assert.Len(t, repo.sends, 3)
+
+	// 同一次沉睡期内同一分组不重复触达
+	stats, err = job.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.Sent)
+	assert.Len(t, inapp.sent, 2)
+}
+
+func TestFrequencyCap(t *testing.T) {
+	users := []user.Users{{UserID: 1, Username: "u", LastLoginAt: daysAgo(28)}}
+	job, repo, _, _, inapp, email := newTestJob(users, Options{
+		ReturnWindow: 7 * 24 * time.Hour,
+		CapCount:     1,
+		CapWindow:    7 * 24 * time.Hour,
+	})
+	ctx := context.Background()
+
+	_, err := job.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{1}, inapp.sent)
+
+	// 三天后进入 d30 分组，但仍在频控窗口内
+	job.now = func() time.Time { return testNow.AddDate(0, 0, 3) }
+	stats, err := job.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Capped)
+	assert.Empty(t, email.sent)
+
+	job.now = func() time.Time { return testNow.AddDate(0, 0, 8) }
+	stats, err = job.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Sent)
+	assert.Equal(t, []uint{1}, email.sent)
+	assert.Len(t, repo.sends, 2)
+}
+
+func TestSkippedWithoutAddress(t *testing.T) {
+	users := []user.Users{{UserID: 1, Username: "u", LastLoginAt: daysAgo(31)}}
+	job, repo, _, _, _, email := newTestJob(users, Options{ReturnWindow: time.Hour})
+	email.err = ErrNoAddress
+
+	stats, err := job.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Skipped)
+	assert.Equal(t, StatusSkipped, repo.sends[0].Status)
+
+	email.err = errors.New("gateway down")
+	users[0].LastLoginAt = daysAgo(32) // 新的沉睡期
+	job.users.(*fakeUsers).users = users
+	stats, err = job.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Failed)
+	assert.Equal(t, StatusFailed, repo.sends[1].Status)
+}
+
+func TestRetryFailedAndStalePending(t *testing.T) {
+	users := []user.Users{
+		{UserID: 1, Username: "failed", LastLoginAt: daysAgo(10)},
+		{UserID: 2, Username: "crashed", LastLoginAt: daysAgo(10)},
+		{UserID: 3, Username: "inflight", LastLoginAt: daysAgo(10)},
+	}
+	job, repo, _, _, inapp, _ := newTestJob(users, Options{
+		ReturnWindow: 7 * 24 * time.Hour,
+		CapCount:     1,
+		CapWindow:    7 * 24 * time.Hour,
+	})
+	ctx := context.Background()
+	// 用户 2 上次发送中断停留在 pending，用户 3 的发送仍在进行中
+	repo.sends = []Send{
+		{SendID: 1, UserID: 2, Cohort: "d7", DormantSince: *daysAgo(10), Status: StatusPending, SentAt: testNow.Add(-time.Hour)},
+		{SendID: 2, UserID: 3, Cohort: "d7", DormantSince: *daysAgo(10), Status: StatusPending, SentAt: testNow.Add(-time.Minute)},
+	}
+	inapp.err = errors.New("gateway down")
+
+	stats, err := job.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Failed)
+	assert.Equal(t, StatusFailed, repo.sends[0].Status)
+	assert.Equal(t, StatusPending, repo.sends[1].Status)
+	assert.Equal(t, StatusFailed, repo.sends[2].Status)
+
+	// 恢复后失败的记录重新发送，不新增记录；仍在进行中的 pending 不重复领取
+	inapp.err = nil
+	job.now = func() time.Time { return testNow.Add(5 * time.Minute) }
+	stats, err = job.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Sent)
+	assert.ElementsMatch(t, []uint{1, 2}, inapp.sent)
+	assert.Len(t, repo.sends, 3)
+	assert.Equal(t, StatusSent, repo.sends[0].Status)
+	assert.Equal(t, StatusPending, repo.sends[1].Status)
+	assert.Equal(t, StatusSent, repo.sends[2].Status)
+
+	// 已发送的记录计入频控，不再重发
+	stats, err = job.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Capped)
+	assert.Len(t, inapp.sent, 2)
+}
+
+func TestTrackReturns(t *testing.T) {
+	users := []user.Users{
+		{UserID: 1, Username: "login", LastLoginAt: daysAgo(10)},
+		{UserID: 2, Username: "active", LastLoginAt: daysAgo(10)},
+		{UserID: 3, Username: "late", LastLoginAt: daysAgo(10)},
+		{UserID: 4, Username: "gone", LastLoginAt: daysAgo(10)},
+	}
+	job, repo, us, act, _, _ := newTestJob(users, Options{ReturnWindow: 7 * 24 * time.Hour})
+	ctx := context.Background()
+
+	_, err := job.RunOnce(ctx)
+	if !assert.NoError(t, err) || !assert.Len(t, repo.sends, 4) {
+		return
+	}
+
+	later := testNow.AddDate(0, 0, 2)
+	us.users[0].LastLoginAt = &later
+	act.days[2] = []time.Time{later.Truncate(24 * time.Hour)}
+	job.now = func() time.Time { return testNow.AddDate(0, 0, 3) }
+	stats, err := job.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Returned)
+	assert.Equal(t, later, *repo.sends[0].ReturnedAt)
+	assert.Equal(t, later.Truncate(24*time.Hour), *repo.sends[1].ReturnedAt)
+
+	// 窗口结束后才回访的不计入
+	outside := testNow.AddDate(0, 0, 9)
+	us.users[2].LastLoginAt = &outside
+	job.now = func() time.Time { return outside }
+	stats, err = job.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.Returned)
+	assert.Nil(t, repo.sends[2].ReturnedAt)
+	assert.Nil(t, repo.sends[3].ReturnedAt)
+}
+
+func TestTotals(t *testing.T) {
+	rows := []Row{
+		{Date: "2026-03-01", Cohort: "d7", Sent: 10, Returned: 2},
+		{Date: "2026-03-02", Cohort: "d7", Sent: 10, Returned: 3, Pending: 4},
+		{Date: "2026-03-02", Cohort: "old", Sent: 5, Returned: 5},
+	}
+	out := totals(testCohorts, rows)
+	assert.Equal(t, []CohortTotal{
+		{Cohort: "d30"},
+		{Cohort: "d7", Sent: 20, Returned: 5, Pending: 4, Rate: 0.25},
+		{Cohort: "old", Sent: 5, Returned: 5, Rate: 1},
+	}, out)
+}
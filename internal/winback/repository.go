@@ -0,0 +1,139 @@
+package winback
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+var ErrDuplicateSend = errors.New("win-back message already sent for this dormancy")
+
+// 发送状态，只有 sent 计入频控与转化
+const (
+	StatusPending = "pending" // 已落库、渠道尚未返回，进程中断时停留在此状态
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped" // 渠道缺少联系方式等原因未发送
+)
+
+// Send 是一次召回触达，同一用户在同一次沉睡期内每个分组只触达一次
+type Send struct {
+	SendID       uint       `gorm:"primaryKey;autoIncrement" json:"send_id"`
+	UserID       uint       `gorm:"not null;uniqueIndex:idx_user_cohort_dormant;index:idx_user_sent" json:"user_id"`
+	Cohort       string     `gorm:"type:varchar(32);not null;uniqueIndex:idx_user_cohort_dormant" json:"cohort"`
+	DormantSince time.Time  `gorm:"not null;uniqueIndex:idx_user_cohort_dormant" json:"dormant_since"` // 最近一次登录或注册时间
+	Channel      string     `gorm:"type:varchar(16);not null" json:"channel"`
+	Status       string     `gorm:"type:varchar(16);not null" json:"status"`
+	Error        string     `gorm:"type:varchar(255)" json:"error"`
+	SentAt       time.Time  `gorm:"not null;index:idx_user_sent;index" json:"sent_at"`
+	ReturnedAt   *time.Time `json:"returned_at"` // 召回窗口内检测到的回访时间
+}
+
+func (Send) TableName() string {
+	return "winback_sends"
+}
+
+// Row 是按分组、发送日期汇总的召回转化
+type Row struct {
+	Date     string  `json:"date"`
+	Cohort   string  `json:"cohort"`
+	Sent     int64   `json:"sent"`
+	Returned int64   `json:"returned"`
+	Pending  int64   `json:"pending"` // 未回访且窗口未结束
+	Rate     float64 `json:"rate" gorm:"-"`
+}
+
+type winbackRepository struct {
+	db *gorm.DB
+}
+
+type WinbackRepository interface {
+	Create(send *Send) error
+	// Reclaim 重新领取同一次沉睡期内发送失败、或 pending 早于 staleBefore 的记录，成功时回填 SendID
+	Reclaim(send *Send, staleBefore time.Time) (bool, error)
+	UpdateStatus(sendID uint, status, errMsg string) error
+	// CountSent 返回用户自 since 起成功发送的召回消息数，用于频控
+	CountSent(userID uint, since time.Time) (int64, error)
+	// PendingReturns 返回 sentAfter 之后发送、尚未回访的记录
+	PendingReturns(sentAfter time.Time, afterID uint, limit int) ([]Send, error)
+	MarkReturned(sendID uint, at time.Time) error
+	Report(start, end, now time.Time, window time.Duration) ([]Row, error)
+}
+
+func NewWinbackRepository(db *gorm.DB) WinbackRepository {
+	if err := db.AutoMigrate(&Send{}); err != nil {
+		panic("failed to migrate winback table")
+	}
+	return &winbackRepository{db: db}
+}
+
+func (repo *winbackRepository) Create(send *Send) error {
+	if err := repo.db.Create(send).Error; err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 { // Error 1062: Duplicate entry
+			return ErrDuplicateSend
+		}
+		return err
+	}
+	return nil
+}
+
+func (repo *winbackRepository) Reclaim(send *Send, staleBefore time.Time) (bool, error) {
+	var reclaimed bool
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		key := tx.Where("user_id = ? AND cohort = ? AND dormant_since = ?", send.UserID, send.Cohort, send.DormantSince)
+		res := key.Session(&gorm.Session{}).Model(&Send{}).
+			Where("status = ? OR (status = ? AND sent_at < ?)", StatusFailed, StatusPending, staleBefore).
+			Updates(map[string]any{"status": StatusPending, "channel": send.Channel, "error": "", "sent_at": send.SentAt})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		var existing Send
+		if err := key.Session(&gorm.Session{}).Select("send_id").First(&existing).Error; err != nil {
+			return err
+		}
+		send.SendID, reclaimed = existing.SendID, true
+		return nil
+	})
+	return reclaimed, err
+}
+
+func (repo *winbackRepository) UpdateStatus(sendID uint, status, errMsg string) error {
+	return repo.db.Model(&Send{}).Where("send_id = ?", sendID).
+		Updates(map[string]any{"status": status, "error": errMsg}).Error
+}
+
+func (repo *winbackRepository) CountSent(userID uint, since time.Time) (int64, error) {
+	var n int64
+	err := repo.db.Model(&Send{}).Where("user_id = ? AND status = ? AND sent_at >= ?", userID, StatusSent, since).Count(&n).Error
+	return n, err
+}
+
+func (repo *winbackRepository) PendingReturns(sentAfter time.Time, afterID uint, limit int) ([]Send, error) {
+	var sends []Send
+	err := repo.db.Where("send_id > ? AND status = ? AND returned_at IS NULL AND sent_at >= ?", afterID, StatusSent, sentAfter).
+		Order("send_id").Limit(limit).Find(&sends).Error
+	return sends, err
+}
+
+func (repo *winbackRepository) MarkReturned(sendID uint, at time.Time) error {
+	return repo.db.Model(&Send{}).Where("send_id = ? AND returned_at IS NULL", sendID).Update("returned_at", at).Error
+}
+
+func (repo *winbackRepository) Report(start, end, now time.Time, window time.Duration) ([]Row, error) {
+	var rows []Row
+	err := repo.db.Model(&Send{}).
+		Select("DATE_FORMAT(sent_at, '%Y-%m-%d') AS date, cohort, COUNT(*) AS sent, "+
+			"SUM(returned_at IS NOT NULL) AS returned, "+
+			"SUM(returned_at IS NULL AND sent_at >= ?) AS pending", now.Add(-window)).
+		Where("status = ? AND sent_at >= ? AND sent_at < ?", StatusSent, start, end).
+		Group("date, cohort").Order("date, cohort").Scan(&rows).Error
+	for i := range rows {
+		if rows[i].Sent > 0 {
+			rows[i].Rate = float64(rows[i].Returned) / float64(rows[i].Sent)
+		}
+	}
+	return rows, err
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	config "usergrowth/configs"
+	"usergrowth/internal/logs"
+
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gogf/gf/v2/net/gtrace"
+)
+
+type AdminManager struct {
+	userLogger logs.Logger
+	cfg        *config.AdminConfig
+}
+
+func NewAdminManager(userLogger logs.Logger, cfg *config.AdminConfig) *AdminManager {
+	return &AdminManager{
+		userLogger: userLogger,
+		cfg:        cfg,
+	}
+}
+
+// AdminHandler 必须挂在 JWTHandler 之后，依赖其写入的 userid
+func (m *AdminManager) AdminHandler(r *ghttp.Request) {
+	ctx := r.GetCtx()
+	ctx, span := gtrace.NewSpan(ctx, "Middleware.AdminHandler")
+	defer span.End()
+	r.SetCtx(ctx)
+
+	userid := r.GetCtxVar("userid").String()
+	if userid == "" || !m.isAdmin(userid) {
+		m.userLogger.Info(ctx, "access denied: not admin", "userid", userid, "ip", r.GetClientIp())
+		r.Response.WriteStatus(http.StatusForbidden)
+		r.Response.WriteJson(ghttp.DefaultHandlerResponse{
+			Code:    http.StatusForbidden,
+			Message: "无管理员权限",
+			Data:    nil,
+		})
+		r.Exit()
+		return
+	}
+
+	r.Middleware.Next()
+}
+
+func (m *AdminManager) isAdmin(userid string) bool {
+	for _, id := range m.cfg.UserIDs {
+		if id == userid {
+			return true
+		}
+	}
+	return false
+}
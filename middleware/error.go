@@ -38,23 +38,24 @@ func (m *ErrorManager) ErrorHandler(r *ghttp.Request) {
 	err := r.GetError()
 
 	if err != nil {
+		// 业务可通过 gcode.WithCode 附带 detail（如拒绝原因），原样放入 data 供前端区分
 		code := gerror.Code(err)
-		switch code {
-		case gcode.CodeValidationFailed:
+		switch code.Code() {
+		case gcode.CodeValidationFailed.Code():
 			m.errorLogger.Info(ctx, "validation failed: ", err)
 			r.Response.ClearBuffer()
 			r.Response.WriteJson(g.Map{
 				"code":    http.StatusBadRequest,
 				"message": err.Error(),
-				"data":    nil,
+				"data":    code.Detail(),
 			})
-		case gcode.CodeNotAuthorized:
+		case gcode.CodeNotAuthorized.Code():
 			m.errorLogger.Info(ctx, "authorization failed: ", err)
 			r.Response.ClearBuffer()
 			r.Response.WriteJson(g.Map{
 				"code":    http.StatusUnauthorized,
 				"message": err.Error(),
-				"data":    nil,
+				"data":    code.Detail(),
 			})
 		default:
 			isPanic := false
@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 	config "usergrowth/configs"
 	"usergrowth/internal/logs"
@@ -22,17 +24,24 @@ type UserClaims struct {
 	jwt.RegisteredClaims
 }
 
+// ActivityRecorder 记录鉴权通过的用户当天活跃
+type ActivityRecorder interface {
+	MarkActive(ctx context.Context, userID string)
+}
+
 type JWTManager struct {
 	rdb        redis.Cache
 	userLogger logs.Logger
 	cfg        *config.MiddlewareConfig
+	activity   ActivityRecorder
 }
 
-func NewJWTManager(rdb redis.Cache, userLogger logs.Logger, cfg *config.MiddlewareConfig) *JWTManager {
+func NewJWTManager(rdb redis.Cache, userLogger logs.Logger, cfg *config.MiddlewareConfig, activity ActivityRecorder) *JWTManager {
 	return &JWTManager{
 		rdb:        rdb,
 		userLogger: userLogger,
 		cfg:        cfg,
+		activity:   activity,
 	}
 }
 
@@ -81,6 +90,28 @@ func ValidateToken(tokenString string) (*UserClaims, error) {
 	return nil, fmt.Errorf("token is nil")
 }
 
+func revokedKey(userID string) string {
+	return "jwt:revoked:" + userID
+}
+
+// RevokeTokens 使用户此前签发的 token 全部失效，如封禁后强制下线；标记保留一个 token 有效期
+func RevokeTokens(ctx context.Context, rdb redis.Cache, userID string) error {
+	return rdb.SetCache(revokedKey(userID), strconv.FormatInt(time.Now().Unix(), 10), jwtExpireTime, ctx)
+}
+
+// revoked 判断 token 是否签发于用户被强制下线之前；读取失败按未吊销处理，会话校验已先行访问过 Redis
+func (m *JWTManager) revoked(ctx context.Context, claims *UserClaims) bool {
+	val, err := m.rdb.GetCache(revokedKey(claims.UserId), ctx)
+	if err != nil {
+		return false
+	}
+	at, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || claims.IssuedAt == nil {
+		return true
+	}
+	return claims.IssuedAt.Unix() <= at
+}
+
 func ParseTokenUnverified(tokenString string) (*UserClaims, error) {
 	claims := &UserClaims{}
 	parser := jwt.NewParser()
@@ -142,9 +173,46 @@ func (m *JWTManager) JWTHandler(r *ghttp.Request) {
 		r.Exit()
 		return
 	}
+	if m.revoked(ctx, claims) {
+		m.userLogger.Info(ctx, "access denied: token revoked", "userid", claims.UserId, "ip", r.GetClientIp())
+		r.Response.WriteStatus(http.StatusUnauthorized)
+		r.Response.WriteJson(ghttp.DefaultHandlerResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "账号已被封禁或强制下线",
+			Data:    nil,
+		})
+		r.Exit()
+		return
+	}
 	if cache == claims.UserId {
 		r.SetCtxVar("userid", claims.UserId)
 		span.SetAttributes(attribute.String("user.id", claims.UserId))
+		if m.activity != nil {
+			m.activity.MarkActive(ctx, claims.UserId)
+		}
+	}
+
+	r.Middleware.Next()
+}
+
+// OptionalJWTHandler 用于允许匿名访问的接口：Token 有效时写入 userid，否则直接放行
+func (m *JWTManager) OptionalJWTHandler(r *ghttp.Request) {
+	tokenString := r.Cookie.Get("jwt-token").String()
+	if tokenString == "" {
+		r.Middleware.Next()
+		return
+	}
+	ctx := r.GetCtx()
+	ctx, span := gtrace.NewSpan(ctx, "Middleware.OptionalJWTHandler")
+	defer span.End()
+	r.SetCtx(ctx)
+
+	claims, err := ValidateToken(tokenString)
+	if err == nil {
+		if cache, err := m.rdb.GetCache(tokenString, ctx); err == nil && cache == claims.UserId && !m.revoked(ctx, claims) {
+			r.SetCtxVar("userid", claims.UserId)
+			span.SetAttributes(attribute.String("user.id", claims.UserId))
+		}
 	}
 
 	r.Middleware.Next()